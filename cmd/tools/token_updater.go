@@ -2,15 +2,20 @@ package main
 
 import (
 	"flag"
-	"github.com/pkoukk/tiktoken-go"
 	"log"
 	"os"
-	"sse-demo-core/internal/app/structs"
+	"sse-demo-core/internal/app/chunker"
+	trepository "sse-demo-core/internal/app/repository/threads"
+	"sse-demo-core/internal/app/service/threadservice"
 	"sse-demo-core/internal/config"
 	"sse-demo-core/internal/db"
-	"strings"
 )
 
+// размер пачки сообщений, досчитываемых за один запрос
+const batchSize = 500
+
+// Токены сообщений считаются при записи, утилита досчитывает только сообщения с tokens is null:
+// записанные до подсчета при записи или загруженные в базу в обход сервиса
 func main() {
 	confFile := flag.String("config", "conf/application.conf", "-config=<config file name>")
 	flag.Parse()
@@ -29,43 +34,31 @@ func main() {
 	defer d.Close()
 	log.Println(">> DATABASE CONNECTION SUCCESSFUL")
 
-	var messages []structs.ThreadMessage
-	err := d.Select(&messages, `select tm.id,
-										 tm.object,
-										 EXTRACT(epoch FROM tm.created_at)::bigint as created_at,
-										 tm.thread_id,
-										 tm.role,
-										 tm.prompt,
-										 tm.assistant_id,
-										 tm.run_id,
-										 tm.content::json,
-									     tm.file_ids::json,
-										 tm.hidden,
-										 tm.metadata
-								from messages tm`)
-	if err != nil {
-		log.Panic(">> Ошибка поиска сообщений")
-	}
-
-	codec, err := tiktoken.GetEncoding("cl100k_base")
+	// тот же подсчет, что и при записи сообщения
+	chunks, err := chunker.New(conf)
 	if err != nil {
 		log.Panic(err)
 	}
-	for _, msg := range messages {
-		p := sanitize(msg.Prompt)
 
-		tokens := codec.Encode(p, nil, nil)
-		_, err = d.Exec(`update messages set tokens = $1 where id = $2`,
-			len(tokens),
-			msg.ID)
+	threads := trepository.New(d)
+
+	total := 0
+	for {
+		messages, err := threads.FindMessagesWithoutTokens(batchSize)
 		if err != nil {
-			log.Panic("Ошибка обновления токенов сообщения, ", err.Error())
+			log.Panic(">> Ошибка поиска сообщений, ", err.Error())
 		}
+		if len(messages) == 0 {
+			break
+		}
+
+		for _, msg := range messages {
+			if err = threads.UpdateMessageTokens(msg.ID, threadservice.CountTokens(chunks, msg.Prompt)); err != nil {
+				log.Panic("Ошибка обновления токенов сообщения, ", err.Error())
+			}
+		}
+		total += len(messages)
 	}
-}
 
-func sanitize(s string) string {
-	res := strings.ReplaceAll(s, "```python\n", "")
-	res = strings.ReplaceAll(res, "```", "")
-	return res
+	log.Println(">> messages backfilled: ", total)
 }
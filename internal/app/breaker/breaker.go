@@ -0,0 +1,247 @@
+package breaker
+
+import (
+	"errors"
+	"fmt"
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"github.com/gurkankaymak/hocon"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"sync"
+	"time"
+)
+
+// ErrOpen возвращается без обращения к провайдеру, пока breaker открыт
+var ErrOpen = errors.New("circuit breaker is open")
+
+// State состояние breaker, значение gauge circuit_breaker_state
+type State int
+
+const (
+	Closed State = iota
+	HalfOpen
+	Open
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case HalfOpen:
+		return "half-open"
+	default:
+		return "open"
+	}
+}
+
+var breakerState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "sse_demo_core",
+	Subsystem: "provider",
+	Name:      "circuit_breaker_state",
+	Help:      "Состояние circuit breaker провайдера: 0 - closed, 1 - half-open, 2 - open",
+}, []string{"provider"})
+
+// OpenError отказ открытого breaker, RetryAfter - время до пробных запросов
+type OpenError struct {
+	Name       string
+	RetryAfter time.Duration
+}
+
+func (e *OpenError) Error() string {
+	if e.RetryAfter <= 0 {
+		return fmt.Sprintf("%s is unavailable, %s, probing", e.Name, ErrOpen)
+	}
+	return fmt.Sprintf("%s is unavailable, %s, retry in %s", e.Name, ErrOpen, e.RetryAfter.Round(time.Second))
+}
+
+func (e *OpenError) Unwrap() error {
+	return ErrOpen
+}
+
+// Settings пороги breaker
+type Settings struct {
+	// Window количество последних запросов, по которым считается доля ошибок
+	Window int
+	// MinRequests breaker не открывается, пока в окне меньше запросов
+	MinRequests int
+	// FailureRate доля ошибок в окне, при которой breaker открывается, 0..1
+	FailureRate float64
+	// OpenTimeout время в открытом состоянии до пробных запросов
+	OpenTimeout time.Duration
+	// HalfOpenProbes количество пробных запросов, успех всех закрывает breaker
+	HalfOpenProbes int
+}
+
+// SettingsFromConfig пороги из блока circuit_breaker конфига
+func SettingsFromConfig(config *hocon.Config) Settings {
+	return Settings{
+		Window:         config.GetInt("circuit_breaker.window"),
+		MinRequests:    config.GetInt("circuit_breaker.min_requests"),
+		FailureRate:    float64(config.GetInt("circuit_breaker.failure_rate")) / 100,
+		OpenTimeout:    time.Duration(config.GetInt("circuit_breaker.open_timeout")) * time.Second,
+		HalfOpenProbes: config.GetInt("circuit_breaker.half_open_probes"),
+	}
+}
+
+// Breaker circuit breaker провайдера по доле ошибок в окне последних запросов
+type Breaker struct {
+	name     string
+	settings Settings
+
+	mu       sync.Mutex
+	state    State
+	results  []bool // кольцевой буфер результатов, true - ошибка
+	next     int
+	count    int
+	failures int
+	openedAt time.Time
+	// probes пробные запросы в half-open: выданные и успешные
+	probes    int
+	succeeded int
+	// generation меняется при каждой смене состояния, результаты запросов прошлых поколений не учитываются
+	generation uint64
+	now        func() time.Time
+}
+
+// Ticket разрешение запроса, выданное Allow: поколение breaker на момент выдачи и занят ли пробный запрос
+type Ticket struct {
+	generation uint64
+	probe      bool
+}
+
+func New(name string, settings Settings) *Breaker {
+	if settings.Window <= 0 {
+		settings.Window = 20
+	}
+	if settings.MinRequests <= 0 || settings.MinRequests > settings.Window {
+		settings.MinRequests = settings.Window
+	}
+	if settings.FailureRate <= 0 {
+		settings.FailureRate = 0.5
+	}
+	if settings.HalfOpenProbes <= 0 {
+		settings.HalfOpenProbes = 1
+	}
+
+	b := &Breaker{name: name, settings: settings, results: make([]bool, settings.Window), now: time.Now}
+	breakerState.WithLabelValues(name).Set(float64(Closed))
+	return b
+}
+
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.current()
+}
+
+// Allow разрешает запрос или возвращает *OpenError.
+// Разрешенный запрос обязан сообщить результат через Done или Ignore с выданным Ticket
+func (b *Breaker) Allow() (Ticket, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.current() {
+	case Closed:
+		return Ticket{generation: b.generation}, nil
+	case HalfOpen:
+		if b.probes < b.settings.HalfOpenProbes {
+			b.probes++
+			return Ticket{generation: b.generation, probe: true}, nil
+		}
+		// все пробные запросы выданы, ждем их результатов
+		return Ticket{}, &OpenError{Name: b.name, RetryAfter: 0}
+	default:
+		return Ticket{}, &OpenError{Name: b.name, RetryAfter: b.settings.OpenTimeout - b.now().Sub(b.openedAt)}
+	}
+}
+
+// Done результат разрешенного запроса, failed - ошибка провайдера.
+// Результаты запросов, разрешенных до смены состояния, не учитываются
+func (b *Breaker) Done(t Ticket, failed bool) {
+	logger := logdoc.GetLogger()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state := b.current()
+	if t.generation != b.generation {
+		return
+	}
+
+	switch state {
+	case HalfOpen:
+		if failed {
+			logger.Warn(">> ", b.name, " circuit breaker probe failed, opening again")
+			b.open()
+			return
+		}
+		b.succeeded++
+		if b.succeeded >= b.settings.HalfOpenProbes {
+			logger.Info(">> ", b.name, " circuit breaker probes succeeded, closing")
+			b.setState(Closed)
+			b.reset()
+		}
+	case Closed:
+		b.record(failed)
+		if b.count >= b.settings.MinRequests && float64(b.failures) >= b.settings.FailureRate*float64(b.count) {
+			logger.Warn(">> ", b.name, " circuit breaker opened, ", b.failures, " failures of ", b.count, " requests")
+			b.open()
+		}
+	}
+}
+
+// Ignore освобождает разрешение запроса, результат которого ничего не говорит о провайдере,
+// например запрос отменен клиентом. Пробный запрос возвращается для следующего Allow
+func (b *Breaker) Ignore(t Ticket) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.current()
+	if t.probe && t.generation == b.generation {
+		b.probes--
+	}
+}
+
+// current состояние с учетом истечения OpenTimeout, вызывается под блокировкой
+func (b *Breaker) current() State {
+	if b.state == Open && b.now().Sub(b.openedAt) >= b.settings.OpenTimeout {
+		b.setState(HalfOpen)
+		b.probes = 0
+		b.succeeded = 0
+	}
+	return b.state
+}
+
+func (b *Breaker) open() {
+	b.setState(Open)
+	b.openedAt = b.now()
+	b.reset()
+}
+
+func (b *Breaker) setState(s State) {
+	b.state = s
+	b.generation++
+	breakerState.WithLabelValues(b.name).Set(float64(s))
+}
+
+func (b *Breaker) record(failed bool) {
+	if b.count == len(b.results) {
+		if b.results[b.next] {
+			b.failures--
+		}
+	} else {
+		b.count++
+	}
+	b.results[b.next] = failed
+	if failed {
+		b.failures++
+	}
+	b.next = (b.next + 1) % len(b.results)
+}
+
+func (b *Breaker) reset() {
+	for i := range b.results {
+		b.results[i] = false
+	}
+	b.next, b.count, b.failures = 0, 0, 0
+}
@@ -0,0 +1,145 @@
+package breaker
+
+import (
+	"errors"
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	_, _ = logdoc.Init("udp", "127.0.0.1:9", "breaker-test")
+	os.Exit(m.Run())
+}
+
+// testBreaker breaker с управляемым временем: открывается при половине ошибок в окне из 4, не раньше 2 запросов
+func testBreaker(t *testing.T, probes int) (*Breaker, *time.Time) {
+	now := time.Now()
+	b := New(t.Name(), Settings{Window: 4, MinRequests: 2, FailureRate: 0.5, OpenTimeout: time.Minute, HalfOpenProbes: probes})
+	b.now = func() time.Time { return now }
+	return b, &now
+}
+
+func allow(t *testing.T, b *Breaker) Ticket {
+	t.Helper()
+
+	ticket, err := b.Allow()
+	if err != nil {
+		t.Fatalf("request rejected in state %s, %v", b.State(), err)
+	}
+	return ticket
+}
+
+// halfOpen открывает breaker ошибками и дожидается пробных запросов
+func halfOpen(t *testing.T, b *Breaker, now *time.Time) {
+	t.Helper()
+
+	b.Done(allow(t, b), true)
+	b.Done(allow(t, b), true)
+	if b.State() != Open {
+		t.Fatalf("state %s after failures, want open", b.State())
+	}
+	*now = now.Add(time.Minute)
+	if b.State() != HalfOpen {
+		t.Fatalf("state %s after open timeout, want half-open", b.State())
+	}
+}
+
+func TestOpensOnFailureRate(t *testing.T) {
+	b, _ := testBreaker(t, 1)
+
+	b.Done(allow(t, b), false)
+	b.Done(allow(t, b), false)
+	b.Done(allow(t, b), true)
+	if b.State() != Closed {
+		t.Fatalf("state %s, want closed below failure rate", b.State())
+	}
+	b.Done(allow(t, b), true)
+
+	_, err := b.Allow()
+	var openErr *OpenError
+	if !errors.As(err, &openErr) || openErr.RetryAfter != time.Minute {
+		t.Fatalf("got %v, want open error with retry after 1m", err)
+	}
+}
+
+func TestProbesClose(t *testing.T) {
+	b, now := testBreaker(t, 2)
+	halfOpen(t, b, now)
+
+	first, second := allow(t, b), allow(t, b)
+	if _, err := b.Allow(); !errors.Is(err, ErrOpen) {
+		t.Fatalf("got %v, want rejection while probes are running", err)
+	}
+	b.Done(first, false)
+	b.Done(second, false)
+	if b.State() != Closed {
+		t.Fatalf("state %s after successful probes, want closed", b.State())
+	}
+}
+
+func TestStaleResultsAreIgnored(t *testing.T) {
+	b, now := testBreaker(t, 1)
+
+	// запросы разрешены до открытия и завершились, когда breaker уже пробует провайдера
+	slowSuccess, slowFailure := allow(t, b), allow(t, b)
+	halfOpen(t, b, now)
+
+	b.Done(slowSuccess, false)
+	b.Done(slowFailure, true)
+	if b.State() != HalfOpen {
+		t.Fatalf("state %s after results of requests admitted while closed, want half-open", b.State())
+	}
+
+	probe := allow(t, b)
+	b.Done(probe, false)
+	if b.State() != Closed {
+		t.Fatalf("state %s after successful probe, want closed", b.State())
+	}
+}
+
+func TestStaleProbeAfterReopen(t *testing.T) {
+	b, now := testBreaker(t, 2)
+	halfOpen(t, b, now)
+
+	failed, slow := allow(t, b), allow(t, b)
+	b.Done(failed, true)
+	*now = now.Add(time.Minute)
+
+	// пробный запрос прошлого half-open не занимает и не освобождает пробы нового
+	b.Done(slow, false)
+	b.Ignore(slow)
+	first, second := allow(t, b), allow(t, b)
+	if _, err := b.Allow(); !errors.Is(err, ErrOpen) {
+		t.Fatalf("got %v, want only 2 probes", err)
+	}
+	b.Done(first, false)
+	if b.State() != HalfOpen {
+		t.Fatalf("state %s after 1 of 2 probes, want half-open", b.State())
+	}
+	b.Done(second, false)
+	if b.State() != Closed {
+		t.Fatalf("state %s, want closed", b.State())
+	}
+}
+
+func TestIgnore(t *testing.T) {
+	b, now := testBreaker(t, 1)
+
+	// отмененный запрос, разрешенный до открытия, не освобождает пробу
+	closed := allow(t, b)
+	halfOpen(t, b, now)
+	probe := allow(t, b)
+	b.Ignore(closed)
+	if _, err := b.Allow(); !errors.Is(err, ErrOpen) {
+		t.Fatalf("got %v, want the probe still taken", err)
+	}
+
+	// отмененная проба возвращается следующему запросу
+	b.Ignore(probe)
+	b.Done(allow(t, b), false)
+	if b.State() != Closed {
+		t.Fatalf("state %s, want closed", b.State())
+	}
+}
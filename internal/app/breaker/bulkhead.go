@@ -0,0 +1,61 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gurkankaymak/hocon"
+	"time"
+)
+
+// ErrBulkheadFull все слоты провайдера заняты дольше допустимого ожидания
+var ErrBulkheadFull = errors.New("too many concurrent requests")
+
+// Bulkhead ограничивает количество одновременных запросов к провайдеру,
+// чтобы зависший провайдер не занимал все горутины и соединения сервиса
+type Bulkhead struct {
+	name  string
+	slots chan struct{}
+	// wait максимальное ожидание свободного слота, 0 - не ждать
+	wait time.Duration
+}
+
+func NewBulkhead(name string, maxConcurrent int, wait time.Duration) *Bulkhead {
+	if maxConcurrent <= 0 {
+		maxConcurrent = 1
+	}
+	return &Bulkhead{name: name, slots: make(chan struct{}, maxConcurrent), wait: wait}
+}
+
+// BulkheadFromConfig ограничения из блока bulkhead конфига
+func BulkheadFromConfig(name string, config *hocon.Config) *Bulkhead {
+	return NewBulkhead(name, config.GetInt("bulkhead.max_concurrent"), time.Duration(config.GetInt("bulkhead.max_wait"))*time.Second)
+}
+
+// Acquire занимает слот, освободить его обязан вызывающий через Release
+func (b *Bulkhead) Acquire(ctx context.Context) error {
+	select {
+	case b.slots <- struct{}{}:
+		return nil
+	default:
+	}
+	if b.wait <= 0 {
+		return fmt.Errorf("%s: %w", b.name, ErrBulkheadFull)
+	}
+
+	timer := time.NewTimer(b.wait)
+	defer timer.Stop()
+
+	select {
+	case b.slots <- struct{}{}:
+		return nil
+	case <-timer.C:
+		return fmt.Errorf("%s: %w", b.name, ErrBulkheadFull)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *Bulkhead) Release() {
+	<-b.slots
+}
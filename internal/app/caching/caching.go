@@ -1,6 +1,12 @@
 package caching
 
-import "time"
+import (
+	"errors"
+	"time"
+)
+
+// ErrNotFound возвращается из Get, если ключ отсутствует в кеше
+var ErrNotFound = errors.New("cache: key not found")
 
 // Cache интерфейс определяет стандартные методы для работы с кешем.
 type Cache interface {
@@ -8,7 +14,7 @@ type Cache interface {
 	Set(key string, value any, expiration time.Duration) error
 
 	// Get получает значение из кеша по ключу.
-	// Если ключ не найден, возвращает ошибку.
+	// Если ключ не найден, возвращает ErrNotFound.
 	Get(key string) (any, error)
 
 	// Delete удаляет значение из кеша по ключу.
@@ -0,0 +1,107 @@
+package caching
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// MemoryCache LRU кеш в памяти процесса для запуска в одном экземпляре и тестов.
+// При переполнении вытесняется запись, которая дольше всех не читалась
+type MemoryCache struct {
+	mu       sync.Mutex
+	capacity int
+	items    map[string]*list.Element
+	order    *list.List // от недавно использованных к давно использованным
+	now      func() time.Time
+}
+
+type memoryEntry struct {
+	key     string
+	value   any
+	expires time.Time // нулевое - без истечения
+}
+
+// NewMemoryCache конструктор для создания экземпляра MemoryCache на capacity записей.
+func NewMemoryCache(capacity int) *MemoryCache {
+	if capacity <= 0 {
+		capacity = 10000
+	}
+	return &MemoryCache{
+		capacity: capacity,
+		items:    make(map[string]*list.Element),
+		order:    list.New(),
+		now:      time.Now,
+	}
+}
+
+// Set реализация метода Set интерфейса Cache.
+func (cache *MemoryCache) Set(key string, value any, expiration time.Duration) error {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	var expires time.Time
+	if expiration > 0 {
+		expires = cache.now().Add(expiration)
+	}
+
+	if el, ok := cache.items[key]; ok {
+		entry := el.Value.(*memoryEntry)
+		entry.value = value
+		entry.expires = expires
+		cache.order.MoveToFront(el)
+		return nil
+	}
+
+	cache.items[key] = cache.order.PushFront(&memoryEntry{key: key, value: value, expires: expires})
+	for cache.order.Len() > cache.capacity {
+		cache.remove(cache.order.Back())
+	}
+	return nil
+}
+
+// Get реализация метода Get интерфейса Cache.
+func (cache *MemoryCache) Get(key string) (any, error) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	el, ok := cache.items[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	entry := el.Value.(*memoryEntry)
+	if !entry.expires.IsZero() && !cache.now().Before(entry.expires) {
+		cache.remove(el)
+		return nil, ErrNotFound
+	}
+
+	cache.order.MoveToFront(el)
+	return entry.value, nil
+}
+
+// Delete реализация метода Delete интерфейса Cache.
+func (cache *MemoryCache) Delete(key string) error {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if el, ok := cache.items[key]; ok {
+		cache.remove(el)
+	}
+	return nil
+}
+
+// Purge реализация метода Purge интерфейса Cache.
+func (cache *MemoryCache) Purge() error {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.items = make(map[string]*list.Element)
+	cache.order.Init()
+	return nil
+}
+
+func (cache *MemoryCache) remove(el *list.Element) {
+	cache.order.Remove(el)
+	delete(cache.items, el.Value.(*memoryEntry).key)
+}
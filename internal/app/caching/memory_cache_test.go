@@ -0,0 +1,144 @@
+package caching
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testCache кеш с управляемым временем
+func testCache(capacity int) (*MemoryCache, *time.Time) {
+	now := time.Now()
+	cache := NewMemoryCache(capacity)
+	cache.now = func() time.Time { return now }
+	return cache, &now
+}
+
+func expectValue(t *testing.T, cache *MemoryCache, key string, expected any) {
+	t.Helper()
+
+	value, err := cache.Get(key)
+	if err != nil {
+		t.Fatalf("get %s, %v", key, err)
+	}
+	if value != expected {
+		t.Errorf("value of %s is %v, expected %v", key, value, expected)
+	}
+}
+
+func expectMissing(t *testing.T, cache *MemoryCache, key string) {
+	t.Helper()
+
+	if value, err := cache.Get(key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("get %s returned %v, %v, expected ErrNotFound", key, value, err)
+	}
+}
+
+func TestEvictsLeastRecentlyUsed(t *testing.T) {
+	cache, _ := testCache(3)
+
+	for _, key := range []string{"a", "b", "c"} {
+		_ = cache.Set(key, key, 0)
+	}
+
+	// чтение a делает давно неиспользованной b
+	expectValue(t, cache, "a", "a")
+	_ = cache.Set("d", "d", 0)
+
+	expectMissing(t, cache, "b")
+	expectValue(t, cache, "a", "a")
+	expectValue(t, cache, "c", "c")
+	expectValue(t, cache, "d", "d")
+
+	// перезапись существующего ключа тоже считается использованием
+	_ = cache.Set("a", "a2", 0)
+	_ = cache.Set("e", "e", 0)
+
+	expectMissing(t, cache, "c")
+	expectValue(t, cache, "a", "a2")
+	expectValue(t, cache, "d", "d")
+	expectValue(t, cache, "e", "e")
+
+	if cache.order.Len() != 3 || len(cache.items) != 3 {
+		t.Errorf("cache holds %d entries and %d keys, expected 3", cache.order.Len(), len(cache.items))
+	}
+}
+
+func TestExpiration(t *testing.T) {
+	cache, now := testCache(10)
+
+	_ = cache.Set("short", 1, time.Minute)
+	_ = cache.Set("long", 2, time.Hour)
+	_ = cache.Set("forever", 3, 0)
+
+	*now = now.Add(time.Minute - time.Second)
+	expectValue(t, cache, "short", 1)
+
+	*now = now.Add(time.Second)
+	expectMissing(t, cache, "short")
+	expectValue(t, cache, "long", 2)
+
+	*now = now.Add(24 * time.Hour)
+	expectMissing(t, cache, "long")
+	expectValue(t, cache, "forever", 3)
+
+	// истекшие записи удаляются при чтении
+	if _, ok := cache.items["short"]; ok {
+		t.Error("expired entry is not removed")
+	}
+
+	// перезапись продлевает срок
+	_ = cache.Set("forever", 4, time.Minute)
+	*now = now.Add(time.Minute)
+	expectMissing(t, cache, "forever")
+}
+
+func TestDeleteAndPurge(t *testing.T) {
+	cache, _ := testCache(10)
+
+	_ = cache.Set("a", 1, 0)
+	_ = cache.Set("b", 2, 0)
+
+	_ = cache.Delete("a")
+	_ = cache.Delete("missing")
+	expectMissing(t, cache, "a")
+	expectValue(t, cache, "b", 2)
+
+	_ = cache.Purge()
+	expectMissing(t, cache, "b")
+	if cache.order.Len() != 0 {
+		t.Errorf("%d entries left after purge", cache.order.Len())
+	}
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	cache := NewMemoryCache(50)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := fmt.Sprintf("key-%d", (g*1000+i)%100)
+				switch i % 4 {
+				case 0, 1:
+					_ = cache.Set(key, i, time.Minute)
+				case 2:
+					if _, err := cache.Get(key); err != nil && !errors.Is(err, ErrNotFound) {
+						t.Error(err)
+					}
+				default:
+					_ = cache.Delete(key)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if cache.order.Len() > 50 || cache.order.Len() != len(cache.items) {
+		t.Errorf("cache holds %d entries and %d keys, capacity 50", cache.order.Len(), len(cache.items))
+	}
+}
@@ -2,6 +2,7 @@ package caching
 
 import (
 	"context"
+	"errors"
 	"github.com/redis/go-redis/v9"
 	"time"
 )
@@ -30,7 +31,11 @@ func (cache *RedisCache) Set(key string, value any, expiration time.Duration) er
 
 // Get реализация метода Get интерфейса Cache.
 func (cache *RedisCache) Get(key string) (any, error) {
-	return cache.client.Get(cache.ctx, key).Result()
+	value, err := cache.client.Get(cache.ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+	return value, err
 }
 
 // Delete реализация метода Delete интерфейса Cache.
@@ -0,0 +1,36 @@
+package chunker
+
+import "sync"
+
+// Budget ограничение суммарного количества токенов загрузки, безопасен для одновременного использования
+type Budget struct {
+	mu    sync.Mutex
+	limit int
+	used  int
+}
+
+// NewBudget limit 0 - без ограничения
+func NewBudget(limit int) *Budget {
+	return &Budget{limit: limit}
+}
+
+// Add учитывает токены файла, возвращает использованное количество и false, если бюджет превышен
+func (b *Budget) Add(tokens int) (int, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.used += tokens
+	return b.used, b.limit <= 0 || b.used <= b.limit
+}
+
+// Exceeded true, если бюджет уже превышен
+func (b *Budget) Exceeded() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.limit > 0 && b.used > b.limit
+}
+
+func (b *Budget) Limit() int {
+	return b.limit
+}
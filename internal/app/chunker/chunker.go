@@ -0,0 +1,232 @@
+package chunker
+
+import (
+	"fmt"
+	"github.com/gurkankaymak/hocon"
+	"github.com/pkoukk/tiktoken-go"
+	"regexp"
+	"strings"
+)
+
+const (
+	defaultEncoding  = "cl100k_base"
+	defaultMaxTokens = 512
+	defaultOverlap   = 64
+)
+
+// PageSeparator разделитель страниц pdf и листов xlsx в извлеченном содержимом
+const PageSeparator = "\f"
+
+var heading = regexp.MustCompile(`^#{1,6}\s+(.+)$`)
+
+// Section логический раздел документа: страница, лист или часть под заголовком
+type Section struct {
+	Title string
+	// Page номер страницы pdf или листа xlsx, 0 для документов без страниц
+	Page int
+	Text string
+}
+
+// Chunk фрагмент содержимого, ограниченный по количеству токенов
+type Chunk struct {
+	Index   int    `json:"index"`
+	Section string `json:"section,omitempty"`
+	Page    int    `json:"page,omitempty"`
+	Text    string `json:"text"`
+	Tokens  int    `json:"tokens"`
+}
+
+// codec кодирование текста в токены, реализуется tiktoken.Tiktoken
+type codec interface {
+	Encode(text string, allowedSpecial []string, disallowedSpecial []string) []int
+	Decode(tokens []int) string
+}
+
+// Chunker делит извлеченное содержимое на фрагменты по количеству токенов с перекрытием,
+// не склеивая в одном фрагменте разные страницы, листы и разделы под заголовками
+type Chunker struct {
+	codec     codec
+	maxTokens int
+	overlap   int
+}
+
+func New(config *hocon.Config) (*Chunker, error) {
+	encoding := config.GetString("chunking.encoding")
+	if encoding == "" {
+		encoding = defaultEncoding
+	}
+
+	maxTokens := config.GetInt("chunking.max_tokens")
+	if maxTokens <= 0 {
+		maxTokens = defaultMaxTokens
+	}
+
+	overlap := defaultOverlap
+	if config.Get("chunking.overlap") != nil {
+		overlap = config.GetInt("chunking.overlap")
+	}
+	if overlap < 0 || overlap >= maxTokens {
+		return nil, fmt.Errorf("chunking.overlap must be in [0, %d), got %d", maxTokens, overlap)
+	}
+
+	codec, err := tiktoken.GetEncoding(encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Chunker{codec: codec, maxTokens: maxTokens, overlap: overlap}, nil
+}
+
+// Count количество токенов текста
+func (c *Chunker) Count(text string) int {
+	return len(c.codec.Encode(text, nil, nil))
+}
+
+// Split делит содержимое на разделы и разделы на фрагменты
+func (c *Chunker) Split(content string) []Chunk {
+	return c.SplitSections(Sections(content))
+}
+
+// Sections делит содержимое на страницы (листы) по PageSeparator, а страницы на разделы по заголовкам markdown
+func Sections(content string) []Section {
+	pages := strings.Split(content, PageSeparator)
+
+	var sections []Section
+	for i, page := range pages {
+		number := 0
+		if len(pages) > 1 {
+			number = i + 1
+		}
+
+		current := Section{Page: number}
+		var sb strings.Builder
+
+		flush := func() {
+			current.Text = strings.TrimSpace(sb.String())
+			if current.Text != "" {
+				sections = append(sections, current)
+			}
+			sb.Reset()
+		}
+
+		for _, line := range strings.Split(page, "\n") {
+			if m := heading.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+				flush()
+				current = Section{Title: strings.TrimSpace(m[1]), Page: number}
+			}
+			sb.WriteString(line)
+			sb.WriteString("\n")
+		}
+		flush()
+	}
+
+	return sections
+}
+
+// SplitSections делит разделы на фрагменты не больше maxTokens токенов.
+// Соседние фрагменты одного раздела перекрываются последними строками предыдущего фрагмента
+// в пределах overlap токенов
+func (c *Chunker) SplitSections(sections []Section) []Chunk {
+	var chunks []Chunk
+
+	for _, section := range sections {
+		for _, text := range c.splitText(section.Text) {
+			chunks = append(chunks, Chunk{
+				Index:   len(chunks),
+				Section: section.Title,
+				Page:    section.Page,
+				Text:    text,
+				Tokens:  c.Count(text),
+			})
+		}
+	}
+
+	return chunks
+}
+
+type unit struct {
+	text   string
+	tokens int
+}
+
+func (c *Chunker) splitText(text string) []string {
+	if c.Count(text) <= c.maxTokens {
+		return []string{text}
+	}
+
+	var result []string
+	var current []unit
+	var size int
+
+	for _, u := range c.units(text) {
+		if size+u.tokens > c.maxTokens && len(current) > 0 {
+			result = append(result, join(current))
+
+			// переносим в следующий фрагмент хвост текущего в пределах overlap
+			var tail []unit
+			var tailSize int
+			for i := len(current) - 1; i >= 0; i-- {
+				if tailSize+current[i].tokens > c.overlap || tailSize+current[i].tokens+u.tokens > c.maxTokens {
+					break
+				}
+				tail = append([]unit{current[i]}, tail...)
+				tailSize += current[i].tokens
+			}
+			current, size = tail, tailSize
+		}
+
+		current = append(current, u)
+		size += u.tokens
+	}
+
+	if len(current) > 0 {
+		result = append(result, join(current))
+	}
+
+	return result
+}
+
+// units строки текста, слишком длинные строки делятся по словам,
+// слишком длинные слова режутся по токенам
+func (c *Chunker) units(text string) []unit {
+	var units []unit
+
+	for _, line := range strings.Split(text, "\n") {
+		tokens := c.Count(line + "\n")
+		if tokens <= c.maxTokens {
+			units = append(units, unit{text: line + "\n", tokens: tokens})
+			continue
+		}
+
+		first := len(units)
+		for _, word := range strings.Fields(line) {
+			tokens = c.Count(word + " ")
+			if tokens <= c.maxTokens {
+				units = append(units, unit{text: word + " ", tokens: tokens})
+				continue
+			}
+
+			ids := c.codec.Encode(word, nil, nil)
+			for start := 0; start < len(ids); start += c.maxTokens {
+				end := start + c.maxTokens
+				if end > len(ids) {
+					end = len(ids)
+				}
+				units = append(units, unit{text: strings.ToValidUTF8(c.codec.Decode(ids[start:end]), ""), tokens: end - start})
+			}
+		}
+		if len(units) > first {
+			units[len(units)-1].text += "\n"
+		}
+	}
+
+	return units
+}
+
+func join(units []unit) string {
+	var sb strings.Builder
+	for _, u := range units {
+		sb.WriteString(u.text)
+	}
+	return strings.TrimSpace(sb.String())
+}
@@ -0,0 +1,58 @@
+package embedding
+
+import (
+	"context"
+	"fmt"
+	"github.com/gurkankaymak/hocon"
+	"sse-demo-core/internal/app/interfaces/services"
+	"sse-demo-core/internal/app/structs"
+)
+
+const defaultBatch = 32
+
+// Embedder эмбеддинги текстов моделью эмбеддингов провайдера integration.active
+type Embedder struct {
+	provider services.LLMProvider
+	enabled  bool
+	batch    int
+}
+
+// New при search.enabled = false эмбеддинги не считаются и поиск по загрузкам недоступен
+func New(config *hocon.Config, provider services.LLMProvider) *Embedder {
+	batch := config.GetInt("search.batch")
+	if batch <= 0 {
+		batch = defaultBatch
+	}
+	return &Embedder{provider: provider, enabled: config.GetBoolean("search.enabled"), batch: batch}
+}
+
+func (e *Embedder) Enabled() bool {
+	return e.enabled
+}
+
+// Embed эмбеддинги texts в их порядке, тексты уходят провайдеру пачками по search.batch
+func (e *Embedder) Embed(ctx context.Context, texts []string) (*structs.EmbeddingResponse, error) {
+	result := &structs.EmbeddingResponse{Provider: e.provider.Name()}
+
+	for start := 0; start < len(texts); start += e.batch {
+		end := start + e.batch
+		if end > len(texts) {
+			end = len(texts)
+		}
+
+		response, err := e.provider.Embed(ctx, structs.EmbeddingRequest{Input: texts[start:end]})
+		if err != nil {
+			return nil, err
+		}
+		if len(response.Embeddings) != end-start {
+			return nil, fmt.Errorf("%s: %d embeddings for %d texts", e.provider.Name(), len(response.Embeddings), end-start)
+		}
+
+		result.Model = response.Model
+		result.Embeddings = append(result.Embeddings, response.Embeddings...)
+		result.Usage.PromptToken += response.Usage.PromptToken
+		result.Usage.TotalTokens += response.Usage.TotalTokens
+	}
+
+	return result, nil
+}
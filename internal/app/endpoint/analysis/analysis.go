@@ -0,0 +1,294 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"github.com/golang-jwt/jwt"
+	"github.com/gurkankaymak/hocon"
+	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
+	"math"
+	"net/http"
+	"sse-demo-core/internal/app/breaker"
+	"sse-demo-core/internal/app/chunker"
+	"sse-demo-core/internal/app/embedding"
+	fileutils "sse-demo-core/internal/app/endpoint/files/utils"
+	"sse-demo-core/internal/app/integration/llm"
+	"sse-demo-core/internal/app/interfaces/services"
+	"sse-demo-core/internal/app/policy"
+	"sse-demo-core/internal/app/redaction"
+	"sse-demo-core/internal/app/structs"
+	"sse-demo-core/internal/app/utils"
+	"sse-demo-core/internal/app/workerpool"
+	"strings"
+	"time"
+)
+
+type Endpoint struct {
+	config     *hocon.Config
+	users      services.UserService
+	layers     services.LayerService
+	prompts    services.PromptService
+	redactions services.RedactionService
+	results    services.AnalysisService
+	llm        services.LLMProvider
+	accounting services.UsageService
+	chunker    *chunker.Chunker
+	// embedder и search поиск фрагментов-источников для ответов на вопросы
+	embedder *embedding.Embedder
+	search   services.SearchService
+	// pool очередь анализов загрузок, отдельная от пула обработки файлов
+	pool        *workerpool.Pool
+	connections *fileutils.Connections
+}
+
+func New(config *hocon.Config, userSvc services.UserService, layerSvc services.LayerService, promptSvc services.PromptService,
+	redactionSvc services.RedactionService, analysisSvc services.AnalysisService, provider services.LLMProvider,
+	usageSvc services.UsageService, chunks *chunker.Chunker, embedder *embedding.Embedder, searchSvc services.SearchService,
+	pool *workerpool.Pool, connections *fileutils.Connections) *Endpoint {
+	return &Endpoint{config: config, users: userSvc, layers: layerSvc, prompts: promptSvc, redactions: redactionSvc,
+		results: analysisSvc, llm: provider, accounting: usageSvc, chunker: chunks, embedder: embedder, search: searchSvc,
+		pool: pool, connections: connections}
+}
+
+// AnalysisHandler запускает потоковый анализ моделью активного провайдера.
+// Фрагменты ответа уходят подписчикам /sse?guid= событиями analysis_delta, в конце analysis_completed с расходом токенов
+func (e *Endpoint) AnalysisHandler(ctx echo.Context) error {
+	logger := logdoc.GetLogger()
+	logger.Info(">> AnalysisHandler started..")
+
+	user, httpErr := e.user(ctx)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	var req structs.AnalysisRequest
+	if err := ctx.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, structs.ErrorResponse{Error: "invalid request body"})
+	}
+
+	messages := req.Messages
+	if strings.TrimSpace(req.Prompt) != "" {
+		messages = append(messages, structs.Content{Role: "user", Content: req.Prompt})
+	}
+	if len(messages) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, structs.ErrorResponse{Error: "empty prompt"})
+	}
+
+	guid := req.GUID
+	if guid == "" {
+		guid = uuid.NewV4().String()
+	}
+	uid := uuid.NewV4().String()
+
+	// канал создаем до ответа, чтобы клиент сразу мог подписаться на /sse
+	if err := e.connections.Open(guid, user.ID); err != nil {
+		logger.Error(">> AnalysisHandler > guid:", guid, " rejected, ", err)
+		return echo.NewHTTPError(http.StatusConflict, structs.ErrorResponse{Error: err.Error()})
+	}
+
+	request := structs.LLMRequest{
+		Model:       req.Model,
+		Messages:    messages,
+		Temperature: req.Temperature,
+		MaxTokens:   req.MaxTokens,
+	}
+
+	// таймаут анализа включает ожидание в очереди
+	c, cancel := context.WithTimeout(analysisContext(bypassCache(ctx)), time.Duration(e.config.GetInt("analysis.timeout"))*time.Second)
+
+	position, err := e.pool.Submit(c, user.ID, func(c context.Context) {
+		defer cancel()
+		e.analyze(c, guid, uid, user, request)
+	})
+	if err != nil {
+		cancel()
+		e.connections.Release(guid)
+		logger.Error(">> AnalysisHandler > error queueing analysis, ", err)
+		return echo.NewHTTPError(http.StatusServiceUnavailable, structs.ErrorResponse{Error: "analysis queue is not available"})
+	}
+
+	logger.Info(">> queued analysis uid:", uid, " with guid:", guid, ", userId:", user.ID, ", provider:", e.llm.Name(),
+		", position:", position)
+
+	return ctx.JSON(http.StatusAccepted, structs.AnalysisStartedResponse{GUID: guid, UUID: uid, Position: position})
+}
+
+func (e *Endpoint) analyze(c context.Context, guid string, uid string, user *structs.User, req structs.LLMRequest) {
+	logger := logdoc.GetLogger()
+
+	send, closeSender := e.sender(guid, uid)
+	defer closeSender()
+
+	// анализ отменен по таймауту, пока ждал в очереди
+	if c.Err() != nil {
+		logger.Error(">> analysis uid:", uid, " with guid:", guid, " cancelled while queued")
+		_ = send("analysis_error", analysisError(errors.New("analysis cancelled while queued")))
+		return
+	}
+
+	if e.quotaExceeded(send, user) {
+		return
+	}
+
+	response, usage, err := e.run(c, send, user.ID, guid, req, nil)
+	if err != nil {
+		logger.Error(">> analysis uid:", uid, " with guid:", guid, " error, ", err)
+		_ = send("analysis_error", analysisError(err))
+		return
+	}
+
+	logger.Info(">> analysis uid:", uid, " with guid:", guid, " completed, tokens: ", usage.TotalTokens)
+
+	_ = send("analysis_completed", structs.AnalysisCompletedDetails{
+		Provider:     response.Provider,
+		Model:        response.Model,
+		FinishReason: response.FinishReason,
+		Usage:        usage,
+		Cached:       response.Cached,
+	})
+}
+
+// bypassCache клиент запросил ответ модели мимо кеша ответов
+func bypassCache(ctx echo.Context) bool {
+	return strings.EqualFold(ctx.Request().Header.Get(llm.BypassHeader), "true")
+}
+
+// analysisContext контекст фонового анализа, не связанный с завершившимся http запросом
+func analysisContext(bypass bool) context.Context {
+	if bypass {
+		return llm.BypassCache(context.Background())
+	}
+	return context.Background()
+}
+
+// sender отправляет события анализа подписчикам /sse через очередь, не блокируя анализ и слот очереди анализов.
+// События доставляются не дольше analysis.timeout, closeSender дожидается доставки в фоне
+func (e *Endpoint) sender(guid string, uid string) (send func(state string, details any) error, closeSender func()) {
+	dc, dcancel := context.WithTimeout(context.Background(), time.Duration(e.config.GetInt("analysis.timeout"))*time.Second)
+	forwarder := fileutils.NewConnectionsForwarder(dc, guid, e.connections)
+
+	send = func(state string, details any) error {
+		forwarder.Send(structs.Notification{GUID: guid, UUID: uid, State: state, Details: details})
+		return nil
+	}
+	closeSender = func() {
+		go func() {
+			forwarder.Close()
+			dcancel()
+		}()
+	}
+	return send, closeSender
+}
+
+// quotaExceeded проверяет месячную квоту запросов к модели перед анализом, об исчерпанной квоте сообщает событием quota_exceeded
+func (e *Endpoint) quotaExceeded(send func(state string, details any) error, user *structs.User) bool {
+	logger := logdoc.GetLogger()
+
+	details, err := e.accounting.CheckQuota(user, policy.JobLLM, 0)
+	if err != nil {
+		logger.Warn(">> error checking quota of userId:", user.ID, ", ", err)
+		return false
+	}
+	if details == nil {
+		return false
+	}
+
+	logger.Warn(">> userId:", user.ID, " exceeded quota, ", details.Message)
+	_ = send("quota_exceeded", details)
+	return true
+}
+
+// run выполняет потоковый запрос к модели, отправляя события analysis_started и analysis_delta.
+// restore возвращает персональные данные вместо заменителей во фрагментах ответа, nil - фрагменты уходят как есть.
+// Запрос учитывается в расходе пользователя userID
+func (e *Endpoint) run(c context.Context, send func(state string, details any) error, userID int, guid string,
+	req structs.LLMRequest, restore func(text string) string) (*structs.LLMResponse, structs.Usage, error) {
+	_ = send("analysis_started", nil)
+
+	if restore == nil {
+		restore = func(text string) string { return text }
+	}
+	stream := redaction.NewStream(restore)
+	sendDelta := func(delta string) error {
+		if delta == "" {
+			return nil
+		}
+		return send("analysis_delta", structs.AnalysisDeltaDetails{Delta: delta})
+	}
+
+	// фрагменты ждут подписчика в очереди отправки, чтение ответа модели не ждет клиента
+	response, err := e.llm.Stream(c, req, func(delta string) error {
+		return sendDelta(stream.Write(delta))
+	})
+	if err != nil {
+		return nil, structs.Usage{}, err
+	}
+	_ = sendDelta(stream.Flush())
+
+	usage := e.usage(req, response)
+	e.record(userID, guid, response, usage)
+
+	return response, usage, nil
+}
+
+// record учитывает запрос к модели в расходе пользователя, ответ из кеша не учитывается
+func (e *Endpoint) record(userID int, guid string, response *structs.LLMResponse, usage structs.Usage) {
+	logger := logdoc.GetLogger()
+
+	if response.Cached {
+		return
+	}
+	if err := e.accounting.RecordLLM(userID, guid, &usage); err != nil {
+		logger.Error(">> error recording llm usage of userId:", userID, ", ", err)
+	}
+}
+
+// analysisError событие ошибки анализа, недоступность провайдера отмечается отдельной причиной
+func analysisError(err error) structs.AnalysisErrorDetails {
+	details := structs.AnalysisErrorDetails{Message: err.Error()}
+
+	var openErr *breaker.OpenError
+	switch {
+	case errors.As(err, &openErr):
+		details.Reason = "provider_unavailable"
+		details.RetryAfter = int(math.Ceil(openErr.RetryAfter.Seconds()))
+	case errors.Is(err, breaker.ErrBulkheadFull):
+		details.Reason = "provider_busy"
+	}
+	return details
+}
+
+// user пользователь из claims, их кладет в контекст headerchecker
+func (e *Endpoint) user(ctx echo.Context) (*structs.User, *echo.HTTPError) {
+	logger := logdoc.GetLogger()
+
+	claims, ok := ctx.Get("claims").(jwt.MapClaims)
+	if !ok {
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, structs.ErrorResponse{Error: "authorization required"})
+	}
+	user, err := utils.GetUserFromClaims(claims, e.users)
+	if err != nil {
+		logger.Error(">> error getting user from token claims, ", err)
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, structs.ErrorResponse{Error: "user not found"})
+	}
+	return user, nil
+}
+
+// usage расход токенов из ответа провайдера, если провайдер его не сообщил - считаем сами
+func (e *Endpoint) usage(req structs.LLMRequest, response *structs.LLMResponse) structs.Usage {
+	usage := response.Usage
+	if usage.PromptToken == 0 {
+		for _, m := range req.Messages {
+			usage.PromptToken += e.chunker.Count(m.Content)
+		}
+	}
+	if usage.CompletionTokens == 0 {
+		usage.CompletionTokens = e.chunker.Count(response.Content)
+	}
+	if usage.TotalTokens == 0 {
+		usage.TotalTokens = usage.PromptToken + usage.CompletionTokens
+	}
+	return usage
+}
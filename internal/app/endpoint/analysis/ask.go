@@ -0,0 +1,251 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
+	"net/http"
+	"sse-demo-core/internal/app/language"
+	"sse-demo-core/internal/app/prompttemplate"
+	"sse-demo-core/internal/app/redaction"
+	"sse-demo-core/internal/app/structs"
+	"strings"
+	"time"
+)
+
+const defaultAskTopK = 8
+
+// AskHandler ставит в очередь анализа ответ на вопрос по загрузкам пользователя. Найденные поиском фрагменты-источники
+// уходят подписчикам /sse?guid= событием ask_sources, ответ модели со ссылками [n] на источники - событиями analysis_delta,
+// в конце analysis_completed с ответом и источниками, на которые он ссылается
+func (e *Endpoint) AskHandler(ctx echo.Context) error {
+	logger := logdoc.GetLogger()
+	logger.Info(">> AskHandler started..")
+
+	user, httpErr := e.user(ctx)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	if !e.embedder.Enabled() {
+		return echo.NewHTTPError(http.StatusServiceUnavailable, structs.ErrorResponse{Error: "search is disabled"})
+	}
+
+	var req structs.AskRequest
+	if err := ctx.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, structs.ErrorResponse{Error: "invalid request body"})
+	}
+	req.Question = strings.TrimSpace(req.Question)
+	if req.Question == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, structs.ErrorResponse{Error: "empty question"})
+	}
+
+	guid := uuid.NewV4().String()
+	uid := uuid.NewV4().String()
+
+	// канал создаем до ответа, чтобы клиент сразу мог подписаться на /sse
+	_ = e.connections.Open(guid, user.ID)
+
+	// таймаут ответа включает ожидание в очереди
+	c, cancel := context.WithTimeout(analysisContext(bypassCache(ctx)), time.Duration(e.config.GetInt("analysis.timeout"))*time.Second)
+
+	position, err := e.pool.Submit(c, user.ID, func(c context.Context) {
+		defer cancel()
+		e.ask(c, user, guid, uid, req)
+	})
+	if err != nil {
+		cancel()
+		e.connections.Release(guid)
+		logger.Error(">> AskHandler > error queueing question, ", err)
+		return echo.NewHTTPError(http.StatusServiceUnavailable, structs.ErrorResponse{Error: "analysis queue is not available"})
+	}
+
+	logger.Info(">> queued question uid:", uid, " with guid:", guid, ", userId:", user.ID, ", uploads: ", len(req.GUIDs),
+		", position:", position)
+
+	return ctx.JSON(http.StatusAccepted, structs.AnalysisStartedResponse{GUID: guid, UUID: uid, Position: position})
+}
+
+func (e *Endpoint) ask(c context.Context, user *structs.User, guid string, uid string, req structs.AskRequest) {
+	logger := logdoc.GetLogger()
+
+	send, closeSender := e.sender(guid, uid)
+	defer closeSender()
+
+	fail := func(err error) {
+		logger.Error(">> question uid:", uid, " with guid:", guid, " error, ", err)
+		_ = send("analysis_error", analysisError(err))
+	}
+
+	// вопрос отменен по таймауту, пока ждал в очереди
+	if c.Err() != nil {
+		fail(errors.New("question cancelled while queued"))
+		return
+	}
+
+	if e.quotaExceeded(send, user) {
+		return
+	}
+
+	chunks, err := e.retrieve(c, user.ID, req)
+	if err != nil {
+		fail(err)
+		return
+	}
+	if len(chunks) == 0 {
+		logger.Warn(">> question uid:", uid, " of userId:", user.ID, " has no sources")
+		_ = send("analysis_error", structs.AnalysisErrorDetails{Reason: "no_sources", Message: "no indexed upload content found"})
+		return
+	}
+
+	sources := e.sources(user.ID, chunks)
+	_ = send("ask_sources", structs.AskSourcesDetails{Sources: sources})
+
+	renumbered, values := e.renumber(user.ID, chunks)
+	restore := func(text string) string {
+		return redaction.Replace(text, func(p string) string {
+			if value, ok := values[p]; ok {
+				return value
+			}
+			return p
+		})
+	}
+
+	lang := language.Detect(req.Question).Language
+	messages, err := prompttemplate.AskMessages(e.askPrompt(lang), prompttemplate.FromChunks(renumbered, lang), req.Question)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	response, usage, err := e.run(c, send, user.ID, guid, structs.LLMRequest{Messages: messages}, restore)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	answer := restore(response.Content)
+
+	var cited []structs.AskSource
+	for _, n := range prompttemplate.Citations(answer, len(sources)) {
+		cited = append(cited, sources[n-1])
+	}
+
+	logger.Info(">> question uid:", uid, " with guid:", guid, " answered, sources: ", len(sources), ", cited: ", len(cited),
+		", tokens: ", usage.TotalTokens)
+
+	_ = send("analysis_completed", structs.AnalysisCompletedDetails{
+		Provider:     response.Provider,
+		Model:        response.Model,
+		FinishReason: response.FinishReason,
+		Usage:        usage,
+		Cached:       response.Cached,
+		Result:       answer,
+		Sources:      cited,
+	})
+}
+
+// retrieve фрагменты загрузок пользователя, ближайшие к вопросу. Содержимое фрагментов очищено от персональных данных
+func (e *Endpoint) retrieve(c context.Context, userID int, req structs.AskRequest) ([]structs.LayerChunk, error) {
+	query, err := e.embedder.Embed(c, []string{req.Question})
+	if err != nil {
+		return nil, err
+	}
+
+	topK := req.TopK
+	if topK <= 0 {
+		topK = e.config.GetInt("ask.top_k")
+	}
+	if topK <= 0 {
+		topK = defaultAskTopK
+	}
+	if limit := e.config.GetInt("search.max_top_k"); limit > 0 && topK > limit {
+		topK = limit
+	}
+
+	return e.search.Search(userID, query.Model, query.Embeddings[0], req.GUIDs, topK)
+}
+
+// sources источники ответа в порядке близости к вопросу с восстановленными персональными данными
+func (e *Endpoint) sources(userID int, chunks []structs.LayerChunk) []structs.AskSource {
+	logger := logdoc.GetLogger()
+
+	sources := make([]structs.AskSource, 0, len(chunks))
+	for i, c := range chunks {
+		content, err := e.redactions.RestoreLayer(c.GUID, c.UUID, userID, c.Content)
+		if err != nil {
+			logger.Warn(">> error restoring redactions of upload guid:", c.GUID, ", ", err)
+			content = c.Content
+		}
+		sources = append(sources, structs.AskSource{Number: i + 1, GUID: c.GUID, UUID: c.UUID, FileName: c.FileName,
+			Page: c.Page, Section: c.Section, Index: c.Index, Score: c.Score, Content: content})
+	}
+	return sources
+}
+
+// askPrompt промпт этапа ask.stage на языке вопроса, без этапа или промпта этапа - встроенный prompttemplate.AskPrompt
+func (e *Endpoint) askPrompt(lang string) string {
+	logger := logdoc.GetLogger()
+
+	stage := e.config.GetInt("ask.stage")
+	if stage == 0 {
+		return prompttemplate.AskPrompt
+	}
+
+	prompt, err := e.prompts.FindStagePrompt(stage, lang)
+	if err != nil {
+		logger.Warn(">> prompt of ask stage ", stage, " not found, using default prompt, ", err)
+		return prompttemplate.AskPrompt
+	}
+	return prompt.PromptText
+}
+
+// renumber заменители персональных данных нумеруются в каждой загрузке отдельно, и одинаковые заменители источников
+// из разных загрузок означают разные данные. Перед отправкой в модель заменители источников получают номера,
+// уникальные в пределах вопроса, одно значение - один заменитель. Возвращает фрагменты для модели и исходные значения
+// новых заменителей
+func (e *Endpoint) renumber(userID int, chunks []structs.LayerChunk) ([]structs.LayerChunk, map[string]string) {
+	logger := logdoc.GetLogger()
+
+	renumbered := make([]structs.LayerChunk, len(chunks))
+	values := make(map[string]string)
+	byValue := make(map[string]string)
+	byLayer := make(map[string]string)
+	layers := make(map[string][]structs.RedactionToken)
+	counters := make(map[string]int)
+	for i, c := range chunks {
+		tokens, ok := layers[c.UUID]
+		if !ok {
+			var err error
+			if tokens, err = e.redactions.FindTokens(c.GUID, c.UUID, userID); err != nil {
+				logger.Warn(">> error restoring redactions of upload guid:", c.GUID, ", layer uid:", c.UUID, ", ", err)
+			}
+			layers[c.UUID] = tokens
+		}
+
+		c.Content = redaction.Replace(c.Content, func(p string) string {
+			if renamed, ok := byLayer[c.UUID+p]; ok {
+				return renamed
+			}
+
+			// заменитель без известного значения тоже перенумеровывается, чтобы не совпасть с новым
+			value := redaction.Restore(p, tokens)
+			kind := p[1:strings.LastIndex(p, "_")]
+			renamed, ok := byValue[kind+value]
+			if !ok || value == p {
+				counters[kind]++
+				renamed = fmt.Sprintf("[%s_%d]", kind, counters[kind])
+				if value != p {
+					byValue[kind+value], values[renamed] = renamed, value
+				}
+			}
+			byLayer[c.UUID+p] = renamed
+			return renamed
+		})
+		renumbered[i] = c
+	}
+	return renumbered, values
+}
@@ -0,0 +1,380 @@
+package analysis
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
+	"net/http"
+	fileutils "sse-demo-core/internal/app/endpoint/files/utils"
+	"sse-demo-core/internal/app/jsonschema"
+	"sse-demo-core/internal/app/prompttemplate"
+	"sse-demo-core/internal/app/structs"
+	"strings"
+	"time"
+)
+
+const defaultExtractionAttempts = 3
+
+// extractionRun запуск извлечения данных из слоев загрузки по одной схеме
+type extractionRun struct {
+	user        *structs.User
+	guid        string
+	uid         string
+	schema      *jsonschema.Schema
+	layers      []structs.UserLayer
+	extractions []structs.Extraction
+	send        func(state string, details any) error
+}
+
+// ExtractionHandler ставит в очередь анализа извлечение данных по JSON Schema из слоев загрузки.
+// Провайдер ограничивает ответ JSON режимом или вызовом функции, если умеет, ответ проверяется схемой,
+// при ошибках проверки модель переспрашивается до extraction.attempts раз. Ход извлечения уходит подписчикам /sse?guid=
+// событиями extraction_*, результаты сохраняются со слоями и доступны через ExtractionsHandler
+func (e *Endpoint) ExtractionHandler(ctx echo.Context) error {
+	logger := logdoc.GetLogger()
+	logger.Info(">> ExtractionHandler started..")
+
+	user, httpErr := e.user(ctx)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	guid := ctx.Param("guid")
+
+	var req structs.ExtractionRequest
+	if err := ctx.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, structs.ErrorResponse{Error: "invalid request body"})
+	}
+	if len(req.Schema) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, structs.ErrorResponse{Error: "empty schema"})
+	}
+
+	schema, err := jsonschema.Compile(req.Schema)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, structs.ErrorResponse{Error: "invalid schema, " + err.Error()})
+	}
+	var compact bytes.Buffer
+	_ = json.Compact(&compact, req.Schema)
+
+	layers, httpErr := e.uploadLayers(guid, user.ID)
+	if httpErr != nil {
+		return httpErr
+	}
+	if layers, httpErr = selectLayers(layers, req.Layers); httpErr != nil {
+		return httpErr
+	}
+
+	// канал создаем до ответа, чтобы клиент сразу мог подписаться на /sse
+	if err := e.connections.Open(guid, user.ID); err != nil {
+		logger.Error(">> ExtractionHandler > guid:", guid, " rejected, ", err)
+		return echo.NewHTTPError(http.StatusConflict, structs.ErrorResponse{Error: err.Error()})
+	}
+
+	run := &extractionRun{user: user, guid: guid, uid: uuid.NewV4().String(), schema: schema, layers: layers}
+	ids := make([]int, 0, len(layers))
+	for _, layer := range layers {
+		extraction := structs.Extraction{
+			GUID:         guid,
+			UUID:         run.uid,
+			UserID:       user.ID,
+			LayerID:      layer.ID,
+			LayerUUID:    layer.UUID,
+			FileName:     layer.LayerName,
+			Schema:       compact.Bytes(),
+			Instructions: strings.TrimSpace(req.Instructions),
+			Provider:     e.llm.Name(),
+		}
+		if _, err = e.results.StartExtraction(&extraction); err != nil {
+			logger.Error(">> ExtractionHandler > error saving extraction, ", err)
+			e.connections.Release(guid)
+			e.failExtractions(run, err)
+			return echo.NewHTTPError(http.StatusInternalServerError, structs.ErrorResponse{Error: "error starting extraction"})
+		}
+		run.extractions = append(run.extractions, extraction)
+		ids = append(ids, extraction.ID)
+	}
+
+	// таймаут извлечения включает ожидание в очереди
+	c, cancel := context.WithTimeout(analysisContext(bypassCache(ctx)), time.Duration(e.config.GetInt("extraction.timeout"))*time.Second)
+
+	position, err := e.pool.Submit(c, user.ID, func(c context.Context) {
+		defer cancel()
+		e.extract(c, run)
+	})
+	if err != nil {
+		cancel()
+		e.connections.Release(guid)
+		logger.Error(">> ExtractionHandler > error queueing extraction, ", err)
+		e.failExtractions(run, err)
+		return echo.NewHTTPError(http.StatusServiceUnavailable, structs.ErrorResponse{Error: "analysis queue is not available"})
+	}
+
+	logger.Info(">> queued extraction uid:", run.uid, " with guid:", guid, ", userId:", user.ID, ", layers:", len(layers),
+		", position:", position)
+
+	return ctx.JSON(http.StatusAccepted, structs.ExtractionStartedResponse{GUID: guid, UUID: run.uid, ExtractionIDs: ids, Position: position})
+}
+
+// ExtractionsHandler сохраненные результаты извлечения данных из слоев загрузки
+func (e *Endpoint) ExtractionsHandler(ctx echo.Context) error {
+	logger := logdoc.GetLogger()
+
+	user, httpErr := e.user(ctx)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	extractions, err := e.results.FindUploadExtractions(ctx.Param("guid"), user.ID)
+	if err != nil {
+		logger.Error(">> ExtractionsHandler > error reading extractions, ", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, structs.ErrorResponse{Error: "error reading extractions"})
+	}
+	if extractions == nil {
+		extractions = []structs.Extraction{}
+	}
+
+	return ctx.JSON(http.StatusOK, extractions)
+}
+
+func (e *Endpoint) extract(c context.Context, run *extractionRun) {
+	logger := logdoc.GetLogger()
+
+	// события доставляются не дольше таймаута извлечения и не держат слот очереди анализа после его завершения
+	dc, dcancel := context.WithTimeout(context.Background(), time.Duration(e.config.GetInt("extraction.timeout"))*time.Second)
+	forwarder := fileutils.NewConnectionsForwarder(dc, run.guid, e.connections)
+	defer func() {
+		go func() {
+			forwarder.Close()
+			dcancel()
+		}()
+	}()
+
+	run.send = func(state string, details any) error {
+		forwarder.Send(structs.Notification{GUID: run.guid, UUID: run.uid, State: state, Details: details})
+		return nil
+	}
+
+	// извлечение отменено по таймауту, пока ждало в очереди
+	if c.Err() != nil {
+		err := errors.New("extraction cancelled while queued")
+		e.failExtractions(run, err)
+		_ = run.send("extraction_failed", structs.ExtractionDetails{Message: err.Error()})
+		return
+	}
+
+	if e.quotaExceeded(run.send, run.user) {
+		e.failExtractions(run, errors.New("quota exceeded"))
+		return
+	}
+
+	attempts := e.config.GetInt("extraction.attempts")
+	if attempts <= 0 {
+		attempts = defaultExtractionAttempts
+	}
+
+	var completed structs.ExtractionCompletedDetails
+	for i := range run.layers {
+		usage, ok := e.extractLayer(c, run, &run.layers[i], &run.extractions[i], attempts)
+		completed.Usage.PromptToken += usage.PromptToken
+		completed.Usage.CompletionTokens += usage.CompletionTokens
+		completed.Usage.TotalTokens += usage.TotalTokens
+		if ok {
+			completed.Completed++
+		} else {
+			completed.Failed++
+		}
+	}
+
+	logger.Info(">> extraction uid:", run.uid, " with guid:", run.guid, " completed, layers: ", completed.Completed,
+		", failed: ", completed.Failed, ", tokens: ", completed.Usage.TotalTokens)
+
+	_ = run.send("extraction_completed", completed)
+}
+
+// extractLayer извлекает данные слоя: запрос к модели и повторы с ошибками проверки, пока ответ не пройдет проверку схемой.
+// false - данные не извлечены, причина сохранена в извлечении
+func (e *Endpoint) extractLayer(c context.Context, run *extractionRun, layer *structs.UserLayer, extraction *structs.Extraction,
+	attempts int) (structs.Usage, bool) {
+	logger := logdoc.GetLogger()
+
+	details := structs.ExtractionDetails{ExtractionID: extraction.ID, LayerUUID: layer.UUID, FileName: layer.LayerName, Attempt: 1}
+	_ = run.send("extraction_started", details)
+
+	messages := prompttemplate.ExtractMessages(extraction.Schema, extraction.Instructions, *layer)
+
+	var total structs.Usage
+	var cause error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		req := structs.LLMRequest{Messages: messages, Schema: extraction.Schema}
+		response, err := e.llm.Complete(c, req)
+		if err != nil {
+			cause = err
+			break
+		}
+
+		usage := e.usage(req, response)
+		e.record(run.user.ID, run.guid, response, usage)
+		total.PromptToken += usage.PromptToken
+		total.CompletionTokens += usage.CompletionTokens
+		total.TotalTokens += usage.TotalTokens
+
+		extraction.Attempts = attempt
+		extraction.Model = response.Model
+		extraction.PromptTokens = total.PromptToken
+		extraction.CompletionTokens = total.CompletionTokens
+
+		result, errs := e.checkExtraction(run, layer, response.Content)
+		if len(errs) == 0 {
+			extraction.Result = result
+			if err = e.results.CompleteExtraction(extraction); err != nil {
+				logger.Error(">> error saving extraction ", extraction.ID, ", ", err)
+			}
+			details.Attempt, details.Status, details.Result = attempt, extraction.Status, result
+			_ = run.send("extraction_result", details)
+			return total, true
+		}
+
+		logger.Warn(">> extraction ", extraction.ID, " of layer uuid:", layer.UUID, " attempt ", attempt, " does not match schema, ",
+			strings.Join(errs, "; "))
+		cause = fmt.Errorf("reply does not match schema, %w", &jsonschema.ValidationError{Errors: errs})
+
+		// модель переспрашиваем с очищенным ответом и ошибками проверки, значений ошибки не содержат
+		if attempt < attempts {
+			messages = prompttemplate.RetryMessages(messages, response.Content, errs)
+			_ = run.send("extraction_retry", structs.ExtractionDetails{ExtractionID: extraction.ID, LayerUUID: layer.UUID,
+				FileName: layer.LayerName, Attempt: attempt + 1, Errors: errs})
+		}
+	}
+
+	logger.Error(">> extraction ", extraction.ID, " of layer uuid:", layer.UUID, " failed, ", cause)
+	if err := e.results.FailExtraction(extraction, cause); err != nil {
+		logger.Error(">> error saving failed extraction ", extraction.ID, ", ", err)
+	}
+	details.Attempt, details.Status, details.Message = extraction.Attempts, extraction.Status, cause.Error()
+	_ = run.send("extraction_result", details)
+	return total, false
+}
+
+// checkExtraction разбирает ответ модели, возвращает в строки исходные персональные данные слоя и проверяет результат схемой.
+// Персональные данные восстанавливаются до проверки: заменитель не пройдет проверку, например, формата email
+func (e *Endpoint) checkExtraction(run *extractionRun, layer *structs.UserLayer, reply string) (json.RawMessage, []string) {
+	logger := logdoc.GetLogger()
+
+	value, err := prompttemplate.ExtractedJSON(reply)
+	if err != nil {
+		return nil, []string{"$: " + err.Error()}
+	}
+
+	restored, err := restoreStrings(value, func(s string) (string, error) {
+		return e.redactions.RestoreLayer(run.guid, layer.UUID, run.user.ID, s)
+	})
+	if err != nil {
+		logger.Warn(">> error restoring redactions of extraction uid:", run.uid, ", ", err)
+		restored = value
+	}
+
+	var validationErr *jsonschema.ValidationError
+	if err = run.schema.Validate(restored); errors.As(err, &validationErr) {
+		return nil, validationErr.Errors
+	}
+	return restored, nil
+}
+
+// failExtractions сохраняет ошибку всех еще выполняющихся извлечений запуска
+func (e *Endpoint) failExtractions(run *extractionRun, cause error) {
+	logger := logdoc.GetLogger()
+
+	for i := range run.extractions {
+		if run.extractions[i].Status != "running" {
+			continue
+		}
+		if err := e.results.FailExtraction(&run.extractions[i], cause); err != nil {
+			logger.Error(">> error saving failed extraction ", run.extractions[i].ID, ", ", err)
+		}
+	}
+}
+
+// selectLayers слои загрузки с uuid из uuids, пустой uuids - все слои
+func selectLayers(layers []structs.UserLayer, uuids []string) ([]structs.UserLayer, *echo.HTTPError) {
+	if len(uuids) == 0 {
+		return layers, nil
+	}
+
+	byUUID := make(map[string]structs.UserLayer, len(layers))
+	for _, l := range layers {
+		byUUID[l.UUID] = l
+	}
+
+	selected := make([]structs.UserLayer, 0, len(uuids))
+	seen := make(map[string]bool)
+	for _, id := range uuids {
+		layer, ok := byUUID[id]
+		if !ok {
+			return nil, echo.NewHTTPError(http.StatusNotFound, structs.ErrorResponse{Error: "layer " + id + " not found"})
+		}
+		if !seen[id] {
+			seen[id] = true
+			selected = append(selected, layer)
+		}
+	}
+	return selected, nil
+}
+
+// restoreStrings возвращает исходные персональные данные в строковые значения JSON: заменитель в тексте восстанавливается
+// внутри строки, поэтому JSON остается корректным, даже если исходное значение содержит кавычки
+func restoreStrings(value json.RawMessage, restore func(s string) (string, error)) (json.RawMessage, error) {
+	d := json.NewDecoder(bytes.NewReader(value))
+	d.UseNumber()
+
+	var decoded any
+	if err := d.Decode(&decoded); err != nil {
+		return nil, err
+	}
+
+	var walk func(v any) (any, error)
+	walk = func(v any) (any, error) {
+		switch t := v.(type) {
+		case string:
+			// заменители имеют вид [EMAIL_1], строки без них не восстанавливаем
+			if !strings.Contains(t, "[") {
+				return t, nil
+			}
+			return restore(t)
+		case []any:
+			for i := range t {
+				item, err := walk(t[i])
+				if err != nil {
+					return nil, err
+				}
+				t[i] = item
+			}
+		case map[string]any:
+			for k := range t {
+				item, err := walk(t[k])
+				if err != nil {
+					return nil, err
+				}
+				t[k] = item
+			}
+		}
+		return v, nil
+	}
+
+	restored, err := walk(decoded)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	if err = encoder.Encode(restored); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSpace(out.Bytes()), nil
+}
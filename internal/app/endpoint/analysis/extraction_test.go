@@ -0,0 +1,161 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+	"sse-demo-core/internal/app/jsonschema"
+	"sse-demo-core/internal/app/structs"
+	"strings"
+	"testing"
+)
+
+const extractionConf = `
+extraction {
+  timeout = 5
+  attempts = 3
+}`
+
+const totalSchema = `{"type":"object","required":["total"],"properties":{"total":{"type":"number"},"email":{"type":"string","format":"email"}}}`
+
+// replies модель отвечает по очереди ответами replies, последний повторяется
+func replies(replies ...string) *fakeLLM {
+	n := 0
+	return &fakeLLM{reply: func(req structs.LLMRequest) (string, error) {
+		reply := replies[n]
+		if n < len(replies)-1 {
+			n++
+		}
+		if reply == "" {
+			return "", errors.New("provider error")
+		}
+		return reply, nil
+	}}
+}
+
+// runExtraction извлекает данные одного слоя и возвращает сохраненное извлечение и события
+func runExtraction(t *testing.T, llm *fakeLLM, values map[string]string) (structs.Extraction, []structs.Notification) {
+	t.Helper()
+
+	e, results := testEndpoint(t, extractionConf, llm, nil)
+	e.redactions = &fakeRedactions{values: values}
+
+	schema, err := jsonschema.Compile([]byte(totalSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := &structs.User{ID: 7}
+	layer := structs.UserLayer{ID: 1, UUID: "l1", LayerName: "invoice.pdf", SourceData: "Total 120.50, contact [EMAIL_1]"}
+	run := &extractionRun{user: user, guid: "guid-" + t.Name(), uid: "uid", schema: schema, layers: []structs.UserLayer{layer}}
+
+	extraction := structs.Extraction{GUID: run.guid, UUID: run.uid, UserID: user.ID, LayerUUID: layer.UUID, Schema: []byte(totalSchema)}
+	if _, err = results.StartExtraction(&extraction); err != nil {
+		t.Fatal(err)
+	}
+	run.extractions = append(run.extractions, extraction)
+
+	events := subscribe(t, e, run.guid, user.ID, "extraction_completed", "extraction_failed")
+	e.extract(context.Background(), run)
+
+	if len(results.extractions) != 1 {
+		t.Fatalf("%d saved extractions, expected 1", len(results.extractions))
+	}
+	return results.extractions[0], <-events
+}
+
+func countEvents(events []structs.Notification, state string) int {
+	n := 0
+	for _, e := range events {
+		if e.State == state {
+			n++
+		}
+	}
+	return n
+}
+
+func TestExtractValid(t *testing.T) {
+	llm := replies("```json\n{\"total\": 120.5}\n```")
+	extraction, events := runExtraction(t, llm, nil)
+
+	if extraction.Status != "completed" || string(extraction.Result) != `{"total":120.5}` {
+		t.Fatalf("extraction %s with result %s", extraction.Status, extraction.Result)
+	}
+	if extraction.Attempts != 1 || len(llm.requests) != 1 {
+		t.Errorf("%d attempts, %d requests, expected 1", extraction.Attempts, len(llm.requests))
+	}
+	// провайдер получает схему, чтобы ограничить ответ
+	if string(llm.requests[0].Schema) != totalSchema {
+		t.Errorf("request schema %s", llm.requests[0].Schema)
+	}
+	if countEvents(events, "extraction_result") != 1 || countEvents(events, "extraction_completed") != 1 {
+		t.Errorf("events %+v", events)
+	}
+}
+
+// TestExtractRetry ответ, не прошедший проверку схемой, переспрашивается с ошибками проверки
+func TestExtractRetry(t *testing.T) {
+	llm := replies(`{"total": "120.50"}`, `{"total": 120.5}`)
+	extraction, events := runExtraction(t, llm, nil)
+
+	if extraction.Status != "completed" || extraction.Attempts != 2 {
+		t.Fatalf("extraction %s after %d attempts, expected completed after 2", extraction.Status, extraction.Attempts)
+	}
+	if len(llm.requests) != 2 {
+		t.Fatalf("%d requests, expected 2", len(llm.requests))
+	}
+
+	retry := llm.requests[1].Messages
+	if len(retry) != 4 || retry[2].Role != "assistant" || retry[2].Content != `{"total": "120.50"}` {
+		t.Fatalf("retry messages %+v", retry)
+	}
+	if !strings.Contains(retry[3].Content, "$.total: expected number, got string") {
+		t.Errorf("retry message %q does not contain validation error", retry[3].Content)
+	}
+	if countEvents(events, "extraction_retry") != 1 {
+		t.Errorf("%d retry events, expected 1", countEvents(events, "extraction_retry"))
+	}
+}
+
+// TestExtractRejected ответ, не прошедший проверку за extraction.attempts попыток, не сохраняется
+func TestExtractRejected(t *testing.T) {
+	llm := replies(`{"sum": 120.5}`, `not json`, `{"total": null}`)
+	extraction, events := runExtraction(t, llm, nil)
+
+	if extraction.Status != "failed" || extraction.Result != nil {
+		t.Fatalf("extraction %s with result %s, expected failed without result", extraction.Status, extraction.Result)
+	}
+	if extraction.Attempts != 3 || len(llm.requests) != 3 {
+		t.Errorf("%d attempts, %d requests, expected 3", extraction.Attempts, len(llm.requests))
+	}
+	if !strings.Contains(extraction.Error, "reply does not match schema") || !strings.Contains(extraction.Error, "$.total: expected number, got null") {
+		t.Errorf("error %q", extraction.Error)
+	}
+	if countEvents(events, "extraction_retry") != 2 {
+		t.Errorf("%d retry events, expected 2", countEvents(events, "extraction_retry"))
+	}
+}
+
+// TestExtractRestoredPlaceholder заменитель персональных данных восстанавливается до проверки формата
+func TestExtractRestoredPlaceholder(t *testing.T) {
+	llm := replies(`{"total": 120.5, "email": "[EMAIL_1]"}`)
+	extraction, _ := runExtraction(t, llm, map[string]string{"[EMAIL_1]": "ivan@example.com"})
+
+	if extraction.Status != "completed" || extraction.Attempts != 1 {
+		t.Fatalf("extraction %s after %d attempts, %s", extraction.Status, extraction.Attempts, extraction.Error)
+	}
+	if string(extraction.Result) != `{"email":"ivan@example.com","total":120.5}` {
+		t.Errorf("result %s", extraction.Result)
+	}
+}
+
+func TestExtractProviderError(t *testing.T) {
+	llm := replies("")
+	extraction, _ := runExtraction(t, llm, nil)
+
+	if extraction.Status != "failed" || extraction.Error != "provider error" {
+		t.Fatalf("extraction %s with error %q", extraction.Status, extraction.Error)
+	}
+	if len(llm.requests) != 1 {
+		t.Errorf("%d requests, provider errors are not retried by extraction", len(llm.requests))
+	}
+}
@@ -0,0 +1,247 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"github.com/gurkankaymak/hocon"
+	"os"
+	fileutils "sse-demo-core/internal/app/endpoint/files/utils"
+	"sse-demo-core/internal/app/interfaces/services"
+	"sse-demo-core/internal/app/structs"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	_, _ = logdoc.Init("udp", "127.0.0.1:9", "analysis-test")
+	os.Exit(m.Run())
+}
+
+// Фейковые сервисы реализуют только методы, которые вызывает проверяемый код, остальные методы интерфейсов паникуют
+
+type fakePrompts struct {
+	services.PromptService
+	prompts map[int]*structs.Prompt
+}
+
+func (f *fakePrompts) FindStagePrompt(stage int, language string) (*structs.Prompt, error) {
+	if prompt, ok := f.prompts[stage]; ok {
+		return prompt, nil
+	}
+	return nil, errors.New("prompt not found")
+}
+
+// fakeResults хранилище результатов анализа, конвейеров и извлечений в памяти
+type fakeResults struct {
+	services.AnalysisService
+
+	mu          sync.Mutex
+	sequence    int
+	results     []structs.AnalysisResult
+	steps       []int
+	completed   *structs.Pipeline
+	failed      error
+	extractions []structs.Extraction
+}
+
+func (f *fakeResults) StartAnalysis(result *structs.AnalysisResult) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.sequence++
+	result.ID = f.sequence
+	return result.ID, nil
+}
+
+func (f *fakeResults) CompleteAnalysis(result *structs.AnalysisResult) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	result.Status = "completed"
+	f.results = append(f.results, *result)
+	return nil
+}
+
+func (f *fakeResults) FailAnalysis(result *structs.AnalysisResult, cause error) error {
+	return nil
+}
+
+func (f *fakeResults) FindPipelineResults(pipelineID int, completed bool) ([]structs.AnalysisResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var results []structs.AnalysisResult
+	for _, r := range f.results {
+		if r.PipelineID == pipelineID && (!completed || r.Status == "completed") {
+			results = append(results, r)
+		}
+	}
+	return results, nil
+}
+
+func (f *fakeResults) CompletePipelineStep(pipeline *structs.Pipeline, step int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	pipeline.Step = step + 1
+	f.steps = append(f.steps, step)
+	return nil
+}
+
+func (f *fakeResults) CompletePipeline(pipeline *structs.Pipeline) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	pipeline.Status = "completed"
+	f.completed = pipeline
+	return nil
+}
+
+func (f *fakeResults) FailPipeline(pipeline *structs.Pipeline, cause error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	pipeline.Status = "failed"
+	f.failed = cause
+	return nil
+}
+
+func (f *fakeResults) StartExtraction(extraction *structs.Extraction) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.sequence++
+	extraction.ID = f.sequence
+	extraction.Status = "running"
+	return extraction.ID, nil
+}
+
+func (f *fakeResults) CompleteExtraction(extraction *structs.Extraction) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	extraction.Status = "completed"
+	f.extractions = append(f.extractions, *extraction)
+	return nil
+}
+
+func (f *fakeResults) FailExtraction(extraction *structs.Extraction, cause error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	extraction.Status = "failed"
+	extraction.Error = cause.Error()
+	f.extractions = append(f.extractions, *extraction)
+	return nil
+}
+
+// fakeRedactions восстанавливает заменители слоев из values, результаты конвейеров возвращает как есть
+type fakeRedactions struct {
+	services.RedactionService
+	values map[string]string
+}
+
+func (f *fakeRedactions) Restore(guid string, userID int, text string) (string, error) {
+	return text, nil
+}
+
+func (f *fakeRedactions) RestoreLayer(guid string, uid string, userID int, text string) (string, error) {
+	for placeholder, value := range f.values {
+		text = strings.ReplaceAll(text, placeholder, value)
+	}
+	return text, nil
+}
+
+type fakeUsage struct {
+	services.UsageService
+}
+
+func (f *fakeUsage) CheckQuota(user *structs.User, job string, size int64) (*structs.QuotaExceededDetails, error) {
+	return nil, nil
+}
+
+func (f *fakeUsage) RecordLLM(userID int, guid string, usage *structs.Usage) error {
+	return nil
+}
+
+// fakeLLM отвечает результатом reply и запоминает запросы
+type fakeLLM struct {
+	services.LLMProvider
+
+	mu       sync.Mutex
+	requests []structs.LLMRequest
+	reply    func(req structs.LLMRequest) (string, error)
+}
+
+func (f *fakeLLM) Name() string {
+	return "fake"
+}
+
+func (f *fakeLLM) Complete(ctx context.Context, req structs.LLMRequest) (*structs.LLMResponse, error) {
+	f.mu.Lock()
+	f.requests = append(f.requests, req)
+	f.mu.Unlock()
+
+	content, err := f.reply(req)
+	if err != nil {
+		return nil, err
+	}
+	// расход сообщаем сами, как провайдеры: подсчет токенов chunker требует словаря tiktoken
+	return &structs.LLMResponse{Provider: "fake", Model: "fake-model", Content: content, FinishReason: "stop",
+		Usage: structs.Usage{PromptToken: 10, CompletionTokens: 5, TotalTokens: 15}}, nil
+}
+
+// lastMessage содержимое последнего сообщения запроса
+func lastMessage(req structs.LLMRequest) string {
+	return req.Messages[len(req.Messages)-1].Content
+}
+
+// testEndpoint endpoint с фейковыми сервисами и конфигом conf
+func testEndpoint(t *testing.T, conf string, llm *fakeLLM, prompts map[int]*structs.Prompt) (*Endpoint, *fakeResults) {
+	t.Helper()
+
+	config, err := hocon.ParseString(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := &fakeResults{}
+	e := &Endpoint{config: config, prompts: &fakePrompts{prompts: prompts}, redactions: &fakeRedactions{}, results: results,
+		llm: llm, accounting: &fakeUsage{}, connections: fileutils.NewConnections()}
+	return e, results
+}
+
+// subscribe читает события guid до события, завершающего задачу
+func subscribe(t *testing.T, e *Endpoint, guid string, userID int, final ...string) <-chan []structs.Notification {
+	t.Helper()
+
+	if err := e.connections.Open(guid, userID); err != nil {
+		t.Fatal(err)
+	}
+	stream := e.connections.Subscribe(guid, userID)
+
+	done := make(chan []structs.Notification, 1)
+	go func() {
+		var events []structs.Notification
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case n := <-stream:
+				events = append(events, n)
+				for _, state := range final {
+					if n.State == state {
+						done <- events
+						return
+					}
+				}
+			case <-timeout:
+				done <- events
+				return
+			}
+		}
+	}()
+	return done
+}
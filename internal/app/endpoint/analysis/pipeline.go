@@ -0,0 +1,555 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"github.com/gurkankaymak/hocon"
+	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
+	"net/http"
+	fileutils "sse-demo-core/internal/app/endpoint/files/utils"
+	"sse-demo-core/internal/app/prompttemplate"
+	"sse-demo-core/internal/app/structs"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pipelineRun состояние выполнения конвейера
+type pipelineRun struct {
+	user     *structs.User
+	pipeline *structs.Pipeline
+	layers   []structs.UserLayer
+	// saved результаты, сохраненные до перезапуска конвейера, по этапу и uuid слоя
+	saved map[string]structs.AnalysisResult
+	send  func(state string, details any) error
+}
+
+// StartPipelineHandler ставит в очередь конвейер анализа загрузки: этапы выполняются по порядку, результат этапа уходит
+// на вход следующему. Ход конвейера уходит подписчикам /sse?guid=, результаты этапов сохраняются,
+// прерванный конвейер продолжается с последнего завершенного этапа через ResumePipelineHandler
+func (e *Endpoint) StartPipelineHandler(ctx echo.Context) error {
+	logger := logdoc.GetLogger()
+	logger.Info(">> StartPipelineHandler started..")
+
+	user, httpErr := e.user(ctx)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	guid := ctx.Param("guid")
+
+	var req structs.PipelineRequest
+	if err := ctx.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, structs.ErrorResponse{Error: "invalid request body"})
+	}
+	name := req.Name
+	if name == "" {
+		name = e.config.GetString("pipeline.default")
+	}
+
+	stages, err := pipelineStages(e.config, name)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, structs.ErrorResponse{Error: err.Error()})
+	}
+
+	layers, httpErr := e.uploadLayers(guid, user.ID)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	// промпты всех этапов проверяем до запуска, чтобы конвейер не падал на середине
+	lang := prompttemplate.FromLayers(layers).Language
+	for _, stage := range stages {
+		if _, err = e.prompts.FindStagePrompt(stage.Stage, lang); err != nil {
+			logger.Error(">> StartPipelineHandler > prompt of stage ", stage.Stage, " not found, ", err)
+			return echo.NewHTTPError(http.StatusNotFound, structs.ErrorResponse{Error: fmt.Sprintf("prompt of stage %d not found", stage.Stage)})
+		}
+	}
+
+	data, _ := json.Marshal(stages)
+	pipeline := structs.Pipeline{
+		GUID:     guid,
+		UUID:     uuid.NewV4().String(),
+		UserID:   user.ID,
+		Name:     name,
+		Stages:   data,
+		Language: lang,
+	}
+	if _, err = e.results.StartPipeline(&pipeline); err != nil {
+		logger.Error(">> StartPipelineHandler > error saving pipeline, ", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, structs.ErrorResponse{Error: "error starting pipeline"})
+	}
+
+	return e.submitPipeline(ctx, user, &pipeline, layers)
+}
+
+// ResumePipelineHandler продолжает конвейер, завершившийся ошибкой или прерванный падением процесса,
+// с первого незавершенного этапа. Сохраненные результаты этапов повторно не запрашиваются у модели
+func (e *Endpoint) ResumePipelineHandler(ctx echo.Context) error {
+	logger := logdoc.GetLogger()
+	logger.Info(">> ResumePipelineHandler started..")
+
+	user, pipeline, httpErr := e.pipeline(ctx)
+	if httpErr != nil {
+		return httpErr
+	}
+	if pipeline.Status == "completed" {
+		return echo.NewHTTPError(http.StatusConflict, structs.ErrorResponse{Error: "pipeline already completed"})
+	}
+
+	layers, httpErr := e.uploadLayers(pipeline.GUID, user.ID)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	// выполняющийся конвейер обновляется после каждого этапа, не обновлявшийся дольше таймаута - прерван
+	resumed, err := e.results.ResumePipeline(pipeline, e.config.GetInt("pipeline.timeout"))
+	if err != nil {
+		logger.Error(">> ResumePipelineHandler > error resuming pipeline ", pipeline.ID, ", ", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, structs.ErrorResponse{Error: "error resuming pipeline"})
+	}
+	if !resumed {
+		return echo.NewHTTPError(http.StatusConflict, structs.ErrorResponse{Error: "pipeline is running"})
+	}
+
+	return e.submitPipeline(ctx, user, pipeline, layers)
+}
+
+// PipelinesHandler конвейеры загрузки с результатами этапов
+func (e *Endpoint) PipelinesHandler(ctx echo.Context) error {
+	logger := logdoc.GetLogger()
+
+	user, httpErr := e.user(ctx)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	pipelines, err := e.results.FindUploadPipelines(ctx.Param("guid"), user.ID)
+	if err != nil {
+		logger.Error(">> PipelinesHandler > error reading pipelines, ", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, structs.ErrorResponse{Error: "error reading pipelines"})
+	}
+	if pipelines == nil {
+		pipelines = []structs.Pipeline{}
+	}
+
+	for i := range pipelines {
+		if err = e.pipelineResults(&pipelines[i]); err != nil {
+			logger.Error(">> PipelinesHandler > error reading results of pipeline ", pipelines[i].ID, ", ", err)
+			return echo.NewHTTPError(http.StatusInternalServerError, structs.ErrorResponse{Error: "error reading pipelines"})
+		}
+	}
+
+	return ctx.JSON(http.StatusOK, pipelines)
+}
+
+func (e *Endpoint) PipelineHandler(ctx echo.Context) error {
+	logger := logdoc.GetLogger()
+
+	_, pipeline, httpErr := e.pipeline(ctx)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	if err := e.pipelineResults(pipeline); err != nil {
+		logger.Error(">> PipelineHandler > error reading results of pipeline ", pipeline.ID, ", ", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, structs.ErrorResponse{Error: "error reading pipeline"})
+	}
+
+	return ctx.JSON(http.StatusOK, pipeline)
+}
+
+func (e *Endpoint) submitPipeline(ctx echo.Context, user *structs.User, pipeline *structs.Pipeline, layers []structs.UserLayer) error {
+	position, err := e.queuePipeline(bypassCache(ctx), user, pipeline, layers)
+	if errors.Is(err, fileutils.ErrForeignStream) {
+		return echo.NewHTTPError(http.StatusConflict, structs.ErrorResponse{Error: err.Error()})
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusServiceUnavailable, structs.ErrorResponse{Error: "analysis queue is not available"})
+	}
+
+	return ctx.JSON(http.StatusAccepted, structs.PipelineStartedResponse{GUID: pipeline.GUID, UUID: pipeline.UUID,
+		PipelineID: pipeline.ID, Step: pipeline.Step, Position: position})
+}
+
+// queuePipeline ставит конвейер в очередь анализа, конвейер, который не удалось поставить, завершается ошибкой
+func (e *Endpoint) queuePipeline(bypass bool, user *structs.User, pipeline *structs.Pipeline, layers []structs.UserLayer) (int, error) {
+	logger := logdoc.GetLogger()
+
+	// канал создаем до ответа, чтобы клиент сразу мог подписаться на /sse
+	if err := e.connections.Open(pipeline.GUID, user.ID); err != nil {
+		logger.Error(">> error queueing pipeline ", pipeline.ID, ", ", err)
+		_ = e.results.FailPipeline(pipeline, err)
+		return 0, err
+	}
+
+	// таймаут конвейера включает ожидание в очереди
+	c, cancel := context.WithTimeout(analysisContext(bypass), time.Duration(e.config.GetInt("pipeline.timeout"))*time.Second)
+
+	position, err := e.pool.Submit(c, user.ID, func(c context.Context) {
+		defer cancel()
+		e.runPipeline(c, user, pipeline, layers)
+	})
+	if err != nil {
+		cancel()
+		e.connections.Release(pipeline.GUID)
+		logger.Error(">> error queueing pipeline ", pipeline.ID, ", ", err)
+		_ = e.results.FailPipeline(pipeline, err)
+		return 0, err
+	}
+
+	logger.Info(">> queued pipeline ", pipeline.ID, " ", pipeline.Name, " with guid:", pipeline.GUID, ", userId:", user.ID,
+		", step:", pipeline.Step, ", position:", position)
+
+	return position, nil
+}
+
+// ResumeInterrupted продолжает конвейеры, прерванные остановкой или падением процесса: при запуске и затем каждые
+// pipeline.resume_interval секунд ищет конвейеры running, не обновлявшиеся дольше pipeline.timeout
+func (e *Endpoint) ResumeInterrupted() {
+	interval := time.Duration(e.config.GetInt("pipeline.resume_interval")) * time.Second
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		e.resumeInterrupted()
+		<-ticker.C
+	}
+}
+
+func (e *Endpoint) resumeInterrupted() {
+	logger := logdoc.GetLogger()
+
+	stale := e.config.GetInt("pipeline.timeout")
+	pipelines, err := e.results.FindInterruptedPipelines(stale)
+	if err != nil {
+		logger.Error(">> error reading interrupted pipelines, ", err)
+		return
+	}
+
+	for i := range pipelines {
+		pipeline := &pipelines[i]
+
+		// конвейер мог продолжить другой процесс или пользователь
+		resumed, err := e.results.ResumePipeline(pipeline, stale)
+		if err != nil {
+			logger.Error(">> error resuming pipeline ", pipeline.ID, ", ", err)
+			continue
+		}
+		if !resumed {
+			continue
+		}
+
+		user, err := e.users.FindUserById(pipeline.UserID)
+		if err != nil {
+			logger.Error(">> error resuming pipeline ", pipeline.ID, ", user not found, ", err)
+			_ = e.results.FailPipeline(pipeline, errors.New("user not found"))
+			continue
+		}
+		layers, err := e.layers.FindUploadLayers(pipeline.GUID, pipeline.UserID)
+		if err != nil || len(layers) == 0 {
+			logger.Error(">> error resuming pipeline ", pipeline.ID, ", upload layers not found, ", err)
+			_ = e.results.FailPipeline(pipeline, errors.New("upload not found"))
+			continue
+		}
+
+		logger.Info(">> resuming interrupted pipeline ", pipeline.ID, " with guid:", pipeline.GUID, " from step ", pipeline.Step)
+		_, _ = e.queuePipeline(false, user, pipeline, layers)
+	}
+}
+
+func (e *Endpoint) runPipeline(c context.Context, user *structs.User, pipeline *structs.Pipeline, layers []structs.UserLayer) {
+	logger := logdoc.GetLogger()
+
+	// события доставляются не дольше таймаута конвейера и не держат слот очереди анализа после его завершения
+	dc, dcancel := context.WithTimeout(context.Background(), time.Duration(e.config.GetInt("pipeline.timeout"))*time.Second)
+	forwarder := fileutils.NewConnectionsForwarder(dc, pipeline.GUID, e.connections)
+	defer func() {
+		go func() {
+			forwarder.Close()
+			dcancel()
+		}()
+	}()
+
+	r := &pipelineRun{user: user, pipeline: pipeline, layers: layers, saved: make(map[string]structs.AnalysisResult)}
+	r.send = func(state string, details any) error {
+		forwarder.Send(structs.Notification{GUID: pipeline.GUID, UUID: pipeline.UUID, State: state, Details: details})
+		return nil
+	}
+
+	fail := func(step int, err error) {
+		logger.Error(">> pipeline ", pipeline.ID, " with guid:", pipeline.GUID, " failed at step ", step, ", ", err)
+		if err := e.results.FailPipeline(pipeline, err); err != nil {
+			logger.Error(">> error saving failed pipeline ", pipeline.ID, ", ", err)
+		}
+		_ = r.send("pipeline_failed", structs.PipelineDetails{PipelineID: pipeline.ID, Step: step, Message: err.Error()})
+	}
+
+	// конвейер отменен по таймауту, пока ждал в очереди
+	if c.Err() != nil {
+		fail(pipeline.Step, errors.New("pipeline cancelled while queued"))
+		return
+	}
+
+	if e.quotaExceeded(r.send, user) {
+		if err := e.results.FailPipeline(pipeline, errors.New("quota exceeded")); err != nil {
+			logger.Error(">> error saving failed pipeline ", pipeline.ID, ", ", err)
+		}
+		return
+	}
+
+	var stages []structs.PipelineStage
+	if err := json.Unmarshal(pipeline.Stages, &stages); err != nil {
+		fail(pipeline.Step, fmt.Errorf("invalid pipeline stages, %w", err))
+		return
+	}
+
+	saved, err := e.results.FindPipelineResults(pipeline.ID, true)
+	if err != nil {
+		fail(pipeline.Step, err)
+		return
+	}
+	for _, result := range saved {
+		r.saved[stepKey(result.PipelineStep, result.LayerUUID)] = result
+	}
+
+	logger.Info(">> pipeline ", pipeline.ID, " with guid:", pipeline.GUID, " started at step ", pipeline.Step, ", saved results: ", len(saved))
+	_ = r.send("pipeline_started", structs.PipelineDetails{PipelineID: pipeline.ID, Step: pipeline.Step})
+
+	// результаты предыдущего этапа: этапа per_file - по uuid слоя, остальных - под пустым ключом
+	var outputs map[string]string
+	for step, stage := range stages {
+		_ = r.send("pipeline_stage_started", structs.PipelineDetails{PipelineID: pipeline.ID, Step: step, Stage: stage.Stage, PerFile: stage.PerFile})
+
+		outputs, err = e.runStage(c, r, step, stage, outputs)
+		if err != nil {
+			fail(step, err)
+			return
+		}
+
+		if step >= pipeline.Step {
+			if err = e.results.CompletePipelineStep(pipeline, step); err != nil {
+				fail(step, err)
+				return
+			}
+		}
+		_ = r.send("pipeline_stage_completed", structs.PipelineDetails{PipelineID: pipeline.ID, Step: step, Stage: stage.Stage, PerFile: stage.PerFile})
+	}
+
+	pipeline.Result = e.restore(pipeline, joinOutputs(layers, outputs))
+	if err = e.results.CompletePipeline(pipeline); err != nil {
+		logger.Error(">> error saving pipeline ", pipeline.ID, ", ", err)
+	}
+
+	logger.Info(">> pipeline ", pipeline.ID, " with guid:", pipeline.GUID, " completed")
+
+	_ = r.send("pipeline_completed", structs.PipelineDetails{PipelineID: pipeline.ID, Step: len(stages), Result: pipeline.Result})
+}
+
+// runStage выполняет этап step: этап per_file - для каждого слоя загрузки, входом служит результат предыдущего этапа
+// для этого же слоя, либо содержимое слоя. Остальные этапы выполняются один раз на общем результате предыдущего этапа
+func (e *Endpoint) runStage(c context.Context, r *pipelineRun, step int, stage structs.PipelineStage, inputs map[string]string) (map[string]string, error) {
+	prompt, err := e.prompts.FindStagePrompt(stage.Stage, r.pipeline.Language)
+	if err != nil {
+		return nil, fmt.Errorf("prompt of stage %d not found", stage.Stage)
+	}
+
+	outputs := make(map[string]string)
+	if !stage.PerFile {
+		data := prompttemplate.FromLayers(r.layers)
+		if step > 0 {
+			data.Content = joinOutputs(r.layers, inputs)
+		}
+		outputs[""], err = e.runStep(c, r, step, prompt, nil, data)
+		return outputs, err
+	}
+
+	for i := range r.layers {
+		layer := &r.layers[i]
+		data := prompttemplate.FromLayers([]structs.UserLayer{*layer})
+		if step > 0 {
+			data.Content = inputs[layer.UUID]
+		}
+		if outputs[layer.UUID], err = e.runStep(c, r, step, prompt, layer, data); err != nil {
+			return nil, err
+		}
+	}
+	return outputs, nil
+}
+
+// runStep запрашивает у модели результат этапа для слоя layer, nil - для всей загрузки.
+// Результат сохраняется в том виде, в каком уходит в модель на следующем этапе, с заменителями персональных данных
+func (e *Endpoint) runStep(c context.Context, r *pipelineRun, step int, prompt *structs.Prompt, layer *structs.UserLayer,
+	data prompttemplate.Data) (string, error) {
+	logger := logdoc.GetLogger()
+
+	details := structs.PipelineDetails{PipelineID: r.pipeline.ID, Step: step, Stage: prompt.PromptStage, PerFile: layer != nil}
+	result := structs.AnalysisResult{
+		GUID:          r.pipeline.GUID,
+		UUID:          uuid.NewV4().String(),
+		UserID:        r.user.ID,
+		PromptID:      prompt.ID,
+		PromptStage:   prompt.PromptStage,
+		PromptVersion: prompt.Version,
+		Language:      r.pipeline.Language,
+		Provider:      e.llm.Name(),
+		PipelineID:    r.pipeline.ID,
+		PipelineStep:  step,
+	}
+	if layer != nil {
+		result.LayerUUID = layer.UUID
+		result.FileName = layer.LayerName
+		details.FileName = layer.LayerName
+	}
+
+	if saved, ok := r.saved[stepKey(step, result.LayerUUID)]; ok {
+		details.ResultID, details.Result, details.Resumed = saved.ID, e.restore(r.pipeline, saved.Result), true
+		_ = r.send("pipeline_stage_result", details)
+		return saved.Result, nil
+	}
+
+	messages, err := prompttemplate.Messages(prompt.PromptText, data)
+	if err != nil {
+		return "", err
+	}
+	req := structs.LLMRequest{Messages: messages}
+
+	if _, err = e.results.StartAnalysis(&result); err != nil {
+		return "", err
+	}
+
+	response, err := e.llm.Complete(c, req)
+	if err != nil {
+		if err := e.results.FailAnalysis(&result, err); err != nil {
+			logger.Error(">> error saving failed analysis uid:", result.UUID, ", ", err)
+		}
+		return "", err
+	}
+
+	usage := e.usage(req, response)
+	e.record(r.user.ID, r.pipeline.GUID, response, usage)
+
+	result.Model = response.Model
+	result.Result = response.Content
+	result.PromptTokens = usage.PromptToken
+	result.CompletionTokens = usage.CompletionTokens
+	if err = e.results.CompleteAnalysis(&result); err != nil {
+		return "", err
+	}
+
+	details.ResultID, details.Result = result.ID, e.restore(r.pipeline, response.Content)
+	_ = r.send("pipeline_stage_result", details)
+
+	return response.Content, nil
+}
+
+// pipelineResults результаты этапов конвейера с восстановленными персональными данными
+func (e *Endpoint) pipelineResults(pipeline *structs.Pipeline) error {
+	results, err := e.results.FindPipelineResults(pipeline.ID, false)
+	if err != nil {
+		return err
+	}
+	for i := range results {
+		results[i].Result = e.restore(pipeline, results[i].Result)
+	}
+	pipeline.Results = results
+	return nil
+}
+
+// restore возвращает в результат модели исходные персональные данные загрузки
+func (e *Endpoint) restore(pipeline *structs.Pipeline, content string) string {
+	logger := logdoc.GetLogger()
+
+	restored, err := e.redactions.Restore(pipeline.GUID, pipeline.UserID, content)
+	if err != nil {
+		logger.Warn(">> error restoring redactions of pipeline ", pipeline.ID, ", ", err)
+		return content
+	}
+	return restored
+}
+
+// pipeline конвейер из параметров пути guid и id
+func (e *Endpoint) pipeline(ctx echo.Context) (*structs.User, *structs.Pipeline, *echo.HTTPError) {
+	user, httpErr := e.user(ctx)
+	if httpErr != nil {
+		return nil, nil, httpErr
+	}
+
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		return nil, nil, echo.NewHTTPError(http.StatusBadRequest, structs.ErrorResponse{Error: "invalid pipeline id"})
+	}
+
+	pipeline, err := e.results.FindPipeline(id, ctx.Param("guid"), user.ID)
+	if err != nil {
+		return nil, nil, echo.NewHTTPError(http.StatusNotFound, structs.ErrorResponse{Error: "pipeline not found"})
+	}
+	return user, pipeline, nil
+}
+
+func (e *Endpoint) uploadLayers(guid string, userID int) ([]structs.UserLayer, *echo.HTTPError) {
+	logger := logdoc.GetLogger()
+
+	layers, err := e.layers.FindUploadLayers(guid, userID)
+	if err != nil {
+		logger.Error(">> error reading layers of upload guid:", guid, ", ", err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, structs.ErrorResponse{Error: "error reading upload"})
+	}
+	if len(layers) == 0 {
+		return nil, echo.NewHTTPError(http.StatusNotFound, structs.ErrorResponse{Error: "upload not found"})
+	}
+	return layers, nil
+}
+
+// pipelineStages этапы конвейера name из pipeline.pipelines. Этапы per_file идут первыми:
+// после объединения результатов файлов разделить их обратно по файлам нельзя
+func pipelineStages(config *hocon.Config, name string) ([]structs.PipelineStage, error) {
+	path := "pipeline.pipelines." + name
+	if name == "" || strings.Contains(name, ".") || config.Get(path) == nil {
+		return nil, fmt.Errorf("pipeline %s not found", name)
+	}
+
+	perFile := make(map[int]bool)
+	for _, stage := range config.GetIntSlice(path + ".per_file") {
+		perFile[stage] = true
+	}
+
+	var stages []structs.PipelineStage
+	for i, stage := range config.GetIntSlice(path + ".stages") {
+		if perFile[stage] && i > 0 && !stages[i-1].PerFile {
+			return nil, fmt.Errorf("pipeline %s: per_file stage %d follows merged stage", name, stage)
+		}
+		stages = append(stages, structs.PipelineStage{Stage: stage, PerFile: perFile[stage]})
+	}
+	if len(stages) == 0 {
+		return nil, fmt.Errorf("pipeline %s has no stages", name)
+	}
+	return stages, nil
+}
+
+// joinOutputs общий результат этапа: результаты этапа per_file объединяются под заголовками файлов
+func joinOutputs(layers []structs.UserLayer, outputs map[string]string) string {
+	if output, ok := outputs[""]; ok {
+		return output
+	}
+
+	var joined strings.Builder
+	for _, l := range layers {
+		if joined.Len() > 0 {
+			joined.WriteString("\n\n")
+		}
+		joined.WriteString("### " + l.LayerName + "\n\n" + outputs[l.UUID])
+	}
+	return joined.String()
+}
+
+func stepKey(step int, layerUUID string) string {
+	return strconv.Itoa(step) + "/" + layerUUID
+}
@@ -0,0 +1,247 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gurkankaymak/hocon"
+	"os"
+	"reflect"
+	"regexp"
+	"sse-demo-core/internal/app/prompttemplate"
+	"sse-demo-core/internal/app/structs"
+	"strconv"
+	"testing"
+)
+
+const pipelineConf = `
+pipeline {
+  timeout = 5
+  pipelines {
+    summary {
+      stages = [10, 11, 12]
+      per_file = [10]
+    }
+  }
+}`
+
+var pipelineLayers = []structs.UserLayer{
+	{UUID: "l1", LayerName: "a.txt", SourceData: "text A", Language: "en"},
+	{UUID: "l2", LayerName: "b.txt", SourceData: "text B", Language: "en"},
+}
+
+var pipelinePrompts = map[int]*structs.Prompt{
+	10: {ID: 1, PromptStage: 10, PromptText: "s10", Version: 1},
+	11: {ID: 2, PromptStage: 11, PromptText: "s11", Version: 1},
+	12: {ID: 3, PromptStage: 12, PromptText: "s12", Version: 1},
+}
+
+// stageReply ответ модели: системный промпт этапа и полученное содержимое
+func stageReply(prompt string, content string) string {
+	return prompt + "(" + content + ")"
+}
+
+func pipelineLLM() *fakeLLM {
+	return &fakeLLM{reply: func(req structs.LLMRequest) (string, error) {
+		return stageReply(req.Messages[0].Content, lastMessage(req)), nil
+	}}
+}
+
+func testPipeline(t *testing.T, e *Endpoint, step int) *structs.Pipeline {
+	t.Helper()
+
+	stages, err := pipelineStages(e.config, "summary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(stages)
+	return &structs.Pipeline{ID: 1, GUID: "guid-" + t.Name(), UUID: "uuid", UserID: 7, Name: "summary", Stages: data,
+		Language: "en", Step: step, Status: "running"}
+}
+
+// pipelineOutputs ожидаемые результаты этапов конвейера summary
+func pipelineOutputs() (files map[string]string, merged string, result string) {
+	files = map[string]string{
+		"l1": stageReply("s10", "### a.txt\n\ntext A"),
+		"l2": stageReply("s10", "### b.txt\n\ntext B"),
+	}
+	merged = stageReply("s11", joinOutputs(pipelineLayers, files))
+	return files, merged, stageReply("s12", merged)
+}
+
+func savedResult(step int, layerUUID string, result string, status string) structs.AnalysisResult {
+	return structs.AnalysisResult{PipelineID: 1, PipelineStep: step, LayerUUID: layerUUID, Result: result, Status: status}
+}
+
+func TestRunPipeline(t *testing.T) {
+	llm := pipelineLLM()
+	e, results := testEndpoint(t, pipelineConf, llm, pipelinePrompts)
+	pipeline := testPipeline(t, e, 0)
+	events := subscribe(t, e, pipeline.GUID, pipeline.UserID, "pipeline_completed", "pipeline_failed")
+
+	e.runPipeline(context.Background(), &structs.User{ID: 7}, pipeline, pipelineLayers)
+	<-events
+
+	_, _, result := pipelineOutputs()
+	if results.completed == nil || results.completed.Result != result {
+		t.Fatalf("pipeline result %+v, expected %q", results.completed, result)
+	}
+	if !reflect.DeepEqual(results.steps, []int{0, 1, 2}) {
+		t.Errorf("completed steps %v, expected [0 1 2]", results.steps)
+	}
+	if len(llm.requests) != 4 {
+		t.Errorf("%d model requests, expected 4", len(llm.requests))
+	}
+	if len(results.results) != 4 {
+		t.Errorf("%d saved results, expected 4", len(results.results))
+	}
+}
+
+// TestResumePipeline конвейер продолжается с сохраненных результатов: завершенные шаги не запрашиваются у модели повторно,
+// а их результаты становятся входом следующих этапов
+func TestResumePipeline(t *testing.T) {
+	files, merged, result := pipelineOutputs()
+
+	cases := []struct {
+		name     string
+		step     int
+		saved    []structs.AnalysisResult
+		requests int
+		steps    []int
+		resumed  int
+	}{
+		{
+			// процесс упал посреди этапа per_file: первый файл обработан, второй - нет
+			name:     "partially completed stage",
+			step:     0,
+			saved:    []structs.AnalysisResult{savedResult(0, "l1", files["l1"], "completed"), savedResult(0, "l2", "partial", "failed")},
+			requests: 3,
+			steps:    []int{0, 1, 2},
+			resumed:  1,
+		},
+		{
+			name:     "completed stages",
+			step:     2,
+			saved:    []structs.AnalysisResult{savedResult(0, "l1", files["l1"], "completed"), savedResult(0, "l2", files["l2"], "completed"), savedResult(1, "", merged, "completed")},
+			requests: 1,
+			steps:    []int{2},
+			resumed:  3,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			llm := pipelineLLM()
+			e, results := testEndpoint(t, pipelineConf, llm, pipelinePrompts)
+			results.results = c.saved
+			pipeline := testPipeline(t, e, c.step)
+			events := subscribe(t, e, pipeline.GUID, pipeline.UserID, "pipeline_completed", "pipeline_failed")
+
+			e.runPipeline(context.Background(), &structs.User{ID: 7}, pipeline, pipelineLayers)
+
+			resumed := 0
+			for _, n := range <-events {
+				if details, ok := n.Details.(structs.PipelineDetails); ok && n.State == "pipeline_stage_result" && details.Resumed {
+					resumed++
+				}
+			}
+
+			if results.completed == nil || results.completed.Result != result {
+				t.Fatalf("pipeline result %+v, expected %q", results.completed, result)
+			}
+			if len(llm.requests) != c.requests {
+				t.Errorf("%d model requests, expected %d", len(llm.requests), c.requests)
+			}
+			if !reflect.DeepEqual(results.steps, c.steps) {
+				t.Errorf("completed steps %v, expected %v", results.steps, c.steps)
+			}
+			if resumed != c.resumed {
+				t.Errorf("%d resumed stage results, expected %d", resumed, c.resumed)
+			}
+		})
+	}
+}
+
+func TestRunPipelineMissingPrompt(t *testing.T) {
+	e, results := testEndpoint(t, pipelineConf, pipelineLLM(), map[int]*structs.Prompt{10: pipelinePrompts[10]})
+	pipeline := testPipeline(t, e, 0)
+	events := subscribe(t, e, pipeline.GUID, pipeline.UserID, "pipeline_completed", "pipeline_failed")
+
+	e.runPipeline(context.Background(), &structs.User{ID: 7}, pipeline, pipelineLayers)
+	<-events
+
+	if results.failed == nil || results.completed != nil {
+		t.Fatalf("pipeline is not failed, error %v", results.failed)
+	}
+	// первый этап завершен и будет пропущен при повторном запуске
+	if !reflect.DeepEqual(results.steps, []int{0}) {
+		t.Errorf("completed steps %v, expected [0]", results.steps)
+	}
+}
+
+func TestPipelineStages(t *testing.T) {
+	config, err := hocon.ParseString(`pipeline.pipelines {
+  summary { stages = [10, 11, 12], per_file = [10] }
+  merged_first { stages = [11, 10], per_file = [10] }
+  empty { stages = [] }
+}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stages, err := pipelineStages(config, "summary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []structs.PipelineStage{{Stage: 10, PerFile: true}, {Stage: 11}, {Stage: 12}}
+	if !reflect.DeepEqual(stages, expected) {
+		t.Errorf("stages %v, expected %v", stages, expected)
+	}
+
+	for _, name := range []string{"merged_first", "empty", "missing", "", "summary.stages"} {
+		if _, err = pipelineStages(config, name); err == nil {
+			t.Errorf("pipeline %q is accepted", name)
+		}
+	}
+}
+
+// TestDefaultPrompts миграция создает промпты всех этапов конвейеров из conf/application.conf, и их шаблоны корректны
+func TestDefaultPrompts(t *testing.T) {
+	migration, err := os.ReadFile("../../../../migrations/11_pipelines.up.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := hocon.ParseResource("../../../../conf/application.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seeded := make(map[int]string)
+	for _, m := range regexp.MustCompile(`\((\d+), '([^']+)',\s*'([^']+)'\)`).FindAllStringSubmatch(string(migration), -1) {
+		stage, _ := strconv.Atoi(m[1])
+		seeded[stage] = m[3]
+	}
+	if len(seeded) == 0 {
+		t.Fatal("no prompts are seeded")
+	}
+
+	for name := range config.GetObject("pipeline.pipelines") {
+		stages, err := pipelineStages(config, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, stage := range stages {
+			text, ok := seeded[stage.Stage]
+			if !ok {
+				t.Errorf("prompt of stage %d of pipeline %s is not seeded", stage.Stage, name)
+				continue
+			}
+			if err = prompttemplate.Validate(text); err != nil {
+				t.Errorf("prompt of stage %d is invalid, %v", stage.Stage, err)
+			}
+		}
+	}
+
+	if _, err = pipelineStages(config, config.GetString("pipeline.default")); err != nil {
+		t.Errorf("default pipeline, %v", err)
+	}
+}
@@ -0,0 +1,190 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
+	"net/http"
+	"sse-demo-core/internal/app/prompttemplate"
+	"sse-demo-core/internal/app/redaction"
+	"sse-demo-core/internal/app/structs"
+	"time"
+)
+
+// UploadAnalysisHandler ставит в очередь анализ слоев загрузки промптом этапа из таблицы prompts.
+// Ход анализа уходит подписчикам /sse?guid=, результат сохраняется и доступен через UploadAnalysisResultsHandler
+func (e *Endpoint) UploadAnalysisHandler(ctx echo.Context) error {
+	logger := logdoc.GetLogger()
+	logger.Info(">> UploadAnalysisHandler started..")
+
+	user, httpErr := e.user(ctx)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	guid := ctx.Param("guid")
+
+	var req structs.UploadAnalysisRequest
+	if err := ctx.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, structs.ErrorResponse{Error: "invalid request body"})
+	}
+	stage := req.Stage
+	if stage == 0 {
+		stage = e.config.GetInt("analysis.stage")
+	}
+
+	layers, err := e.layers.FindUploadLayers(guid, user.ID)
+	if err != nil {
+		logger.Error(">> UploadAnalysisHandler > error reading upload layers, ", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, structs.ErrorResponse{Error: "error reading upload"})
+	}
+	if len(layers) == 0 {
+		return echo.NewHTTPError(http.StatusNotFound, structs.ErrorResponse{Error: "upload not found"})
+	}
+
+	data := prompttemplate.FromLayers(layers)
+	lang := data.Language
+	prompt, err := e.prompts.FindStagePrompt(stage, lang)
+	if err != nil {
+		logger.Error(">> UploadAnalysisHandler > prompt of stage ", stage, " not found, ", err)
+		return echo.NewHTTPError(http.StatusNotFound, structs.ErrorResponse{Error: "prompt not found"})
+	}
+
+	messages, err := prompttemplate.Messages(prompt.PromptText, data)
+	if err != nil {
+		logger.Error(">> UploadAnalysisHandler > prompt ", prompt.ID, " of stage ", stage, ", ", err)
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, structs.ErrorResponse{Error: err.Error()})
+	}
+
+	// канал создаем до ответа, чтобы клиент сразу мог подписаться на /sse
+	if err := e.connections.Open(guid, user.ID); err != nil {
+		logger.Error(">> UploadAnalysisHandler > guid:", guid, " rejected, ", err)
+		return echo.NewHTTPError(http.StatusConflict, structs.ErrorResponse{Error: err.Error()})
+	}
+
+	result := structs.AnalysisResult{
+		GUID:          guid,
+		UUID:          uuid.NewV4().String(),
+		UserID:        user.ID,
+		PromptID:      prompt.ID,
+		PromptStage:   stage,
+		PromptVersion: prompt.Version,
+		Language:      lang,
+		Provider:      e.llm.Name(),
+	}
+	if _, err = e.results.StartAnalysis(&result); err != nil {
+		logger.Error(">> UploadAnalysisHandler > error saving analysis, ", err)
+		e.connections.Release(guid)
+		return echo.NewHTTPError(http.StatusInternalServerError, structs.ErrorResponse{Error: "error starting analysis"})
+	}
+
+	// таймаут анализа включает ожидание в очереди
+	c, cancel := context.WithTimeout(analysisContext(bypassCache(ctx)), time.Duration(e.config.GetInt("analysis.timeout"))*time.Second)
+
+	request := structs.LLMRequest{Messages: messages}
+
+	position, err := e.pool.Submit(c, user.ID, func(c context.Context) {
+		defer cancel()
+		e.analyzeUpload(c, user, &result, request)
+	})
+	if err != nil {
+		cancel()
+		e.connections.Release(guid)
+		logger.Error(">> UploadAnalysisHandler > error queueing analysis, ", err)
+		_ = e.results.FailAnalysis(&result, err)
+		return echo.NewHTTPError(http.StatusServiceUnavailable, structs.ErrorResponse{Error: "analysis queue is not available"})
+	}
+
+	logger.Info(">> queued analysis uid:", result.UUID, " with guid:", guid, ", userId:", user.ID, ", stage:", stage,
+		", language:", lang, ", position:", position)
+
+	return ctx.JSON(http.StatusAccepted, structs.AnalysisStartedResponse{GUID: guid, UUID: result.UUID, ResultID: result.ID, Position: position})
+}
+
+// UploadAnalysisResultsHandler сохраненные результаты анализов загрузки
+func (e *Endpoint) UploadAnalysisResultsHandler(ctx echo.Context) error {
+	logger := logdoc.GetLogger()
+
+	user, httpErr := e.user(ctx)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	results, err := e.results.FindUploadResults(ctx.Param("guid"), user.ID)
+	if err != nil {
+		logger.Error(">> UploadAnalysisResultsHandler > error reading analysis results, ", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, structs.ErrorResponse{Error: "error reading analysis results"})
+	}
+	if results == nil {
+		results = []structs.AnalysisResult{}
+	}
+
+	return ctx.JSON(http.StatusOK, results)
+}
+
+func (e *Endpoint) analyzeUpload(c context.Context, user *structs.User, result *structs.AnalysisResult, req structs.LLMRequest) {
+	logger := logdoc.GetLogger()
+
+	send, closeSender := e.sender(result.GUID, result.UUID)
+	defer closeSender()
+
+	fail := func(err error) {
+		logger.Error(">> analysis uid:", result.UUID, " with guid:", result.GUID, " error, ", err)
+		if err := e.results.FailAnalysis(result, err); err != nil {
+			logger.Error(">> error saving failed analysis uid:", result.UUID, ", ", err)
+		}
+		_ = send("analysis_error", analysisError(err))
+	}
+
+	// анализ отменен по таймауту, пока ждал в очереди
+	if c.Err() != nil {
+		fail(errors.New("analysis cancelled while queued"))
+		return
+	}
+
+	// квота проверяется при запуске, а не при постановке в очередь
+	if e.quotaExceeded(send, user) {
+		if err := e.results.FailAnalysis(result, errors.New("quota exceeded")); err != nil {
+			logger.Error(">> error saving failed analysis uid:", result.UUID, ", ", err)
+		}
+		return
+	}
+
+	// в модель уходил очищенный текст, в ответе и фрагментах analysis_delta возвращаем исходные персональные данные
+	tokens, err := e.redactions.FindTokens(result.GUID, "", result.UserID)
+	if err != nil {
+		logger.Warn(">> error restoring redactions of analysis uid:", result.UUID, ", ", err)
+	}
+	restore := func(text string) string {
+		return redaction.Restore(text, tokens)
+	}
+
+	response, usage, err := e.run(c, send, user.ID, result.GUID, req, restore)
+	if err != nil {
+		fail(err)
+		return
+	}
+	content := restore(response.Content)
+
+	result.Model = response.Model
+	result.Result = content
+	result.PromptTokens = usage.PromptToken
+	result.CompletionTokens = usage.CompletionTokens
+	if err = e.results.CompleteAnalysis(result); err != nil {
+		logger.Error(">> error saving analysis uid:", result.UUID, ", ", err)
+	}
+
+	logger.Info(">> analysis uid:", result.UUID, " with guid:", result.GUID, " completed, tokens: ", usage.TotalTokens)
+
+	_ = send("analysis_completed", structs.AnalysisCompletedDetails{
+		Provider:     response.Provider,
+		Model:        response.Model,
+		FinishReason: response.FinishReason,
+		Usage:        usage,
+		Cached:       response.Cached,
+		ResultID:     result.ID,
+		Result:       content,
+	})
+}
@@ -0,0 +1,358 @@
+package assistants
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"github.com/golang-jwt/jwt"
+	"github.com/gurkankaymak/hocon"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	fileutils "sse-demo-core/internal/app/endpoint/files/utils"
+	"sse-demo-core/internal/app/interfaces/services"
+	"sse-demo-core/internal/app/structs"
+	"sse-demo-core/internal/app/utils"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Endpoint struct {
+	config      *hocon.Config
+	users       services.UserService
+	layers      services.LayerService
+	threads     services.ThreadService
+	assistants  services.Assistants
+	accounting  services.UsageService
+	connections *fileutils.Connections
+}
+
+func New(config *hocon.Config, userSvc services.UserService, layerSvc services.LayerService, threadSvc services.ThreadService,
+	assistants services.Assistants, usageSvc services.UsageService, connections *fileutils.Connections) *Endpoint {
+	return &Endpoint{config: config, users: userSvc, layers: layerSvc, threads: threadSvc, assistants: assistants,
+		accounting: usageSvc, connections: connections}
+}
+
+// FilesHandler загружает обработанное содержимое слоев загрузки в файлы ассистентов.
+// Уже загруженные слои повторно не отправляются
+func (e *Endpoint) FilesHandler(ctx echo.Context) error {
+	logger := logdoc.GetLogger()
+	logger.Info(">> Assistants FilesHandler started..")
+
+	user, httpErr := e.user(ctx)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	var req structs.AssistantsFilesRequest
+	if err := ctx.Bind(&req); err != nil || req.GUID == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, structs.ErrorResponse{Error: "upload guid required"})
+	}
+
+	layers, httpErr := e.uploadLayers(req.GUID, user.ID)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	files, err := e.uploadFiles(ctx.Request().Context(), user.ID, layers, req.AssistantID)
+	if err != nil {
+		logger.Error(">> FilesHandler > error uploading files of upload guid:", req.GUID, ", ", err)
+		return providerError(err)
+	}
+
+	return ctx.JSON(http.StatusOK, files)
+}
+
+// CreateThreadHandler создает тред ассистента, файлы загрузки guid прикладываются к первому сообщению треда
+func (e *Endpoint) CreateThreadHandler(ctx echo.Context) error {
+	logger := logdoc.GetLogger()
+	logger.Info(">> CreateThreadHandler started..")
+
+	user, httpErr := e.user(ctx)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	var req structs.CreateThreadRequest
+	if err := ctx.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, structs.ErrorResponse{Error: "invalid request body"})
+	}
+
+	var files []structs.ThreadFile
+	if req.GUID != "" {
+		layers, httpErr := e.uploadLayers(req.GUID, user.ID)
+		if httpErr != nil {
+			return httpErr
+		}
+
+		var err error
+		if files, err = e.uploadFiles(ctx.Request().Context(), user.ID, layers, ""); err != nil {
+			logger.Error(">> CreateThreadHandler > error uploading files of upload guid:", req.GUID, ", ", err)
+			return providerError(err)
+		}
+	}
+
+	created, err := e.assistants.CreateThread(ctx.Request().Context())
+	if err != nil {
+		logger.Error(">> CreateThreadHandler > error creating thread, ", err)
+		return providerError(err)
+	}
+
+	metadata := []byte("{}")
+	if created.Metadata != nil {
+		metadata, _ = json.Marshal(created.Metadata)
+	}
+	thread := structs.Thread{
+		UserID:     user.ID,
+		ThreadName: req.Name,
+		ThreadID:   created.ID,
+		Object:     created.Object,
+		GUID:       req.GUID,
+		CreatedAt:  time.Unix(int64(created.CreatedAt), 0),
+		Metadata:   metadata,
+	}
+	for i := range files {
+		files[i].ThreadID = thread.ThreadID
+	}
+	if _, err = e.threads.CreateThread(&thread, files); err != nil {
+		logger.Error(">> CreateThreadHandler > error saving thread ", thread.ThreadID, ", ", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, structs.ErrorResponse{Error: "error saving thread"})
+	}
+
+	logger.Info(">> thread ", thread.ThreadID, " created, userId:", user.ID, ", files: ", len(files))
+
+	return ctx.JSON(http.StatusCreated, thread)
+}
+
+func (e *Endpoint) ThreadsHandler(ctx echo.Context) error {
+	logger := logdoc.GetLogger()
+
+	user, httpErr := e.user(ctx)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	threads, err := e.threads.FindUserThreads(user.ID)
+	if err != nil {
+		logger.Error(">> ThreadsHandler > error reading threads, ", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, structs.ErrorResponse{Error: "error reading threads"})
+	}
+
+	return ctx.JSON(http.StatusOK, utils.Ternary(threads == nil, []structs.Thread{}, threads))
+}
+
+// CreateMessageHandler отправляет сообщение пользователя в тред и сохраняет его
+func (e *Endpoint) CreateMessageHandler(ctx echo.Context) error {
+	logger := logdoc.GetLogger()
+	logger.Info(">> CreateMessageHandler started..")
+
+	user, thread, httpErr := e.thread(ctx)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	var req structs.ThreadMessageRequest
+	if err := ctx.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, structs.ErrorResponse{Error: "invalid request body"})
+	}
+	if strings.TrimSpace(req.Content) == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, structs.ErrorResponse{Error: "empty message"})
+	}
+	// Assistants API принимает в тред только сообщения пользователя
+	req.Role = "user"
+
+	// файлы загрузки треда уходят с первым сообщением
+	if len(req.FileIDS) == 0 && thread.Messages == 0 {
+		files, err := e.threads.FindThreadFiles(thread.ThreadID, user.ID)
+		if err != nil {
+			logger.Error(">> CreateMessageHandler > error reading thread files, ", err)
+			return echo.NewHTTPError(http.StatusInternalServerError, structs.ErrorResponse{Error: "error reading thread files"})
+		}
+		for _, file := range files {
+			req.FileIDS = append(req.FileIDS, file.FileID)
+		}
+	}
+
+	created, err := e.assistants.CreateMessage(ctx.Request().Context(), thread.ThreadID, req)
+	if err != nil {
+		logger.Error(">> CreateMessageHandler > error posting message to thread ", thread.ThreadID, ", ", err)
+		return providerError(err)
+	}
+
+	message := e.threadMessage(user.ID, created)
+	if err = e.threads.SaveMessage(&message); err != nil {
+		logger.Error(">> CreateMessageHandler > error saving message ", message.ID, ", ", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, structs.ErrorResponse{Error: "error saving message"})
+	}
+
+	return ctx.JSON(http.StatusCreated, message)
+}
+
+func (e *Endpoint) MessagesHandler(ctx echo.Context) error {
+	logger := logdoc.GetLogger()
+
+	user, thread, httpErr := e.thread(ctx)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	messages, err := e.threads.FindThreadMessages(thread.ThreadID, user.ID)
+	if err != nil {
+		logger.Error(">> MessagesHandler > error reading messages of thread ", thread.ThreadID, ", ", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, structs.ErrorResponse{Error: "error reading messages"})
+	}
+
+	return ctx.JSON(http.StatusOK, utils.Ternary(messages == nil, []structs.ThreadMessage{}, messages))
+}
+
+// uploadLayers слои загрузки пользователя
+func (e *Endpoint) uploadLayers(guid string, userID int) ([]structs.UserLayer, *echo.HTTPError) {
+	logger := logdoc.GetLogger()
+
+	layers, err := e.layers.FindUploadLayers(guid, userID)
+	if err != nil {
+		logger.Error(">> error reading upload layers, ", err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, structs.ErrorResponse{Error: "error reading upload"})
+	}
+	if len(layers) == 0 {
+		return nil, echo.NewHTTPError(http.StatusNotFound, structs.ErrorResponse{Error: "upload not found"})
+	}
+	return layers, nil
+}
+
+// uploadFiles загружает содержимое слоев в файлы ассистентов и запоминает файл в слое,
+// с assistantID файлы подключаются к ассистенту
+func (e *Endpoint) uploadFiles(c context.Context, userID int, layers []structs.UserLayer, assistantID string) ([]structs.ThreadFile, error) {
+	logger := logdoc.GetLogger()
+
+	var files []structs.ThreadFile
+	for i := range layers {
+		layer := &layers[i]
+
+		content := layerContent(*layer)
+		if content == "" {
+			continue
+		}
+
+		file := structs.ThreadFile{
+			UserID:    userID,
+			FileID:    layer.OpenaiFileID,
+			FileName:  layer.SourceName + ".txt",
+			CreatedAt: layer.Loaded,
+			Purpose:   "assistants",
+			Bytes:     len(content),
+		}
+
+		if file.FileID == "" {
+			uploaded, err := e.assistants.UploadFile(c, file.FileName, []byte(content))
+			if err != nil {
+				return nil, fmt.Errorf("layer %s: %w", layer.LayerName, err)
+			}
+			file.FileID = uploaded.ID
+			file.CreatedAt = time.Unix(int64(uploaded.CreatedAt), 0)
+			file.Bytes = uploaded.Bytes
+		}
+
+		linked := layer.AssistantID
+		if assistantID != "" && assistantID != layer.AssistantID {
+			if _, err := e.assistants.LinkFile(c, assistantID, file.FileID); err != nil {
+				return nil, fmt.Errorf("layer %s: %w", layer.LayerName, err)
+			}
+			linked = assistantID
+		}
+
+		if file.FileID != layer.OpenaiFileID || linked != layer.AssistantID {
+			if err := e.layers.SetLayerFile(layer, file.FileID, linked); err != nil {
+				return nil, err
+			}
+			logger.Info(">> layer ", layer.ID, " uploaded to assistants file ", file.FileID)
+		}
+
+		files = append(files, file)
+	}
+
+	return files, nil
+}
+
+// layerContent обработанное содержимое слоя, как его видит модель
+func layerContent(layer structs.UserLayer) string {
+	if layer.OptimizedData != "" {
+		return layer.OptimizedData
+	}
+	return layer.SourceData
+}
+
+// threadMessage сообщение провайдера в виде для сохранения, текст сообщения - его текстовые части
+func (e *Endpoint) threadMessage(userID int, m *structs.AIMessage) structs.ThreadMessage {
+	var prompt strings.Builder
+	for _, c := range m.Content {
+		if c.Type != "text" {
+			continue
+		}
+		if prompt.Len() > 0 {
+			prompt.WriteString("\n")
+		}
+		prompt.WriteString(c.Text.Value)
+	}
+
+	content, _ := json.Marshal(m.Content)
+	fileIDs, _ := json.Marshal(utils.Ternary(m.FileIds == nil, []string{}, m.FileIds))
+
+	return structs.ThreadMessage{
+		ID:          m.ID,
+		UserID:      strconv.Itoa(userID),
+		Object:      m.Object,
+		CreatedAt:   m.CreatedAt,
+		ThreadID:    m.ThreadID,
+		Role:        m.Role,
+		Prompt:      prompt.String(),
+		AssistantID: m.AssistantID,
+		RunID:       m.RunID,
+		Content:     content,
+		FileIDs:     fileIDs,
+		Metadata:    json.RawMessage("{}"),
+	}
+}
+
+// thread пользователь и его тред из параметра :thread
+func (e *Endpoint) thread(ctx echo.Context) (*structs.User, *structs.Thread, *echo.HTTPError) {
+	user, httpErr := e.user(ctx)
+	if httpErr != nil {
+		return nil, nil, httpErr
+	}
+
+	thread, err := e.threads.FindThread(ctx.Param("thread"), user.ID)
+	if err != nil {
+		return nil, nil, echo.NewHTTPError(http.StatusNotFound, structs.ErrorResponse{Error: "thread not found"})
+	}
+	return user, thread, nil
+}
+
+// user пользователь из claims, их кладет в контекст headerchecker
+func (e *Endpoint) user(ctx echo.Context) (*structs.User, *echo.HTTPError) {
+	logger := logdoc.GetLogger()
+
+	claims, ok := ctx.Get("claims").(jwt.MapClaims)
+	if !ok {
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, structs.ErrorResponse{Error: "authorization required"})
+	}
+	user, err := utils.GetUserFromClaims(claims, e.users)
+	if err != nil {
+		logger.Error(">> error getting user from token claims, ", err)
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, structs.ErrorResponse{Error: "user not found"})
+	}
+	return user, nil
+}
+
+// providerError ответ на ошибку Assistants API: ошибки запроса (кроме авторизации и лимитов) отдаются клиенту как есть,
+// остальные - как 502
+func providerError(err error) *echo.HTTPError {
+	var apiErr structs.APIError
+	if errors.As(err, &apiErr) && apiErr.Status >= 400 && apiErr.Status < 500 &&
+		apiErr.Status != http.StatusUnauthorized && apiErr.Status != http.StatusTooManyRequests {
+		return echo.NewHTTPError(apiErr.Status, structs.ErrorResponse{Code: apiErr.Status, Error: apiErr.Message})
+	}
+	return echo.NewHTTPError(http.StatusBadGateway, structs.ErrorResponse{Code: http.StatusBadGateway, Error: err.Error()})
+}
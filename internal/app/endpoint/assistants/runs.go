@@ -0,0 +1,205 @@
+package assistants
+
+import (
+	"context"
+	"fmt"
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"github.com/labstack/echo/v4"
+	uuid "github.com/satori/go.uuid"
+	"net/http"
+	fileutils "sse-demo-core/internal/app/endpoint/files/utils"
+	"sse-demo-core/internal/app/policy"
+	"sse-demo-core/internal/app/structs"
+	"time"
+)
+
+// CreateRunHandler запускает ассистента на треде. Шаги запуска уходят подписчикам /sse?guid= событиями run_step,
+// в конце run_completed с сохраненными ответами ассистента, либо run_failed
+func (e *Endpoint) CreateRunHandler(ctx echo.Context) error {
+	logger := logdoc.GetLogger()
+	logger.Info(">> CreateRunHandler started..")
+
+	user, thread, httpErr := e.thread(ctx)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	var req structs.ThreadRunRequest
+	if err := ctx.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, structs.ErrorResponse{Error: "invalid request body"})
+	}
+	if req.AssistantID == "" && e.assistants.DefaultAssistant() == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, structs.ErrorResponse{Error: "assistant_id required"})
+	}
+
+	// запуск сверх месячной квоты не начинается, канал /sse для него еще не открыт
+	details, err := e.accounting.CheckQuota(user, policy.JobLLM, 0)
+	if err != nil {
+		logger.Warn(">> CreateRunHandler > error checking quota of userId:", user.ID, ", ", err)
+	} else if details != nil {
+		logger.Warn(">> CreateRunHandler > userId:", user.ID, " exceeded quota, ", details.Message)
+		return echo.NewHTTPError(http.StatusTooManyRequests, structs.ErrorResponse{Code: http.StatusTooManyRequests,
+			Error: details.Message, Reason: "quota_exceeded"})
+	}
+
+	run, err := e.assistants.CreateRun(ctx.Request().Context(), thread.ThreadID, req)
+	if err != nil {
+		logger.Error(">> CreateRunHandler > error starting run on thread ", thread.ThreadID, ", ", err)
+		return providerError(err)
+	}
+
+	guid := uuid.NewV4().String()
+	uid := uuid.NewV4().String()
+
+	// канал создаем до ответа, чтобы клиент сразу мог подписаться на /sse
+	_ = e.connections.Open(guid, user.ID)
+
+	logger.Info(">> started run ", run.ID, " on thread ", thread.ThreadID, " with guid:", guid, ", userId:", user.ID)
+
+	go e.watchRun(guid, uid, user.ID, run)
+
+	return ctx.JSON(http.StatusAccepted, structs.RunStartedResponse{GUID: guid, UUID: uid, RunID: run.ID, Status: run.Status})
+}
+
+// RunHandler текущее состояние запуска у провайдера
+func (e *Endpoint) RunHandler(ctx echo.Context) error {
+	logger := logdoc.GetLogger()
+
+	_, thread, httpErr := e.thread(ctx)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	run, err := e.assistants.GetRun(ctx.Request().Context(), thread.ThreadID, ctx.Param("run"))
+	if err != nil {
+		logger.Error(">> RunHandler > error reading run ", ctx.Param("run"), ", ", err)
+		return providerError(err)
+	}
+
+	return ctx.JSON(http.StatusOK, run)
+}
+
+// финальные статусы запуска, requires_action не поддерживается - функции ассистента не вызываются
+var finishedRuns = map[string]bool{
+	"completed":       true,
+	"failed":          true,
+	"cancelled":       true,
+	"expired":         true,
+	"requires_action": true,
+}
+
+// watchRun опрашивает запуск до финального статуса, пересылая новые и изменившиеся шаги в /sse.
+// Ответы ассистента сохраняются, даже если подписчика /sse нет
+func (e *Endpoint) watchRun(guid string, uid string, userID int, run *structs.AIThreadRun) {
+	logger := logdoc.GetLogger()
+
+	c, cancel := context.WithTimeout(context.Background(), time.Duration(e.config.GetInt("assistants.timeout"))*time.Second)
+	defer cancel()
+
+	forwarder := fileutils.NewConnectionsForwarder(c, guid, e.connections)
+	defer forwarder.Close()
+
+	send := func(state string, details any) {
+		forwarder.Send(structs.Notification{GUID: guid, UUID: uid, State: state, Details: details})
+	}
+	fail := func(status string, code string, message string) {
+		logger.Error(">> run ", run.ID, " with guid:", guid, " ", status, ", ", message)
+		send("run_failed", structs.RunFailedDetails{RunID: run.ID, Status: status, Code: code, Message: message})
+	}
+
+	send("run_started", structs.RunStartedResponse{GUID: guid, UUID: uid, RunID: run.ID, Status: run.Status})
+
+	ticker := time.NewTicker(time.Duration(e.config.GetInt("assistants.poll_interval")) * time.Second)
+	defer ticker.Stop()
+
+	// последний отправленный статус каждого шага
+	steps := make(map[string]string)
+	for {
+		e.sendSteps(c, run, steps, send)
+
+		if finishedRuns[run.Status] {
+			break
+		}
+
+		select {
+		case <-c.Done():
+			fail("timeout", "", "run is not finished in time")
+			return
+		case <-ticker.C:
+		}
+
+		current, err := e.assistants.GetRun(c, run.ThreadID, run.ID)
+		if err != nil {
+			fail("error", "", err.Error())
+			return
+		}
+		run = current
+	}
+
+	switch run.Status {
+	case "completed":
+	case "requires_action":
+		fail(run.Status, "", "assistant function calls are not supported")
+		return
+	default:
+		fail(run.Status, run.LastError.Code, run.LastError.Message)
+		return
+	}
+
+	messages, err := e.saveReplies(c, userID, run)
+	if err != nil {
+		fail("error", "", err.Error())
+		return
+	}
+
+	logger.Info(">> run ", run.ID, " with guid:", guid, " completed, replies: ", len(messages))
+
+	if err = e.accounting.RecordLLM(userID, guid, run.Usage); err != nil {
+		logger.Error(">> error recording usage of run ", run.ID, ", ", err)
+	}
+
+	send("run_completed", structs.RunCompletedDetails{RunID: run.ID, Messages: messages, Usage: run.Usage})
+}
+
+// sendSteps отправляет шаги запуска, появившиеся или сменившие статус с прошлого опроса
+func (e *Endpoint) sendSteps(c context.Context, run *structs.AIThreadRun, sent map[string]string, send func(state string, details any)) {
+	logger := logdoc.GetLogger()
+
+	steps, err := e.assistants.ListRunSteps(c, run.ThreadID, run.ID)
+	if err != nil {
+		logger.Warn(">> error reading steps of run ", run.ID, ", ", err)
+		return
+	}
+
+	for _, step := range steps.Data {
+		if sent[step.ID] == step.Status {
+			continue
+		}
+		sent[step.ID] = step.Status
+		send("run_step", step)
+	}
+}
+
+// saveReplies сохраняет сообщения ассистента, созданные запуском
+func (e *Endpoint) saveReplies(c context.Context, userID int, run *structs.AIThreadRun) ([]structs.ThreadMessage, error) {
+	list, err := e.assistants.ListMessages(c, run.ThreadID)
+	if err != nil {
+		return nil, err
+	}
+
+	// сообщения приходят новыми первыми, сохраняем в порядке создания
+	messages := []structs.ThreadMessage{}
+	for i := len(list.Data) - 1; i >= 0; i-- {
+		if list.Data[i].RunID != run.ID {
+			continue
+		}
+
+		message := e.threadMessage(userID, &list.Data[i])
+		if err = e.threads.SaveMessage(&message); err != nil {
+			return nil, fmt.Errorf("error saving message %s, %w", message.ID, err)
+		}
+		messages = append(messages, message)
+	}
+
+	return messages, nil
+}
@@ -0,0 +1,48 @@
+package download
+
+import (
+	"errors"
+	"fmt"
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"net/url"
+	"path"
+	"sse-demo-core/internal/app/storage"
+	"sse-demo-core/internal/app/structs"
+)
+
+type Endpoint struct {
+	storage *storage.LocalStorage
+}
+
+func New(s *storage.LocalStorage) *Endpoint {
+	return &Endpoint{storage: s}
+}
+
+// DownloadHandler отдает оригинал файла из локального хранилища по подписанной временной ссылке
+func (e *Endpoint) DownloadHandler(ctx echo.Context) error {
+	logger := logdoc.GetLogger()
+
+	key, err := url.PathUnescape(ctx.Param("*"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, structs.ErrorResponse{Error: "invalid key"})
+	}
+
+	if !e.storage.Verify(key, ctx.QueryParam("expires"), ctx.QueryParam("signature")) {
+		return echo.NewHTTPError(http.StatusForbidden, structs.ErrorResponse{Error: "invalid or expired link"})
+	}
+
+	r, err := e.storage.Get(ctx.Request().Context(), key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, structs.ErrorResponse{Error: "file not found"})
+	}
+	if err != nil {
+		logger.Error(">> DownloadHandler > error reading file from storage, ", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, structs.ErrorResponse{Error: "error reading file"})
+	}
+	defer r.Close()
+
+	ctx.Response().Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename*=UTF-8''%s", url.PathEscape(path.Base(key))))
+	return ctx.Stream(http.StatusOK, "application/octet-stream", r)
+}
@@ -4,43 +4,68 @@ import (
 	"context"
 	"errors"
 	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"github.com/golang-jwt/jwt"
 	"github.com/gurkankaymak/hocon"
 	"github.com/labstack/echo/v4"
 	"io"
 	"net/http"
 	fileutils "sse-demo-core/internal/app/endpoint/files/utils"
+	"sse-demo-core/internal/app/interfaces/services"
 	"sse-demo-core/internal/app/structs"
+	"sse-demo-core/internal/app/utils"
 	"time"
 )
 
 type Endpoint struct {
 	config *hocon.Config
+	users  services.UserService
 }
 
-func New(config *hocon.Config) *Endpoint {
-	return &Endpoint{config: config}
+// финальные события, после которых поток /sse завершается
+var finalStates = map[string]bool{
+	"completed":            true,
+	"analysis_completed":   true,
+	"analysis_error":       true,
+	"run_completed":        true,
+	"run_failed":           true,
+	"quota_exceeded":       true,
+	"pipeline_completed":   true,
+	"pipeline_failed":      true,
+	"extraction_completed": true,
+	"extraction_failed":    true,
 }
 
-func (e *Endpoint) ProcessStreamingDataHandler(sseConnections map[string]chan structs.Notification) echo.HandlerFunc {
+func New(config *hocon.Config, userSvc services.UserService) *Endpoint {
+	return &Endpoint{config: config, users: userSvc}
+}
+
+func (e *Endpoint) ProcessStreamingDataHandler(connections *fileutils.Connections) echo.HandlerFunc {
 	return func(ctx echo.Context) error {
 		logger := logdoc.GetLogger()
 
 		logger.Info(">> ProcessStreamingDataHandler started..")
 
-		guid := ctx.QueryParam("guid")
-		stream := sseConnections[guid]
+		// события содержат персональные данные, подписаться может только пользователь, запустивший задачу
+		claims, ok := ctx.Get("claims").(jwt.MapClaims)
+		if !ok {
+			return echo.NewHTTPError(http.StatusUnauthorized, structs.ErrorResponse{Error: "authorization required"})
+		}
+		user, err := utils.GetUserFromClaims(claims, e.users)
+		if err != nil {
+			logger.Error(">> error getting user from token claims, ", err)
+			return echo.NewHTTPError(http.StatusUnauthorized, structs.ErrorResponse{Error: "user not found"})
+		}
 
+		guid := ctx.QueryParam("guid")
 		if guid == "" {
 			return echo.NewHTTPError(http.StatusBadRequest, structs.ErrorResponse{Error: "empty guid param"})
 		}
+		stream := connections.Subscribe(guid, user.ID)
 		if stream == nil {
 			return echo.NewHTTPError(http.StatusBadRequest, structs.ErrorResponse{Error: "empty stream"})
 		}
 
-		defer func() {
-			close(stream)
-			delete(sseConnections, guid)
-		}()
+		defer connections.Remove(guid, stream)
 
 		ctx.Response().Header().Set("Content-Type", "text/event-stream")
 		ctx.Response().Header().Set("Cache-Control", "no-cache")
@@ -50,7 +75,16 @@ func (e *Endpoint) ProcessStreamingDataHandler(sseConnections map[string]chan st
 		ctx.Response().WriteHeader(http.StatusOK)
 		ctx.Response().Flush()
 
-		c, cancel := context.WithTimeout(context.Background(), time.Duration(e.config.GetInt("upload.timeout"))*time.Second)
+		// поток ждет дольше из таймаутов загрузки, анализа, запуска ассистента и конвейера,
+		// работа модели обычно идет дольше обработки файлов
+		timeout := e.config.GetInt("upload.timeout")
+		for _, path := range []string{"analysis.timeout", "assistants.timeout", "pipeline.timeout"} {
+			if t := e.config.GetInt(path); t > timeout {
+				timeout = t
+			}
+		}
+
+		c, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
 		defer cancel()
 
 		for {
@@ -59,12 +93,12 @@ func (e *Endpoint) ProcessStreamingDataHandler(sseConnections map[string]chan st
 				return nil
 			case msg := <-stream:
 				// отправляем событие на основании данных из канала
-				err := fileutils.SendSSEvent(ctx, msg.GUID, msg.UUID, msg.State, msg.FileName)
+				err := fileutils.SendNotification(ctx, msg)
 				if errors.Is(err, io.EOF) {
 					break
 				}
 
-				if msg.State == "completed" {
+				if finalStates[msg.State] {
 					return nil
 				}
 
@@ -2,25 +2,50 @@ package files
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
 	"github.com/gurkankaymak/hocon"
 	"github.com/labstack/echo/v4"
 	uuid "github.com/satori/go.uuid"
 	"mime/multipart"
 	"net/http"
+	"sse-demo-core/internal/app/caching"
+	"sse-demo-core/internal/app/chunker"
+	"sse-demo-core/internal/app/embedding"
 	fileutils "sse-demo-core/internal/app/endpoint/files/utils"
 	"sse-demo-core/internal/app/interfaces/services"
-	docxxlsxprocessor "sse-demo-core/internal/app/processors/docs"
-	pdfprocessor "sse-demo-core/internal/app/processors/pdf"
-	csvprocessor "sse-demo-core/internal/app/processors/text"
+	"sse-demo-core/internal/app/language"
+	"sse-demo-core/internal/app/normalize"
+	"sse-demo-core/internal/app/policy"
+	"sse-demo-core/internal/app/processors"
+	"sse-demo-core/internal/app/redaction"
+	"sse-demo-core/internal/app/storage"
 	"sse-demo-core/internal/app/structs"
+	"sse-demo-core/internal/app/workerpool"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type Endpoint struct {
-	config *hocon.Config
-	jwt    services.JwtService
+	config    *hocon.Config
+	jwt       services.JwtService
+	users     services.UserService
+	layers    services.LayerService
+	pool      *workerpool.Pool
+	cache     caching.Cache
+	storage   storage.Storage
+	processor *processors.Processor
+	chunker   *chunker.Chunker
+	// redactor очистка персональных данных перед отправкой содержимого в модель
+	redactor   *redaction.Redactor
+	redactions services.RedactionService
+	accounting services.UsageService
+	// embedder и search индексация фрагментов слоев для поиска по загрузкам
+	embedder *embedding.Embedder
+	search   services.SearchService
 }
 
 type Response struct {
@@ -34,11 +59,33 @@ type FileLayer struct {
 	Error  string
 }
 
-func New(config *hocon.Config, jwtSvc services.JwtService) *Endpoint {
-	return &Endpoint{config: config, jwt: jwtSvc}
+func New(config *hocon.Config, jwtSvc services.JwtService, userSvc services.UserService, layerSvc services.LayerService,
+	pool *workerpool.Pool, cache caching.Cache, store storage.Storage,
+	processor *processors.Processor, chunks *chunker.Chunker, redactor *redaction.Redactor, redactionSvc services.RedactionService,
+	usageSvc services.UsageService, embedder *embedding.Embedder, searchSvc services.SearchService) *Endpoint {
+	return &Endpoint{config: config, jwt: jwtSvc, users: userSvc, layers: layerSvc, pool: pool, cache: cache, storage: store,
+		processor: processor, chunker: chunks, redactor: redactor, redactions: redactionSvc, accounting: usageSvc,
+		embedder: embedder, search: searchSvc}
 }
 
-func (e *Endpoint) FileUploadHandler(sseConnections map[string]chan structs.Notification) echo.HandlerFunc {
+// upload состояние загрузки, общее для всех ее файлов
+type upload struct {
+	userID int
+	guid   string
+	notify func(n structs.Notification)
+	budget *chunker.Budget
+	// redaction заменители персональных данных, общие для всех файлов загрузки
+	redaction *redaction.Session
+	// budgetExceeded один раз сообщает о превышении бюджета токенов и отменяет необработанные файлы
+	budgetExceeded func(used int)
+	// files, bytes обработанные файлы загрузки, учитываются в расходе пользователя
+	files atomic.Int64
+	bytes atomic.Int64
+	// hashes SHA-256 файлов, посчитанные при чтении тела запроса
+	hashes map[*multipart.FileHeader]string
+}
+
+func (e *Endpoint) FileUploadHandler(connections *fileutils.Connections) echo.HandlerFunc {
 	return func(ctx echo.Context) error {
 		logger := logdoc.GetLogger()
 		logger.Info(">> FileUploadHandler started..")
@@ -48,7 +95,12 @@ func (e *Endpoint) FileUploadHandler(sseConnections map[string]chan structs.Noti
 			logger.Info(cook.Name, ":", cook.Value, ":", cook.Domain)
 		}
 
-		var content string
+		// пользователя кладет в контекст multipartchecker
+		user, ok := ctx.Get("user").(*structs.User)
+		if !ok {
+			return echo.NewHTTPError(http.StatusUnauthorized, structs.ErrorResponse{Error: "authorization required"})
+		}
+		userID := user.ID
 
 		// Multipart form
 		form, err := ctx.MultipartForm()
@@ -72,9 +124,12 @@ func (e *Endpoint) FileUploadHandler(sseConnections map[string]chan structs.Noti
 			guid = guidForm[0]
 		}
 
-		sseConnections[guid] = make(chan structs.Notification)
+		if err = connections.Open(guid, userID); err != nil {
+			logger.Error(">> upload with guid:", guid, " of userId:", userID, " rejected, ", err)
+			return echo.NewHTTPError(http.StatusConflict, structs.ErrorResponse{Error: err.Error()})
+		}
 
-		logger.Info(">> started uploading with guid:", guid)
+		logger.Info(">> started uploading with guid:", guid, ", userId:", userID)
 
 		stateCh := make(chan structs.Notification, len(files))
 
@@ -107,100 +162,407 @@ func (e *Endpoint) FileUploadHandler(sseConnections map[string]chan structs.Noti
 				// чистим сообщения и выходим
 				select {
 				case <-done:
+					// finish ставит финальное событие в stateCh до сигнала done, select мог выбрать done раньше него:
+					// отправляем все поставленные события, чтобы не потерять completed и события файлов
+					drainNotifications(ctx, stateCh)
+
 					// Отменяем все события, ждем и выходим из горутины
 					cancel()
 					time.Sleep(100 * time.Millisecond)
 					done <- struct{}{}
 					return
 				case state := <-stateCh:
-					_ = fileutils.SendSSEvent(ctx, guid, state.UUID, state.State, state.FileName)
+					_ = fileutils.SendNotification(ctx, state)
 				}
 			}
 		}()
 
-		_ = fileutils.SendSSEvent(ctx, guid, "", "upload started", "")
-		fileutils.SendSSEToConnectionsChanWithTimeout(c, &wg, guid, sseConnections, &structs.Notification{GUID: guid, UUID: "", State: "upload started", FileName: ""}, true)
+		// Все события загрузки уходят и в ответ на /upload, и подписчикам /sse
+		forwarder := fileutils.NewConnectionsForwarder(c, guid, connections)
+		notify := func(n structs.Notification) {
+			stateCh <- n
+			forwarder.Send(n)
+		}
+
+		finish := func(n structs.Notification) {
+			notify(n)
+			forwarder.Close()
+
+			done <- struct{}{}
+			<-done
+		}
+
+		notify(structs.Notification{GUID: guid, UUID: "", State: "upload started", FileName: ""})
+
+		// месячная квота роли проверяется до обработки файлов, загрузка сверх квоты не начинается
+		if details := e.quotaExceeded(user, files); details != nil {
+			finish(structs.Notification{GUID: guid, UUID: "", State: "quota_exceeded", FileName: "", Details: details})
+			return nil
+		}
+
+		// при превышении бюджета токенов необработанные файлы загрузки отменяются
+		pc, stop := context.WithCancel(c)
+		defer stop()
+
+		var budgetOnce sync.Once
+		u := &upload{
+			userID: userID,
+			guid:   guid,
+			notify: notify,
+			budget: chunker.NewBudget(e.config.GetInt("upload.tokens.max_upload")),
+
+			redaction: e.redactor.NewSession(),
+		}
+		u.hashes, _ = ctx.Get(fileutils.FileHashesKey).(map[*multipart.FileHeader]string)
+		u.budgetExceeded = func(used int) {
+			budgetOnce.Do(func() {
+				notify(structs.Notification{GUID: guid, UUID: "", State: "token_budget_exceeded", FileName: "",
+					Details: structs.TokenBudgetDetails{Tokens: used, Limit: u.budget.Limit()}})
+				stop()
+			})
+		}
 
-		// Начали обработку файлов
+		// Начали обработку файлов, файлы обрабатываются общим пулом с ограниченным количеством обработчиков
 		for _, file := range files {
-			wg.Add(1)
-			go func(file *multipart.FileHeader) {
-				uid := uuid.NewV4().String()
-				logger.Info(">> processing file ", file.Filename, ", uid:", uid, " with guid:", guid)
+			file := file
+			uid := uuid.NewV4().String()
 
+			wg.Add(1)
+			position, err := e.pool.Submit(pc, userID, func(c context.Context) {
 				defer func() {
-					stateCh <- structs.Notification{GUID: guid, UUID: uid, State: "file_completed", FileName: file.Filename}
-					fileutils.SendSSEToConnectionsChanWithTimeout(c, &wg, guid, sseConnections, &structs.Notification{GUID: guid, UUID: uid, State: "file_completed", FileName: file.Filename}, true)
+					notify(structs.Notification{GUID: guid, UUID: uid, State: "file_completed", FileName: file.Filename})
 					wg.Done()
 				}()
 
-				// отправляем событие создания слоя данных пользователя
-				stateCh <- structs.Notification{GUID: guid, UUID: uid, State: "file_processing_started", FileName: file.Filename}
-				fileutils.SendSSEToConnectionsChanWithTimeout(c, &wg, guid, sseConnections, &structs.Notification{GUID: guid, UUID: uid, State: "file_processing_started", FileName: file.Filename}, true)
+				e.processFile(c, u, uid, file)
+			})
+			if err != nil {
+				logger.Error(">> File Processing Error, ", err)
+				notify(fileError(guid, uid, file.Filename, &processors.ProcessingError{Reason: processors.ReasonCancelled, Err: err}))
+				wg.Done()
+				continue
+			}
 
-				// Определяем тип файла
-				fileType, err := fileutils.DetectFileType(file)
-				if err != nil {
-					logger.Error("error checking file:%s", file.Filename)
-					return
-				}
+			if position > 0 {
+				notify(structs.Notification{GUID: guid, UUID: uid, State: "queued", FileName: file.Filename, Details: structs.QueuedDetails{Position: position}})
+			}
+		}
 
-				// pre-processing file
-				switch {
-				case fileType == "application/zip" && (file.Header.Get("Content-Type") == "application/vnd.openxmlformats-officedocument.wordprocessingml.document" ||
-					file.Header.Get("Content-Type") == "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"):
-					content, err = docxxlsxprocessor.ProcessDocument(file)
-				case fileType == "application/pdf":
-					content, err = pdfprocessor.ProcessPdfFile(file)
-				case file.Header.Get("Content-Type") == "text/csv":
-					content, err = csvprocessor.ProcessCSVFile(file)
-					if err != nil {
-						logger.Error(">> File Processing Error, ", err)
-						stateCh <- structs.Notification{GUID: guid, UUID: uid, State: "file_processing_error", FileName: file.Filename}
-						fileutils.SendSSEToConnectionsChanWithTimeout(c, &wg, guid, sseConnections, &structs.Notification{GUID: guid, UUID: uid, State: "file_processing_error", FileName: file.Filename}, true)
-						return
-					}
-				case fileType == "text/csv":
-					content, err = csvprocessor.ProcessCSVHeader(file, 10)
-					if err != nil {
-						logger.Error(">> File Processing Error, ", err)
-						stateCh <- structs.Notification{GUID: guid, UUID: uid, State: "file_processing_error", FileName: file.Filename}
-						fileutils.SendSSEToConnectionsChanWithTimeout(c, &wg, guid, sseConnections, &structs.Notification{GUID: guid, UUID: uid, State: "file_processing_error", FileName: file.Filename}, true)
-						return
-					}
-				default:
-					logger.Error(">> File Processing Error, unsupported content")
-					stateCh <- structs.Notification{GUID: guid, UUID: uid, State: "file_processing_error", FileName: file.Filename}
-					fileutils.SendSSEToConnectionsChanWithTimeout(c, &wg, guid, sseConnections, &structs.Notification{GUID: guid, UUID: uid, State: "file_processing_error", FileName: file.Filename}, true)
-					return
-				}
+		wg.Wait()
 
-				if err != nil {
-					logger.Error(">> File Processing Error, ", err)
-					stateCh <- structs.Notification{GUID: guid, UUID: uid, State: "file_processing_error", FileName: file.Filename}
-					fileutils.SendSSEToConnectionsChanWithTimeout(c, &wg, guid, sseConnections, &structs.Notification{GUID: guid, UUID: uid, State: "file_processing_error", FileName: file.Filename}, true)
-					return
-				}
+		if processed := u.files.Load(); processed > 0 {
+			if err = e.accounting.RecordUpload(userID, guid, int(processed), u.bytes.Load()); err != nil {
+				logger.Error(">> error recording upload usage of userId:", userID, ", ", err)
+			}
+		}
 
-				stateCh <- structs.Notification{GUID: guid, UUID: uid, State: "file_processed", FileName: file.Filename}
-				fileutils.SendSSEToConnectionsChanWithTimeout(c, &wg, guid, sseConnections, &structs.Notification{GUID: guid, UUID: uid, State: "file_processed", FileName: file.Filename}, true)
+		finish(structs.Notification{GUID: guid, UUID: "", State: "completed", FileName: ""})
 
-				if content == "" {
-					logger.Error(">> File Processing Error, empty content")
-					stateCh <- structs.Notification{GUID: guid, UUID: uid, State: "file_processing_error", FileName: file.Filename}
-					fileutils.SendSSEToConnectionsChanWithTimeout(c, &wg, guid, sseConnections, &structs.Notification{GUID: guid, UUID: uid, State: "file_processing_error", FileName: file.Filename}, true)
-					return
-				}
-			}(file)
+		return nil
+	}
+}
+
+// drainNotifications отправляет в ответ все события, уже поставленные в очередь
+func drainNotifications(ctx echo.Context, stateCh <-chan structs.Notification) {
+	for {
+		select {
+		case state := <-stateCh:
+			_ = fileutils.SendNotification(ctx, state)
+		default:
+			return
 		}
+	}
+}
 
-		wg.Wait()
-		_ = fileutils.SendSSEvent(ctx, guid, "", "completed", "")
-		fileutils.SendSSEToConnectionsChanWithTimeout(c, &wg, guid, sseConnections, &structs.Notification{GUID: guid, UUID: "", State: "completed", FileName: ""}, false)
+func (e *Endpoint) processFile(c context.Context, u *upload, uid string, file *multipart.FileHeader) {
+	logger := logdoc.GetLogger()
+
+	// бюджет токенов загрузки исчерпан предыдущими файлами
+	if u.budget.Exceeded() {
+		logger.Warn(">> file ", file.Filename, " skipped, upload with guid:", u.guid, " exceeded token budget")
+		u.notify(fileError(u.guid, uid, file.Filename, &processors.ProcessingError{Reason: processors.ReasonTokenBudget,
+			Err: fmt.Errorf("upload token budget of %d tokens exceeded", u.budget.Limit())}))
+		return
+	}
+
+	// загрузка отменена по таймауту, пока файл ждал в очереди
+	if c.Err() != nil {
+		logger.Warn(">> file ", file.Filename, " skipped, upload with guid:", u.guid, " cancelled")
+		u.notify(fileError(u.guid, uid, file.Filename, &processors.ProcessingError{Reason: processors.ReasonCancelled, Err: c.Err()}))
+		return
+	}
+
+	logger.Info(">> processing file ", file.Filename, ", uid:", uid, " with guid:", u.guid)
+
+	// отправляем событие создания слоя данных пользователя
+	u.notify(structs.Notification{GUID: u.guid, UUID: uid, State: "file_processing_started", FileName: file.Filename})
+
+	// хеш считается при чтении тела запроса, для файлов без него - чтением файла
+	hash := u.hashes[file]
+	var err error
+	if hash == "" {
+		hash, err = fileutils.HashFile(file)
+	}
+	var cacheKey string
+	if err == nil {
+		cacheKey, err = e.processor.CacheKey(file, hash)
+	}
+	if err != nil {
+		logger.Error(">> File Processing Error, file ", file.Filename, ", ", err)
+		u.notify(fileError(u.guid, uid, file.Filename, &processors.ProcessingError{Reason: processors.ReasonProcessingFailed, Err: err}))
+		return
+	}
+
+	// одинаковые файлы повторно не разбираем, извлеченное содержимое хранится в кеше по хешу файла и виду обработки
+	result, cached := e.cachedContent(cacheKey)
+	if cached {
+		logger.Info(">> file ", file.Filename, " content found in cache, sha256:", hash)
+		u.notify(structs.Notification{GUID: u.guid, UUID: uid, State: "file_cached", FileName: file.Filename, Details: structs.CachedDetails{SHA256: hash}})
+	} else {
+		// pre-processing file, каждый файл обрабатывается со своим таймаутом
+		result, err = e.processor.Process(c, file)
+		if err != nil {
+			logger.Error(">> File Processing Error, file ", file.Filename, ", ", err)
+			u.notify(fileError(u.guid, uid, file.Filename, err))
+			return
+		}
+		e.cacheContent(cacheKey, result)
+	}
+
+	// нормализуем текст и определяем язык документа, по языку выбираются промпты анализа
+	content := normalize.Text(result.Content)
+	lang := language.Detect(content)
+	logger.Debug(">> file ", file.Filename, " language: ", lang.Language, ", confidence: ", lang.Confidence)
+
+	// очищаем персональные данные, в модель уходит только очищенный текст
+	data := content
+	var redacted *redaction.Result
+	if e.redactor.Enabled() {
+		redacted = u.redaction.Redact(content)
+		data = redacted.Text
+		if err = e.redactions.SaveTokens(u.guid, uid, u.userID, redacted.Tokens); err != nil {
+			logger.Error(">> File Processing Error, error saving redaction tokens of file ", file.Filename, ", ", err)
+			u.notify(fileError(u.guid, uid, file.Filename, &processors.ProcessingError{Reason: processors.ReasonProcessingFailed, Err: err}))
+			return
+		}
+	}
+
+	// проверяем бюджет токенов до сохранения, чтобы не отправлять в модель документы сверх контекста
+	tokens := e.chunker.Count(data)
+	if limit := e.config.GetInt("upload.tokens.max_file"); limit > 0 && tokens > limit {
+		logger.Warn(">> file ", file.Filename, " has ", tokens, " tokens, limit ", limit)
+		u.notify(fileError(u.guid, uid, file.Filename, &processors.ProcessingError{Reason: processors.ReasonTokenBudget,
+			Err: fmt.Errorf("file has %d tokens, limit is %d", tokens, limit)}))
+		return
+	}
+	if used, ok := u.budget.Add(tokens); !ok {
+		logger.Warn(">> upload with guid:", u.guid, " exceeded token budget, used ", used, " of ", u.budget.Limit())
+		u.budgetExceeded(used)
+		u.notify(fileError(u.guid, uid, file.Filename, &processors.ProcessingError{Reason: processors.ReasonTokenBudget,
+			Err: fmt.Errorf("upload token budget of %d tokens exceeded", u.budget.Limit())}))
+		return
+	}
+	chunks := e.chunker.Split(data)
+
+	// сохраняем оригинал файла, ошибка хранилища не мешает дальнейшей работе с содержимым
+	key, err := e.storeOriginal(c, storage.UploadKey(u.userID, u.guid, uid, file.Filename), file)
+	if err != nil {
+		logger.Error(">> error storing original file ", file.Filename, ", ", err)
+	} else if link, err := e.storage.Presign(c, key, time.Duration(e.config.GetInt("integration.storage.presign_ttl"))*time.Second); err == nil {
+		u.notify(structs.Notification{GUID: u.guid, UUID: uid, State: "file_stored", FileName: file.Filename, Details: structs.StoredDetails{Link: link}})
+	}
+
+	layer := structs.UserLayer{
+		GUID:           u.guid,
+		UUID:           uid,
+		UserID:         u.userID,
+		LayerName:      file.Filename,
+		SourceSize:     int(file.Size),
+		SourceName:     file.Filename,
+		SourceType:     file.Header.Get("Content-Type"),
+		SourceHash:     hash,
+		OCRConfidence:  result.Confidence,
+		Tokens:         tokens,
+		SourceFileLink: key,
+		// при очистке в слое хранится только очищенный текст, исходные значения - в redaction_tokens
+		SourceData: data,
+		Language:   lang.Language,
+		Status:     "processed",
+	}
+	if redacted != nil {
+		layer.OptimizedData = redacted.Text
+		if counts, err := json.Marshal(redacted.Counts); err == nil {
+			layer.Redactions = string(counts)
+		}
+	}
+	if _, err = e.layers.SaveLayer(&layer); err != nil {
+		logger.Error(">> File Processing Error, error saving layer of file ", file.Filename, ", ", err)
+		if key != "" {
+			_ = e.storage.Delete(c, key)
+		}
+		u.notify(fileError(u.guid, uid, file.Filename, &processors.ProcessingError{Reason: processors.ReasonProcessingFailed, Err: err}))
+		return
+	}
+
+	u.files.Add(1)
+	u.bytes.Add(file.Size)
+
+	// ошибка индексации не мешает работе со слоем, он только не попадет в поиск
+	indexed := e.index(c, &layer, chunks)
+
+	logger.Debug(">> file ", file.Filename, " processed, content length: ", len(content), ", tokens: ", tokens, ", chunks: ", len(chunks))
+	u.notify(structs.Notification{GUID: u.guid, UUID: uid, State: "file_processed", FileName: file.Filename,
+		Details: structs.ProcessedDetails{OCR: result.OCR, Confidence: result.Confidence, Tokens: tokens, Chunks: len(chunks),
+			Redactions: redactionCounts(redacted), Language: lang.Language, Indexed: indexed}})
+}
+
+// index сохраняет фрагменты слоя с эмбеддингами для поиска и возвращает их количество.
+// Эмбеддинги считаются по очищенному от персональных данных тексту
+func (e *Endpoint) index(c context.Context, layer *structs.UserLayer, chunks []chunker.Chunk) int {
+	logger := logdoc.GetLogger()
+
+	if !e.embedder.Enabled() || len(chunks) == 0 {
+		return 0
+	}
+
+	texts := make([]string, 0, len(chunks))
+	for _, chunk := range chunks {
+		texts = append(texts, chunk.Text)
+	}
+
+	response, err := e.embedder.Embed(c, texts)
+	if err != nil {
+		logger.Error(">> error computing embeddings of file ", layer.LayerName, ", ", err)
+		return 0
+	}
+
+	rows := make([]structs.LayerChunk, 0, len(chunks))
+	for i, chunk := range chunks {
+		rows = append(rows, structs.LayerChunk{
+			LayerID:   layer.ID,
+			GUID:      layer.GUID,
+			UserID:    layer.UserID,
+			Index:     chunk.Index,
+			Section:   chunk.Section,
+			Page:      chunk.Page,
+			Content:   chunk.Text,
+			Tokens:    chunk.Tokens,
+			Model:     response.Model,
+			Embedding: response.Embeddings[i],
+		})
+	}
+	if err = e.search.SaveChunks(rows); err != nil {
+		logger.Error(">> error saving chunks of file ", layer.LayerName, ", ", err)
+		return 0
+	}
+
+	return len(rows)
+}
+
+// quotaExceeded проверяет, что загрузка files укладывается в месячную квоту роли пользователя
+func (e *Endpoint) quotaExceeded(user *structs.User, files []*multipart.FileHeader) *structs.QuotaExceededDetails {
+	logger := logdoc.GetLogger()
+
+	var size int64
+	for _, file := range files {
+		size += file.Size
+	}
+
+	details, err := e.accounting.CheckQuota(user, policy.JobUpload, size)
+	if err != nil {
+		logger.Warn(">> error checking quota of userId:", user.ID, ", ", err)
+		return nil
+	}
+	if details != nil {
+		logger.Warn(">> userId:", user.ID, " exceeded quota, ", details.Message)
+	}
+	return details
+}
+
+// storeOriginal сохраняет оригинал загруженного файла в хранилище и возвращает его ключ
+func (e *Endpoint) storeOriginal(c context.Context, key string, file *multipart.FileHeader) (string, error) {
+	src, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	if err = e.storage.Put(c, key, src, file.Size, file.Header.Get("Content-Type")); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+func (e *Endpoint) cachedContent(key string) (*processors.Result, bool) {
+	logger := logdoc.GetLogger()
+
+	if !e.config.GetBoolean("upload.dedup.enabled") {
+		return nil, false
+	}
+
+	value, err := e.cache.Get(key)
+	if err != nil {
+		if !errors.Is(err, caching.ErrNotFound) {
+			logger.Warn(">> error reading content cache, ", err)
+		}
+		return nil, false
+	}
+
+	data, ok := value.(string)
+	if !ok {
+		return nil, false
+	}
+	// содержимое в кеше не очищено от персональных данных и шифруется ключом redaction.key,
+	// запись, сохраненная с другим ключом, считается промахом
+	if data, err = e.redactions.Decrypt(data); err != nil {
+		return nil, false
+	}
 
-		done <- struct{}{}
-		<-done
+	var result processors.Result
+	if err = json.Unmarshal([]byte(data), &result); err != nil || result.Content == "" {
+		return nil, false
+	}
+	return &result, true
+}
+
+func (e *Endpoint) cacheContent(key string, result *processors.Result) {
+	logger := logdoc.GetLogger()
+
+	if !e.config.GetBoolean("upload.dedup.enabled") {
+		return
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		logger.Warn(">> error encoding content cache, ", err)
+		return
+	}
 
+	encrypted, err := e.redactions.Encrypt(string(data))
+	if err != nil {
+		logger.Warn(">> error encrypting content cache, ", err)
+		return
+	}
+
+	err = e.cache.Set(key, encrypted, time.Duration(e.config.GetInt("upload.dedup.ttl"))*time.Second)
+	if err != nil {
+		logger.Warn(">> error writing content cache, ", err)
+	}
+}
+
+func redactionCounts(r *redaction.Result) map[string]int {
+	if r == nil || r.Total() == 0 {
 		return nil
 	}
+	return r.Counts
+}
+
+func fileError(guid string, uid string, fileName string, err error) structs.Notification {
+	return structs.Notification{
+		GUID:     guid,
+		UUID:     uid,
+		State:    "file_processing_error",
+		FileName: fileName,
+		Details:  structs.FileErrorDetails{Reason: processors.Reason(err), Message: err.Error()},
+	}
 }
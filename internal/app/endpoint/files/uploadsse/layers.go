@@ -0,0 +1,55 @@
+package files
+
+import (
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"sse-demo-core/internal/app/structs"
+	"sse-demo-core/internal/app/utils"
+	"time"
+)
+
+// LayerLinkHandler выдает временную ссылку на скачивание оригинала файла слоя uuid загрузки guid пользователя.
+// Ссылка из события file_stored истекает через integration.storage.presign_ttl, здесь ее можно получить заново
+func (e *Endpoint) LayerLinkHandler(ctx echo.Context) error {
+	logger := logdoc.GetLogger()
+	logger.Info(">> LayerLinkHandler started..")
+
+	claims, ok := ctx.Get("claims").(jwt.MapClaims)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, structs.ErrorResponse{Error: "authorization required"})
+	}
+	user, err := utils.GetUserFromClaims(claims, e.users)
+	if err != nil {
+		logger.Error(">> LayerLinkHandler > error getting user from token claims, ", err)
+		return echo.NewHTTPError(http.StatusUnauthorized, structs.ErrorResponse{Error: "user not found"})
+	}
+
+	guid, uid := ctx.Param("guid"), ctx.Param("uuid")
+	layers, err := e.layers.FindUploadLayers(guid, user.ID)
+	if err != nil {
+		logger.Error(">> LayerLinkHandler > error reading layers of upload guid:", guid, ", ", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, structs.ErrorResponse{Error: "error reading upload"})
+	}
+
+	for _, layer := range layers {
+		if layer.UUID != uid {
+			continue
+		}
+		if layer.SourceFileLink == "" {
+			return echo.NewHTTPError(http.StatusNotFound, structs.ErrorResponse{Error: "original file is not stored"})
+		}
+
+		ttl := time.Duration(e.config.GetInt("integration.storage.presign_ttl")) * time.Second
+		link, err := e.storage.Presign(ctx.Request().Context(), layer.SourceFileLink, ttl)
+		if err != nil {
+			logger.Error(">> LayerLinkHandler > error presigning original of layer uid:", uid, ", ", err)
+			return echo.NewHTTPError(http.StatusInternalServerError, structs.ErrorResponse{Error: "error creating link"})
+		}
+		return ctx.JSON(http.StatusOK, structs.LayerLinkResponse{UUID: layer.UUID, FileName: layer.SourceName, Link: link,
+			ExpiresAt: time.Now().Add(ttl)})
+	}
+
+	return echo.NewHTTPError(http.StatusNotFound, structs.ErrorResponse{Error: "layer not found"})
+}
@@ -0,0 +1,101 @@
+package fileutils
+
+import (
+	"context"
+	"errors"
+	"sse-demo-core/internal/app/structs"
+	"sync"
+)
+
+// ErrForeignStream guid уже занят задачей другого пользователя
+var ErrForeignStream = errors.New("guid is used by another user")
+
+// Connections каналы подписчиков /sse по guid загрузки или анализа.
+// Каналы создаются обработчиками загрузки и анализа, а удаляются /sse и завершенными задачами из разных горутин,
+// поэтому доступ под мьютексом
+type Connections struct {
+	mu      sync.RWMutex
+	streams map[string]chan structs.Notification
+	// owners пользователь, запустивший задачу guid: guid выбирает клиент, а события содержат персональные данные,
+	// поэтому подписаться на канал и занять guid может только он. Владелец остается и после ухода подписчика
+	owners map[string]int
+	// tasks количество незавершенных задач guid, с последней из них удаляются канал и владелец
+	tasks map[string]int
+}
+
+func NewConnections() *Connections {
+	return &Connections{streams: make(map[string]chan structs.Notification), owners: make(map[string]int), tasks: make(map[string]int)}
+}
+
+// Open создает канал guid задачи пользователя userID, если его еще нет. guid задачи другого пользователя - ErrForeignStream.
+// Каждый успешный Open завершается Release: его вызывает ConnectionsForwarder задачи при закрытии,
+// а обработчик - если задача так и не запустилась
+func (c *Connections) Open(guid string, userID int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if owner, ok := c.owners[guid]; ok && owner != userID {
+		return ErrForeignStream
+	}
+	c.owners[guid] = userID
+	c.tasks[guid]++
+
+	if _, ok := c.streams[guid]; !ok {
+		c.streams[guid] = make(chan structs.Notification)
+	}
+	return nil
+}
+
+// Release завершает задачу guid. После последней задачи канал и владелец удаляются:
+// событий больше не будет, а guid снова может занять любой пользователь
+func (c *Connections) Release(guid string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.tasks[guid] > 1 {
+		c.tasks[guid]--
+		return
+	}
+	delete(c.tasks, guid)
+	delete(c.owners, guid)
+	delete(c.streams, guid)
+}
+
+// Subscribe канал guid для подписчика /sse userID, nil - канала нет или он принадлежит другому пользователю
+func (c *Connections) Subscribe(guid string, userID int) chan structs.Notification {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if owner, ok := c.owners[guid]; !ok || owner != userID {
+		return nil
+	}
+	return c.streams[guid]
+}
+
+func (c *Connections) Get(guid string) chan structs.Notification {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.streams[guid]
+}
+
+// Remove удаляет канал guid, если его еще не заменили новым.
+// Канал не закрывается: отправители могут еще держать его и дожидаются отмены своего контекста
+func (c *Connections) Remove(guid string, stream chan structs.Notification) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.streams[guid] == stream {
+		delete(c.streams, guid)
+	}
+}
+
+// Send блокируется, пока подписчик /sse не прочитает уведомление, либо до отмены контекста
+func (c *Connections) Send(ctx context.Context, guid string, n structs.Notification) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case c.Get(guid) <- n:
+		return nil
+	}
+}
@@ -0,0 +1,98 @@
+package fileutils
+
+import (
+	"context"
+	"errors"
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"os"
+	"sse-demo-core/internal/app/structs"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	_, _ = logdoc.Init("udp", "127.0.0.1:9", "fileutils-test")
+	os.Exit(m.Run())
+}
+
+// empty в Connections не осталось записей guid
+func empty(c *Connections, guid string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	_, stream := c.streams[guid]
+	_, owner := c.owners[guid]
+	_, tasks := c.tasks[guid]
+	return !stream && !owner && !tasks
+}
+
+// TestRelease канал и владелец guid удаляются с завершением последней задачи
+func TestRelease(t *testing.T) {
+	c := NewConnections()
+
+	// анализ запущен по guid загрузки, пока она еще идет
+	for i := 0; i < 2; i++ {
+		if err := c.Open("guid", 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Open("guid", 2); !errors.Is(err, ErrForeignStream) {
+		t.Fatalf("got %v, want ErrForeignStream", err)
+	}
+
+	c.Release("guid")
+	if c.Subscribe("guid", 1) == nil {
+		t.Fatal("stream removed while second task is running")
+	}
+
+	c.Release("guid")
+	if !empty(c, "guid") {
+		t.Fatal("stream or owner left after last task")
+	}
+	if err := c.Open("guid", 2); err != nil {
+		t.Fatalf("released guid rejected, %v", err)
+	}
+}
+
+// TestForwarderCloseReleases закрытие forwarder завершает задачу: без подписчика события отбрасываются по таймауту,
+// а записи guid не остаются в Connections
+func TestForwarderCloseReleases(t *testing.T) {
+	c := NewConnections()
+	if err := c.Open("guid", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	forwarder := NewConnectionsForwarder(ctx, "guid", c)
+	forwarder.Send(structs.Notification{GUID: "guid", State: "completed"})
+	forwarder.Close()
+
+	if !empty(c, "guid") {
+		t.Fatal("stream or owner left after forwarder is closed")
+	}
+}
+
+func TestForwarderDelivers(t *testing.T) {
+	c := NewConnections()
+	if err := c.Open("guid", 1); err != nil {
+		t.Fatal(err)
+	}
+	stream := c.Subscribe("guid", 1)
+
+	forwarder := NewConnectionsForwarder(context.Background(), "guid", c)
+	for _, state := range []string{"started", "completed"} {
+		forwarder.Send(structs.Notification{GUID: "guid", State: state})
+	}
+	for _, state := range []string{"started", "completed"} {
+		if n := <-stream; n.State != state {
+			t.Fatalf("got %s, want %s", n.State, state)
+		}
+	}
+	forwarder.Close()
+
+	if !empty(c, "guid") {
+		t.Fatal("stream or owner left after forwarder is closed")
+	}
+}
@@ -2,12 +2,18 @@ package fileutils
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"github.com/LogDoc-org/logdoc-go-appender/common"
 	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
 	"github.com/golang-jwt/jwt"
 	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"io"
+	"mime"
 	"mime/multipart"
 	"net/http"
 	"runtime"
@@ -19,10 +25,32 @@ import (
 )
 
 type SSEvent struct {
-	GUID  string      `json:"guid"`
-	UUID  string      `json:"uuid"`
-	Event string      `json:"event"`
-	Data  interface{} `json:"data"`
+	GUID    string      `json:"guid"`
+	UUID    string      `json:"uuid"`
+	Event   string      `json:"event"`
+	Data    interface{} `json:"data"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// размер очереди уведомлений одной загрузки, ожидающих подписчика /sse
+const forwarderQueueSize = 256
+
+// droppedEvents уведомления, не доставленные подписчикам /sse: очередь переполнена (queue_full)
+// или подписчик не появился до таймаута (no_subscriber)
+var droppedEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "sse_demo_core",
+	Subsystem: "sse",
+	Name:      "dropped_events_total",
+	Help:      "Количество уведомлений, не доставленных подписчикам /sse",
+}, []string{"reason"})
+
+// ConnectionsForwarder доставляет уведомления загрузки подписчикам /sse
+// одним фоновым процессом в порядке отправки, вместо горутины на каждое событие
+type ConnectionsForwarder struct {
+	guid        string
+	connections *Connections
+	queue       chan structs.Notification
+	done        chan struct{}
 }
 
 func ProcessAuth(j services.JwtService, users services.UserService, ctx echo.Context) (int, bool, *echo.HTTPError) {
@@ -86,13 +114,18 @@ func ProcessAuth(j services.JwtService, users services.UserService, ctx echo.Con
 }
 
 func SendSSEvent(ctx echo.Context, guid string, uid string, eventName string, fileName string) error {
+	return SendNotification(ctx, structs.Notification{GUID: guid, UUID: uid, State: eventName, FileName: fileName})
+}
+
+func SendNotification(ctx echo.Context, n structs.Notification) error {
 	logger := logdoc.GetLogger()
 
 	event := SSEvent{
-		GUID:  guid,
-		UUID:  uid,
-		Event: eventName,
-		Data:  fileName,
+		GUID:    n.GUID,
+		UUID:    n.UUID,
+		Event:   n.State,
+		Data:    n.FileName,
+		Details: n.Details,
 	}
 	data, err := json.Marshal(event)
 	if err != nil {
@@ -135,48 +168,166 @@ func SendSSEEvent(mu sync.Locker, ctx echo.Context, uid string, eventName string
 	return nil
 }
 
-func SendSSEToConnectionsChanWithTimeout(ctx context.Context, wg *sync.WaitGroup, guid string, sseConnections map[string]chan structs.Notification, data *structs.Notification, async bool) {
-	if async {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			sendSSEDataToChannel(ctx, guid, sseConnections, data)
-		}()
-	} else {
-		sendSSEDataToChannel(ctx, guid, sseConnections, data)
+func NewConnectionsForwarder(ctx context.Context, guid string, connections *Connections) *ConnectionsForwarder {
+	logger := logdoc.GetLogger()
+
+	f := &ConnectionsForwarder{
+		guid:        guid,
+		connections: connections,
+		queue:       make(chan structs.Notification, forwarderQueueSize),
+		done:        make(chan struct{}),
 	}
-}
 
-func sendSSEDataToChannel(ctx context.Context, guid string, sseConnections map[string]chan structs.Notification, data *structs.Notification) {
-	logger := logdoc.GetLogger()
-	defer func() {
-		err := recover()
-		if err != nil {
-			logger.Error("sendSSEDataToChannel panic, ", err)
+	go func() {
+		defer close(f.done)
+		for data := range f.queue {
+			if err := connections.Send(ctx, guid, data); err != nil {
+				droppedEvents.WithLabelValues("no_subscriber").Inc()
+				logger.Warn(">> nobody reading sse, forwarding done with timeout, event ", data, " dropped")
+			}
 		}
 	}()
 
-	for {
-		select {
-		case <-ctx.Done():
-			logger.Warn(">> nobody reading sse, SendSSEToConnectionsChanWithTimeout done with timeout, event ", *data, " dropped")
-			return
-		case sseConnections[guid] <- *data:
-			return
-		}
+	return f
+}
+
+// Send ставит уведомление в очередь доставки, не блокируя отправителя
+func (f *ConnectionsForwarder) Send(data structs.Notification) {
+	logger := logdoc.GetLogger()
+
+	select {
+	case f.queue <- data:
+	default:
+		droppedEvents.WithLabelValues("queue_full").Inc()
+		logger.Warn(">> sse forwarder queue is full, event ", data, " dropped")
 	}
 }
 
+// Close дожидается доставки всех уведомлений, либо их отбрасывания по таймауту контекста,
+// и завершает задачу guid в Connections
+func (f *ConnectionsForwarder) Close() {
+	close(f.queue)
+	<-f.done
+	f.connections.Release(f.guid)
+}
+
 func DetectFileType(file *multipart.FileHeader) (string, error) {
-	fileInfo, _ := file.Open()
+	fileInfo, err := file.Open()
+	if err != nil {
+		return "", err
+	}
 	defer fileInfo.Close()
 
 	buffer := make([]byte, 512)
-	if _, err := fileInfo.Read(buffer); err != nil {
+	n, err := fileInfo.Read(buffer)
+	if err != nil {
 		return "", err
 	}
-	fileType := http.DetectContentType(buffer)
-	_ = fileType
+	fileType := http.DetectContentType(buffer[:n])
+
+	// http.DetectContentType не распознает tiff, проверяем сигнатуру сами
+	if fileType == "application/octet-stream" && n >= 4 &&
+		(string(buffer[:4]) == "II*\x00" || string(buffer[:4]) == "MM\x00*") {
+		fileType = "image/tiff"
+	}
 
 	return fileType, nil
 }
+
+// HashFile считает SHA-256 содержимого уже разобранного файла, читая его потоком
+func HashFile(file *multipart.FileHeader) (string, error) {
+	src, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	h := sha256.New()
+	if _, err = io.Copy(h, src); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// FileHashesKey ключ контекста запроса с SHA-256 загруженных файлов, map[*multipart.FileHeader]string
+const FileHashesKey = "file_hashes"
+
+// PartHasher считает SHA-256 файлов multipart запроса параллельно с разбором формы, пока тело читается из сети,
+// чтобы не перечитывать файлы после разбора
+type PartHasher struct {
+	pw     *io.PipeWriter
+	done   chan struct{}
+	hashes map[string][]partHash
+}
+
+type partHash struct {
+	fileName string
+	hash     string
+}
+
+// NewPartHasher подменяет тело запроса r, nil - запрос не multipart
+func NewPartHasher(r *http.Request) *PartHasher {
+	_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || params["boundary"] == "" {
+		return nil
+	}
+
+	pr, pw := io.Pipe()
+	h := &PartHasher{pw: pw, done: make(chan struct{}), hashes: make(map[string][]partHash)}
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.TeeReader(r.Body, pw), r.Body}
+
+	go func() {
+		defer close(h.done)
+		// канал дочитывается до конца даже после ошибки, иначе разбор формы встанет на записи в него
+		defer func() {
+			_, _ = io.Copy(io.Discard, pr)
+		}()
+
+		mr := multipart.NewReader(pr, params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err != nil {
+				return
+			}
+			if part.FileName() == "" {
+				continue
+			}
+
+			sum := sha256.New()
+			if _, err = io.Copy(sum, part); err != nil {
+				return
+			}
+			h.hashes[part.FormName()] = append(h.hashes[part.FormName()], partHash{fileName: part.FileName(), hash: hex.EncodeToString(sum.Sum(nil))})
+		}
+	}()
+
+	return h
+}
+
+// Hashes дожидается хешей после разбора формы и сопоставляет их файлам поля формы field.
+// Если файлы формы не совпали с прочитанными частями, хеши не возвращаются
+func (h *PartHasher) Hashes(field string, files []*multipart.FileHeader) map[*multipart.FileHeader]string {
+	if h == nil {
+		return nil
+	}
+
+	_ = h.pw.Close()
+	<-h.done
+
+	parts := h.hashes[field]
+	if len(parts) != len(files) {
+		return nil
+	}
+	hashes := make(map[*multipart.FileHeader]string, len(files))
+	for i, file := range files {
+		if parts[i].fileName != file.Filename {
+			return nil
+		}
+		hashes[file] = parts[i].hash
+	}
+	return hashes
+}
@@ -0,0 +1,236 @@
+package prompts
+
+import (
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"sse-demo-core/internal/app/chunker"
+	"sse-demo-core/internal/app/interfaces/services"
+	"sse-demo-core/internal/app/prompttemplate"
+	"sse-demo-core/internal/app/structs"
+	"strconv"
+	"strings"
+)
+
+// Endpoint управление промптами анализа, доступно ролям admin.roles. Пользователя кладет в контекст rolechecker
+type Endpoint struct {
+	prompts services.PromptService
+	layers  services.LayerService
+	// tokens подсчет токенов сообщений предпросмотра
+	tokens func(text string) int
+}
+
+func New(promptSvc services.PromptService, layerSvc services.LayerService, chunks *chunker.Chunker) *Endpoint {
+	return &Endpoint{prompts: promptSvc, layers: layerSvc, tokens: chunks.Count}
+}
+
+// PromptsHandler промпты, ?stage= - только промпты этапа
+func (e *Endpoint) PromptsHandler(ctx echo.Context) error {
+	logger := logdoc.GetLogger()
+
+	stage, _ := strconv.Atoi(ctx.QueryParam("stage"))
+	prompts, err := e.prompts.FindPrompts(stage)
+	if err != nil {
+		logger.Error(">> PromptsHandler > error reading prompts, ", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, structs.ErrorResponse{Error: "error reading prompts"})
+	}
+	if prompts == nil {
+		prompts = []structs.Prompt{}
+	}
+
+	return ctx.JSON(http.StatusOK, prompts)
+}
+
+func (e *Endpoint) PromptHandler(ctx echo.Context) error {
+	prompt, httpErr := e.prompt(ctx)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	return ctx.JSON(http.StatusOK, prompt)
+}
+
+// CreatePromptHandler сохраняет промпт первой версией, шаблон промпта проверяется до сохранения
+func (e *Endpoint) CreatePromptHandler(ctx echo.Context) error {
+	logger := logdoc.GetLogger()
+
+	prompt, httpErr := bindPrompt(ctx)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	user := ctx.Get("user").(*structs.User)
+	if err := e.prompts.CreatePrompt(prompt, user.ID); err != nil {
+		logger.Error(">> CreatePromptHandler > error saving prompt, ", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, structs.ErrorResponse{Error: "error saving prompt"})
+	}
+
+	logger.Info(">> prompt ", prompt.ID, " of stage ", prompt.PromptStage, " created by userId:", user.ID)
+
+	return ctx.JSON(http.StatusCreated, prompt)
+}
+
+// UpdatePromptHandler сохраняет изменения промпта новой версией, предыдущие версии доступны через VersionsHandler
+func (e *Endpoint) UpdatePromptHandler(ctx echo.Context) error {
+	logger := logdoc.GetLogger()
+
+	id, httpErr := promptID(ctx)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	prompt, httpErr := bindPrompt(ctx)
+	if httpErr != nil {
+		return httpErr
+	}
+	prompt.ID = id
+
+	user := ctx.Get("user").(*structs.User)
+	found, err := e.prompts.UpdatePrompt(prompt, user.ID)
+	if err != nil {
+		logger.Error(">> UpdatePromptHandler > error saving prompt ", id, ", ", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, structs.ErrorResponse{Error: "error saving prompt"})
+	}
+	if !found {
+		return echo.NewHTTPError(http.StatusNotFound, structs.ErrorResponse{Error: "prompt not found"})
+	}
+
+	logger.Info(">> prompt ", prompt.ID, " updated to version ", prompt.Version, " by userId:", user.ID)
+
+	return ctx.JSON(http.StatusOK, prompt)
+}
+
+// DeletePromptHandler удаляет промпт, его версии и результаты анализов промптом сохраняются
+func (e *Endpoint) DeletePromptHandler(ctx echo.Context) error {
+	logger := logdoc.GetLogger()
+
+	id, httpErr := promptID(ctx)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	found, err := e.prompts.DeletePrompt(id)
+	if err != nil {
+		logger.Error(">> DeletePromptHandler > error deleting prompt ", id, ", ", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, structs.ErrorResponse{Error: "error deleting prompt"})
+	}
+	if !found {
+		return echo.NewHTTPError(http.StatusNotFound, structs.ErrorResponse{Error: "prompt not found"})
+	}
+
+	logger.Info(">> prompt ", id, " deleted by userId:", ctx.Get("user").(*structs.User).ID)
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// VersionsHandler версии промпта, новые первыми. История удаленного промпта тоже доступна
+func (e *Endpoint) VersionsHandler(ctx echo.Context) error {
+	logger := logdoc.GetLogger()
+
+	id, httpErr := promptID(ctx)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	versions, err := e.prompts.FindPromptVersions(id)
+	if err != nil {
+		logger.Error(">> VersionsHandler > error reading versions of prompt ", id, ", ", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, structs.ErrorResponse{Error: "error reading prompt versions"})
+	}
+	// у каждого промпта есть хотя бы первая версия
+	if len(versions) == 0 {
+		return echo.NewHTTPError(http.StatusNotFound, structs.ErrorResponse{Error: "prompt not found"})
+	}
+
+	return ctx.JSON(http.StatusOK, versions)
+}
+
+// PreviewHandler сообщения, которые уйдут в модель при анализе загрузки guid промптом, без запроса к модели.
+// Загрузка ищется среди загрузок текущего пользователя
+func (e *Endpoint) PreviewHandler(ctx echo.Context) error {
+	logger := logdoc.GetLogger()
+
+	prompt, httpErr := e.prompt(ctx)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	var req structs.PromptPreviewRequest
+	if err := ctx.Bind(&req); err != nil || req.GUID == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, structs.ErrorResponse{Error: "upload guid required"})
+	}
+
+	text, version := prompt.PromptText, prompt.Version
+	if req.Version > 0 && req.Version != prompt.Version {
+		v, err := e.prompts.FindPromptVersion(prompt.ID, req.Version)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, structs.ErrorResponse{Error: "prompt version not found"})
+		}
+		text, version = v.PromptText, v.Version
+	}
+
+	user := ctx.Get("user").(*structs.User)
+	layers, err := e.layers.FindUploadLayers(req.GUID, user.ID)
+	if err != nil {
+		logger.Error(">> PreviewHandler > error reading upload layers, ", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, structs.ErrorResponse{Error: "error reading upload"})
+	}
+	if len(layers) == 0 {
+		return echo.NewHTTPError(http.StatusNotFound, structs.ErrorResponse{Error: "upload not found"})
+	}
+
+	data := prompttemplate.FromLayers(layers)
+	messages, err := prompttemplate.Messages(text, data)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, structs.ErrorResponse{Error: err.Error()})
+	}
+
+	preview := structs.PromptPreviewResponse{PromptID: prompt.ID, Version: version, GUID: req.GUID, Language: data.Language,
+		Messages: messages}
+	for _, m := range messages {
+		preview.Tokens += e.tokens(m.Content)
+	}
+
+	return ctx.JSON(http.StatusOK, preview)
+}
+
+// prompt промпт из параметра пути id
+func (e *Endpoint) prompt(ctx echo.Context) (*structs.Prompt, *echo.HTTPError) {
+	id, httpErr := promptID(ctx)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	prompt, err := e.prompts.FindPrompt(id)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusNotFound, structs.ErrorResponse{Error: "prompt not found"})
+	}
+	return prompt, nil
+}
+
+func promptID(ctx echo.Context) (int, *echo.HTTPError) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil || id <= 0 {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, structs.ErrorResponse{Error: "invalid prompt id"})
+	}
+	return id, nil
+}
+
+// bindPrompt промпт из тела запроса, шаблон должен разбираться и использовать только переменные prompttemplate.Data
+func bindPrompt(ctx echo.Context) (*structs.Prompt, *echo.HTTPError) {
+	var req structs.PromptRequest
+	if err := ctx.Bind(&req); err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, structs.ErrorResponse{Error: "invalid request body"})
+	}
+	if strings.TrimSpace(req.PromptText) == "" {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, structs.ErrorResponse{Error: "prompt_text required"})
+	}
+	if req.PromptStage <= 0 {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, structs.ErrorResponse{Error: "prompt_stage required"})
+	}
+	if err := prompttemplate.Validate(req.PromptText); err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, structs.ErrorResponse{Error: "invalid prompt template: " + err.Error()})
+	}
+
+	return &structs.Prompt{Name: req.Name, PromptText: req.PromptText, PromptStage: req.PromptStage, Language: req.Language}, nil
+}
@@ -0,0 +1,273 @@
+package prompts
+
+import (
+	"encoding/json"
+	"errors"
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"sse-demo-core/internal/app/interfaces/services"
+	"sse-demo-core/internal/app/structs"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	_, _ = logdoc.Init("udp", "127.0.0.1:9", "prompts-test")
+	os.Exit(m.Run())
+}
+
+// fakePrompts промпты в памяти: каждое сохранение добавляет версию, удаление только помечает промпт удаленным
+type fakePrompts struct {
+	services.PromptService
+
+	prompts  map[int]*structs.Prompt
+	deleted  map[int]bool
+	versions map[int][]structs.PromptVersion
+	saves    int
+}
+
+func newFakePrompts() *fakePrompts {
+	return &fakePrompts{prompts: make(map[int]*structs.Prompt), deleted: make(map[int]bool), versions: make(map[int][]structs.PromptVersion)}
+}
+
+func (f *fakePrompts) FindPrompt(id int) (*structs.Prompt, error) {
+	prompt, ok := f.prompts[id]
+	if !ok || f.deleted[id] {
+		return nil, errors.New("prompt not found")
+	}
+	p := *prompt
+	return &p, nil
+}
+
+func (f *fakePrompts) CreatePrompt(prompt *structs.Prompt, userID int) error {
+	f.saves++
+	prompt.ID, prompt.Version = len(f.prompts)+1, 1
+	f.save(prompt, userID)
+	return nil
+}
+
+func (f *fakePrompts) UpdatePrompt(prompt *structs.Prompt, userID int) (bool, error) {
+	f.saves++
+	current, ok := f.prompts[prompt.ID]
+	if !ok || f.deleted[prompt.ID] {
+		return false, nil
+	}
+	prompt.Version = current.Version + 1
+	f.save(prompt, userID)
+	return true, nil
+}
+
+func (f *fakePrompts) save(prompt *structs.Prompt, userID int) {
+	p := *prompt
+	f.prompts[prompt.ID] = &p
+	f.versions[prompt.ID] = append(f.versions[prompt.ID], structs.PromptVersion{PromptID: prompt.ID, Version: prompt.Version,
+		Name: prompt.Name, PromptText: prompt.PromptText, PromptStage: prompt.PromptStage, Language: prompt.Language, UserID: userID})
+}
+
+func (f *fakePrompts) DeletePrompt(id int) (bool, error) {
+	if _, ok := f.prompts[id]; !ok || f.deleted[id] {
+		return false, nil
+	}
+	f.deleted[id] = true
+	return true, nil
+}
+
+func (f *fakePrompts) FindPromptVersions(id int) ([]structs.PromptVersion, error) {
+	versions := append([]structs.PromptVersion(nil), f.versions[id]...)
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Version > versions[j].Version })
+	return versions, nil
+}
+
+func (f *fakePrompts) FindPromptVersion(id int, version int) (*structs.PromptVersion, error) {
+	for _, v := range f.versions[id] {
+		if v.Version == version {
+			return &v, nil
+		}
+	}
+	return nil, errors.New("version not found")
+}
+
+// fakeLayers загрузка upload пользователя 1
+type fakeLayers struct {
+	services.LayerService
+}
+
+func (f *fakeLayers) FindUploadLayers(guid string, userID int) ([]structs.UserLayer, error) {
+	if guid != "upload" || userID != 1 {
+		return nil, nil
+	}
+	return []structs.UserLayer{
+		{UUID: "l1", LayerName: "contract.pdf", SourceData: "Contract with [EMAIL_1]", Language: "ru", Tokens: 100},
+		{UUID: "l2", LayerName: "prices.xlsx", SourceData: "raw prices", OptimizedData: "optimized prices", Language: "en", Tokens: 10},
+	}, nil
+}
+
+func testEndpoint() (*Endpoint, *fakePrompts) {
+	prompts := newFakePrompts()
+	return &Endpoint{prompts: prompts, layers: &fakeLayers{}, tokens: func(text string) int { return len(strings.Fields(text)) }}, prompts
+}
+
+// call вызывает обработчик от имени администратора с id 1, возвращает статус и тело ответа
+func call(t *testing.T, handler echo.HandlerFunc, method string, id int, body string) (int, []byte) {
+	t.Helper()
+
+	req := httptest.NewRequest(method, "/", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+
+	ctx := echo.New().NewContext(req, rec)
+	if id > 0 {
+		ctx.SetParamNames("id")
+		ctx.SetParamValues(strconv.Itoa(id))
+	}
+	ctx.Set("user", &structs.User{ID: 1})
+
+	if err := handler(ctx); err != nil {
+		var httpErr *echo.HTTPError
+		if !errors.As(err, &httpErr) {
+			t.Fatalf("handler error %v", err)
+		}
+		return httpErr.Code, nil
+	}
+	return rec.Code, rec.Body.Bytes()
+}
+
+func decode[T any](t *testing.T, body []byte) T {
+	t.Helper()
+
+	var value T
+	if err := json.Unmarshal(body, &value); err != nil {
+		t.Fatalf("invalid response %s, %v", body, err)
+	}
+	return value
+}
+
+// TestSaveValidatesTemplate шаблон с ошибкой или неизвестной переменной не сохраняется
+func TestSaveValidatesTemplate(t *testing.T) {
+	e, prompts := testEndpoint()
+
+	invalid := []string{
+		`{"prompt_text": "Summarize {{.Document}}", "prompt_stage": 1}`,
+		`{"prompt_text": "Summarize {{.Content", "prompt_stage": 1}`,
+		`{"prompt_text": "{{range .Language}}{{end}}", "prompt_stage": 1}`,
+		`{"prompt_text": "  ", "prompt_stage": 1}`,
+		`{"prompt_text": "Summarize", "prompt_stage": 0}`,
+	}
+	for _, body := range invalid {
+		if code, _ := call(t, e.CreatePromptHandler, http.MethodPost, 0, body); code != http.StatusBadRequest {
+			t.Errorf("create %s: status %d, expected 400", body, code)
+		}
+	}
+	if prompts.saves != 0 {
+		t.Fatalf("%d invalid prompts saved", prompts.saves)
+	}
+
+	code, body := call(t, e.CreatePromptHandler, http.MethodPost, 0,
+		`{"name": "summary", "prompt_text": "Summarize {{.Files}} in {{.Language}}", "prompt_stage": 1}`)
+	if code != http.StatusCreated {
+		t.Fatalf("create valid prompt: status %d", code)
+	}
+	prompt := decode[structs.Prompt](t, body)
+
+	if code, _ = call(t, e.UpdatePromptHandler, http.MethodPut, prompt.ID, `{"prompt_text": "{{.Unknown}}", "prompt_stage": 1}`); code != http.StatusBadRequest {
+		t.Errorf("update with unknown variable: status %d, expected 400", code)
+	}
+	if prompts.saves != 1 {
+		t.Errorf("%d saves, invalid update must not be saved", prompts.saves)
+	}
+}
+
+// TestVersions изменения промпта сохраняются новыми версиями, история доступна и после удаления промпта
+func TestVersions(t *testing.T) {
+	e, _ := testEndpoint()
+
+	_, body := call(t, e.CreatePromptHandler, http.MethodPost, 0, `{"name": "v1", "prompt_text": "first", "prompt_stage": 1}`)
+	id := decode[structs.Prompt](t, body).ID
+
+	code, body := call(t, e.UpdatePromptHandler, http.MethodPut, id, `{"name": "v2", "prompt_text": "second", "prompt_stage": 1}`)
+	if code != http.StatusOK {
+		t.Fatalf("update: status %d", code)
+	}
+	if updated := decode[structs.Prompt](t, body); updated.Version != 2 || updated.PromptText != "second" {
+		t.Errorf("updated prompt %+v, expected version 2", updated)
+	}
+
+	if code, _ = call(t, e.DeletePromptHandler, http.MethodDelete, id, ""); code != http.StatusNoContent {
+		t.Fatalf("delete: status %d", code)
+	}
+	if code, _ = call(t, e.PromptHandler, http.MethodGet, id, ""); code != http.StatusNotFound {
+		t.Errorf("deleted prompt: status %d, expected 404", code)
+	}
+	if code, _ = call(t, e.UpdatePromptHandler, http.MethodPut, id, `{"prompt_text": "third", "prompt_stage": 1}`); code != http.StatusNotFound {
+		t.Errorf("update of deleted prompt: status %d, expected 404", code)
+	}
+
+	code, body = call(t, e.VersionsHandler, http.MethodGet, id, "")
+	if code != http.StatusOK {
+		t.Fatalf("versions of deleted prompt: status %d", code)
+	}
+	versions := decode[[]structs.PromptVersion](t, body)
+	if len(versions) != 2 || versions[0].Version != 2 || versions[0].PromptText != "second" || versions[1].PromptText != "first" {
+		t.Errorf("versions %+v, expected 2 and 1", versions)
+	}
+
+	if code, _ = call(t, e.VersionsHandler, http.MethodGet, id+1, ""); code != http.StatusNotFound {
+		t.Errorf("versions of missing prompt: status %d, expected 404", code)
+	}
+}
+
+// TestPreview предпросмотр подставляет в шаблон данные сохраненной загрузки пользователя
+func TestPreview(t *testing.T) {
+	e, _ := testEndpoint()
+
+	_, body := call(t, e.CreatePromptHandler, http.MethodPost, 0,
+		`{"prompt_text": "Files: {{range .Files}}{{.}} {{end}}language {{.Language}}", "prompt_stage": 1}`)
+	id := decode[structs.Prompt](t, body).ID
+	_, _ = call(t, e.UpdatePromptHandler, http.MethodPut, id, `{"prompt_text": "Summarize:\n{{.Content}}", "prompt_stage": 1}`)
+
+	code, body := call(t, e.PreviewHandler, http.MethodPost, id, `{"guid": "upload", "version": 1}`)
+	if code != http.StatusOK {
+		t.Fatalf("preview: status %d", code)
+	}
+	preview := decode[structs.PromptPreviewResponse](t, body)
+	if preview.Version != 1 || preview.Language != "ru" || len(preview.Messages) != 2 {
+		t.Fatalf("preview %+v", preview)
+	}
+	if preview.Messages[0].Content != "Files: contract.pdf prices.xlsx language ru" {
+		t.Errorf("system message %q", preview.Messages[0].Content)
+	}
+	// в модель уходит очищенное и оптимизированное содержимое слоев
+	content := "### contract.pdf\n\nContract with [EMAIL_1]\n\n### prices.xlsx\n\noptimized prices"
+	if preview.Messages[1].Content != content {
+		t.Errorf("user message %q, expected %q", preview.Messages[1].Content, content)
+	}
+	if preview.Tokens != 14 {
+		t.Errorf("tokens %d, expected 14", preview.Tokens)
+	}
+
+	// текущая версия подставляет содержимое в сам промпт и уходит одним сообщением
+	_, body = call(t, e.PreviewHandler, http.MethodPost, id, `{"guid": "upload"}`)
+	preview = decode[structs.PromptPreviewResponse](t, body)
+	if preview.Version != 2 || len(preview.Messages) != 1 || preview.Messages[0].Content != "Summarize:\n"+content {
+		t.Errorf("preview of current version %+v", preview)
+	}
+
+	cases := []struct {
+		body string
+		code int
+	}{
+		{`{"guid": "foreign"}`, http.StatusNotFound},
+		{`{"guid": "upload", "version": 5}`, http.StatusNotFound},
+		{`{}`, http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		if code, _ = call(t, e.PreviewHandler, http.MethodPost, id, c.body); code != c.code {
+			t.Errorf("preview %s: status %d, expected %d", c.body, code, c.code)
+		}
+	}
+}
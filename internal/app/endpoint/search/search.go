@@ -0,0 +1,131 @@
+package search
+
+import (
+	"errors"
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"github.com/golang-jwt/jwt"
+	"github.com/gurkankaymak/hocon"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"sse-demo-core/internal/app/breaker"
+	"sse-demo-core/internal/app/embedding"
+	"sse-demo-core/internal/app/interfaces/services"
+	"sse-demo-core/internal/app/structs"
+	"sse-demo-core/internal/app/utils"
+	"strings"
+)
+
+const defaultTopK = 5
+
+type Endpoint struct {
+	config     *hocon.Config
+	users      services.UserService
+	search     services.SearchService
+	redactions services.RedactionService
+	embedder   *embedding.Embedder
+}
+
+func New(config *hocon.Config, userSvc services.UserService, searchSvc services.SearchService, redactionSvc services.RedactionService,
+	embedder *embedding.Embedder) *Endpoint {
+	return &Endpoint{config: config, users: userSvc, search: searchSvc, redactions: redactionSvc, embedder: embedder}
+}
+
+// SearchHandler фрагменты загрузок пользователя, ближайшие к запросу, с файлом и страницей источника
+func (e *Endpoint) SearchHandler(ctx echo.Context) error {
+	logger := logdoc.GetLogger()
+
+	user, httpErr := e.user(ctx)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	if !e.embedder.Enabled() {
+		return echo.NewHTTPError(http.StatusServiceUnavailable, structs.ErrorResponse{Error: "search is disabled"})
+	}
+
+	var req structs.SearchRequest
+	if err := ctx.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, structs.ErrorResponse{Error: "invalid search request"})
+	}
+	req.Query = strings.TrimSpace(req.Query)
+	if req.Query == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, structs.ErrorResponse{Error: "empty query"})
+	}
+
+	response, err := e.embedder.Embed(ctx.Request().Context(), []string{req.Query})
+	if err != nil {
+		logger.Error(">> SearchHandler > error computing query embedding of userId:", user.ID, ", ", err)
+		return providerError(err)
+	}
+
+	chunks, err := e.find(user.ID, response, req)
+	if err != nil {
+		logger.Error(">> SearchHandler > error searching uploads of userId:", user.ID, ", ", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, structs.ErrorResponse{Error: "error searching uploads"})
+	}
+	if chunks == nil {
+		chunks = []structs.LayerChunk{}
+	}
+
+	return ctx.JSON(http.StatusOK, structs.SearchResponse{Query: req.Query, Model: response.Model, Results: chunks})
+}
+
+// find фрагменты, ближайшие к эмбеддингу запроса query, с восстановленными персональными данными
+func (e *Endpoint) find(userID int, query *structs.EmbeddingResponse, req structs.SearchRequest) ([]structs.LayerChunk, error) {
+	chunks, err := e.search.Search(userID, query.Model, query.Embeddings[0], req.GUIDs, e.topK(req.TopK))
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range chunks {
+		if chunks[i].Content, err = e.redactions.RestoreLayer(chunks[i].GUID, chunks[i].UUID, userID, chunks[i].Content); err != nil {
+			return nil, err
+		}
+	}
+	return chunks, nil
+}
+
+// topK количество фрагментов: по умолчанию search.top_k, не больше search.max_top_k
+func (e *Endpoint) topK(requested int) int {
+	topK := requested
+	if topK <= 0 {
+		topK = e.config.GetInt("search.top_k")
+	}
+	if topK <= 0 {
+		topK = defaultTopK
+	}
+	if limit := e.config.GetInt("search.max_top_k"); limit > 0 && topK > limit {
+		topK = limit
+	}
+	return topK
+}
+
+// providerError недоступность провайдера отдается как 503 с причиной, остальные ошибки провайдера - как 502
+func providerError(err error) *echo.HTTPError {
+	var openErr *breaker.OpenError
+	switch {
+	case errors.As(err, &openErr):
+		return echo.NewHTTPError(http.StatusServiceUnavailable, structs.ErrorResponse{Code: http.StatusServiceUnavailable,
+			Error: err.Error(), Reason: "provider_unavailable"})
+	case errors.Is(err, breaker.ErrBulkheadFull):
+		return echo.NewHTTPError(http.StatusServiceUnavailable, structs.ErrorResponse{Code: http.StatusServiceUnavailable,
+			Error: err.Error(), Reason: "provider_busy"})
+	}
+	return echo.NewHTTPError(http.StatusBadGateway, structs.ErrorResponse{Code: http.StatusBadGateway, Error: err.Error()})
+}
+
+// user пользователь из claims, их кладет в контекст headerchecker
+func (e *Endpoint) user(ctx echo.Context) (*structs.User, *echo.HTTPError) {
+	logger := logdoc.GetLogger()
+
+	claims, ok := ctx.Get("claims").(jwt.MapClaims)
+	if !ok {
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, structs.ErrorResponse{Error: "authorization required"})
+	}
+	user, err := utils.GetUserFromClaims(claims, e.users)
+	if err != nil {
+		logger.Error(">> error getting user from token claims, ", err)
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, structs.ErrorResponse{Error: "user not found"})
+	}
+	return user, nil
+}
@@ -0,0 +1,75 @@
+package usage
+
+import (
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"sse-demo-core/internal/app/interfaces/services"
+	"sse-demo-core/internal/app/structs"
+	"sse-demo-core/internal/app/utils"
+)
+
+type Endpoint struct {
+	users services.UserService
+	usage services.UsageService
+}
+
+func New(userSvc services.UserService, usageSvc services.UsageService) *Endpoint {
+	return &Endpoint{users: userSvc, usage: usageSvc}
+}
+
+// MyUsageHandler расход пользователя за текущий и прошлый месяц, год и всего, с квотой его роли
+func (e *Endpoint) MyUsageHandler(ctx echo.Context) error {
+	logger := logdoc.GetLogger()
+
+	claims, ok := ctx.Get("claims").(jwt.MapClaims)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, structs.ErrorResponse{Error: "authorization required"})
+	}
+	user, err := utils.GetUserFromClaims(claims, e.users)
+	if err != nil {
+		logger.Error(">> error getting user from token claims, ", err)
+		return echo.NewHTTPError(http.StatusUnauthorized, structs.ErrorResponse{Error: "user not found"})
+	}
+
+	usage, err := e.usage.FindUserUsage(user)
+	if err != nil {
+		logger.Error(">> MyUsageHandler > error reading usage of userId:", user.ID, ", ", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, structs.ErrorResponse{Error: "error reading usage"})
+	}
+
+	return ctx.JSON(http.StatusOK, usage)
+}
+
+// UsersUsageHandler расход всех пользователей, только для ролей admin.roles
+func (e *Endpoint) UsersUsageHandler(ctx echo.Context) error {
+	logger := logdoc.GetLogger()
+
+	usage, err := e.usage.FindUsersUsage()
+	if err != nil {
+		logger.Error(">> UsersUsageHandler > error reading usage, ", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, structs.ErrorResponse{Error: "error reading usage"})
+	}
+	if usage == nil {
+		usage = []structs.UserUsage{}
+	}
+
+	return ctx.JSON(http.StatusOK, usage)
+}
+
+// MessagesStatisticsHandler количество сообщений пользователей в тредах ассистентов, только для ролей admin.roles
+func (e *Endpoint) MessagesStatisticsHandler(ctx echo.Context) error {
+	logger := logdoc.GetLogger()
+
+	statistics, err := e.usage.FindMessagesStatistics()
+	if err != nil {
+		logger.Error(">> MessagesStatisticsHandler > error reading messages statistics, ", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, structs.ErrorResponse{Error: "error reading messages statistics"})
+	}
+	if statistics == nil {
+		statistics = []structs.UserMessagesStatistics{}
+	}
+
+	return ctx.JSON(http.StatusOK, statistics)
+}
@@ -0,0 +1,185 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"github.com/go-resty/resty/v2"
+	"github.com/gurkankaymak/hocon"
+	"sse-demo-core/internal/app/retry"
+	"sse-demo-core/internal/app/structs"
+	"sse-demo-core/internal/app/utils"
+	"strings"
+)
+
+// Assistants клиент OpenAI Assistants API: файлы, треды, сообщения и запуски ассистента.
+// Работает с integration.openai независимо от активного провайдера, за breaker и bulkhead OpenAI
+type Assistants struct {
+	client        *resty.Client
+	guard         guard
+	retry         retry.Policy
+	baseURL       string
+	filesURI      string
+	assistantsURI string
+	threadsURI    string
+	token         string
+	assistant     string
+}
+
+func NewAssistants(config *hocon.Config, guards *Guards) *Assistants {
+	return &Assistants{
+		client:        resty.New().SetPreRequestHook(utils.CurlLogger),
+		guard:         guards.get(ProviderOpenAI),
+		retry:         retry.FromConfig(config),
+		baseURL:       baseURL(config, ProviderOpenAI),
+		filesURI:      config.GetString("integration.openai.uri.files"),
+		assistantsURI: strings.TrimSuffix(config.GetString("integration.openai.uri.assistants"), "/"),
+		threadsURI:    config.GetString("integration.openai.uri.threads"),
+		token:         config.GetString("integration.openai.token"),
+		assistant:     config.GetString("integration.openai.assistant"),
+	}
+}
+
+// DefaultAssistant ассистент из integration.openai.assistant, запускается, если в запросе ассистент не указан
+func (a *Assistants) DefaultAssistant() string {
+	return a.assistant
+}
+
+// do выполняет запрос send с авторизацией и повторами по политике retry под breaker и bulkhead OpenAI,
+// ответ с ошибкой превращается в APIError
+func (a *Assistants) do(ctx context.Context, send func(r *resty.Request) (*resty.Response, error)) error {
+	return a.guard.call(ctx, func() error {
+		response, err := retry.Do(ctx, a.retry, ProviderOpenAI, func() (*resty.Response, error) {
+			return send(a.client.R().
+				SetContext(ctx).
+				SetAuthToken(a.token).
+				SetHeader("OpenAI-Beta", "assistants=v1"))
+		})
+		if err != nil {
+			return err
+		}
+		if response.IsError() {
+			return apiError(ProviderOpenAI, response)
+		}
+		return nil
+	}, nil)
+}
+
+// UploadFile загружает файл с назначением assistants
+func (a *Assistants) UploadFile(ctx context.Context, name string, data []byte) (*structs.AssistantsFileUploadResponse, error) {
+	logger := logdoc.GetLogger()
+	logger.Debug("Uploading assistants file ", name, "...")
+
+	var file structs.AssistantsFileUploadResponse
+	err := a.do(ctx, func(r *resty.Request) (*resty.Response, error) {
+		return r.SetMultipartFormData(map[string]string{"purpose": "assistants"}).
+			SetFileReader("file", name, bytes.NewReader(data)).
+			SetResult(&file).
+			Post(a.baseURL + a.filesURI)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// LinkFile подключает загруженный файл к ассистенту для retrieval
+func (a *Assistants) LinkFile(ctx context.Context, assistantID string, fileID string) (*structs.AssistantsLinkFileToAssistant, error) {
+	var link structs.AssistantsLinkFileToAssistant
+	err := a.do(ctx, func(r *resty.Request) (*resty.Response, error) {
+		return r.SetBody(structs.LinkRequest{FileID: fileID}).
+			SetResult(&link).
+			Post(a.baseURL + a.assistantsURI + "/" + assistantID + "/files")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+func (a *Assistants) CreateThread(ctx context.Context) (*structs.AssistantsThread, error) {
+	var thread structs.AssistantsThread
+	err := a.do(ctx, func(r *resty.Request) (*resty.Response, error) {
+		return r.SetBody(map[string]any{}).
+			SetResult(&thread).
+			Post(a.baseURL + a.threadsURI)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &thread, nil
+}
+
+func (a *Assistants) CreateMessage(ctx context.Context, threadID string, req structs.ThreadMessageRequest) (*structs.AIMessage, error) {
+	if req.Role == "" {
+		req.Role = "user"
+	}
+
+	var message structs.AIMessage
+	err := a.do(ctx, func(r *resty.Request) (*resty.Response, error) {
+		return r.SetBody(req).
+			SetResult(&message).
+			Post(a.baseURL + a.threadsURI + "/" + threadID + "/messages")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// ListMessages последние сообщения треда, новые первыми
+func (a *Assistants) ListMessages(ctx context.Context, threadID string) (*structs.ListAIMessages, error) {
+	var messages structs.ListAIMessages
+	err := a.do(ctx, func(r *resty.Request) (*resty.Response, error) {
+		return r.SetQueryParams(map[string]string{"order": "desc", "limit": "100"}).
+			SetResult(&messages).
+			Get(a.baseURL + a.threadsURI + "/" + threadID + "/messages")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &messages, nil
+}
+
+func (a *Assistants) CreateRun(ctx context.Context, threadID string, req structs.ThreadRunRequest) (*structs.AIThreadRun, error) {
+	if req.AssistantID == "" {
+		req.AssistantID = a.assistant
+	}
+
+	var run structs.AIThreadRun
+	err := a.do(ctx, func(r *resty.Request) (*resty.Response, error) {
+		return r.SetBody(req).
+			SetResult(&run).
+			Post(a.baseURL + a.threadsURI + "/" + threadID + "/runs")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+func (a *Assistants) GetRun(ctx context.Context, threadID string, runID string) (*structs.AIThreadRun, error) {
+	var run structs.AIThreadRun
+	err := a.do(ctx, func(r *resty.Request) (*resty.Response, error) {
+		return r.SetResult(&run).
+			Get(a.baseURL + a.threadsURI + "/" + threadID + "/runs/" + runID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// ListRunSteps шаги запуска в порядке создания
+func (a *Assistants) ListRunSteps(ctx context.Context, threadID string, runID string) (*structs.AIRunStepDetails, error) {
+	var steps structs.AIRunStepDetails
+	err := a.do(ctx, func(r *resty.Request) (*resty.Response, error) {
+		return r.SetQueryParams(map[string]string{"order": "asc", "limit": "100"}).
+			SetResult(&steps).
+			Get(a.baseURL + a.threadsURI + "/" + threadID + "/runs/" + runID + "/steps")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &steps, nil
+}
@@ -0,0 +1,175 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sse-demo-core/internal/app/breaker"
+	"sse-demo-core/internal/app/caching"
+	"sse-demo-core/internal/app/integration/llm/llmtest"
+	"sse-demo-core/internal/app/structs"
+	"testing"
+)
+
+func newAssistants(t *testing.T, reply string) (*llmtest.Server, *Assistants) {
+	server := newServer(t, reply)
+	config := testConfig(t, server, ProviderGigaChat, "")
+	return server, NewAssistants(config, NewGuards(config))
+}
+
+func TestAssistantsRun(t *testing.T) {
+	server, a := newAssistants(t, "Сумма счета 100 рублей")
+	ctx := context.Background()
+
+	data := []byte("Счет №15 на 100 рублей")
+	file, err := a.UploadFile(ctx, "invoice.txt", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.ID == "" || file.Filename != "invoice.txt" || file.Purpose != "assistants" || file.Bytes != len(data) {
+		t.Fatalf("unexpected file: %+v", file)
+	}
+	link, err := a.LinkFile(ctx, a.DefaultAssistant(), file.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link.ID != file.ID || link.AssistantID != "asst-default" {
+		t.Fatalf("unexpected link: %+v", link)
+	}
+
+	thread, err := a.CreateThread(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	message, err := a.CreateMessage(ctx, thread.ID, structs.ThreadMessageRequest{Content: "Какая сумма?", FileIDS: []string{file.ID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message.Role != "user" || message.ThreadID != thread.ID || len(message.FileIds) != 1 {
+		t.Fatalf("unexpected message: %+v", message)
+	}
+
+	// без ассистента в запросе запускается ассистент из конфига
+	run, err := a.CreateRun(ctx, thread.ID, structs.ThreadRunRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.AssistantID != "asst-default" || run.Status != "queued" {
+		t.Fatalf("unexpected run: %+v", run)
+	}
+	if run, err = a.GetRun(ctx, thread.ID, run.ID); err != nil {
+		t.Fatal(err)
+	}
+	if run.Status != "completed" || run.Usage == nil {
+		t.Fatalf("unexpected run: %+v", run)
+	}
+
+	steps, err := a.ListRunSteps(ctx, thread.ID, run.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(steps.Data) != 1 || steps.Data[0].Status != "completed" {
+		t.Fatalf("unexpected steps: %+v", steps)
+	}
+
+	messages, err := a.ListMessages(ctx, thread.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages.Data) != 2 || messages.Data[0].Role != "assistant" || messages.Data[0].Content[0].Text.Value != "Сумма счета 100 рублей" {
+		t.Fatalf("unexpected messages: %+v", messages)
+	}
+
+	for _, request := range server.Requests() {
+		if request.Authorization != "Bearer sk-test" {
+			t.Fatalf("request %s with authorization %q", request.Path, request.Authorization)
+		}
+	}
+}
+
+func TestAssistantsRunFailed(t *testing.T) {
+	server, a := newAssistants(t, "")
+	server.RunStatus = "failed"
+	ctx := context.Background()
+
+	thread, err := a.CreateThread(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	run, err := a.CreateRun(ctx, thread.ID, structs.ThreadRunRequest{AssistantID: "asst-other"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run, err = a.GetRun(ctx, thread.ID, run.ID); err != nil {
+		t.Fatal(err)
+	}
+	if run.Status != "failed" || run.AssistantID != "asst-other" || run.LastError.Message == "" {
+		t.Fatalf("unexpected run: %+v", run)
+	}
+}
+
+func TestAssistantsErrors(t *testing.T) {
+	server, a := newAssistants(t, "")
+
+	_, err := a.ListMessages(context.Background(), "thread-unknown")
+	var apiErr structs.APIError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusNotFound {
+		t.Fatalf("got %v, want APIError 404", err)
+	}
+
+	server.Fail(http.StatusServiceUnavailable, 1)
+	if _, err = a.CreateThread(context.Background()); err != nil {
+		t.Fatalf("got %v, want success after retry", err)
+	}
+}
+
+// TestAssistantsSharedBreaker Assistants API и модель OpenAI работают за общим breaker:
+// ошибки ассистента открывают его и для запросов к модели, и наоборот
+func TestAssistantsSharedBreaker(t *testing.T) {
+	server := newServer(t, "ok")
+	config := testConfig(t, server, ProviderOpenAI, "retry.max_attempts = 1")
+	guards := NewGuards(config)
+	a := NewAssistants(config, guards)
+	provider, err := New(config, caching.NewMemoryCache(10), guards)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &testProvider{provider: provider}
+
+	// ответы 4xx не считаются отказом провайдера
+	for i := 0; i < 3; i++ {
+		if _, err = a.ListMessages(context.Background(), "thread-unknown"); errors.Is(err, breaker.ErrOpen) {
+			t.Fatalf("request %d: breaker opened on client errors", i)
+		}
+	}
+
+	server.Fail(http.StatusInternalServerError, 0)
+	for i := 0; i < 2; i++ {
+		if _, err = a.CreateThread(context.Background()); errors.Is(err, breaker.ErrOpen) || err == nil {
+			t.Fatalf("request %d: got %v, want provider error", i, err)
+		}
+	}
+	requests := len(server.Requests())
+
+	if _, err = p.complete("hi"); !errors.Is(err, breaker.ErrOpen) {
+		t.Fatalf("model request: got %v, want open breaker error", err)
+	}
+	if _, err = a.CreateThread(context.Background()); !errors.Is(err, breaker.ErrOpen) {
+		t.Fatalf("assistants request: got %v, want open breaker error", err)
+	}
+	if n := len(server.Requests()); n != requests {
+		t.Fatalf("server got %d requests with open breaker", n-requests)
+	}
+}
+
+func TestGuardsPerProvider(t *testing.T) {
+	guards := NewGuards(testConfig(t, newServer(t, ""), ProviderOpenAI, ""))
+
+	openai := guards.get(ProviderOpenAI)
+	if again := guards.get(ProviderOpenAI); again.breaker != openai.breaker || again.bulkhead != openai.bulkhead {
+		t.Fatal("second call created new breaker and bulkhead for the provider")
+	}
+	if other := guards.get(ProviderGigaChat); other.breaker == openai.breaker || other.bulkhead == openai.bulkhead {
+		t.Fatal("providers share breaker or bulkhead")
+	}
+}
@@ -0,0 +1,157 @@
+package llm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"sse-demo-core/internal/app/caching"
+	"sse-demo-core/internal/app/interfaces/services"
+	"sse-demo-core/internal/app/structs"
+	"time"
+)
+
+var cacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "sse_demo_core",
+	Subsystem: "llm_cache",
+	Name:      "requests_total",
+	Help:      "Запросы к модели через кеш ответов: hit, miss, bypass",
+}, []string{"provider", "result"})
+
+// BypassHeader заголовок запроса к API, при значении true ответ модели не берется из кеша
+const BypassHeader = "X-Cache-Bypass"
+
+type bypassKey struct{}
+
+// BypassCache запрос с этим контекстом идет в модель мимо кеша, свежий ответ сохраняется в кеш
+func BypassCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassKey{}, true)
+}
+
+func bypassed(ctx context.Context) bool {
+	bypass, _ := ctx.Value(bypassKey{}).(bool)
+	return bypass
+}
+
+// cached провайдер с кешем ответов: одинаковые запросы (сообщения, модель, параметры) берутся из кеша
+type cached struct {
+	provider services.LLMProvider
+	cache    caching.Cache
+	ttl      time.Duration
+	// model модель провайдера из конфига, подставляется в ключ вместо пустой модели запроса
+	model string
+}
+
+func Cached(provider services.LLMProvider, cache caching.Cache, ttl time.Duration, model string) services.LLMProvider {
+	return &cached{provider: provider, cache: cache, ttl: ttl, model: model}
+}
+
+func (c *cached) Name() string {
+	return c.provider.Name()
+}
+
+func (c *cached) Complete(ctx context.Context, req structs.LLMRequest) (*structs.LLMResponse, error) {
+	key := c.key(req)
+	if response, ok := c.lookup(ctx, key); ok {
+		return response, nil
+	}
+
+	response, err := c.provider.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	c.store(key, response)
+	return response, nil
+}
+
+// Stream ответ из кеша отдается одним фрагментом
+func (c *cached) Stream(ctx context.Context, req structs.LLMRequest, onDelta func(delta string) error) (*structs.LLMResponse, error) {
+	key := c.key(req)
+	if response, ok := c.lookup(ctx, key); ok {
+		if err := onDelta(response.Content); err != nil {
+			return nil, err
+		}
+		return response, nil
+	}
+
+	response, err := c.provider.Stream(ctx, req, onDelta)
+	if err != nil {
+		return nil, err
+	}
+	c.store(key, response)
+	return response, nil
+}
+
+func (c *cached) ListModels(ctx context.Context) ([]structs.Model, error) {
+	return c.provider.ListModels(ctx)
+}
+
+// Embed эмбеддинги не кешируются, содержимое загрузки индексируется один раз
+func (c *cached) Embed(ctx context.Context, req structs.EmbeddingRequest) (*structs.EmbeddingResponse, error) {
+	return c.provider.Embed(ctx, req)
+}
+
+// key sha256 от провайдера, модели, сообщений и параметров запроса
+func (c *cached) key(req structs.LLMRequest) string {
+	if req.Model == "" {
+		req.Model = c.model
+	}
+	data, _ := json.Marshal(struct {
+		Provider string
+		structs.LLMRequest
+	}{c.provider.Name(), req})
+
+	sum := sha256.Sum256(data)
+	return "llm:" + hex.EncodeToString(sum[:])
+}
+
+func (c *cached) lookup(ctx context.Context, key string) (*structs.LLMResponse, bool) {
+	logger := logdoc.GetLogger()
+
+	if bypassed(ctx) {
+		cacheRequests.WithLabelValues(c.provider.Name(), "bypass").Inc()
+		return nil, false
+	}
+
+	value, err := c.cache.Get(key)
+	if err != nil {
+		if !errors.Is(err, caching.ErrNotFound) {
+			logger.Warn(">> error reading llm response cache, ", err)
+		}
+		cacheRequests.WithLabelValues(c.provider.Name(), "miss").Inc()
+		return nil, false
+	}
+
+	data, ok := value.(string)
+	var response structs.LLMResponse
+	if !ok || json.Unmarshal([]byte(data), &response) != nil {
+		cacheRequests.WithLabelValues(c.provider.Name(), "miss").Inc()
+		return nil, false
+	}
+
+	cacheRequests.WithLabelValues(c.provider.Name(), "hit").Inc()
+	response.Cached = true
+	return &response, true
+}
+
+func (c *cached) store(key string, response *structs.LLMResponse) {
+	logger := logdoc.GetLogger()
+
+	// обрезанный по лимиту токенов ответ не кешируем
+	if response.Content == "" || response.FinishReason == "length" {
+		return
+	}
+
+	data, err := json.Marshal(response)
+	if err != nil {
+		logger.Warn(">> error encoding llm response cache, ", err)
+		return
+	}
+	if err = c.cache.Set(key, string(data), c.ttl); err != nil {
+		logger.Warn(">> error writing llm response cache, ", err)
+	}
+}
@@ -0,0 +1,236 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"github.com/go-resty/resty/v2"
+	"net/http"
+	"sse-demo-core/internal/app/retry"
+	"sse-demo-core/internal/app/structs"
+	"sse-demo-core/internal/app/utils"
+	"strings"
+)
+
+// compatClient клиент API, совместимого с OpenAI chat completions, на нем работают OpenAI и GigaChat
+type compatClient struct {
+	name      string
+	client    *resty.Client
+	retry     retry.Policy
+	baseURL   string
+	chatURI   string
+	modelsURI string
+	model     string
+	// embeddingsURI и embeddingModel запросы эмбеддингов, пустой embeddingsURI - провайдер их не поддерживает
+	embeddingsURI  string
+	embeddingModel string
+	// body тело запроса в формате провайдера
+	body func(req structs.LLMRequest, model string, stream bool) any
+	// auth заголовки авторизации запроса
+	auth func(ctx context.Context) (map[string]string, error)
+	// unauthorized вызывается, если API ответило 401 на запрос с заголовками headers.
+	// true - авторизация обновлена, запрос повторяется один раз
+	unauthorized func(headers map[string]string) bool
+}
+
+func (c *compatClient) Name() string {
+	return c.name
+}
+
+// do выполняет запрос send с заголовками авторизации и повторами по политике retry,
+// при 401 обновляет авторизацию и повторяет запрос
+func (c *compatClient) do(ctx context.Context, send func(r *resty.Request) (*resty.Response, error)) (*resty.Response, error) {
+	for attempt := 0; ; attempt++ {
+		headers, err := c.auth(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		response, err := retry.Do(ctx, c.retry, c.name, func() (*resty.Response, error) {
+			return send(c.client.R().
+				SetContext(ctx).
+				SetHeader("Content-Type", "application/json").
+				SetHeaders(headers))
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if response.StatusCode() != http.StatusUnauthorized || attempt > 0 || c.unauthorized == nil || !c.unauthorized(headers) {
+			return response, nil
+		}
+
+		// тело потокового ответа resty не читает и не закрывает
+		if body := response.RawBody(); body != nil {
+			_ = body.Close()
+		}
+		logdoc.GetLogger().Warn(c.name, " responded 401, retrying with refreshed authorization")
+	}
+}
+
+func (c *compatClient) modelFor(req structs.LLMRequest) string {
+	if req.Model != "" {
+		return req.Model
+	}
+	return c.model
+}
+
+func (c *compatClient) Complete(ctx context.Context, req structs.LLMRequest) (*structs.LLMResponse, error) {
+	logger := logdoc.GetLogger()
+	logger.Debug("Executing ", c.name, " chat completion...")
+
+	var data structs.OpenAIResponse
+	response, err := c.do(ctx, func(r *resty.Request) (*resty.Response, error) {
+		return r.SetBody(c.body(req, c.modelFor(req), false)).
+			SetResult(&data).
+			Post(c.baseURL + c.chatURI)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if response.IsError() {
+		return nil, apiError(c.name, response)
+	}
+	if len(data.Choices) == 0 {
+		return nil, errors.New(c.name + ": empty choices in response")
+	}
+
+	return &structs.LLMResponse{
+		Provider:     c.name,
+		Model:        data.Model,
+		Content:      messageContent(data.Choices[0].Message),
+		FinishReason: data.Choices[0].FinishReason,
+		Usage:        data.Usage,
+	}, nil
+}
+
+// messageContent текст ответа, при вызове функции - ее аргументы JSON
+func messageContent(message structs.Message) string {
+	if message.FunctionCall == nil || len(message.FunctionCall.Arguments) == 0 {
+		return message.Content
+	}
+	var arguments string
+	if err := json.Unmarshal(message.FunctionCall.Arguments, &arguments); err == nil {
+		return arguments
+	}
+	return string(message.FunctionCall.Arguments)
+}
+
+func (c *compatClient) Stream(ctx context.Context, req structs.LLMRequest, onDelta func(delta string) error) (*structs.LLMResponse, error) {
+	logger := logdoc.GetLogger()
+	logger.Debug("Executing ", c.name, " streaming chat completion...")
+
+	response, err := c.do(ctx, func(r *resty.Request) (*resty.Response, error) {
+		return r.SetBody(c.body(req, c.modelFor(req), true)).
+			SetHeader("Accept", "text/event-stream").
+			SetDoNotParseResponse(true).
+			Post(c.baseURL + c.chatURI)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if response.IsError() {
+		return nil, streamError(c.name, response)
+	}
+
+	body := response.RawBody()
+	defer body.Close()
+
+	result := &structs.LLMResponse{Provider: c.name, Model: c.modelFor(req)}
+	var content strings.Builder
+
+	err = ReadStream(ctx, body, func(data []byte) error {
+		var chunk structs.OpenAIStreamingResponse
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return err
+		}
+		if chunk.Model != "" {
+			result.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			result.Usage = *chunk.Usage
+		}
+		for _, choice := range chunk.Choices {
+			if choice.FinishReason != "" {
+				result.FinishReason = choice.FinishReason
+			}
+			if choice.Delta.Content == "" {
+				continue
+			}
+			content.WriteString(choice.Delta.Content)
+			if err := onDelta(choice.Delta.Content); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result.Content = content.String()
+	return result, nil
+}
+
+func (c *compatClient) ListModels(ctx context.Context) ([]structs.Model, error) {
+	var data structs.OpenAIModelsResponse
+	response, err := c.do(ctx, func(r *resty.Request) (*resty.Response, error) {
+		return r.SetResult(&data).Get(c.baseURL + c.modelsURI)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if response.IsError() {
+		return nil, apiError(c.name, response)
+	}
+
+	return data.Data, nil
+}
+
+func (c *compatClient) Embed(ctx context.Context, req structs.EmbeddingRequest) (*structs.EmbeddingResponse, error) {
+	logger := logdoc.GetLogger()
+	logger.Debug("Executing ", c.name, " embeddings of ", len(req.Input), " texts...")
+
+	if c.embeddingsURI == "" {
+		return nil, errors.New(c.name + ": embeddings are not configured")
+	}
+
+	model := req.Model
+	if model == "" {
+		model = c.embeddingModel
+	}
+
+	var data structs.OpenAIEmbeddingResponse
+	response, err := c.do(ctx, func(r *resty.Request) (*resty.Response, error) {
+		return r.SetBody(structs.OpenAIEmbeddingRequest{Model: model, Input: req.Input}).
+			SetResult(&data).
+			Post(c.baseURL + c.embeddingsURI)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if response.IsError() {
+		return nil, apiError(c.name, response)
+	}
+	if len(data.Data) != len(req.Input) {
+		return nil, fmt.Errorf("%s: %d embeddings in response for %d texts", c.name, len(data.Data), len(req.Input))
+	}
+
+	// порядок эмбеддингов в ответе задает index
+	embeddings := make([][]float32, len(req.Input))
+	for _, item := range data.Data {
+		if item.Index < 0 || item.Index >= len(embeddings) {
+			return nil, fmt.Errorf("%s: embedding index %d out of range", c.name, item.Index)
+		}
+		embeddings[item.Index] = item.Embedding
+	}
+
+	return &structs.EmbeddingResponse{
+		Provider:   c.name,
+		Model:      utils.Ternary(data.Model == "", model, data.Model).(string),
+		Embeddings: embeddings,
+		Usage:      data.Usage,
+	}, nil
+}
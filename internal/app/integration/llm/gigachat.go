@@ -0,0 +1,72 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-resty/resty/v2"
+	"github.com/gurkankaymak/hocon"
+	"sse-demo-core/internal/app/retry"
+	"sse-demo-core/internal/app/structs"
+	"sse-demo-core/internal/app/utils"
+	"strings"
+)
+
+// GigaChat клиент GigaChat API, совместимого с OpenAI chat completions
+type GigaChat struct {
+	compatClient
+}
+
+func NewGigaChat(config *hocon.Config, tokens *GigaChatTokens) *GigaChat {
+	return &GigaChat{compatClient{
+		name:           ProviderGigaChat,
+		client:         resty.New().SetPreRequestHook(utils.CurlLogger),
+		retry:          retry.FromConfig(config),
+		baseURL:        baseURL(config, ProviderGigaChat),
+		chatURI:        config.GetString("integration.gigachat.uri.chat"),
+		modelsURI:      config.GetString("integration.gigachat.uri.models"),
+		model:          config.GetString("integration.gigachat.model"),
+		embeddingsURI:  config.GetString("integration.gigachat.uri.embeddings"),
+		embeddingModel: config.GetString("integration.gigachat.embedding_model"),
+		body: func(req structs.LLMRequest, model string, stream bool) any {
+			body := structs.GigaChatRequest{
+				Model:       model,
+				Stream:      stream,
+				Messages:    req.Messages,
+				Temperature: req.Temperature,
+				MaxTokens:   req.MaxTokens,
+			}
+			// JSON режима у GigaChat нет, ответ по схеме получаем вызовом функции, параметры функции - только объект
+			if len(req.Schema) > 0 && objectSchema(req.Schema) {
+				body.Functions = []structs.GigaChatFunction{{Name: ExtractFunction, Description: "Save data extracted from the document", Parameters: req.Schema}}
+				body.FunctionCall = &structs.FunctionCallName{Name: ExtractFunction}
+			}
+			return body
+		},
+		auth: func(ctx context.Context) (map[string]string, error) {
+			token, err := tokens.Token(ctx)
+			if err != nil {
+				return nil, err
+			}
+			headers := map[string]string{"Authorization": "Bearer " + token}
+			if rqID := config.GetString("integration.gigachat.rqID"); rqID != "" {
+				headers["X-Request-ID"] = rqID
+			}
+			return headers, nil
+		},
+		// токен могли отозвать раньше срока, получаем новый
+		unauthorized: func(headers map[string]string) bool {
+			tokens.Invalidate(strings.TrimPrefix(headers["Authorization"], "Bearer "))
+			return true
+		},
+	}}
+}
+
+// ExtractFunction функция, аргументами которой модель возвращает данные по схеме
+const ExtractFunction = "extract"
+
+func objectSchema(schema json.RawMessage) bool {
+	var root struct {
+		Type any `json:"type"`
+	}
+	return json.Unmarshal(schema, &root) == nil && root.Type == "object"
+}
@@ -0,0 +1,185 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"github.com/go-resty/resty/v2"
+	"github.com/gurkankaymak/hocon"
+	uuid "github.com/satori/go.uuid"
+	"golang.org/x/sync/singleflight"
+	"sse-demo-core/internal/app/caching"
+	"sse-demo-core/internal/app/retry"
+	"sse-demo-core/internal/app/structs"
+	"sse-demo-core/internal/app/utils"
+	"time"
+)
+
+const gigaChatTokenKey = "gigachat:token"
+
+// GigaChatTokens выдает access token GigaChat API, полученный по auth ключу.
+// Токен живет 30 минут, хранится в кеше и обновляется заранее, до истечения.
+// Одновременные запросы за новым токеном объединяются в один
+type GigaChatTokens struct {
+	client *resty.Client
+	retry  retry.Policy
+	cache  caching.Cache
+	group  singleflight.Group
+
+	url   string
+	auth  string
+	scope string
+	rqID  string
+	// refreshBefore за сколько до истечения токен считается устаревшим
+	refreshBefore time.Duration
+}
+
+func NewGigaChatTokens(config *hocon.Config, cache caching.Cache) *GigaChatTokens {
+	return &GigaChatTokens{
+		client:        resty.New().SetPreRequestHook(utils.CurlLogger),
+		retry:         retry.FromConfig(config),
+		cache:         cache,
+		url:           hostURL(config, "integration.gigachat.oauth") + config.GetString("integration.gigachat.oauth.uri"),
+		auth:          config.GetString("integration.gigachat.auth"),
+		scope:         config.GetString("integration.gigachat.oauth.scope"),
+		rqID:          config.GetString("integration.gigachat.rqID"),
+		refreshBefore: time.Duration(config.GetInt("integration.gigachat.oauth.refresh_before")) * time.Second,
+	}
+}
+
+// Token действующий токен из кеша, если его нет или он скоро истечет - получает новый
+func (t *GigaChatTokens) Token(ctx context.Context) (string, error) {
+	if token, ok := t.cached(); ok {
+		return token.Token, nil
+	}
+
+	ch := t.group.DoChan(gigaChatTokenKey, func() (any, error) {
+		// пока ждали очереди, токен мог получить другой запрос
+		if token, ok := t.cached(); ok {
+			return token.Token, nil
+		}
+		// запрос за токеном не привязан к контексту вызвавшего, его результат нужен всем ожидающим
+		c, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		token, err := t.fetch(c)
+		if err != nil {
+			// обновить заранее не удалось, но старый токен еще действует
+			if old, ok := t.load(); ok && time.Until(expiresAt(old)) > 0 {
+				logdoc.GetLogger().Warn(">> error refreshing gigachat token, using current one, ", err)
+				return old.Token, nil
+			}
+			return nil, err
+		}
+		return token, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case r := <-ch:
+		if r.Err != nil {
+			return "", r.Err
+		}
+		return r.Val.(string), nil
+	}
+}
+
+// Invalidate удаляет из кеша токен, отвергнутый API с 401, следующий Token получит новый.
+// Токен, уже обновленный другим запросом, не удаляется
+func (t *GigaChatTokens) Invalidate(token string) {
+	logger := logdoc.GetLogger()
+
+	if cached, ok := t.load(); ok && cached.Token != token {
+		return
+	}
+	if err := t.cache.Delete(gigaChatTokenKey); err != nil {
+		logger.Warn(">> error deleting gigachat token from cache, ", err)
+	}
+}
+
+// cached токен из кеша, если до его истечения больше refreshBefore
+func (t *GigaChatTokens) cached() (*structs.GigaChatToken, bool) {
+	token, ok := t.load()
+	if !ok || time.Until(expiresAt(token)) <= t.refreshBefore {
+		return nil, false
+	}
+	return token, true
+}
+
+func (t *GigaChatTokens) load() (*structs.GigaChatToken, bool) {
+	logger := logdoc.GetLogger()
+
+	value, err := t.cache.Get(gigaChatTokenKey)
+	if err != nil {
+		if !errors.Is(err, caching.ErrNotFound) {
+			logger.Warn(">> error reading gigachat token from cache, ", err)
+		}
+		return nil, false
+	}
+
+	data, ok := value.(string)
+	if !ok {
+		return nil, false
+	}
+
+	var token structs.GigaChatToken
+	if err = json.Unmarshal([]byte(data), &token); err != nil || token.Token == "" {
+		return nil, false
+	}
+	return &token, true
+}
+
+func (t *GigaChatTokens) fetch(ctx context.Context) (string, error) {
+	logger := logdoc.GetLogger()
+	logger.Debug("Requesting gigachat access token...")
+
+	rqID := t.rqID
+	if rqID == "" {
+		rqID = uuid.NewV4().String()
+	}
+
+	var token structs.GigaChatToken
+	response, err := retry.Do(ctx, t.retry, ProviderGigaChat+" oauth", func() (*resty.Response, error) {
+		return t.client.R().
+			SetContext(ctx).
+			SetHeader("Authorization", "Basic "+t.auth).
+			SetHeader("RqUID", rqID).
+			SetHeader("Accept", "application/json").
+			SetFormData(map[string]string{"scope": t.scope}).
+			SetResult(&token).
+			Post(t.url)
+	})
+	if err != nil {
+		return "", err
+	}
+	if response.IsError() {
+		return "", apiError(ProviderGigaChat+" oauth", response)
+	}
+	if token.Token == "" {
+		return "", errors.New("gigachat oauth: empty access token in response")
+	}
+
+	ttl := time.Until(expiresAt(&token))
+	if ttl <= 0 {
+		return "", fmt.Errorf("gigachat oauth: token already expired at %s", expiresAt(&token))
+	}
+
+	data, err := json.Marshal(token)
+	if err != nil {
+		return "", err
+	}
+	if err = t.cache.Set(gigaChatTokenKey, string(data), ttl); err != nil {
+		logger.Warn(">> error writing gigachat token to cache, ", err)
+	}
+
+	logger.Debug("Got gigachat access token, expires at ", expiresAt(&token))
+	return token.Token, nil
+}
+
+// expiresAt время истечения токена, GigaChat возвращает expires_at в миллисекундах
+func expiresAt(token *structs.GigaChatToken) time.Time {
+	return time.UnixMilli(token.Expires)
+}
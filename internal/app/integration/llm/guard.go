@@ -0,0 +1,134 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"github.com/gurkankaymak/hocon"
+	"sse-demo-core/internal/app/breaker"
+	"sse-demo-core/internal/app/interfaces/services"
+	"sse-demo-core/internal/app/retry"
+	"sse-demo-core/internal/app/structs"
+	"sync"
+)
+
+// Guards circuit breaker и bulkhead провайдеров. Клиенты одного провайдера, например модели и Assistants API OpenAI,
+// получают общие breaker и слоты bulkhead: ошибки любого из них открывают breaker для обоих
+type Guards struct {
+	config *hocon.Config
+
+	mu     sync.Mutex
+	guards map[string]guard
+}
+
+func NewGuards(config *hocon.Config) *Guards {
+	return &Guards{config: config, guards: make(map[string]guard)}
+}
+
+// get breaker и bulkhead провайдера name, создаются при первом обращении
+func (g *Guards) get(name string) guard {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	pg, ok := g.guards[name]
+	if !ok {
+		pg = guard{breaker: breaker.New(name, breaker.SettingsFromConfig(g.config)), bulkhead: breaker.BulkheadFromConfig(name, g.config)}
+		g.guards[name] = pg
+	}
+	return pg
+}
+
+// guard пока провайдер недоступен, запросы отклоняются сразу, без повторов и ожидания,
+// а количество одновременных запросов к нему ограничено
+type guard struct {
+	breaker  *breaker.Breaker
+	bulkhead *breaker.Bulkhead
+}
+
+// guarded провайдер за circuit breaker и bulkhead
+type guarded struct {
+	provider services.LLMProvider
+	guard
+}
+
+func Guard(provider services.LLMProvider, b *breaker.Breaker, bulkhead *breaker.Bulkhead) services.LLMProvider {
+	return &guarded{provider: provider, guard: guard{breaker: b, bulkhead: bulkhead}}
+}
+
+func (g *guarded) Name() string {
+	return g.provider.Name()
+}
+
+func (g *guarded) Complete(ctx context.Context, req structs.LLMRequest) (response *structs.LLMResponse, err error) {
+	err = g.call(ctx, func() error {
+		response, err = g.provider.Complete(ctx, req)
+		return err
+	}, nil)
+	return response, err
+}
+
+func (g *guarded) Stream(ctx context.Context, req structs.LLMRequest, onDelta func(delta string) error) (response *structs.LLMResponse, err error) {
+	// ошибка отправки фрагмента клиенту - не ошибка провайдера
+	var deltaErr error
+	err = g.call(ctx, func() error {
+		response, err = g.provider.Stream(ctx, req, func(delta string) error {
+			if err := onDelta(delta); err != nil {
+				deltaErr = err
+				return err
+			}
+			return nil
+		})
+		return err
+	}, func(err error) bool {
+		return deltaErr != nil && errors.Is(err, deltaErr)
+	})
+	return response, err
+}
+
+func (g *guarded) ListModels(ctx context.Context) (models []structs.Model, err error) {
+	err = g.call(ctx, func() error {
+		models, err = g.provider.ListModels(ctx)
+		return err
+	}, nil)
+	return models, err
+}
+
+func (g *guarded) Embed(ctx context.Context, req structs.EmbeddingRequest) (response *structs.EmbeddingResponse, err error) {
+	err = g.call(ctx, func() error {
+		response, err = g.provider.Embed(ctx, req)
+		return err
+	}, nil)
+	return response, err
+}
+
+// call выполняет fn под breaker и bulkhead, ignore - ошибки, не относящиеся к провайдеру
+func (g guard) call(ctx context.Context, fn func() error, ignore func(err error) bool) error {
+	ticket, err := g.breaker.Allow()
+	if err != nil {
+		return err
+	}
+	if err = g.bulkhead.Acquire(ctx); err != nil {
+		g.breaker.Ignore(ticket)
+		return err
+	}
+	defer g.bulkhead.Release()
+
+	err = fn()
+	switch {
+	case err == nil:
+		g.breaker.Done(ticket, false)
+	case ctx.Err() != nil || errors.Is(err, context.Canceled) || (ignore != nil && ignore(err)):
+		g.breaker.Ignore(ticket)
+	default:
+		g.breaker.Done(ticket, providerFailure(err))
+	}
+	return err
+}
+
+// providerFailure ошибки клиента 4xx означают, что провайдер работает, остальные ошибки - что нет
+func providerFailure(err error) bool {
+	var apiErr structs.APIError
+	if errors.As(err, &apiErr) {
+		return retry.Retryable(apiErr.Status)
+	}
+	return true
+}
@@ -0,0 +1,217 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"github.com/go-resty/resty/v2"
+	"github.com/gurkankaymak/hocon"
+	"sse-demo-core/internal/app/retry"
+	"sse-demo-core/internal/app/structs"
+	"sse-demo-core/internal/app/utils"
+	"strings"
+)
+
+// HuggingFace клиент Hugging Face Inference API для моделей генерации текста
+type HuggingFace struct {
+	client    *resty.Client
+	retry     retry.Policy
+	baseURL   string
+	modelsURI string
+	chatURI   string
+	model     string
+	token     string
+	// embeddingsURI путь feature extraction, модель эмбеддингов добавляется в конец пути
+	embeddingsURI  string
+	embeddingModel string
+}
+
+func NewHuggingFace(config *hocon.Config) *HuggingFace {
+	return &HuggingFace{
+		client:         resty.New().SetPreRequestHook(utils.CurlLogger),
+		retry:          retry.FromConfig(config),
+		baseURL:        baseURL(config, ProviderHuggingFace),
+		modelsURI:      config.GetString("integration.huggingface.uri.models"),
+		chatURI:        config.GetString("integration.huggingface.uri.chat"),
+		model:          config.GetString("integration.huggingface.model"),
+		token:          config.GetString("integration.huggingface.token"),
+		embeddingsURI:  config.GetString("integration.huggingface.uri.embeddings"),
+		embeddingModel: config.GetString("integration.huggingface.embedding_model"),
+	}
+}
+
+func (h *HuggingFace) Name() string {
+	return ProviderHuggingFace
+}
+
+// url модель задается в пути запроса, по умолчанию путь из integration.huggingface.uri.chat
+func (h *HuggingFace) url(req structs.LLMRequest) string {
+	if req.Model != "" && req.Model != h.model {
+		return h.baseURL + h.modelsURI + "/" + strings.TrimPrefix(req.Model, "/")
+	}
+	return h.baseURL + h.modelsURI + h.chatURI
+}
+
+func (h *HuggingFace) request(ctx context.Context, req structs.LLMRequest, stream bool) *resty.Request {
+	return h.client.R().
+		SetContext(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetHeader("Authorization", "Bearer "+strings.TrimPrefix(h.token, "Bearer ")).
+		SetBody(structs.HuggingFaceRequest{
+			Inputs: llama2Prompt(req.Messages),
+			Stream: stream,
+			Parameters: &structs.HuggingFaceParameters{
+				MaxNewTokens: req.MaxTokens,
+				Temperature:  req.Temperature,
+			},
+			Options: structs.HuggingFaceOptions{UseCache: false},
+		})
+}
+
+func (h *HuggingFace) Complete(ctx context.Context, req structs.LLMRequest) (*structs.LLMResponse, error) {
+	logger := logdoc.GetLogger()
+	logger.Debug("Executing hugging face text generation...")
+
+	var data structs.HuggingFaceResponse
+	response, err := retry.Do(ctx, h.retry, ProviderHuggingFace, func() (*resty.Response, error) {
+		return h.request(ctx, req, false).SetResult(&data).Post(h.url(req))
+	})
+	if err != nil {
+		return nil, err
+	}
+	if response.IsError() {
+		return nil, apiError(ProviderHuggingFace, response)
+	}
+	if len(data) == 0 {
+		return nil, errors.New(ProviderHuggingFace + ": empty response")
+	}
+
+	return &structs.LLMResponse{Provider: ProviderHuggingFace, Model: h.modelName(req), Content: data[0].GeneratedText}, nil
+}
+
+func (h *HuggingFace) Stream(ctx context.Context, req structs.LLMRequest, onDelta func(delta string) error) (*structs.LLMResponse, error) {
+	logger := logdoc.GetLogger()
+	logger.Debug("Executing hugging face streaming text generation...")
+
+	response, err := retry.Do(ctx, h.retry, ProviderHuggingFace, func() (*resty.Response, error) {
+		return h.request(ctx, req, true).
+			SetHeader("Accept", "text/event-stream").
+			SetDoNotParseResponse(true).
+			Post(h.url(req))
+	})
+	if err != nil {
+		return nil, err
+	}
+	if response.IsError() {
+		return nil, streamError(ProviderHuggingFace, response)
+	}
+
+	body := response.RawBody()
+	defer body.Close()
+
+	result := &structs.LLMResponse{Provider: ProviderHuggingFace, Model: h.modelName(req)}
+	var content strings.Builder
+
+	err = ReadStream(ctx, body, func(data []byte) error {
+		var chunk structs.HuggingFaceStreamingResponse
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return err
+		}
+		if chunk.Details != nil {
+			result.FinishReason = chunk.Details.FinishReason
+			result.Usage.CompletionTokens = chunk.Details.GeneratedTokens
+		}
+		if chunk.Token.Special || chunk.Token.Text == "" {
+			return nil
+		}
+		content.WriteString(chunk.Token.Text)
+		return onDelta(chunk.Token.Text)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result.Content = content.String()
+	return result, nil
+}
+
+// ListModels Inference API не отдает список моделей, возвращаем модель из конфига
+func (h *HuggingFace) ListModels(context.Context) ([]structs.Model, error) {
+	return []structs.Model{{ID: h.model, Object: "model", OwnedBy: ProviderHuggingFace}}, nil
+}
+
+func (h *HuggingFace) Embed(ctx context.Context, req structs.EmbeddingRequest) (*structs.EmbeddingResponse, error) {
+	logger := logdoc.GetLogger()
+	logger.Debug("Executing hugging face feature extraction of ", len(req.Input), " texts...")
+
+	if h.embeddingsURI == "" {
+		return nil, errors.New(ProviderHuggingFace + ": embeddings are not configured")
+	}
+
+	model := req.Model
+	if model == "" {
+		model = h.embeddingModel
+	}
+
+	var data [][]float32
+	response, err := retry.Do(ctx, h.retry, ProviderHuggingFace, func() (*resty.Response, error) {
+		return h.client.R().
+			SetContext(ctx).
+			SetHeader("Content-Type", "application/json").
+			SetHeader("Authorization", "Bearer "+strings.TrimPrefix(h.token, "Bearer ")).
+			// wait_for_model - не получать 503, пока модель загружается
+			SetBody(structs.HuggingFaceEmbeddingRequest{Inputs: req.Input, Options: structs.HuggingFaceOptions{WaitForModel: true}}).
+			SetResult(&data).
+			Post(h.baseURL + h.embeddingsURI + "/" + strings.TrimPrefix(model, "/"))
+	})
+	if err != nil {
+		return nil, err
+	}
+	if response.IsError() {
+		return nil, apiError(ProviderHuggingFace, response)
+	}
+	if len(data) != len(req.Input) {
+		return nil, fmt.Errorf("%s: %d embeddings in response for %d texts", ProviderHuggingFace, len(data), len(req.Input))
+	}
+
+	// Inference API не сообщает расход токенов
+	return &structs.EmbeddingResponse{Provider: ProviderHuggingFace, Model: model, Embeddings: data}, nil
+}
+
+func (h *HuggingFace) modelName(req structs.LLMRequest) string {
+	if req.Model != "" {
+		return req.Model
+	}
+	return h.model
+}
+
+// llama2Prompt собирает сообщения чата в формат промпта Llama 2 chat
+func llama2Prompt(messages []structs.Content) string {
+	var sb strings.Builder
+	var system string
+	open := false
+
+	for _, m := range messages {
+		switch m.Role {
+		case "system":
+			system = m.Content
+		case "assistant":
+			if open {
+				sb.WriteString(" " + m.Content + " </s>")
+				open = false
+			}
+		default:
+			sb.WriteString("<s>[INST] ")
+			if system != "" {
+				sb.WriteString("<<SYS>>\n" + system + "\n<</SYS>>\n\n")
+				system = ""
+			}
+			sb.WriteString(m.Content + " [/INST]")
+			open = true
+		}
+	}
+
+	return sb.String()
+}
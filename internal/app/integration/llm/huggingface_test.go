@@ -0,0 +1,103 @@
+package llm
+
+import (
+	"context"
+	"sse-demo-core/internal/app/structs"
+	"testing"
+)
+
+func TestHuggingFaceComplete(t *testing.T) {
+	server := newServer(t, "Hello from llama")
+	p := newProvider(t, server, ProviderHuggingFace, "")
+
+	response, err := p.provider.Complete(context.Background(), structs.LLMRequest{
+		Messages:  []structs.Content{{Role: "system", Content: "Be brief"}, {Role: "user", Content: "hi"}},
+		MaxTokens: 100,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Content != "Hello from llama" || response.Model != "Llama-2-70b-chat-hf" {
+		t.Fatalf("unexpected response: %+v", response)
+	}
+
+	request := server.Requests()[0]
+	if request.Path != "/models/meta-llama/Llama-2-70b-chat-hf" || request.Authorization != "Bearer hf-test" {
+		t.Fatalf("unexpected request %s with authorization %q", request.Path, request.Authorization)
+	}
+	body := requestBody(t, server, 0)
+	parameters, _ := body["parameters"].(map[string]any)
+	if body["inputs"] != "<s>[INST] <<SYS>>\nBe brief\n<</SYS>>\n\nhi [/INST]" || parameters["max_new_tokens"] != 100.0 {
+		t.Fatalf("unexpected request body: %v", body)
+	}
+}
+
+func TestHuggingFaceModelOverride(t *testing.T) {
+	server := newServer(t, "ok")
+	p := newProvider(t, server, ProviderHuggingFace, "")
+
+	response, err := p.provider.Complete(context.Background(), structs.LLMRequest{
+		Model:    "mistralai/Mistral-7B-Instruct-v0.2",
+		Messages: []structs.Content{{Role: "user", Content: "hi"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Model != "mistralai/Mistral-7B-Instruct-v0.2" {
+		t.Fatalf("unexpected model %s", response.Model)
+	}
+	if path := server.Requests()[0].Path; path != "/models/mistralai/Mistral-7B-Instruct-v0.2" {
+		t.Fatalf("unexpected path %s", path)
+	}
+}
+
+func TestHuggingFaceStream(t *testing.T) {
+	server := newServer(t, "one two")
+	p := newProvider(t, server, ProviderHuggingFace, "")
+
+	response, deltas, err := p.stream("hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deltas) != 2 || response.Content != "one two" {
+		t.Fatalf("unexpected stream: deltas %q, response %+v", deltas, response)
+	}
+	if response.FinishReason != "eos_token" || response.Usage.CompletionTokens != 2 {
+		t.Fatalf("unexpected details: %+v", response)
+	}
+	if body := requestBody(t, server, 0); body["stream"] != true {
+		t.Fatalf("unexpected request body: %v", body)
+	}
+}
+
+func TestHuggingFaceEmbed(t *testing.T) {
+	server := newServer(t, "")
+	p := newProvider(t, server, ProviderHuggingFace, "")
+
+	response, err := p.provider.Embed(context.Background(), structs.EmbeddingRequest{Input: []string{"a", "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Model != "sentence-transformers/all-MiniLM-L6-v2" || len(response.Embeddings) != 2 {
+		t.Fatalf("unexpected response: %+v", response)
+	}
+	if path := server.Requests()[0].Path; path != "/pipeline/feature-extraction/sentence-transformers/all-MiniLM-L6-v2" {
+		t.Fatalf("unexpected path %s", path)
+	}
+	if body := requestBody(t, server, 0); body["options"].(map[string]any)["wait_for_model"] != true {
+		t.Fatalf("unexpected request body: %v", body)
+	}
+}
+
+func TestLlama2Prompt(t *testing.T) {
+	prompt := llama2Prompt([]structs.Content{
+		{Role: "system", Content: "sys"},
+		{Role: "user", Content: "q1"},
+		{Role: "assistant", Content: "a1"},
+		{Role: "user", Content: "q2"},
+	})
+	want := "<s>[INST] <<SYS>>\nsys\n<</SYS>>\n\nq1 [/INST] a1 </s><s>[INST] q2 [/INST]"
+	if prompt != want {
+		t.Fatalf("prompt\n%q\nwant\n%q", prompt, want)
+	}
+}
@@ -0,0 +1,90 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/go-resty/resty/v2"
+	"github.com/gurkankaymak/hocon"
+	"io"
+	"net/http"
+	"sse-demo-core/internal/app/caching"
+	"sse-demo-core/internal/app/interfaces/services"
+	"sse-demo-core/internal/app/structs"
+	"strings"
+	"time"
+)
+
+// Имена провайдеров, значения integration.active
+const (
+	ProviderOpenAI      = "openai"
+	ProviderGigaChat    = "gigachat"
+	ProviderHuggingFace = "huggingface"
+)
+
+// New создает клиента провайдера, выбранного в integration.active, cache хранит токены доступа провайдера и ответы модели.
+// Клиент работает за circuit breaker и bulkhead провайдера из guards
+func New(config *hocon.Config, cache caching.Cache, guards *Guards) (services.LLMProvider, error) {
+	var provider services.LLMProvider
+	switch active := config.GetString("integration.active"); active {
+	case ProviderOpenAI:
+		provider = NewOpenAI(config)
+	case ProviderGigaChat:
+		provider = NewGigaChat(config, NewGigaChatTokens(config, cache))
+	case ProviderHuggingFace:
+		provider = NewHuggingFace(config)
+	default:
+		return nil, fmt.Errorf("unknown llm provider %q in integration.active", active)
+	}
+
+	name := provider.Name()
+	provider = &guarded{provider: provider, guard: guards.get(name)}
+
+	// ответы из кеша не проходят через breaker и не занимают слоты bulkhead
+	if config.GetBoolean("llm_cache.enabled") {
+		provider = Cached(provider, cache, time.Duration(config.GetInt("llm_cache.ttl"))*time.Second,
+			config.GetString("integration."+name+".model"))
+	}
+	return provider, nil
+}
+
+// baseURL адрес провайдера из integration.<provider>
+func baseURL(config *hocon.Config, provider string) string {
+	return hostURL(config, "integration."+provider)
+}
+
+// hostURL адрес из proto, host и необязательного port блока конфига path
+func hostURL(config *hocon.Config, path string) string {
+	url := config.GetString(path+".proto") + "://" + config.GetString(path+".host")
+	if port := config.GetString(path + ".port"); port != "" {
+		url += ":" + port
+	}
+	return url
+}
+
+// apiError ошибка ответа провайдера, текст берется из тела ответа в формате OpenAI, если он есть
+func apiError(provider string, response *resty.Response) error {
+	return errorFromBody(provider, response.StatusCode(), response.Body())
+}
+
+// streamError ошибка потокового ответа, тело ответа не разбиралось resty и читается здесь
+func streamError(provider string, response *resty.Response) error {
+	body := response.RawBody()
+	defer body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(body, 64<<10))
+	return errorFromBody(provider, response.StatusCode(), data)
+}
+
+func errorFromBody(provider string, status int, body []byte) error {
+	message := strings.TrimSpace(string(body))
+
+	var openAIErr structs.OpenAIError
+	if err := json.Unmarshal(body, &openAIErr); err == nil && openAIErr.Error.Message != "" {
+		message = openAIErr.Error.Message
+	}
+	if message == "" {
+		message = http.StatusText(status)
+	}
+
+	return structs.APIError{Status: status, Message: fmt.Sprintf("%s: %s", provider, message)}
+}
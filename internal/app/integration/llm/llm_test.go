@@ -0,0 +1,266 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"github.com/gurkankaymak/hocon"
+	"net/http"
+	"os"
+	"sse-demo-core/internal/app/breaker"
+	"sse-demo-core/internal/app/caching"
+	"sse-demo-core/internal/app/integration/llm/llmtest"
+	"sse-demo-core/internal/app/interfaces/services"
+	"sse-demo-core/internal/app/structs"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	_, _ = logdoc.Init("udp", "127.0.0.1:9", "llm-test")
+	os.Exit(m.Run())
+}
+
+// testConfig все провайдеры направлены на фейковый сервер, повторы без задержек.
+// extra дописывается в конец и переопределяет значения
+func testConfig(t *testing.T, server *llmtest.Server, active string, extra string) *hocon.Config {
+	t.Helper()
+
+	host, port := server.Host(), server.Port()
+	config, err := hocon.ParseString(fmt.Sprintf(`
+integration {
+  active = %q
+  openai {
+    proto = "http", host = %q, port = %q
+    uri { models = "/v1/models", files = "/v1/files", assistants = "/v1/assistants/", threads = "/v1/threads", chat = "/v1/chat/completions", embeddings = "/v1/embeddings" }
+    token = "sk-test", assistant = "asst-default", model = "gpt-test", embedding_model = "embed-test", response_format = "json_object"
+  }
+  gigachat {
+    proto = "http", host = %q, port = %q
+    uri { chat = "/api/v1/chat/completions", models = "/api/v1/models", embeddings = "/api/v1/embeddings" }
+    auth = "Y2xpZW50LXNlY3JldA==", rqID = ""
+    oauth { proto = "http", host = %q, port = %q, uri = "/api/v2/oauth", scope = "GIGACHAT_API_PERS", refresh_before = 60 }
+    model = "GigaChat", embedding_model = "Embeddings"
+  }
+  huggingface {
+    proto = "http", host = %q, port = %q
+    uri { models = "/models", chat = "/meta-llama/Llama-2-70b-chat-hf", embeddings = "/pipeline/feature-extraction" }
+    token = "hf-test", model = "Llama-2-70b-chat-hf", embedding_model = "sentence-transformers/all-MiniLM-L6-v2"
+  }
+}
+retry { max_attempts = 3, initial_interval = 1, max_interval = 5, max_elapsed = 10 }
+circuit_breaker { window = 4, min_requests = 2, failure_rate = 50, open_timeout = 60, half_open_probes = 1 }
+bulkhead { max_concurrent = 2, max_wait = 1 }
+llm_cache { enabled = false, ttl = 60 }
+`, active, host, port, host, port, host, port, host, port) + "\n" + extra)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+func newServer(t *testing.T, reply string) *llmtest.Server {
+	server := llmtest.NewServer(reply)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newProvider провайдер active из New: за breaker и bulkhead, с кешем ответов по llm_cache
+func newProvider(t *testing.T, server *llmtest.Server, active string, extra string) *testProvider {
+	config := testConfig(t, server, active, extra)
+	provider, err := New(config, caching.NewMemoryCache(100), NewGuards(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testProvider{provider: provider}
+}
+
+type testProvider struct {
+	provider services.LLMProvider
+}
+
+func (p *testProvider) complete(content string) (*structs.LLMResponse, error) {
+	return p.provider.Complete(context.Background(), structs.LLMRequest{Messages: []structs.Content{{Role: "user", Content: content}}})
+}
+
+// stream потоковый ответ и полученные фрагменты
+func (p *testProvider) stream(content string) (*structs.LLMResponse, []string, error) {
+	var deltas []string
+	response, err := p.provider.Stream(context.Background(), structs.LLMRequest{Messages: []structs.Content{{Role: "user", Content: content}}},
+		func(delta string) error {
+			deltas = append(deltas, delta)
+			return nil
+		})
+	return response, deltas, err
+}
+
+// requestBody тело i-го запроса к серверу
+func requestBody(t *testing.T, server *llmtest.Server, i int) map[string]any {
+	t.Helper()
+
+	requests := server.Requests()
+	if i >= len(requests) {
+		t.Fatalf("server got %d requests, want more than %d", len(requests), i)
+	}
+	var body map[string]any
+	if err := json.Unmarshal(requests[i].Body, &body); err != nil {
+		t.Fatalf("request %d body is not json: %v", i, err)
+	}
+	return body
+}
+
+func requestsTo(server *llmtest.Server, path string) int {
+	n := 0
+	for _, r := range server.Requests() {
+		if strings.HasSuffix(r.Path, path) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestNewUnknownProvider(t *testing.T) {
+	server := newServer(t, "")
+	config := testConfig(t, server, "claude", "")
+	if _, err := New(config, caching.NewMemoryCache(10), NewGuards(config)); err == nil {
+		t.Fatal("expected error for unknown provider")
+	}
+}
+
+func TestRetryServerErrors(t *testing.T) {
+	server := newServer(t, "recovered")
+	server.Status, server.Failures = http.StatusServiceUnavailable, 2
+	p := newProvider(t, server, ProviderOpenAI, "")
+
+	response, err := p.complete("hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Content != "recovered" {
+		t.Fatalf("content %q, want %q", response.Content, "recovered")
+	}
+	if n := len(server.Requests()); n != 3 {
+		t.Fatalf("server got %d requests, want 3", n)
+	}
+}
+
+func TestRetryExhausted(t *testing.T) {
+	server := newServer(t, "")
+	server.Status = http.StatusBadGateway
+	p := newProvider(t, server, ProviderOpenAI, "")
+
+	_, err := p.complete("hi")
+	var apiErr structs.APIError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusBadGateway {
+		t.Fatalf("got %v, want APIError with status 502", err)
+	}
+	if n := len(server.Requests()); n != 3 {
+		t.Fatalf("server got %d requests, want retry.max_attempts = 3", n)
+	}
+}
+
+func TestClientErrorsAreNotRetried(t *testing.T) {
+	server := newServer(t, "")
+	server.Status = http.StatusBadRequest
+	p := newProvider(t, server, ProviderOpenAI, "")
+
+	_, err := p.complete("hi")
+	var apiErr structs.APIError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusBadRequest || !strings.Contains(apiErr.Message, "openai: Bad Request") {
+		t.Fatalf("got %v, want APIError 400 with provider message", err)
+	}
+	if n := len(server.Requests()); n != 1 {
+		t.Fatalf("server got %d requests, want 1", n)
+	}
+}
+
+func TestBreakerOpensOnProviderFailures(t *testing.T) {
+	server := newServer(t, "ok")
+	server.Status = http.StatusInternalServerError
+	p := newProvider(t, server, ProviderOpenAI, "retry.max_attempts = 1")
+
+	for i := 0; i < 2; i++ {
+		if _, err := p.complete("hi"); errors.Is(err, breaker.ErrOpen) || err == nil {
+			t.Fatalf("request %d: got %v, want provider error", i, err)
+		}
+	}
+
+	// доля ошибок достигла порога, следующий запрос отклоняется без обращения к провайдеру
+	_, err := p.complete("hi")
+	var openErr *breaker.OpenError
+	if !errors.Is(err, breaker.ErrOpen) || !errors.As(err, &openErr) || openErr.RetryAfter <= 0 {
+		t.Fatalf("got %v, want open breaker error with retry after", err)
+	}
+	if n := len(server.Requests()); n != 2 {
+		t.Fatalf("server got %d requests, want 2", n)
+	}
+}
+
+func TestBreakerIgnoresClientErrors(t *testing.T) {
+	server := newServer(t, "ok")
+	server.Status = http.StatusUnprocessableEntity
+	p := newProvider(t, server, ProviderOpenAI, "retry.max_attempts = 1")
+
+	for i := 0; i < 5; i++ {
+		if _, err := p.complete("hi"); errors.Is(err, breaker.ErrOpen) {
+			t.Fatalf("request %d: breaker opened on client errors", i)
+		}
+	}
+	if n := len(server.Requests()); n != 5 {
+		t.Fatalf("server got %d requests, want 5", n)
+	}
+}
+
+func TestBreakerIgnoresDeltaErrors(t *testing.T) {
+	server := newServer(t, "one two three")
+	p := newProvider(t, server, ProviderOpenAI, "retry.max_attempts = 1")
+	clientGone := errors.New("client disconnected")
+
+	for i := 0; i < 4; i++ {
+		_, err := p.provider.Stream(context.Background(), structs.LLMRequest{Messages: []structs.Content{{Role: "user", Content: "hi"}}},
+			func(string) error { return clientGone })
+		if !errors.Is(err, clientGone) {
+			t.Fatalf("request %d: got %v, want error of onDelta", i, err)
+		}
+	}
+
+	// ошибки отправки клиенту не открывают breaker
+	if _, err := p.complete("hi"); err != nil {
+		t.Fatalf("got %v after client errors, want success", err)
+	}
+}
+
+func TestCachedResponses(t *testing.T) {
+	server := newServer(t, "cached answer")
+	p := newProvider(t, server, ProviderOpenAI, "llm_cache.enabled = true")
+
+	first, err := p.complete("same question")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := p.complete("same question")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Cached || !second.Cached || second.Content != first.Content {
+		t.Fatalf("unexpected cache results: first %+v, second %+v", first, second)
+	}
+
+	// другой вопрос и запрос мимо кеша идут в модель
+	if _, err = p.complete("other question"); err != nil {
+		t.Fatal(err)
+	}
+	bypass, err := p.provider.Complete(BypassCache(context.Background()),
+		structs.LLMRequest{Messages: []structs.Content{{Role: "user", Content: "same question"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bypass.Cached {
+		t.Fatal("bypass request answered from cache")
+	}
+	if n := requestsTo(server, "/chat/completions"); n != 3 {
+		t.Fatalf("model got %d requests, want 3", n)
+	}
+}
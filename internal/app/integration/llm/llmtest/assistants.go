@@ -0,0 +1,141 @@
+package llmtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sse-demo-core/internal/app/structs"
+	"strings"
+	"time"
+)
+
+// fakeThread тред Assistants API фейкового сервера, запуск завершается при первом опросе с ответом Reply
+type fakeThread struct {
+	messages []structs.AIMessage
+	runs     map[string]*structs.AIThreadRun
+}
+
+// assistants обрабатывает запросы Assistants API, false - запрос не к Assistants API
+func (f *Server) assistants(w http.ResponseWriter, r *http.Request) bool {
+	path := strings.Trim(r.URL.Path, "/")
+	parts := strings.Split(path, "/")
+	now := int(time.Now().Unix())
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodPost && strings.HasSuffix(path, "/files") && strings.Contains(path, "assistants/"):
+		var req structs.LinkRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		writeJSON(w, structs.AssistantsLinkFileToAssistant{ID: req.FileID, Object: "assistant.file", CreatedAt: now, AssistantID: parts[len(parts)-2]})
+	case r.Method == http.MethodPost && strings.HasSuffix(path, "files"):
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return true
+		}
+		_ = file.Close()
+		f.sequence++
+		writeJSON(w, structs.AssistantsFileUploadResponse{Object: "file", ID: fmt.Sprintf("file-%d", f.sequence), Purpose: r.FormValue("purpose"),
+			Filename: header.Filename, Bytes: int(header.Size), CreatedAt: now, Status: "processed"})
+	case r.Method == http.MethodPost && strings.HasSuffix(path, "threads"):
+		f.sequence++
+		id := fmt.Sprintf("thread-%d", f.sequence)
+		f.threads[id] = &fakeThread{runs: make(map[string]*structs.AIThreadRun)}
+		writeJSON(w, structs.AssistantsThread{ID: id, Object: "thread", CreatedAt: now, Metadata: map[string]any{}})
+	case strings.Contains(path, "threads/"):
+		f.thread(w, r, parts[indexOf(parts, "threads")+1:], now)
+	default:
+		return false
+	}
+	return true
+}
+
+// thread запросы к треду: parts - путь после /threads/
+func (f *Server) thread(w http.ResponseWriter, r *http.Request, parts []string, now int) {
+	thread := f.threads[parts[0]]
+	if thread == nil {
+		writeError(w, http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case len(parts) == 2 && parts[1] == "messages" && r.Method == http.MethodPost:
+		var req structs.ThreadMessageRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		f.sequence++
+		message := fakeMessage(fmt.Sprintf("msg-%d", f.sequence), parts[0], req.Role, req.Content, "", now)
+		message.FileIds = req.FileIDS
+		thread.messages = append(thread.messages, message)
+		writeJSON(w, message)
+	case len(parts) == 2 && parts[1] == "messages":
+		list := structs.ListAIMessages{Object: "list"}
+		for i := len(thread.messages) - 1; i >= 0; i-- {
+			list.Data = append(list.Data, thread.messages[i])
+		}
+		writeJSON(w, list)
+	case len(parts) == 2 && parts[1] == "runs" && r.Method == http.MethodPost:
+		var req structs.ThreadRunRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		f.sequence++
+		run := &structs.AIThreadRun{ID: fmt.Sprintf("run-%d", f.sequence), Object: "thread.run", CreatedAt: now,
+			AssistantID: req.AssistantID, ThreadID: parts[0], Status: "queued"}
+		thread.runs[run.ID] = run
+		writeJSON(w, run)
+	case len(parts) >= 3 && parts[1] == "runs" && thread.runs[parts[2]] != nil:
+		run := thread.runs[parts[2]]
+		if len(parts) == 4 && parts[3] == "steps" {
+			writeJSON(w, f.steps(run))
+			return
+		}
+		if run.Status == "queued" {
+			f.complete(thread, run, now)
+		}
+		writeJSON(w, run)
+	default:
+		writeError(w, http.StatusNotFound)
+	}
+}
+
+// complete завершает запуск ответом ассистента Reply
+func (f *Server) complete(thread *fakeThread, run *structs.AIThreadRun, now int) {
+	if f.RunStatus != "" {
+		run.Status = f.RunStatus
+		run.LastError = structs.LastError{Code: "server_error", Message: "fake run " + f.RunStatus}
+		return
+	}
+
+	f.sequence++
+	thread.messages = append(thread.messages, fakeMessage(fmt.Sprintf("msg-%d", f.sequence), run.ThreadID, "assistant", f.Reply, run.ID, now))
+	run.Status = "completed"
+	run.CompletedAt = now
+	run.Usage = &structs.Usage{PromptToken: 1, CompletionTokens: len(strings.Fields(f.Reply)), TotalTokens: 1 + len(strings.Fields(f.Reply))}
+}
+
+// steps единственный шаг запуска - создание сообщения, его статус следует за статусом запуска
+func (f *Server) steps(run *structs.AIThreadRun) structs.AIRunStepDetails {
+	step := structs.AIRunStep{ID: "step-" + run.ID, Object: "thread.run.step", CreatedAt: run.CreatedAt, RunID: run.ID,
+		AssistantID: run.AssistantID, ThreadID: run.ThreadID, Type: "message_creation", Status: "in_progress"}
+	step.StepDetails.Type = "message_creation"
+	if run.Status != "queued" {
+		step.Status = run.Status
+	}
+	return structs.AIRunStepDetails{Object: "list", Data: []structs.AIRunStep{step}}
+}
+
+func fakeMessage(id string, threadID string, role string, text string, runID string, now int) structs.AIMessage {
+	content := structs.MessageContent{Type: "text"}
+	content.Text.Value = text
+	return structs.AIMessage{ID: id, Object: "thread.message", CreatedAt: now, ThreadID: threadID, Role: role,
+		Content: []structs.MessageContent{content}, FileIds: []string{}, RunID: runID}
+}
+
+func indexOf(parts []string, part string) int {
+	for i, p := range parts {
+		if p == part {
+			return i
+		}
+	}
+	return -1
+}
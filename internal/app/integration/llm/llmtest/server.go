@@ -0,0 +1,324 @@
+// Package llmtest фейковый API провайдеров моделей для тестов клиентов llm
+package llmtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sse-demo-core/internal/app/structs"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Server сервер, отвечающий как OpenAI (включая Assistants API), GigaChat и Hugging Face.
+// Для подключения провайдера в конфиге указываются proto = "http", host и port сервера
+type Server struct {
+	*httptest.Server
+	// Reply текст ответа модели, в потоковом режиме отдается по словам
+	Reply string
+	// Status код ошибки, который сервер вернет на запросы, 0 - без ошибки.
+	// Задается до первого запроса, после - через Fail
+	Status int
+	// Failures сколько следующих запросов получат Status, 0 - все запросы
+	Failures int
+	// RequireToken запросы к модели принимаются только с токеном, выданным /oauth, как в GigaChat
+	RequireToken bool
+	// TokenTTL время жизни токенов /oauth, по умолчанию 30 минут
+	TokenTTL time.Duration
+	// RunStatus финальный статус запусков ассистента вместо completed, например failed
+	RunStatus string
+
+	mu       sync.Mutex
+	tokens   map[string]bool
+	issued   int
+	failed   int
+	requests []Request
+	threads  map[string]*fakeThread
+	sequence int
+}
+
+// Request запрос, принятый сервером
+type Request struct {
+	Method        string
+	Path          string
+	Authorization string
+	Body          []byte
+}
+
+func NewServer(reply string) *Server {
+	f := &Server{Reply: reply, tokens: make(map[string]bool), threads: make(map[string]*fakeThread)}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.handle))
+	return f
+}
+
+// Host адрес сервера для integration.<provider>.host
+func (f *Server) Host() string {
+	host, _, _ := net.SplitHostPort(f.Listener.Addr().String())
+	return host
+}
+
+// Port порт сервера для integration.<provider>.port
+func (f *Server) Port() string {
+	_, port, _ := net.SplitHostPort(f.Listener.Addr().String())
+	return port
+}
+
+// Revoke отзывает все выданные токены, следующие запросы с ними получат 401
+func (f *Server) Revoke() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tokens = make(map[string]bool)
+}
+
+// TokensIssued количество токенов, выданных /oauth
+func (f *Server) TokensIssued() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.issued
+}
+
+// Fail следующие failures запросов (0 - все) получат ответ с кодом status, status 0 - ошибки прекращаются
+func (f *Server) Fail(status int, failures int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Status, f.Failures, f.failed = status, failures, 0
+}
+
+// Requests принятые запросы в порядке поступления
+func (f *Server) Requests() []Request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Request(nil), f.requests...)
+}
+
+func (f *Server) handle(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	f.mu.Lock()
+	f.requests = append(f.requests, Request{Method: r.Method, Path: r.URL.Path, Authorization: r.Header.Get("Authorization"), Body: body})
+	fail := f.Status != 0 && (f.Failures == 0 || f.failed < f.Failures)
+	if fail {
+		f.failed++
+	}
+	f.mu.Unlock()
+
+	if fail {
+		writeError(w, f.Status)
+		return
+	}
+
+	if r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/oauth") {
+		f.oauth(w)
+		return
+	}
+
+	if f.RequireToken && !f.valid(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")) {
+		writeError(w, http.StatusUnauthorized)
+		return
+	}
+
+	if f.assistants(w, r) {
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/models"):
+		writeJSON(w, structs.OpenAIModelsResponse{Object: "list", Data: []structs.Model{{ID: "fake-model", Object: "model", OwnedBy: "fake"}}})
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/chat/completions"):
+		f.chat(w, r)
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/embeddings"):
+		f.embeddings(w, r)
+	case r.Method == http.MethodPost && strings.Contains(r.URL.Path, "/feature-extraction/"):
+		f.featureExtraction(w, r)
+	case r.Method == http.MethodPost && strings.Contains(r.URL.Path, "/models/"):
+		f.generate(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *Server) oauth(w http.ResponseWriter) {
+	ttl := f.TokenTTL
+	if ttl == 0 {
+		ttl = 30 * time.Minute
+	}
+
+	f.mu.Lock()
+	f.issued++
+	token := fmt.Sprintf("fake-token-%d", f.issued)
+	f.tokens[token] = true
+	f.mu.Unlock()
+
+	writeJSON(w, structs.GigaChatToken{Token: token, Expires: time.Now().Add(ttl).UnixMilli()})
+}
+
+func (f *Server) valid(token string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.tokens[token]
+}
+
+func (f *Server) chat(w http.ResponseWriter, r *http.Request) {
+	var req structs.GigaChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !req.Stream {
+		// с functions отвечаем вызовом функции, как GigaChat: Reply - аргументы
+		message := structs.Message{Role: "assistant", Content: f.Reply}
+		finishReason := "stop"
+		if req.FunctionCall != nil {
+			arguments := json.RawMessage(f.Reply)
+			if !json.Valid(arguments) {
+				arguments, _ = json.Marshal(f.Reply)
+			}
+			message = structs.Message{Role: "assistant", FunctionCall: &structs.FunctionCall{Name: req.FunctionCall.Name, Arguments: arguments}}
+			finishReason = "function_call"
+		}
+
+		writeJSON(w, structs.OpenAIResponse{
+			ID:      "fake",
+			Object:  "chat.completion",
+			Created: time.Now().Unix(),
+			Model:   req.Model,
+			Usage:   structs.Usage{PromptToken: 1, CompletionTokens: len(strings.Fields(f.Reply)), TotalTokens: 1 + len(strings.Fields(f.Reply))},
+			Choices: []structs.Choice{{Message: message, FinishReason: finishReason}},
+		})
+		return
+	}
+
+	f.stream(w, func(word string, last bool) any {
+		chunk := map[string]any{"id": "fake", "object": "chat.completion.chunk", "model": req.Model}
+		choice := map[string]any{"index": 0, "delta": map[string]string{"content": word}}
+		if last {
+			choice["finish_reason"] = "stop"
+			chunk["usage"] = structs.Usage{PromptToken: 1, CompletionTokens: len(strings.Fields(f.Reply)), TotalTokens: 1 + len(strings.Fields(f.Reply))}
+		}
+		chunk["choices"] = []any{choice}
+		return chunk
+	}, true)
+}
+
+func (f *Server) generate(w http.ResponseWriter, r *http.Request) {
+	var req structs.HuggingFaceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !req.Stream {
+		writeJSON(w, []map[string]string{{"generated_text": f.Reply}})
+		return
+	}
+
+	f.stream(w, func(word string, last bool) any {
+		chunk := map[string]any{"token": map[string]any{"text": word, "special": false}, "generated_text": nil}
+		if last {
+			chunk["generated_text"] = f.Reply
+			chunk["details"] = map[string]any{"finish_reason": "eos_token", "generated_tokens": len(strings.Fields(f.Reply))}
+		}
+		return chunk
+	}, false)
+}
+
+func (f *Server) embeddings(w http.ResponseWriter, r *http.Request) {
+	var req structs.OpenAIEmbeddingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response := structs.OpenAIEmbeddingResponse{Object: "list", Model: req.Model}
+	for i, text := range req.Input {
+		response.Data = append(response.Data, struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		}{Index: i, Embedding: Embedding(text)})
+		response.Usage.PromptToken += len(strings.Fields(text))
+	}
+	response.Usage.TotalTokens = response.Usage.PromptToken
+	writeJSON(w, response)
+}
+
+func (f *Server) featureExtraction(w http.ResponseWriter, r *http.Request) {
+	var req structs.HuggingFaceEmbeddingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	embeddings := make([][]float32, 0, len(req.Inputs))
+	for _, text := range req.Inputs {
+		embeddings = append(embeddings, Embedding(text))
+	}
+	writeJSON(w, embeddings)
+}
+
+// Embedding эмбеддинг фейкового сервера: нормированный мешок слов, слова раскладываются по 64 измерениям хешем.
+// Тексты с общими словами получаются близкими, этого достаточно для проверки поиска
+func Embedding(text string) []float32 {
+	embedding := make([]float32, 64)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(word))
+		embedding[h.Sum32()%uint32(len(embedding))]++
+	}
+
+	var norm float64
+	for _, v := range embedding {
+		norm += float64(v * v)
+	}
+	if norm == 0 {
+		return embedding
+	}
+	for i := range embedding {
+		embedding[i] /= float32(math.Sqrt(norm))
+	}
+	return embedding
+}
+
+// stream отдает ответ по словам событиями SSE
+func (f *Server) stream(w http.ResponseWriter, chunk func(word string, last bool) any, done bool) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	flusher, _ := w.(http.Flusher)
+
+	words := strings.SplitAfter(f.Reply, " ")
+	for i, word := range words {
+		data, _ := json.Marshal(chunk(word, i == len(words)-1))
+		_, _ = fmt.Fprintf(w, "data: %s\n\n", data)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	if done {
+		_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+	}
+}
+
+func writeError(w http.ResponseWriter, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = fmt.Fprintf(w, `{"error":{"message":%q,"type":"fake"}}`, http.StatusText(status))
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,59 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-resty/resty/v2"
+	"github.com/gurkankaymak/hocon"
+	"sse-demo-core/internal/app/retry"
+	"sse-demo-core/internal/app/structs"
+	"sse-demo-core/internal/app/utils"
+)
+
+// OpenAI клиент OpenAI chat completions
+type OpenAI struct {
+	compatClient
+}
+
+func NewOpenAI(config *hocon.Config) *OpenAI {
+	return &OpenAI{compatClient{
+		name:           ProviderOpenAI,
+		client:         resty.New().SetPreRequestHook(utils.CurlLogger),
+		retry:          retry.FromConfig(config),
+		baseURL:        baseURL(config, ProviderOpenAI),
+		chatURI:        config.GetString("integration.openai.uri.chat"),
+		modelsURI:      config.GetString("integration.openai.uri.models"),
+		model:          config.GetString("integration.openai.model"),
+		embeddingsURI:  config.GetString("integration.openai.uri.embeddings"),
+		embeddingModel: config.GetString("integration.openai.embedding_model"),
+		body: func(req structs.LLMRequest, model string, stream bool) any {
+			body := structs.OpenAIRequest{
+				Model:       model,
+				Stream:      stream,
+				Messages:    req.Messages,
+				Temperature: req.Temperature,
+				MaxTokens:   req.MaxTokens,
+			}
+			// без include_usage OpenAI не сообщает расход токенов в потоковом режиме
+			if stream {
+				body.StreamOptions = &structs.OpenAIStreamOptions{IncludeUsage: true}
+			}
+			if len(req.Schema) > 0 {
+				body.ResponseFormat = responseFormat(config.GetString("integration.openai.response_format"), req.Schema)
+			}
+			return body
+		},
+		auth: func(context.Context) (map[string]string, error) {
+			return map[string]string{"Authorization": "Bearer " + config.GetString("integration.openai.token")}, nil
+		},
+	}}
+}
+
+// responseFormat json_schema передает схему модели, json_object (по умолчанию) только гарантирует ответ JSON объектом.
+// strict не включается: строгий режим OpenAI принимает не всякую схему
+func responseFormat(format string, schema json.RawMessage) *structs.OpenAIResponseFormat {
+	if format == "json_schema" {
+		return &structs.OpenAIResponseFormat{Type: format, JSONSchema: &structs.OpenAIJSONSchema{Name: "extract", Schema: schema}}
+	}
+	return &structs.OpenAIResponseFormat{Type: "json_object"}
+}
@@ -0,0 +1,153 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"sse-demo-core/internal/app/integration/llm/llmtest"
+	"sse-demo-core/internal/app/structs"
+	"strings"
+	"testing"
+)
+
+func TestOpenAIComplete(t *testing.T) {
+	server := newServer(t, "Привет, мир")
+	p := newProvider(t, server, ProviderOpenAI, "")
+
+	response, err := p.complete("hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Provider != ProviderOpenAI || response.Content != "Привет, мир" || response.FinishReason != "stop" || response.Usage.TotalTokens == 0 {
+		t.Fatalf("unexpected response: %+v", response)
+	}
+
+	request := server.Requests()[0]
+	if request.Path != "/v1/chat/completions" || request.Authorization != "Bearer sk-test" {
+		t.Fatalf("unexpected request %s with authorization %q", request.Path, request.Authorization)
+	}
+	body := requestBody(t, server, 0)
+	if body["model"] != "gpt-test" || body["stream"] == true || body["response_format"] != nil {
+		t.Fatalf("unexpected request body: %v", body)
+	}
+}
+
+func TestOpenAIStream(t *testing.T) {
+	server := newServer(t, "one two three")
+	p := newProvider(t, server, ProviderOpenAI, "")
+
+	response, deltas, err := p.stream("hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(deltas, "") != "one two three" || len(deltas) != 3 {
+		t.Fatalf("unexpected deltas %q", deltas)
+	}
+	if response.Content != "one two three" || response.FinishReason != "stop" || response.Usage.CompletionTokens != 3 {
+		t.Fatalf("unexpected response: %+v", response)
+	}
+
+	// без stream_options OpenAI не присылает usage в потоке
+	body := requestBody(t, server, 0)
+	options, _ := body["stream_options"].(map[string]any)
+	if body["stream"] != true || options["include_usage"] != true {
+		t.Fatalf("unexpected request body: %v", body)
+	}
+}
+
+func TestOpenAIResponseFormat(t *testing.T) {
+	schema := json.RawMessage(`{"type":"object","properties":{"total":{"type":"number"}}}`)
+	cases := []struct {
+		format string
+		want   string
+	}{
+		{"json_object", "json_object"},
+		{"json_schema", "json_schema"},
+	}
+	for _, c := range cases {
+		t.Run(c.format, func(t *testing.T) {
+			server := newServer(t, `{"total":10}`)
+			p := newProvider(t, server, ProviderOpenAI, `integration.openai.response_format = "`+c.format+`"`)
+
+			response, err := p.provider.Complete(context.Background(), structs.LLMRequest{
+				Messages: []structs.Content{{Role: "user", Content: "extract"}},
+				Schema:   schema,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if response.Content != `{"total":10}` {
+				t.Fatalf("unexpected content %q", response.Content)
+			}
+
+			format, _ := requestBody(t, server, 0)["response_format"].(map[string]any)
+			if format["type"] != c.want {
+				t.Fatalf("response_format %v, want type %s", format, c.want)
+			}
+			if _, ok := format["json_schema"]; ok != (c.want == "json_schema") {
+				t.Fatalf("unexpected json_schema in response_format %v", format)
+			}
+		})
+	}
+}
+
+func TestOpenAIListModels(t *testing.T) {
+	server := newServer(t, "")
+	p := newProvider(t, server, ProviderOpenAI, "")
+
+	models, err := p.provider.ListModels(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != 1 || models[0].ID != "fake-model" {
+		t.Fatalf("unexpected models %+v", models)
+	}
+	if path := server.Requests()[0].Path; path != "/v1/models" {
+		t.Fatalf("unexpected path %s", path)
+	}
+}
+
+func TestOpenAIEmbed(t *testing.T) {
+	server := newServer(t, "")
+	p := newProvider(t, server, ProviderOpenAI, "")
+	input := []string{"счет на оплату", "акт сверки"}
+
+	response, err := p.provider.Embed(context.Background(), structs.EmbeddingRequest{Input: input})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Model != "embed-test" || len(response.Embeddings) != len(input) {
+		t.Fatalf("unexpected response: model %s, %d embeddings", response.Model, len(response.Embeddings))
+	}
+	for i, text := range input {
+		if !equalEmbeddings(response.Embeddings[i], llmtest.Embedding(text)) {
+			t.Fatalf("embedding %d does not match text %q", i, text)
+		}
+	}
+	if path := server.Requests()[0].Path; path != "/v1/embeddings" {
+		t.Fatalf("unexpected path %s", path)
+	}
+}
+
+func TestEmbedNotConfigured(t *testing.T) {
+	server := newServer(t, "")
+	p := newProvider(t, server, ProviderOpenAI, `integration.openai.uri.embeddings = ""`)
+
+	if _, err := p.provider.Embed(context.Background(), structs.EmbeddingRequest{Input: []string{"text"}}); err == nil {
+		t.Fatal("expected error without integration.openai.uri.embeddings")
+	}
+	if n := len(server.Requests()); n != 0 {
+		t.Fatalf("server got %d requests, want 0", n)
+	}
+}
+
+func equalEmbeddings(a, b []float32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,74 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+)
+
+const maxStreamLine = 1 << 20
+
+var doneMarker = []byte("[DONE]")
+
+// ReadStream читает потоковый ответ провайдера в формате SSE (строки data: ...) или NDJSON
+// (по json объекту на строку) и вызывает onData для каждого сообщения.
+// Сообщение [DONE] завершает поток
+func ReadStream(ctx context.Context, r io.Reader, onData func(data []byte) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), maxStreamLine)
+
+	// событие SSE может состоять из нескольких строк data:, они объединяются до пустой строки
+	var event [][]byte
+
+	flush := func() (bool, error) {
+		if len(event) == 0 {
+			return false, nil
+		}
+		data := bytes.Join(event, []byte("\n"))
+		event = event[:0]
+		if bytes.Equal(bytes.TrimSpace(data), doneMarker) {
+			return true, nil
+		}
+		return false, onData(data)
+	}
+
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		line := bytes.TrimRight(scanner.Bytes(), "\r")
+
+		switch {
+		case len(line) == 0:
+			if done, err := flush(); done || err != nil {
+				return err
+			}
+		case bytes.HasPrefix(line, []byte("data:")):
+			data := bytes.TrimPrefix(line, []byte("data:"))
+			event = append(event, append([]byte(nil), bytes.TrimPrefix(data, []byte(" "))...))
+		case line[0] == ':' || bytes.HasPrefix(line, []byte("event:")) ||
+			bytes.HasPrefix(line, []byte("id:")) || bytes.HasPrefix(line, []byte("retry:")):
+			// комментарии и служебные поля SSE не используем
+		default:
+			// NDJSON, сообщение целиком в одной строке
+			if done, err := flush(); done || err != nil {
+				return err
+			}
+			if bytes.Equal(bytes.TrimSpace(line), doneMarker) {
+				return nil
+			}
+			if err := onData(append([]byte(nil), line...)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	_, err := flush()
+	return err
+}
@@ -0,0 +1,76 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func readAll(t *testing.T, stream string) ([]string, error) {
+	t.Helper()
+
+	var messages []string
+	err := ReadStream(context.Background(), strings.NewReader(stream), func(data []byte) error {
+		messages = append(messages, string(data))
+		return nil
+	})
+	return messages, err
+}
+
+func TestReadStreamSSE(t *testing.T) {
+	messages, err := readAll(t, ": keep-alive\r\n"+
+		"event: message\nid: 1\ndata: {\"a\":1}\n\n"+
+		"data: {\"b\":\ndata: 2}\n\n"+
+		"retry: 1000\n"+
+		"data: [DONE]\n\n"+
+		"data: {\"after\":\"done\"}\n\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 || messages[0] != `{"a":1}` || messages[1] != "{\"b\":\n2}" {
+		t.Fatalf("unexpected messages %q", messages)
+	}
+}
+
+func TestReadStreamWithoutTrailingBlankLine(t *testing.T) {
+	messages, err := readAll(t, "data: one\n\ndata: two")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 || messages[1] != "two" {
+		t.Fatalf("unexpected messages %q", messages)
+	}
+}
+
+func TestReadStreamNDJSON(t *testing.T) {
+	messages, err := readAll(t, "{\"token\":\"a\"}\n{\"token\":\"b\"}\n[DONE]\n{\"token\":\"c\"}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 || messages[1] != `{"token":"b"}` {
+		t.Fatalf("unexpected messages %q", messages)
+	}
+}
+
+func TestReadStreamStopsOnError(t *testing.T) {
+	stop := errors.New("stop")
+	calls := 0
+	err := ReadStream(context.Background(), strings.NewReader("data: 1\n\ndata: 2\n\n"), func([]byte) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Fatalf("got %v after %d calls, want stop after 1", err, calls)
+	}
+}
+
+func TestReadStreamCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := ReadStream(ctx, strings.NewReader("data: 1\n\n"), func([]byte) error { return nil })
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,23 @@
+package services
+
+import "sse-demo-core/internal/app/structs"
+
+type AnalysisService interface {
+	StartAnalysis(result *structs.AnalysisResult) (int, error)
+	CompleteAnalysis(result *structs.AnalysisResult) error
+	FailAnalysis(result *structs.AnalysisResult, cause error) error
+	FindUploadResults(guid string, userID int) ([]structs.AnalysisResult, error)
+	StartPipeline(pipeline *structs.Pipeline) (int, error)
+	ResumePipeline(pipeline *structs.Pipeline, stale int) (bool, error)
+	CompletePipelineStep(pipeline *structs.Pipeline, step int) error
+	CompletePipeline(pipeline *structs.Pipeline) error
+	FailPipeline(pipeline *structs.Pipeline, cause error) error
+	FindInterruptedPipelines(stale int) ([]structs.Pipeline, error)
+	FindPipeline(id int, guid string, userID int) (*structs.Pipeline, error)
+	FindUploadPipelines(guid string, userID int) ([]structs.Pipeline, error)
+	FindPipelineResults(pipelineID int, completed bool) ([]structs.AnalysisResult, error)
+	StartExtraction(extraction *structs.Extraction) (int, error)
+	CompleteExtraction(extraction *structs.Extraction) error
+	FailExtraction(extraction *structs.Extraction, cause error) error
+	FindUploadExtractions(guid string, userID int) ([]structs.Extraction, error)
+}
@@ -0,0 +1,19 @@
+package services
+
+import (
+	"context"
+	"sse-demo-core/internal/app/structs"
+)
+
+// Assistants клиент OpenAI Assistants API
+type Assistants interface {
+	DefaultAssistant() string
+	UploadFile(ctx context.Context, name string, data []byte) (*structs.AssistantsFileUploadResponse, error)
+	LinkFile(ctx context.Context, assistantID string, fileID string) (*structs.AssistantsLinkFileToAssistant, error)
+	CreateThread(ctx context.Context) (*structs.AssistantsThread, error)
+	CreateMessage(ctx context.Context, threadID string, req structs.ThreadMessageRequest) (*structs.AIMessage, error)
+	ListMessages(ctx context.Context, threadID string) (*structs.ListAIMessages, error)
+	CreateRun(ctx context.Context, threadID string, req structs.ThreadRunRequest) (*structs.AIThreadRun, error)
+	GetRun(ctx context.Context, threadID string, runID string) (*structs.AIThreadRun, error)
+	ListRunSteps(ctx context.Context, threadID string, runID string) (*structs.AIRunStepDetails, error)
+}
@@ -0,0 +1,9 @@
+package services
+
+import "sse-demo-core/internal/app/structs"
+
+type LayerService interface {
+	SaveLayer(layer *structs.UserLayer) (int, error)
+	FindUploadLayers(guid string, userID int) ([]structs.UserLayer, error)
+	SetLayerFile(layer *structs.UserLayer, fileID string, assistantID string) error
+}
@@ -0,0 +1,17 @@
+package services
+
+import (
+	"context"
+	"sse-demo-core/internal/app/structs"
+)
+
+// LLMProvider клиент языковой модели, провайдер выбирается в integration.active
+type LLMProvider interface {
+	Name() string
+	Complete(ctx context.Context, req structs.LLMRequest) (*structs.LLMResponse, error)
+	// Stream вызывает onDelta для каждого полученного фрагмента ответа и возвращает собранный ответ
+	Stream(ctx context.Context, req structs.LLMRequest, onDelta func(delta string) error) (*structs.LLMResponse, error)
+	ListModels(ctx context.Context) ([]structs.Model, error)
+	// Embed эмбеддинги текстов для поиска по содержимому загрузок
+	Embed(ctx context.Context, req structs.EmbeddingRequest) (*structs.EmbeddingResponse, error)
+}
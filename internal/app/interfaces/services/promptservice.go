@@ -0,0 +1,14 @@
+package services
+
+import "sse-demo-core/internal/app/structs"
+
+type PromptService interface {
+	FindStagePrompt(stage int, language string) (*structs.Prompt, error)
+	FindPrompts(stage int) ([]structs.Prompt, error)
+	FindPrompt(id int) (*structs.Prompt, error)
+	CreatePrompt(prompt *structs.Prompt, userID int) error
+	UpdatePrompt(prompt *structs.Prompt, userID int) (bool, error)
+	DeletePrompt(id int) (bool, error)
+	FindPromptVersions(id int) ([]structs.PromptVersion, error)
+	FindPromptVersion(id int, version int) (*structs.PromptVersion, error)
+}
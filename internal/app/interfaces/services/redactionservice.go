@@ -0,0 +1,12 @@
+package services
+
+import "sse-demo-core/internal/app/structs"
+
+type RedactionService interface {
+	SaveTokens(guid string, uid string, userID int, tokens []structs.RedactionToken) error
+	Restore(guid string, userID int, text string) (string, error)
+	RestoreLayer(guid string, uid string, userID int, text string) (string, error)
+	FindTokens(guid string, uid string, userID int) ([]structs.RedactionToken, error)
+	Encrypt(text string) (string, error)
+	Decrypt(text string) (string, error)
+}
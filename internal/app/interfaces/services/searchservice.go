@@ -0,0 +1,8 @@
+package services
+
+import "sse-demo-core/internal/app/structs"
+
+type SearchService interface {
+	SaveChunks(chunks []structs.LayerChunk) error
+	Search(userID int, model string, embedding []float32, guids []string, topK int) ([]structs.LayerChunk, error)
+}
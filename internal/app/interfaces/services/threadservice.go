@@ -0,0 +1,12 @@
+package services
+
+import "sse-demo-core/internal/app/structs"
+
+type ThreadService interface {
+	CreateThread(thread *structs.Thread, files []structs.ThreadFile) (int, error)
+	FindThread(threadID string, userID int) (*structs.Thread, error)
+	FindUserThreads(userID int) ([]structs.Thread, error)
+	FindThreadFiles(threadID string, userID int) ([]structs.ThreadFile, error)
+	SaveMessage(message *structs.ThreadMessage) error
+	FindThreadMessages(threadID string, userID int) ([]structs.ThreadMessage, error)
+}
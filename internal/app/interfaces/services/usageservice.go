@@ -0,0 +1,12 @@
+package services
+
+import "sse-demo-core/internal/app/structs"
+
+type UsageService interface {
+	RecordUpload(userID int, guid string, files int, bytes int64) error
+	RecordLLM(userID int, guid string, usage *structs.Usage) error
+	CheckQuota(user *structs.User, job string, size int64) (*structs.QuotaExceededDetails, error)
+	FindUserUsage(user *structs.User) (*structs.UserUsage, error)
+	FindUsersUsage() ([]structs.UserUsage, error)
+	FindMessagesStatistics() ([]structs.UserMessagesStatistics, error)
+}
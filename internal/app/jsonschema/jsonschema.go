@@ -0,0 +1,499 @@
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/mail"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// unsupported ключевые слова, которые меняют результат проверки, но не поддерживаются.
+// Схема с ними отклоняется при компиляции, чтобы не принять значение, не прошедшее бы полную проверку
+var unsupported = []string{"$ref", "$dynamicRef", "patternProperties", "propertyNames", "dependentRequired", "dependentSchemas",
+	"prefixItems", "contains", "uniqueItems", "if", "then", "else", "unevaluatedProperties", "unevaluatedItems"}
+
+var types = map[string]bool{"object": true, "array": true, "string": true, "number": true, "integer": true, "boolean": true, "null": true}
+
+// Schema проверка JSON значений подмножеством JSON Schema: type, properties, required, additionalProperties, items,
+// enum, const, minimum, maximum, exclusiveMinimum, exclusiveMaximum, multipleOf, minLength, maxLength, pattern,
+// format (date, date-time, email), minItems, maxItems, minProperties, maxProperties, allOf, anyOf, oneOf, not.
+// Аннотации (title, description, examples, default) не проверяются
+type Schema struct {
+	root     any
+	patterns map[string]*regexp.Regexp
+}
+
+// ValidationError ошибки проверки значения схемой: путь к значению и нарушенное ограничение, без самих значений
+type ValidationError struct {
+	Errors []string
+}
+
+func (e *ValidationError) Error() string {
+	return strings.Join(e.Errors, "; ")
+}
+
+// Compile разбирает схему и проверяет, что все ее ограничения поддерживаются
+func Compile(data []byte) (*Schema, error) {
+	root, err := decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema json, %w", err)
+	}
+	if _, ok := root.(map[string]any); !ok {
+		return nil, errors.New("schema must be a json object")
+	}
+
+	s := &Schema{root: root, patterns: make(map[string]*regexp.Regexp)}
+	if err = s.compile(root, "#"); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Validate проверяет JSON значение data схемой, ошибки проверки возвращаются как *ValidationError
+func (s *Schema) Validate(data []byte) error {
+	value, err := decode(data)
+	if err != nil {
+		return &ValidationError{Errors: []string{"$: invalid json, " + err.Error()}}
+	}
+
+	var errs []string
+	s.validate(s.root, value, "$", &errs)
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+// Type тип корня схемы, пустой, если тип не задан или задано несколько типов
+func (s *Schema) Type() string {
+	t, _ := s.root.(map[string]any)["type"].(string)
+	return t
+}
+
+func decode(data []byte) (any, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+
+	var value any
+	if err := d.Decode(&value); err != nil {
+		return nil, err
+	}
+	if d.More() {
+		return nil, errors.New("unexpected data after json value")
+	}
+	return value, nil
+}
+
+func (s *Schema) compile(node any, path string) error {
+	if _, ok := node.(bool); ok {
+		return nil
+	}
+	schema, ok := node.(map[string]any)
+	if !ok {
+		return fmt.Errorf("%s: schema must be an object or boolean", path)
+	}
+
+	for _, keyword := range unsupported {
+		if _, ok := schema[keyword]; ok {
+			return fmt.Errorf("%s: keyword %q is not supported", path, keyword)
+		}
+	}
+
+	for keyword, value := range schema {
+		var err error
+		switch keyword {
+		case "type":
+			err = compileType(value, path)
+		case "properties":
+			properties, ok := value.(map[string]any)
+			if !ok {
+				return fmt.Errorf("%s/properties: must be an object", path)
+			}
+			for name, property := range properties {
+				if err = s.compile(property, path+"/properties/"+name); err != nil {
+					return err
+				}
+			}
+		case "additionalProperties", "items", "not":
+			if _, ok := value.([]any); ok {
+				return fmt.Errorf("%s/%s: array form is not supported", path, keyword)
+			}
+			err = s.compile(value, path+"/"+keyword)
+		case "allOf", "anyOf", "oneOf":
+			schemas, ok := value.([]any)
+			if !ok || len(schemas) == 0 {
+				return fmt.Errorf("%s/%s: must be a non-empty array", path, keyword)
+			}
+			for i, sub := range schemas {
+				if err = s.compile(sub, fmt.Sprintf("%s/%s/%d", path, keyword, i)); err != nil {
+					return err
+				}
+			}
+		case "required":
+			names, ok := value.([]any)
+			if !ok {
+				return fmt.Errorf("%s/required: must be an array of strings", path)
+			}
+			for _, name := range names {
+				if _, ok := name.(string); !ok {
+					return fmt.Errorf("%s/required: must be an array of strings", path)
+				}
+			}
+		case "enum":
+			if _, ok := value.([]any); !ok {
+				return fmt.Errorf("%s/enum: must be an array", path)
+			}
+		case "minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum", "multipleOf":
+			n, ok := value.(json.Number)
+			if !ok {
+				return fmt.Errorf("%s/%s: must be a number", path, keyword)
+			}
+			if f, _ := n.Float64(); keyword == "multipleOf" && f <= 0 {
+				return fmt.Errorf("%s/multipleOf: must be greater than 0", path)
+			}
+		case "minLength", "maxLength", "minItems", "maxItems", "minProperties", "maxProperties":
+			if _, ok := count(value); !ok {
+				return fmt.Errorf("%s/%s: must be a non-negative integer", path, keyword)
+			}
+		case "pattern":
+			pattern, ok := value.(string)
+			if !ok {
+				return fmt.Errorf("%s/pattern: must be a string", path)
+			}
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("%s/pattern: %w", path, err)
+			}
+			s.patterns[pattern] = re
+		case "format":
+			if _, ok := value.(string); !ok {
+				return fmt.Errorf("%s/format: must be a string", path)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func compileType(value any, path string) error {
+	names := []any{value}
+	if list, ok := value.([]any); ok {
+		names = list
+	}
+	for _, name := range names {
+		if t, ok := name.(string); !ok || !types[t] {
+			return fmt.Errorf("%s/type: unknown type %v", path, name)
+		}
+	}
+	return nil
+}
+
+func (s *Schema) validate(node any, value any, path string, errs *[]string) {
+	if allowed, ok := node.(bool); ok {
+		if !allowed {
+			*errs = append(*errs, path+": no value is allowed")
+		}
+		return
+	}
+	schema := node.(map[string]any)
+
+	if t, ok := schema["type"]; ok && !matchType(t, value) {
+		*errs = append(*errs, fmt.Sprintf("%s: expected %s, got %s", path, typeNames(t), typeOf(value)))
+		return
+	}
+
+	if enum, ok := schema["enum"].([]any); ok && !contains(enum, value) {
+		*errs = append(*errs, path+": value is not one of enum")
+	}
+	if constant, ok := schema["const"]; ok && !equal(constant, value) {
+		*errs = append(*errs, path+": value is not equal to const")
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		s.validateObject(schema, v, path, errs)
+	case []any:
+		s.validateArray(schema, v, path, errs)
+	case string:
+		s.validateString(schema, v, path, errs)
+	case json.Number:
+		validateNumber(schema, v, path, errs)
+	}
+
+	s.validateCombinators(schema, value, path, errs)
+}
+
+func (s *Schema) validateObject(schema map[string]any, object map[string]any, path string, errs *[]string) {
+	if required, ok := schema["required"].([]any); ok {
+		for _, name := range required {
+			if _, ok := object[name.(string)]; !ok {
+				*errs = append(*errs, fmt.Sprintf("%s: missing required property %q", path, name))
+			}
+		}
+	}
+
+	if n, ok := count(schema["minProperties"]); ok && len(object) < n {
+		*errs = append(*errs, fmt.Sprintf("%s: must have at least %d properties", path, n))
+	}
+	if n, ok := count(schema["maxProperties"]); ok && len(object) > n {
+		*errs = append(*errs, fmt.Sprintf("%s: must have at most %d properties", path, n))
+	}
+
+	properties, _ := schema["properties"].(map[string]any)
+	additional, hasAdditional := schema["additionalProperties"]
+
+	// порядок ошибок не зависит от порядка обхода map
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if property, ok := properties[name]; ok {
+			s.validate(property, object[name], path+"."+name, errs)
+		} else if additional == false {
+			*errs = append(*errs, path+"."+name+": additional property is not allowed")
+		} else if hasAdditional {
+			s.validate(additional, object[name], path+"."+name, errs)
+		}
+	}
+}
+
+func (s *Schema) validateArray(schema map[string]any, array []any, path string, errs *[]string) {
+	if n, ok := count(schema["minItems"]); ok && len(array) < n {
+		*errs = append(*errs, fmt.Sprintf("%s: must have at least %d items", path, n))
+	}
+	if n, ok := count(schema["maxItems"]); ok && len(array) > n {
+		*errs = append(*errs, fmt.Sprintf("%s: must have at most %d items", path, n))
+	}
+
+	if items, ok := schema["items"]; ok {
+		for i, item := range array {
+			s.validate(items, item, fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	}
+}
+
+func (s *Schema) validateString(schema map[string]any, str string, path string, errs *[]string) {
+	length := utf8.RuneCountInString(str)
+	if n, ok := count(schema["minLength"]); ok && length < n {
+		*errs = append(*errs, fmt.Sprintf("%s: length must be at least %d", path, n))
+	}
+	if n, ok := count(schema["maxLength"]); ok && length > n {
+		*errs = append(*errs, fmt.Sprintf("%s: length must be at most %d", path, n))
+	}
+
+	if pattern, ok := schema["pattern"].(string); ok && !s.patterns[pattern].MatchString(str) {
+		*errs = append(*errs, fmt.Sprintf("%s: does not match pattern %q", path, pattern))
+	}
+
+	if format, ok := schema["format"].(string); ok && !validFormat(format, str) {
+		*errs = append(*errs, fmt.Sprintf("%s: is not a valid %s", path, format))
+	}
+}
+
+func validateNumber(schema map[string]any, number json.Number, path string, errs *[]string) {
+	value, _ := number.Float64()
+
+	checks := []struct {
+		keyword string
+		fails   func(limit float64) bool
+		message string
+	}{
+		{"minimum", func(limit float64) bool { return value < limit }, "must be >= %v"},
+		{"maximum", func(limit float64) bool { return value > limit }, "must be <= %v"},
+		{"exclusiveMinimum", func(limit float64) bool { return value <= limit }, "must be > %v"},
+		{"exclusiveMaximum", func(limit float64) bool { return value >= limit }, "must be < %v"},
+		{"multipleOf", func(limit float64) bool {
+			q := value / limit
+			return math.Abs(q-math.Round(q)) > 1e-9
+		}, "must be a multiple of %v"},
+	}
+	for _, check := range checks {
+		limit, ok := schema[check.keyword].(json.Number)
+		if !ok {
+			continue
+		}
+		if l, _ := limit.Float64(); check.fails(l) {
+			*errs = append(*errs, fmt.Sprintf("%s: "+check.message, path, limit))
+		}
+	}
+}
+
+func (s *Schema) validateCombinators(schema map[string]any, value any, path string, errs *[]string) {
+	matches := func(sub any) bool {
+		var subErrs []string
+		s.validate(sub, value, path, &subErrs)
+		return len(subErrs) == 0
+	}
+
+	if all, ok := schema["allOf"].([]any); ok {
+		for _, sub := range all {
+			s.validate(sub, value, path, errs)
+		}
+	}
+
+	if any, ok := schema["anyOf"].([]any); ok {
+		matched := false
+		for _, sub := range any {
+			if matches(sub) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			*errs = append(*errs, path+": must match at least one schema of anyOf")
+		}
+	}
+
+	if one, ok := schema["oneOf"].([]any); ok {
+		matched := 0
+		for _, sub := range one {
+			if matches(sub) {
+				matched++
+			}
+		}
+		if matched != 1 {
+			*errs = append(*errs, fmt.Sprintf("%s: must match exactly one schema of oneOf, matched %d", path, matched))
+		}
+	}
+
+	if not, ok := schema["not"]; ok && matches(not) {
+		*errs = append(*errs, path+": must not match schema of not")
+	}
+}
+
+func matchType(t any, value any) bool {
+	if list, ok := t.([]any); ok {
+		for _, name := range list {
+			if matchType(name, value) {
+				return true
+			}
+		}
+		return false
+	}
+
+	name := t.(string)
+	actual := typeOf(value)
+	if name == "number" && actual == "integer" {
+		return true
+	}
+	return name == actual
+}
+
+func typeNames(t any) string {
+	if list, ok := t.([]any); ok {
+		names := make([]string, 0, len(list))
+		for _, name := range list {
+			names = append(names, name.(string))
+		}
+		return strings.Join(names, " or ")
+	}
+	return t.(string)
+}
+
+func typeOf(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	case json.Number:
+		if f, err := v.Float64(); err == nil && f == math.Trunc(f) && !math.IsInf(f, 0) {
+			return "integer"
+		}
+		return "number"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// count неотрицательное целое ограничение схемы
+func count(value any) (int, bool) {
+	n, ok := value.(json.Number)
+	if !ok {
+		return 0, false
+	}
+	i, err := n.Int64()
+	if err != nil || i < 0 {
+		return 0, false
+	}
+	return int(i), true
+}
+
+func contains(values []any, value any) bool {
+	for _, v := range values {
+		if equal(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// equal равенство JSON значений, числа сравниваются по значению: 1 и 1.0 равны
+func equal(a any, b any) bool {
+	switch av := a.(type) {
+	case json.Number:
+		bv, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		af, _ := av.Float64()
+		bf, _ := bv.Float64()
+		return af == bf
+	case []any:
+		bv, ok := b.([]any)
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !equal(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		bv, ok := b.(map[string]any)
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for k, v := range av {
+			if w, ok := bv[k]; !ok || !equal(v, w) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+// validFormat проверяет форматы date, date-time и email, остальные форматы - аннотации
+func validFormat(format string, value string) bool {
+	switch format {
+	case "date":
+		_, err := time.Parse("2006-01-02", value)
+		return err == nil
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, value)
+		return err == nil
+	case "email":
+		address, err := mail.ParseAddress(value)
+		return err == nil && address.Address == value
+	}
+	return true
+}
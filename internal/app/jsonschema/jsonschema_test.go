@@ -0,0 +1,185 @@
+package jsonschema
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const invoiceSchema = `{
+  "type": "object",
+  "required": ["number", "date", "total", "items"],
+  "additionalProperties": false,
+  "properties": {
+    "number": {"type": "string", "pattern": "^INV-\\d+$"},
+    "date": {"type": "string", "format": "date"},
+    "email": {"type": ["string", "null"], "format": "email"},
+    "currency": {"enum": ["RUB", "USD", "EUR"]},
+    "total": {"type": "number", "minimum": 0, "multipleOf": 0.01},
+    "items": {
+      "type": "array",
+      "minItems": 1,
+      "items": {
+        "type": "object",
+        "required": ["name", "quantity"],
+        "properties": {
+          "name": {"type": "string", "minLength": 1, "maxLength": 20},
+          "quantity": {"type": "integer", "exclusiveMinimum": 0}
+        }
+      }
+    }
+  }
+}`
+
+func compile(t *testing.T, schema string) *Schema {
+	t.Helper()
+
+	s, err := Compile([]byte(schema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestValidate(t *testing.T) {
+	s := compile(t, invoiceSchema)
+
+	cases := []struct {
+		name   string
+		value  string
+		errors []string
+	}{
+		{
+			name:  "valid",
+			value: `{"number": "INV-1", "date": "2024-03-01", "email": null, "currency": "RUB", "total": 10.5, "items": [{"name": "a", "quantity": 2}]}`,
+		},
+		{
+			name:   "missing required",
+			value:  `{"number": "INV-1", "date": "2024-03-01", "total": 1}`,
+			errors: []string{`$: missing required property "items"`},
+		},
+		{
+			name:   "wrong types",
+			value:  `{"number": 1, "date": "2024-03-01", "total": "10", "items": {}}`,
+			errors: []string{"$.items: expected array, got object", "$.number: expected string, got integer", "$.total: expected number, got string"},
+		},
+		{
+			name:  "constraints",
+			value: `{"number": "N-1", "date": "01.03.2024", "email": "[EMAIL_1]", "currency": "GBP", "total": -1.001, "items": [], "extra": true}`,
+			errors: []string{
+				`$.currency: value is not one of enum`,
+				`$.date: is not a valid date`,
+				`$.email: is not a valid email`,
+				`$.extra: additional property is not allowed`,
+				`$.items: must have at least 1 items`,
+				`$.number: does not match pattern "^INV-\\d+$"`,
+				`$.total: must be >= 0`,
+				`$.total: must be a multiple of 0.01`,
+			},
+		},
+		{
+			name:  "nested items",
+			value: `{"number": "INV-1", "date": "2024-03-01", "total": 1, "items": [{"name": "", "quantity": 0}, {"name": "very long item name here", "quantity": 1.5}]}`,
+			errors: []string{
+				"$.items[0].name: length must be at least 1",
+				"$.items[0].quantity: must be > 0",
+				"$.items[1].name: length must be at most 20",
+				"$.items[1].quantity: expected integer, got number",
+			},
+		},
+		{
+			name:   "not json",
+			value:  `{"number": `,
+			errors: []string{"$: invalid json, unexpected EOF"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := s.Validate([]byte(c.value))
+			if c.errors == nil {
+				if err != nil {
+					t.Fatalf("valid value rejected, %v", err)
+				}
+				return
+			}
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("error %v, expected ValidationError", err)
+			}
+			if !reflect.DeepEqual(validationErr.Errors, c.errors) {
+				t.Errorf("errors\n%q\nexpected\n%q", validationErr.Errors, c.errors)
+			}
+		})
+	}
+}
+
+// TestValidationErrorsWithoutValues ошибки уходят в модель при повторном запросе и в логи, значений в них быть не должно
+func TestValidationErrorsWithoutValues(t *testing.T) {
+	s := compile(t, `{"type": "object", "properties": {"email": {"type": "string", "format": "email"}, "code": {"const": "A"}}}`)
+
+	err := s.Validate([]byte(`{"email": "ivan.petrov at mail", "code": "secret-value"}`))
+	if err == nil {
+		t.Fatal("invalid value accepted")
+	}
+	for _, value := range []string{"ivan.petrov", "secret-value"} {
+		if strings.Contains(err.Error(), value) {
+			t.Errorf("error %q contains value %q", err, value)
+		}
+	}
+}
+
+func TestCombinators(t *testing.T) {
+	s := compile(t, `{
+  "anyOf": [{"type": "string"}, {"type": "integer"}],
+  "oneOf": [{"type": "integer"}, {"minimum": 10}],
+  "not": {"const": 5}
+}`)
+
+	cases := []struct {
+		value string
+		valid bool
+	}{
+		{`"text"`, true},
+		{`3`, true},
+		{`5`, false},
+		{`12`, false},
+		{`true`, false},
+	}
+	for _, c := range cases {
+		if err := s.Validate([]byte(c.value)); (err == nil) != c.valid {
+			t.Errorf("Validate(%s) = %v, expected valid %v", c.value, err, c.valid)
+		}
+	}
+}
+
+func TestCompile(t *testing.T) {
+	invalid := []string{
+		`[]`,
+		`{"type": "text"}`,
+		`{"$ref": "#/definitions/a"}`,
+		`{"properties": {"a": {"uniqueItems": true}}}`,
+		`{"items": [{"type": "string"}]}`,
+		`{"anyOf": []}`,
+		`{"required": [1]}`,
+		`{"pattern": "("}`,
+		`{"multipleOf": 0}`,
+		`{"minLength": -1}`,
+		`{"type": "object"} {}`,
+	}
+	for _, schema := range invalid {
+		if _, err := Compile([]byte(schema)); err == nil {
+			t.Errorf("schema %s is accepted", schema)
+		}
+	}
+
+	s := compile(t, `{"type": "object", "title": "annotation", "description": "ignored"}`)
+	if s.Type() != "object" {
+		t.Errorf("type %q, expected object", s.Type())
+	}
+	if s = compile(t, `{"type": ["object", "null"]}`); s.Type() != "" {
+		t.Errorf("type %q of several types, expected empty", s.Type())
+	}
+}
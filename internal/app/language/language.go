@@ -0,0 +1,132 @@
+package language
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Коды языков ISO 639-1
+const (
+	Russian   = "ru"
+	Ukrainian = "uk"
+	English   = "en"
+	German    = "de"
+	French    = "fr"
+	Spanish   = "es"
+	Unknown   = ""
+)
+
+// minLetters меньше букв - язык не определяем
+const minLetters = 20
+
+// sampleSize определяем по началу текста, этого достаточно и не зависит от размера документа
+const sampleSize = 20000
+
+// Detection результат определения языка
+type Detection struct {
+	Language string
+	// Confidence уверенность, 0..1
+	Confidence float64
+}
+
+// stopwords частые служебные слова языков одной письменности
+var stopwords = map[string]map[string]bool{
+	Russian:   set("и в не на что с по как это он я к но из а то за же от для так все был мы вы при или бы если их уже когда только ее ли да"),
+	Ukrainian: set("і в не на що з та як це він я до але із а то за же від для так усі був ми ви при або би якщо їх вже коли тільки її чи є"),
+	English:   set("the of and to in is that for it with as was on be by this are or from at an not have which but has were can will their"),
+	German:    set("der die und in den von zu das mit sich des auf für ist im dem nicht ein eine als auch es an werden aus er hat dass sie nach"),
+	French:    set("le la les de des et à en un une du est que qui pour dans par sur pas au plus ne se ce avec il sont ou"),
+	Spanish:   set("el la los las de del y en un una que es por para con no se al lo como más su sus pero o ha fue son"),
+}
+
+// Detect определяет язык текста без обращения к внешним сервисам: сначала по письменности,
+// затем по частоте служебных слов языков этой письменности
+func Detect(text string) Detection {
+	text = sample(text)
+
+	var cyrillic, latin int
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		}
+	}
+
+	letters := cyrillic + latin
+	if letters < minLetters {
+		return Detection{Language: Unknown}
+	}
+
+	var candidates []string
+	var share float64
+	if cyrillic >= latin {
+		candidates = []string{Russian, Ukrainian}
+		share = float64(cyrillic) / float64(letters)
+	} else {
+		candidates = []string{English, German, French, Spanish}
+		share = float64(latin) / float64(letters)
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+
+	scores := make(map[string]int, len(candidates))
+	total := 0
+	for _, w := range words {
+		for _, lang := range candidates {
+			if stopwords[lang][w] {
+				scores[lang]++
+				total++
+			}
+		}
+	}
+
+	// буквы, которые встречаются только в украинском
+	if cyrillic >= latin && strings.ContainsAny(text, "іїєґІЇЄҐ") && !strings.ContainsAny(text, "ыэъЫЭЪ") {
+		scores[Ukrainian] += len(words)/10 + 1
+		total += len(words)/10 + 1
+	}
+
+	best := candidates[0]
+	for _, lang := range candidates[1:] {
+		if scores[lang] > scores[best] {
+			best = lang
+		}
+	}
+
+	confidence := share
+	if total > 0 {
+		confidence *= float64(scores[best]) / float64(total)
+	} else {
+		// служебных слов нет, например таблица, оставляем язык письменности по умолчанию
+		confidence *= 0.5
+	}
+
+	return Detection{Language: best, Confidence: confidence}
+}
+
+// sample начало текста не длиннее sampleSize байт, обрезанное по границе символа,
+// чтобы не оставить в конце обрезанный многобайтовый символ
+func sample(text string) string {
+	if len(text) <= sampleSize {
+		return text
+	}
+
+	end := sampleSize
+	for end > 0 && !utf8.RuneStart(text[end]) {
+		end--
+	}
+	return text[:end]
+}
+
+func set(words string) map[string]bool {
+	result := make(map[string]bool)
+	for _, w := range strings.Fields(words) {
+		result[w] = true
+	}
+	return result
+}
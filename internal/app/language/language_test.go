@@ -0,0 +1,71 @@
+package language
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestDetect(t *testing.T) {
+	cases := []struct {
+		name     string
+		text     string
+		expected string
+	}{
+		{"russian", "Договор вступает в силу с момента подписания и действует до полного исполнения обязательств, если стороны не договорились иначе.", Russian},
+		{"ukrainian", "Договір набирає чинності з моменту підписання і діє до повного виконання зобов'язань, якщо сторони не домовилися інакше.", Ukrainian},
+		{"english", "The agreement comes into force on the date of signing and is valid until the parties have fulfilled their obligations.", English},
+		{"german", "Der Vertrag tritt mit der Unterzeichnung in Kraft und gilt bis zur vollständigen Erfüllung der Pflichten, die sich aus dem Vertrag ergeben.", German},
+		{"french", "Le contrat entre en vigueur à la date de sa signature et reste valable pour la durée des obligations des parties.", French},
+		{"spanish", "El contrato entra en vigor en la fecha de su firma y es válido hasta que las partes cumplan con sus obligaciones.", Spanish},
+		{"too short", "Привет", Unknown},
+		{"digits", "12345 67890 12345 67890 12345 67890", Unknown},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			detection := Detect(c.text)
+			if detection.Language != c.expected {
+				t.Errorf("language %q, expected %q", detection.Language, c.expected)
+			}
+			if detection.Confidence < 0 || detection.Confidence > 1 {
+				t.Errorf("confidence %f out of 0..1", detection.Confidence)
+			}
+		})
+	}
+}
+
+// TestDetectWithoutStopwords таблица без служебных слов определяется по письменности с низкой уверенностью
+func TestDetectWithoutStopwords(t *testing.T) {
+	detection := Detect("Наименование Количество Стоимость Итого Наименование Количество")
+	if detection.Language != Russian {
+		t.Errorf("language %q, expected %q", detection.Language, Russian)
+	}
+	if detection.Confidence > 0.5 {
+		t.Errorf("confidence %f, expected at most 0.5", detection.Confidence)
+	}
+}
+
+// TestSample граница выборки попадает внутрь двухбайтового символа кириллицы
+func TestSample(t *testing.T) {
+	text := "a" + strings.Repeat("я", sampleSize)
+	if utf8.RuneStart(text[sampleSize]) {
+		t.Fatal("sample boundary is not inside a rune")
+	}
+
+	s := sample(text)
+	if !utf8.ValidString(s) {
+		t.Error("sample ends with a broken rune")
+	}
+	if len(s) != sampleSize-1 {
+		t.Errorf("sample length %d, expected %d", len(s), sampleSize-1)
+	}
+
+	if short := "короткий текст"; sample(short) != short {
+		t.Error("short text is cut")
+	}
+
+	if detection := Detect(text); detection.Language != Russian {
+		t.Errorf("language %q, expected %q", detection.Language, Russian)
+	}
+}
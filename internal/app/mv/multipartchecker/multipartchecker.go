@@ -1,17 +1,22 @@
 package multipartchecker
 
 import (
+	"errors"
 	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
 	"github.com/labstack/echo-contrib/jaegertracing"
 	"github.com/labstack/echo/v4"
+	"mime/multipart"
 	"net/http"
+	fileutils "sse-demo-core/internal/app/endpoint/files/utils"
 	"sse-demo-core/internal/app/interfaces/services"
+	"sse-demo-core/internal/app/policy"
 	"sse-demo-core/internal/app/structs"
+	"sse-demo-core/internal/app/utils"
 )
 
 const AUTHORIZATION = "Authorization"
 
-func MultipartCountChecker(service services.JwtService) echo.MiddlewareFunc {
+func MultipartCountChecker(policies *policy.UploadPolicies, service services.JwtService, users services.UserService) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(ctx echo.Context) error {
 			span := jaegertracing.CreateChildSpan(ctx, "multipart header middleware")
@@ -21,7 +26,7 @@ func MultipartCountChecker(service services.JwtService) echo.MiddlewareFunc {
 			logger.Debug(">> Multipart Header Middleware started...")
 
 			if ctx.Request().Method == "POST" &&
-				(ctx.Request().RequestURI == "/upload" || ctx.Request().RequestURI == "/assistants/files") { // Проверяем наличие заголовка Authorization
+				ctx.Request().RequestURI == "/upload" { // Проверяем наличие заголовка Authorization
 				token := ctx.Request().Header.Get(AUTHORIZATION)
 				if token == "" {
 					cookie, err := ctx.Cookie("sse_demoToken")
@@ -38,23 +43,50 @@ func MultipartCountChecker(service services.JwtService) echo.MiddlewareFunc {
 
 					// Кладем нужные нам данные в контекст и используем далее в handlers
 					ctx.Set("claims", claims)
+
+					user, err := utils.GetUserFromClaims(claims, users)
+					if err != nil {
+						logger.Error("error getting user from token claims, ", err)
+						return echo.NewHTTPError(http.StatusUnauthorized, structs.ErrorResponse{Error: "user not found"})
+					}
+					ctx.Set("user", user)
 				} else {
 					return echo.NewHTTPError(http.StatusUnauthorized, structs.ErrorResponse{Error: "authorization required"})
 				}
 
-				// Получаем данные формы
+				// Ограничения загрузки зависят от роли пользователя
+				user := ctx.Get("user").(*structs.User)
+				uploadPolicy := policies.ForRole(user.Role)
+
+				// Проверяем размер до разбора тела, пока файлы еще не прочитаны
+				if v := uploadPolicy.CheckRequestSize(ctx.Request().ContentLength); v != nil {
+					return violation(http.StatusRequestEntityTooLarge, v)
+				}
+				if uploadPolicy.MaxTotalSize > 0 {
+					ctx.Request().Body = http.MaxBytesReader(ctx.Response(), ctx.Request().Body, uploadPolicy.MaxTotalSize+policy.MultipartOverhead)
+				}
+
+				// Получаем данные формы, хеши файлов считаются по ходу чтения тела
+				hasher := fileutils.NewPartHasher(ctx.Request())
 				err := ctx.Request().ParseMultipartForm(32 << 20)
+				var files []*multipart.FileHeader
+				if err == nil {
+					files = ctx.Request().MultipartForm.File["file"]
+				}
+				if hashes := hasher.Hashes("file", files); hashes != nil {
+					ctx.Set(fileutils.FileHashesKey, hashes)
+				}
 				if err != nil {
+					var maxBytesErr *http.MaxBytesError
+					if errors.As(err, &maxBytesErr) {
+						return violation(http.StatusRequestEntityTooLarge, &policy.Violation{Code: policy.ViolationTotalTooLarge, Message: "total upload size exceeded"})
+					}
 					return echo.NewHTTPError(http.StatusBadRequest, structs.ErrorResponse{Error: err.Error()})
 				}
 
-				// Проверяем количество файлов
-				if files, ok := ctx.Request().MultipartForm.File["file"]; ok {
-					if len(files) > 10 {
-						return echo.NewHTTPError(http.StatusBadRequest, structs.ErrorResponse{Error: "only 10 files maximum allowed"})
-					}
-				} else {
-					return echo.NewHTTPError(http.StatusBadRequest, structs.ErrorResponse{Error: "at least 1 file required"})
+				// Проверяем количество, размеры и типы файлов
+				if v := uploadPolicy.CheckFiles(files); v != nil {
+					return violation(violationStatus(v), v)
 				}
 			}
 
@@ -63,3 +95,18 @@ func MultipartCountChecker(service services.JwtService) echo.MiddlewareFunc {
 		}
 	}
 }
+
+func violation(status int, v *policy.Violation) *echo.HTTPError {
+	return echo.NewHTTPError(status, structs.ErrorResponse{Code: status, Error: v.Message, Reason: v.Code})
+}
+
+func violationStatus(v *policy.Violation) int {
+	switch v.Code {
+	case policy.ViolationFileTooLarge, policy.ViolationTotalTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case policy.ViolationTypeNotAllowed:
+		return http.StatusUnsupportedMediaType
+	default:
+		return http.StatusBadRequest
+	}
+}
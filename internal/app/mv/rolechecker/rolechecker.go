@@ -0,0 +1,46 @@
+package rolechecker
+
+import (
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo-contrib/jaegertracing"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"sse-demo-core/internal/app/interfaces/services"
+	"sse-demo-core/internal/app/structs"
+	"sse-demo-core/internal/app/utils"
+)
+
+// RoleCheck пропускает только пользователей с одной из ролей roles, вызывается после headerchecker,
+// который кладет в контекст claims. Найденного пользователя кладет в контекст
+func RoleCheck(users services.UserService, roles []string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			span := jaegertracing.CreateChildSpan(ctx, "role checker middleware")
+			defer span.Finish()
+
+			logger := logdoc.GetLogger()
+			logger.Debug(">> Role check Middleware started...")
+
+			claims, ok := ctx.Get("claims").(jwt.MapClaims)
+			if !ok {
+				return echo.NewHTTPError(http.StatusUnauthorized, structs.ErrorResponse{Error: "authorization required"})
+			}
+			user, err := utils.GetUserFromClaims(claims, users)
+			if err != nil {
+				logger.Error("error getting user from token claims, ", err)
+				return echo.NewHTTPError(http.StatusUnauthorized, structs.ErrorResponse{Error: "user not found"})
+			}
+
+			for _, role := range roles {
+				if user.Role == role {
+					ctx.Set("user", user)
+					return next(ctx)
+				}
+			}
+
+			logger.Warn(">> userId:", user.ID, " with role '", user.Role, "' has no access to ", ctx.Request().RequestURI)
+			return echo.NewHTTPError(http.StatusForbidden, structs.ErrorResponse{Error: "access denied"})
+		}
+	}
+}
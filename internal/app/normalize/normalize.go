@@ -0,0 +1,49 @@
+package normalize
+
+import (
+	"golang.org/x/text/unicode/norm"
+	"regexp"
+	"strings"
+)
+
+// replacer невидимые символы удаляются, лигатуры раскладываются на буквы, особые пробелы заменяются обычным.
+// Табуляция не заменяется: в TSV и таблицах, извлеченных из документов, она разделяет колонки
+var replacer = strings.NewReplacer(
+	"\u00ad", "", // мягкий перенос
+	"\u200b", "", // пробел нулевой ширины
+	"\u200c", "",
+	"\u200d", "",
+	"\u2060", "",
+	"\ufeff", "",
+	"\ufb00", "ff",
+	"\ufb01", "fi",
+	"\ufb02", "fl",
+	"\ufb03", "ffi",
+	"\ufb04", "ffl",
+	"\ufb05", "st",
+	"\ufb06", "st",
+	"\u00a0", " ", // неразрывный пробел
+	"\u2007", " ",
+	"\u202f", " ",
+	"\r\n", "\n",
+	"\r", "\n",
+)
+
+var (
+	spaces     = regexp.MustCompile(` {2,}`)
+	emptyLines = regexp.MustCompile(`\n{3,}`)
+)
+
+// Text нормализует извлеченный текст: NFC, мягкие переносы и невидимые символы, лигатуры, пробелы
+// и пустые строки. Разделители страниц \f и табуляция сохраняются
+func Text(text string) string {
+	text = replacer.Replace(norm.NFC.String(text))
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(spaces.ReplaceAllString(line, " "), " ")
+	}
+
+	// табуляция по краям - пустые ячейки первой и последней строки таблицы
+	return strings.Trim(emptyLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"), " \n\f\v")
+}
@@ -0,0 +1,39 @@
+package normalize
+
+import "testing"
+
+func TestText(t *testing.T) {
+	cases := []struct {
+		name     string
+		text     string
+		expected string
+	}{
+		{"nfc", "e\u0301te\u0301", "\u00e9t\u00e9"},
+		{"soft hyphen and invisible", "пере\u00adнос\u200b сло\ufeffва", "перенос слова"},
+		{"ligatures", "\ufb01nal \ufb02ow o\ufb00er", "final flow offer"},
+		{"special spaces", "10\u00a0000\u202f₽", "10 000 ₽"},
+		{"spaces", "  one   two    three   \nfour  ", "one two three\nfour"},
+		{"line endings", "a\r\nb\rc", "a\nb\nc"},
+		{"empty lines", "a\n\n\n\n\nb\n \n \n \nc", "a\n\nb\n\nc"},
+		{"page separator", "page 1\n\fpage 2", "page 1\n\fpage 2"},
+		{"trim", "\n\n  text \n\n", "text"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := Text(c.text); got != c.expected {
+				t.Errorf("Text(%q) = %q, expected %q", c.text, got, c.expected)
+			}
+		})
+	}
+}
+
+// TestTextKeepsTabs колонки TSV, в том числе пустые, не должны склеиваться
+func TestTextKeepsTabs(t *testing.T) {
+	tsv := "\tname\tcity\n1\tИван  Петров\t\n2\t\tМосква\n3\t\t\t"
+	expected := "\tname\tcity\n1\tИван Петров\t\n2\t\tМосква\n3\t\t\t"
+
+	if got := Text(tsv); got != expected {
+		t.Errorf("Text(%q) = %q, expected %q", tsv, got, expected)
+	}
+}
@@ -0,0 +1,97 @@
+package policy
+
+import (
+	"fmt"
+	"github.com/gurkankaymak/hocon"
+	"sse-demo-core/internal/app/structs"
+)
+
+// Виды работ, перед запуском которых проверяется квота
+const (
+	JobUpload = "upload"
+	JobLLM    = "llm"
+)
+
+// Показатели квоты, уходят клиенту в QuotaExceededDetails.Reason
+const (
+	QuotaUploads  = "uploads"
+	QuotaBytes    = "bytes"
+	QuotaRequests = "requests"
+	QuotaTokens   = "tokens"
+)
+
+// Quotas месячные квоты ролей, разобранные из конфигурации при запуске сервиса
+type Quotas struct {
+	defaults structs.UsageQuota
+	roles    map[string]structs.UsageQuota
+}
+
+// NewQuotas собирает квоты: usage.quota.default, переопределенная для ролей значениями из usage.quota.roles.<role>
+func NewQuotas(config *hocon.Config) (*Quotas, error) {
+	defaults, err := quotaFromConfig(config, "usage.quota.default", structs.UsageQuota{})
+	if err != nil {
+		return nil, err
+	}
+
+	qs := &Quotas{defaults: defaults, roles: make(map[string]structs.UsageQuota)}
+	for role := range config.GetStringMap("usage.quota.roles") {
+		if qs.roles[role], err = quotaFromConfig(config, "usage.quota.roles."+role, defaults); err != nil {
+			return nil, err
+		}
+	}
+	return qs, nil
+}
+
+// ForRole месячная квота роли, для ролей без переопределений - квота по умолчанию
+func (qs *Quotas) ForRole(role string) structs.UsageQuota {
+	if q, ok := qs.roles[role]; ok {
+		return q
+	}
+	return qs.defaults
+}
+
+// CheckQuota проверяет, что работа job не превысит квоту с учетом расхода used за текущий месяц,
+// size - объем загружаемых файлов для JobUpload
+func CheckQuota(q structs.UsageQuota, used structs.UsageCounters, job string, size int64) *structs.QuotaExceededDetails {
+	switch job {
+	case JobUpload:
+		if q.Uploads > 0 && used.Uploads >= q.Uploads {
+			return exceeded(QuotaUploads, int64(q.Uploads), int64(used.Uploads))
+		}
+		if q.Bytes > 0 && used.Bytes+size > q.Bytes {
+			return exceeded(QuotaBytes, q.Bytes, used.Bytes)
+		}
+	case JobLLM:
+		if q.Requests > 0 && used.Requests >= q.Requests {
+			return exceeded(QuotaRequests, int64(q.Requests), int64(used.Requests))
+		}
+		if q.Tokens > 0 && used.Tokens >= q.Tokens {
+			return exceeded(QuotaTokens, int64(q.Tokens), int64(used.Tokens))
+		}
+	}
+	return nil
+}
+
+func exceeded(reason string, limit int64, used int64) *structs.QuotaExceededDetails {
+	return &structs.QuotaExceededDetails{Reason: reason, Limit: limit, Used: used,
+		Message: fmt.Sprintf("monthly %s quota of %d exceeded", reason, limit)}
+}
+
+func quotaFromConfig(config *hocon.Config, path string, q structs.UsageQuota) (structs.UsageQuota, error) {
+	var err error
+	if config.Get(path+".uploads") != nil {
+		q.Uploads = config.GetInt(path + ".uploads")
+	}
+	if config.Get(path+".bytes") != nil {
+		if q.Bytes, err = parseSize(config, path+".bytes"); err != nil {
+			return q, err
+		}
+	}
+	if config.Get(path+".requests") != nil {
+		q.Requests = config.GetInt(path + ".requests")
+	}
+	if config.Get(path+".tokens") != nil {
+		q.Tokens = config.GetInt(path + ".tokens")
+	}
+	return q, nil
+}
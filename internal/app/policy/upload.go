@@ -0,0 +1,178 @@
+package policy
+
+import (
+	"fmt"
+	"github.com/gurkankaymak/hocon"
+	"github.com/labstack/gommon/bytes"
+	"mime/multipart"
+	"path/filepath"
+	"strings"
+)
+
+// Коды нарушений политики загрузки, уходят клиенту в ErrorResponse.Reason
+const (
+	ViolationNoFiles        = "no_files"
+	ViolationTooManyFiles   = "too_many_files"
+	ViolationFileTooLarge   = "file_too_large"
+	ViolationTotalTooLarge  = "total_size_exceeded"
+	ViolationTypeNotAllowed = "type_not_allowed"
+)
+
+// MultipartOverhead запас на границы и заголовки частей multipart при проверке размера тела запроса
+const MultipartOverhead = 1 << 20
+
+// UploadPolicy ограничения загрузки файлов для роли пользователя
+type UploadPolicy struct {
+	MaxFiles     int
+	MaxFileSize  int64
+	MaxTotalSize int64
+	// AllowedTypes расширения файлов (pdf, docx) или MIME типы (text/csv), пустой список - без ограничений
+	AllowedTypes []string
+}
+
+// Violation нарушение политики загрузки с машиночитаемым кодом
+type Violation struct {
+	Code    string
+	Message string
+}
+
+func (v *Violation) Error() string {
+	return v.Message
+}
+
+// UploadPolicies политики загрузки ролей, разобранные из конфигурации при запуске сервиса
+type UploadPolicies struct {
+	defaults UploadPolicy
+	roles    map[string]UploadPolicy
+}
+
+// NewUploadPolicies собирает политики загрузки: upload.policy.default,
+// переопределенная для ролей значениями из upload.policy.roles.<role>.
+// Ошибка в конфигурации останавливает запуск, а не запросы на загрузку
+func NewUploadPolicies(config *hocon.Config) (*UploadPolicies, error) {
+	defaults, err := fromConfig(config, "upload.policy.default", UploadPolicy{})
+	if err != nil {
+		return nil, err
+	}
+
+	ps := &UploadPolicies{defaults: defaults, roles: make(map[string]UploadPolicy)}
+	for role := range config.GetStringMap("upload.policy.roles") {
+		if ps.roles[role], err = fromConfig(config, "upload.policy.roles."+role, defaults); err != nil {
+			return nil, err
+		}
+	}
+	return ps, nil
+}
+
+// ForRole политика загрузки роли, для ролей без переопределений - политика по умолчанию
+func (ps *UploadPolicies) ForRole(role string) UploadPolicy {
+	if p, ok := ps.roles[role]; ok {
+		return p
+	}
+	return ps.defaults
+}
+
+// MaxBodySize максимальный размер тела запроса на загрузку среди всех ролей,
+// используется глобальным ограничителем размера тела. 0 - у какой-то роли размер загрузки не ограничен
+func (ps *UploadPolicies) MaxBodySize() int64 {
+	limit := ps.defaults.MaxTotalSize
+	if limit == 0 {
+		return 0
+	}
+	for _, p := range ps.roles {
+		if p.MaxTotalSize == 0 {
+			return 0
+		}
+		if p.MaxTotalSize > limit {
+			limit = p.MaxTotalSize
+		}
+	}
+	return limit + MultipartOverhead
+}
+
+// CheckRequestSize проверка до разбора multipart по заголовку Content-Length
+func (p UploadPolicy) CheckRequestSize(contentLength int64) *Violation {
+	if p.MaxTotalSize > 0 && contentLength > p.MaxTotalSize+MultipartOverhead {
+		return &Violation{Code: ViolationTotalTooLarge, Message: fmt.Sprintf("total upload size exceeds %s", bytes.Format(p.MaxTotalSize))}
+	}
+	return nil
+}
+
+// CheckFiles проверка разобранных файлов: количество, размеры и типы
+func (p UploadPolicy) CheckFiles(files []*multipart.FileHeader) *Violation {
+	if len(files) == 0 {
+		return &Violation{Code: ViolationNoFiles, Message: "at least 1 file required"}
+	}
+	if p.MaxFiles > 0 && len(files) > p.MaxFiles {
+		return &Violation{Code: ViolationTooManyFiles, Message: fmt.Sprintf("only %d files maximum allowed", p.MaxFiles)}
+	}
+
+	var total int64
+	for _, file := range files {
+		if p.MaxFileSize > 0 && file.Size > p.MaxFileSize {
+			return &Violation{Code: ViolationFileTooLarge, Message: fmt.Sprintf("file %s exceeds %s", file.Filename, bytes.Format(p.MaxFileSize))}
+		}
+		if !p.Allowed(file) {
+			return &Violation{Code: ViolationTypeNotAllowed, Message: fmt.Sprintf("file type of %s is not allowed", file.Filename)}
+		}
+		total += file.Size
+	}
+
+	if p.MaxTotalSize > 0 && total > p.MaxTotalSize {
+		return &Violation{Code: ViolationTotalTooLarge, Message: fmt.Sprintf("total upload size exceeds %s", bytes.Format(p.MaxTotalSize))}
+	}
+
+	return nil
+}
+
+// Allowed проверяет тип файла по расширению имени или по заявленному Content-Type
+func (p UploadPolicy) Allowed(file *multipart.FileHeader) bool {
+	if len(p.AllowedTypes) == 0 {
+		return true
+	}
+
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(file.Filename)), ".")
+	contentType := strings.ToLower(file.Header.Get("Content-Type"))
+
+	for _, t := range p.AllowedTypes {
+		t = strings.ToLower(t)
+		if strings.Contains(t, "/") {
+			if contentType == t || (strings.HasSuffix(t, "/*") && strings.HasPrefix(contentType, strings.TrimSuffix(t, "*"))) {
+				return true
+			}
+		} else if ext == strings.TrimPrefix(t, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+func fromConfig(config *hocon.Config, path string, p UploadPolicy) (UploadPolicy, error) {
+	var err error
+	if config.Get(path+".max_files") != nil {
+		p.MaxFiles = config.GetInt(path + ".max_files")
+	}
+	if config.Get(path+".max_file_size") != nil {
+		if p.MaxFileSize, err = parseSize(config, path+".max_file_size"); err != nil {
+			return p, err
+		}
+	}
+	if config.Get(path+".max_total_size") != nil {
+		if p.MaxTotalSize, err = parseSize(config, path+".max_total_size"); err != nil {
+			return p, err
+		}
+	}
+	if config.Get(path+".allowed_types") != nil {
+		p.AllowedTypes = config.GetStringSlice(path + ".allowed_types")
+	}
+	return p, nil
+}
+
+// parseSize размер из конфигурации в формате 10MB
+func parseSize(config *hocon.Config, path string) (int64, error) {
+	size, err := bytes.Parse(config.GetString(path))
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse size %s = %q: %w", path, config.GetString(path), err)
+	}
+	return size, nil
+}
@@ -0,0 +1,143 @@
+package policy
+
+import (
+	"github.com/gurkankaymak/hocon"
+	"mime/multipart"
+	"net/textproto"
+	"testing"
+)
+
+func newPolicies(t *testing.T, conf string) *UploadPolicies {
+	t.Helper()
+
+	config, err := hocon.ParseString(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps, err := NewUploadPolicies(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ps
+}
+
+// file заголовок файла формы с размером size, содержимое не читается
+func file(name string, contentType string, size int64) *multipart.FileHeader {
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", contentType)
+	return &multipart.FileHeader{Filename: name, Header: header, Size: size}
+}
+
+const policiesConf = `
+upload.policy {
+  default { max_files = 2, max_file_size = "1KB", max_total_size = "1500B", allowed_types = ["pdf", "text/csv", "image/*"] }
+  roles {
+    admin { max_files = 5, max_total_size = "10KB" }
+    unlimited { max_total_size = 0 }
+  }
+}`
+
+func TestForRole(t *testing.T) {
+	ps := newPolicies(t, policiesConf)
+
+	admin := ps.ForRole("admin")
+	// переопределенные значения роли, остальные - из default
+	if admin.MaxFiles != 5 || admin.MaxTotalSize != 10*1000 || admin.MaxFileSize != 1000 || len(admin.AllowedTypes) != 3 {
+		t.Fatalf("unexpected admin policy %+v", admin)
+	}
+	if user := ps.ForRole("user"); user.MaxFiles != 2 || user.MaxTotalSize != 1500 {
+		t.Fatalf("role without overrides got %+v, want default", user)
+	}
+	if unlimited := ps.ForRole("unlimited"); unlimited.MaxTotalSize != 0 || unlimited.MaxFiles != 2 {
+		t.Fatalf("unexpected unlimited policy %+v", unlimited)
+	}
+}
+
+func TestNewUploadPoliciesInvalidSize(t *testing.T) {
+	config, err := hocon.ParseString(`upload.policy.default.max_file_size = "lots"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = NewUploadPolicies(config); err == nil {
+		t.Fatal("expected error for invalid max_file_size")
+	}
+}
+
+func TestMaxBodySize(t *testing.T) {
+	cases := []struct {
+		name string
+		conf string
+		want int64
+	}{
+		{"largest role", `upload.policy { default.max_total_size = "2KB", roles.admin.max_total_size = "10KB" }`, 10*1000 + MultipartOverhead},
+		{"default larger than roles", `upload.policy { default.max_total_size = "20KB", roles.guest.max_total_size = "1KB" }`, 20*1000 + MultipartOverhead},
+		{"unlimited default", `upload.policy { default.max_total_size = 0, roles.guest.max_total_size = "1KB" }`, 0},
+		{"unlimited role", `upload.policy { default.max_total_size = "2KB", roles.admin.max_total_size = 0 }`, 0},
+		{"not configured", `upload.policy.default.max_files = 1`, 0},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := newPolicies(t, c.conf).MaxBodySize(); got != c.want {
+				t.Fatalf("MaxBodySize() = %d, want %d", got, c.want)
+			}
+		})
+	}
+}
+
+func TestCheckFiles(t *testing.T) {
+	p := newPolicies(t, policiesConf).ForRole("user")
+
+	cases := []struct {
+		name  string
+		files []*multipart.FileHeader
+		want  string
+	}{
+		{"no files", nil, ViolationNoFiles},
+		{"too many files", []*multipart.FileHeader{file("a.pdf", "application/pdf", 1), file("b.pdf", "application/pdf", 1),
+			file("c.pdf", "application/pdf", 1)}, ViolationTooManyFiles},
+		{"file too large", []*multipart.FileHeader{file("a.pdf", "application/pdf", 2000)}, ViolationFileTooLarge},
+		{"total too large", []*multipart.FileHeader{file("a.pdf", "application/pdf", 800), file("b.pdf", "application/pdf", 800)},
+			ViolationTotalTooLarge},
+		{"type not allowed", []*multipart.FileHeader{file("a.exe", "application/octet-stream", 1)}, ViolationTypeNotAllowed},
+		{"allowed by extension", []*multipart.FileHeader{file("A.PDF", "application/octet-stream", 1)}, ""},
+		{"allowed by mime type", []*multipart.FileHeader{file("report", "text/csv", 1)}, ""},
+		{"allowed by mime wildcard", []*multipart.FileHeader{file("scan", "image/png", 1), file("photo", "image/jpeg", 1)}, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			v := p.CheckFiles(c.files)
+			if c.want == "" && v != nil {
+				t.Fatalf("unexpected violation %s: %s", v.Code, v.Message)
+			}
+			if c.want != "" && (v == nil || v.Code != c.want) {
+				t.Fatalf("got %+v, want %s", v, c.want)
+			}
+		})
+	}
+}
+
+func TestCheckFilesUnlimited(t *testing.T) {
+	p := newPolicies(t, `upload.policy.default { max_files = 0, max_file_size = 0, max_total_size = 0 }`).ForRole("user")
+
+	files := make([]*multipart.FileHeader, 0, 100)
+	for i := 0; i < 100; i++ {
+		files = append(files, file("big.bin", "application/octet-stream", 1<<30))
+	}
+	if v := p.CheckFiles(files); v != nil {
+		t.Fatalf("unexpected violation %s without limits", v.Code)
+	}
+	if v := p.CheckRequestSize(1 << 40); v != nil {
+		t.Fatalf("unexpected violation %s without limits", v.Code)
+	}
+}
+
+func TestCheckRequestSize(t *testing.T) {
+	p := newPolicies(t, policiesConf).ForRole("user")
+
+	if v := p.CheckRequestSize(1500 + MultipartOverhead); v != nil {
+		t.Fatalf("unexpected violation %s within limit", v.Code)
+	}
+	if v := p.CheckRequestSize(1500 + MultipartOverhead + 1); v == nil || v.Code != ViolationTotalTooLarge {
+		t.Fatalf("got %+v, want %s", v, ViolationTotalTooLarge)
+	}
+}
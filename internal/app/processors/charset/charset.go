@@ -0,0 +1,94 @@
+package charset
+
+import (
+	"bytes"
+	"fmt"
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"github.com/saintfish/chardet"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+	"unicode/utf8"
+)
+
+// Fallback кодировка, если определить кодировку не удалось,
+// большинство наших пользователей русскоязычные, поэтому windows-1251
+const Fallback = "windows-1251"
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// ToUTF8 определяет кодировку текста и перекодирует его в UTF-8.
+// declared кодировка, объявленная в самом документе (meta charset html, заголовок xml), может быть пустой.
+// Возвращает перекодированный текст и имя исходной кодировки
+func ToUTF8(data []byte, declared string) ([]byte, string, error) {
+	logger := logdoc.GetLogger()
+
+	switch {
+	case bytes.HasPrefix(data, bomUTF8):
+		return data[len(bomUTF8):], "UTF-8", nil
+	case bytes.HasPrefix(data, bomUTF16LE):
+		return decode(data, "UTF-16LE", unicode.UTF16(unicode.LittleEndian, unicode.UseBOM))
+	case bytes.HasPrefix(data, bomUTF16BE):
+		return decode(data, "UTF-16BE", unicode.UTF16(unicode.BigEndian, unicode.UseBOM))
+	case utf8.Valid(data):
+		return data, "UTF-8", nil
+	}
+
+	if declared != "" {
+		if enc, err := htmlindex.Get(declared); err == nil {
+			return decode(data, declared, enc)
+		}
+	}
+
+	name := detect(data)
+	if name == "" {
+		logger.Debug("charset not detected, using fallback ", Fallback)
+		name = Fallback
+	}
+
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		logger.Warn("unsupported charset ", name, ", using fallback ", Fallback)
+		name, enc = Fallback, charmap.Windows1251
+	}
+
+	return decode(data, name, enc)
+}
+
+// detect определяет кодировку по статистике текста. На коротких текстах кириллица
+// в однобайтовых кодировках часто определяется как латинская кодировка,
+// поэтому кириллическому кандидату отдается предпочтение при сопоставимой уверенности
+func detect(data []byte) string {
+	results, err := chardet.NewTextDetector().DetectAll(data)
+	if err != nil || len(results) == 0 {
+		return ""
+	}
+
+	best := results[0]
+	if best.Language != "ru" {
+		for _, r := range results[1:] {
+			if r.Language == "ru" && r.Confidence*2 >= best.Confidence {
+				best = r
+				break
+			}
+		}
+	}
+
+	if best.Charset == "UTF-8" {
+		return ""
+	}
+	return best.Charset
+}
+
+func decode(data []byte, name string, enc encoding.Encoding) ([]byte, string, error) {
+	decoded, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return nil, name, fmt.Errorf("error decoding %s text: %w", name, err)
+	}
+	return decoded, name, nil
+}
@@ -63,28 +63,40 @@ func readDocx(data []byte, size int64) (string, error) {
 
 func readXlsx(bytes []byte, size int) (string, error) {
 	// Create an instance of the reader by providing a data stream
-	xl, _ := xlsxreader.NewReader(bytes)
+	xl, err := xlsxreader.NewReader(bytes)
+	if err != nil {
+		return "", err
+	}
+	if len(xl.Sheets) == 0 {
+		return "", fmt.Errorf("xlsx document has no sheets")
+	}
+
+	// Читаем первые size строк каждого листа, листы разделяем form feed, как страницы pdf
+	sheets := make([]string, 0, len(xl.Sheets))
+	for _, sheet := range xl.Sheets {
+		records := make([][]string, 0, size)
+		i := 0
+		for row := range xl.ReadRows(sheet) {
+			i++
+			cells := make([]string, 0, len(row.Cells))
+			for _, cell := range row.Cells {
+				cells = append(cells, cell.Value)
+			}
+			records = append(records, cells)
 
-	// Читаем первые 10 строк из файла
-	records := make([][]string, 0, size)
-	i := 0
-	for row := range xl.ReadRows(xl.Sheets[0]) {
-		i++
-		cells := make([]string, 0, len(row.Cells))
-		for _, cell := range row.Cells {
-			cells = append(cells, cell.Value)
+			if i >= size {
+				break
+			}
 		}
-		records = append(records, cells)
 
-		if i >= size {
-			break
+		// Выводим первые size строк листа под заголовком с именем листа
+		text := "# " + sheet + "\n"
+		for _, record := range records {
+			text += strings.Join(record, ";") + "\n" // добавляем разделитель между полями и перенос строки между строками
 		}
-	}
-	// Выводим первые 10 строк файла
-	var text string
-	for _, record := range records {
-		text += strings.Join(record, ";") + "\n" // добавляем разделитель между полями и перенос строки между строками
+		sheets = append(sheets, text)
 	}
 
+	text := strings.Join(sheets, "\f")
 	return text, nil
 }
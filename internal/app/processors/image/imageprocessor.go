@@ -0,0 +1,33 @@
+package imageprocessor
+
+import (
+	"context"
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"io"
+	"mime/multipart"
+	"sse-demo-core/internal/app/processors/ocr"
+)
+
+// ProcessImage распознает текст изображения (png, jpeg, tiff), возвращает текст и уверенность распознавания
+func ProcessImage(ctx context.Context, file *multipart.FileHeader, recognizer ocr.Recognizer) (string, float64, error) {
+	logger := logdoc.GetLogger()
+	logger.Debug("Processing image file ", file.Filename)
+
+	src, err := file.Open()
+	if err != nil {
+		return "", 0, err
+	}
+	defer src.Close()
+
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return "", 0, err
+	}
+
+	r, err := recognizer.Recognize(ctx, data)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return r.Text, r.Confidence, nil
+}
@@ -0,0 +1,135 @@
+package markupprocessor
+
+import (
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"mime/multipart"
+	"regexp"
+	"strings"
+)
+
+// skipped элементы, которые не несут читаемого содержимого страницы
+var skipped = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Template: true,
+	atom.Iframe:   true,
+	atom.Svg:      true,
+	atom.Canvas:   true,
+	atom.Form:     true,
+	atom.Button:   true,
+	atom.Select:   true,
+	atom.Nav:      true,
+	atom.Header:   true,
+	atom.Footer:   true,
+	atom.Aside:    true,
+	atom.Head:     true,
+}
+
+// blocks элементы, после которых начинается новая строка текста
+var blocks = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Br: true, atom.Li: true, atom.Tr: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Section: true, atom.Article: true, atom.Main: true, atom.Blockquote: true, atom.Pre: true,
+	atom.Table: true, atom.Ul: true, atom.Ol: true, atom.Dl: true, atom.Dt: true, atom.Dd: true,
+	atom.Title: true, atom.Hr: true,
+}
+
+var headings = map[atom.Atom]int{
+	atom.H1: 1, atom.H2: 2, atom.H3: 3, atom.H4: 4, atom.H5: 5, atom.H6: 6,
+}
+
+var (
+	metaCharset = regexp.MustCompile(`(?i)<meta[^>]+charset=["']?([\w-]+)`)
+	spaces      = regexp.MustCompile(`[ \t\p{Zs}]+`)
+	emptyLines  = regexp.MustCompile(`\n{3,}`)
+)
+
+// ProcessHTML извлекает читаемый текст html страницы без скриптов, стилей, навигации и прочей обвязки.
+// Если на странице есть main или article, текст берется только из них
+func ProcessHTML(file *multipart.FileHeader, opts Options) (string, error) {
+	logger := logdoc.GetLogger()
+	logger.Debug("Processing html file ", file.Filename)
+
+	text, err := readText(file, opts, metaCharset)
+	if err != nil {
+		return "", err
+	}
+
+	doc, err := html.Parse(strings.NewReader(text))
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+
+	if title := find(doc, atom.Title); title != nil {
+		extract(&sb, title)
+		sb.WriteString("\n")
+	}
+
+	root := find(doc, atom.Main)
+	if root == nil {
+		root = find(doc, atom.Article)
+	}
+	if root == nil {
+		root = find(doc, atom.Body)
+	}
+	if root == nil {
+		root = doc
+	}
+	extract(&sb, root)
+
+	return cleanup(sb.String()), nil
+}
+
+func find(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := find(c, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func extract(sb *strings.Builder, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		sb.WriteString(n.Data)
+		return
+	case html.ElementNode:
+		if skipped[n.DataAtom] {
+			return
+		}
+		if n.DataAtom == atom.Td || n.DataAtom == atom.Th {
+			sb.WriteString(" ")
+		}
+		// заголовки оставляем в разметке markdown, по ним содержимое делится на разделы
+		if level, ok := headings[n.DataAtom]; ok {
+			sb.WriteString("\n" + strings.Repeat("#", level) + " ")
+		}
+	case html.CommentNode:
+		return
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		extract(sb, c)
+	}
+
+	if n.Type == html.ElementNode && blocks[n.DataAtom] {
+		sb.WriteString("\n")
+	}
+}
+
+func cleanup(text string) string {
+	lines := strings.Split(normalizeNewlines(text), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(spaces.ReplaceAllString(line, " "))
+	}
+	return strings.TrimSpace(emptyLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
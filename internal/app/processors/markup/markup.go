@@ -0,0 +1,87 @@
+package markupprocessor
+
+import (
+	"errors"
+	"fmt"
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"github.com/labstack/gommon/bytes"
+	"io"
+	"mime/multipart"
+	"regexp"
+	"sse-demo-core/internal/app/processors/charset"
+	"strings"
+)
+
+// ErrTooLarge файл превышает ограничение размера текстовой обработки
+var ErrTooLarge = errors.New("file is too large for text processing")
+
+// Options ограничения обработки текстовых файлов
+type Options struct {
+	// MaxSize максимальный размер файла в байтах, 0 - без ограничения
+	MaxSize int64
+	// MaxKeys максимальное количество путей ключей json и xml, 0 - без ограничения
+	MaxKeys int
+}
+
+// ProcessText извлекает содержимое txt и md файлов, markdown оставляем как есть
+func ProcessText(file *multipart.FileHeader, opts Options) (string, error) {
+	logger := logdoc.GetLogger()
+	logger.Debug("Processing text file ", file.Filename)
+
+	text, err := readText(file, opts, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(normalizeNewlines(text)), nil
+}
+
+// readText читает файл с учетом ограничения размера и перекодирует его в UTF-8,
+// declared по необходимости находит кодировку, объявленную в самом документе
+func readText(file *multipart.FileHeader, opts Options, declared *regexp.Regexp) (string, error) {
+	logger := logdoc.GetLogger()
+
+	if opts.MaxSize > 0 && file.Size > opts.MaxSize {
+		return "", fmt.Errorf("%w: %s exceeds %s", ErrTooLarge, file.Filename, bytes.Format(opts.MaxSize))
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	var r io.Reader = src
+	if opts.MaxSize > 0 {
+		r = io.LimitReader(src, opts.MaxSize+1)
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	if opts.MaxSize > 0 && int64(len(data)) > opts.MaxSize {
+		return "", fmt.Errorf("%w: %s exceeds %s", ErrTooLarge, file.Filename, bytes.Format(opts.MaxSize))
+	}
+
+	var hint string
+	if declared != nil {
+		if m := declared.FindSubmatch(data); m != nil {
+			hint = string(m[1])
+		}
+	}
+
+	decoded, name, err := charset.ToUTF8(data, hint)
+	if err != nil {
+		return "", err
+	}
+	if name != "UTF-8" {
+		logger.Debug("file ", file.Filename, " converted from ", name, " to UTF-8")
+	}
+
+	return string(decoded), nil
+}
+
+func normalizeNewlines(text string) string {
+	return strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(text)
+}
@@ -0,0 +1,171 @@
+package markupprocessor
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"io"
+	"mime/multipart"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var xmlEncoding = regexp.MustCompile(`^\s*<\?xml[^>]+encoding=["']([\w-]+)["']`)
+
+// flattener собирает строки вида "путь.к[0].ключу: значение" с ограничением количества
+type flattener struct {
+	sb        strings.Builder
+	max       int
+	count     int
+	truncated bool
+}
+
+func (f *flattener) add(path string, value string) {
+	if f.max > 0 && f.count >= f.max {
+		f.truncated = true
+		return
+	}
+	f.count++
+	f.sb.WriteString(path)
+	f.sb.WriteString(": ")
+	f.sb.WriteString(value)
+	f.sb.WriteString("\n")
+}
+
+func (f *flattener) String() string {
+	return strings.TrimSpace(f.sb.String())
+}
+
+// ProcessJSON разворачивает json документ в список путей ключей со значениями
+func ProcessJSON(file *multipart.FileHeader, opts Options) (string, error) {
+	logger := logdoc.GetLogger()
+	logger.Debug("Processing json file ", file.Filename)
+
+	text, err := readText(file, opts, nil)
+	if err != nil {
+		return "", err
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.UseNumber()
+
+	f := &flattener{max: opts.MaxKeys}
+
+	// в файле может быть несколько документов подряд (json lines)
+	for i := 0; ; i++ {
+		var doc any
+		err = decoder.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+
+		root := ""
+		if decoder.More() || i > 0 {
+			root = fmt.Sprintf("[%d]", i)
+		}
+		flattenJSON(f, root, doc)
+	}
+
+	if f.truncated {
+		logger.Warn("json file ", file.Filename, " truncated to ", opts.MaxKeys, " keys")
+	}
+
+	return f.String(), nil
+}
+
+func flattenJSON(f *flattener, path string, value any) {
+	switch v := value.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			flattenJSON(f, joinPath(path, k), v[k])
+		}
+	case []any:
+		for i, item := range v {
+			flattenJSON(f, fmt.Sprintf("%s[%d]", path, i), item)
+		}
+	case nil:
+		f.add(valuePath(path), "null")
+	default:
+		f.add(valuePath(path), fmt.Sprint(v))
+	}
+}
+
+// ProcessXML разворачивает xml документ в список путей элементов со значениями,
+// атрибуты добавляются как путь.@атрибут
+func ProcessXML(file *multipart.FileHeader, opts Options) (string, error) {
+	logger := logdoc.GetLogger()
+	logger.Debug("Processing xml file ", file.Filename)
+
+	text, err := readText(file, opts, xmlEncoding)
+	if err != nil {
+		return "", err
+	}
+
+	decoder := xml.NewDecoder(strings.NewReader(text))
+	decoder.Strict = false
+	// текст уже перекодирован в UTF-8, объявленную в заголовке кодировку игнорируем
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	f := &flattener{max: opts.MaxKeys}
+
+	var path []string
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			path = append(path, t.Name.Local)
+			for _, attr := range t.Attr {
+				f.add(strings.Join(path, ".")+".@"+attr.Name.Local, attr.Value)
+			}
+		case xml.EndElement:
+			if len(path) > 0 {
+				path = path[:len(path)-1]
+			}
+		case xml.CharData:
+			if value := strings.TrimSpace(string(t)); value != "" && len(path) > 0 {
+				f.add(strings.Join(path, "."), value)
+			}
+		}
+	}
+
+	if f.truncated {
+		logger.Warn("xml file ", file.Filename, " truncated to ", opts.MaxKeys, " keys")
+	}
+
+	return f.String(), nil
+}
+
+// valuePath путь для документа, который целиком является одним значением
+func valuePath(path string) string {
+	if path == "" {
+		return "value"
+	}
+	return path
+}
+
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,50 @@
+package ocr
+
+import (
+	"context"
+	"errors"
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"github.com/gurkankaymak/hocon"
+	"os/exec"
+)
+
+// ErrUnavailable возвращается, если распознавание текста не настроено в системе
+var ErrUnavailable = errors.New("ocr is not available")
+
+// Recognition результат распознавания текста изображения
+type Recognition struct {
+	Text string
+	// Confidence средняя уверенность распознавания слов, 0..100
+	Confidence float64
+}
+
+// Recognizer интерфейс распознавания текста на изображениях
+type Recognizer interface {
+	Recognize(ctx context.Context, image []byte) (*Recognition, error)
+}
+
+// New возвращает распознавание через локальный tesseract, если он установлен,
+// иначе распознавание, которое всегда отвечает ErrUnavailable
+func New(config *hocon.Config) Recognizer {
+	logger := logdoc.GetLogger()
+
+	bin := config.GetString("ocr.tesseract")
+	if bin == "" {
+		bin = "tesseract"
+	}
+
+	path, err := exec.LookPath(bin)
+	if err != nil {
+		logger.Warn(">> tesseract not found, images ocr disabled, ", err)
+		return Unavailable{}
+	}
+
+	return NewTesseract(path, config.GetString("ocr.languages"))
+}
+
+// Unavailable распознавание для систем без OCR
+type Unavailable struct{}
+
+func (Unavailable) Recognize(context.Context, []byte) (*Recognition, error) {
+	return nil, ErrUnavailable
+}
@@ -0,0 +1,83 @@
+package ocr
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Tesseract распознавание локальным бинарником tesseract
+type Tesseract struct {
+	path      string
+	languages string
+}
+
+func NewTesseract(path string, languages string) *Tesseract {
+	if languages == "" {
+		languages = "rus+eng"
+	}
+	return &Tesseract{path: path, languages: languages}
+}
+
+// Recognize передает изображение в tesseract через stdin и разбирает вывод в формате tsv,
+// из которого собирается текст по строкам и средняя уверенность распознавания слов
+func (t *Tesseract) Recognize(ctx context.Context, image []byte) (*Recognition, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, t.path, "stdin", "stdout", "-l", t.languages, "tsv") //nolint:gosec
+	cmd.Stdin = bytes.NewReader(image)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("tesseract error: %w, %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return parseTSV(stdout.String()), nil
+}
+
+// parseTSV колонки: level page_num block_num par_num line_num word_num left top width height conf text
+func parseTSV(tsv string) *Recognition {
+	var text strings.Builder
+	var confSum float64
+	var words int
+	var lastLine, lastPar string
+
+	for i, row := range strings.Split(tsv, "\n") {
+		cols := strings.Split(row, "\t")
+		if i == 0 || len(cols) < 12 {
+			continue
+		}
+
+		conf, err := strconv.ParseFloat(cols[10], 64)
+		word := strings.TrimSpace(cols[11])
+		if err != nil || conf < 0 || word == "" {
+			continue
+		}
+
+		par := cols[1] + "." + cols[2] + "." + cols[3]
+		line := par + "." + cols[4]
+		switch {
+		case lastPar != "" && par != lastPar:
+			text.WriteString("\n\n")
+		case lastLine != "" && line != lastLine:
+			text.WriteString("\n")
+		case lastLine != "":
+			text.WriteString(" ")
+		}
+		text.WriteString(word)
+		lastPar, lastLine = par, line
+
+		confSum += conf
+		words++
+	}
+
+	r := &Recognition{Text: text.String()}
+	if words > 0 {
+		r.Confidence = confSum / float64(words)
+	}
+	return r
+}
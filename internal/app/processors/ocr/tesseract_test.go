@@ -0,0 +1,104 @@
+package ocr
+
+import (
+	"context"
+	"errors"
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"github.com/gurkankaymak/hocon"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	_, _ = logdoc.Init("udp", "127.0.0.1:9", "ocr-test")
+	os.Exit(m.Run())
+}
+
+const tsvHeader = "level\tpage_num\tblock_num\tpar_num\tline_num\tword_num\tleft\ttop\twidth\theight\tconf\ttext\n"
+
+func tsvRow(block, par, line, word string, conf string, text string) string {
+	return strings.Join([]string{"5", "1", block, par, line, word, "0", "0", "10", "10", conf, text}, "\t") + "\n"
+}
+
+func TestParseTSV(t *testing.T) {
+	tsv := tsvHeader +
+		// строки структуры страницы без слов имеют conf -1
+		strings.Join([]string{"1", "1", "0", "0", "0", "0", "0", "0", "100", "100", "-1", ""}, "\t") + "\n" +
+		tsvRow("1", "1", "1", "1", "90", "Счет") +
+		tsvRow("1", "1", "1", "2", "80", "№15") +
+		tsvRow("1", "1", "2", "1", "70", "Итого") +
+		tsvRow("1", "1", "2", "2", "-1", "") +
+		tsvRow("1", "2", "1", "1", "60", "Подпись") +
+		tsvRow("2", "1", "1", "1", "100", "  ") +
+		"broken row\n"
+
+	r := parseTSV(tsv)
+
+	if want := "Счет №15\nИтого\n\nПодпись"; r.Text != want {
+		t.Fatalf("text %q, want %q", r.Text, want)
+	}
+	if want := (90.0 + 80 + 70 + 60) / 4; math.Abs(r.Confidence-want) > 1e-9 {
+		t.Fatalf("confidence %v, want %v", r.Confidence, want)
+	}
+}
+
+func TestParseTSVEmpty(t *testing.T) {
+	r := parseTSV(tsvHeader)
+	if r.Text != "" || r.Confidence != 0 {
+		t.Fatalf("unexpected recognition of empty output: %+v", r)
+	}
+}
+
+// fakeTesseract скрипт вместо tesseract: проверяет аргументы и печатает заданный tsv
+func fakeTesseract(t *testing.T, script string) string {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script as tesseract binary requires unix")
+	}
+	path := filepath.Join(t.TempDir(), "tesseract")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0o700); err != nil { //nolint:gosec
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestTesseractRecognize(t *testing.T) {
+	bin := fakeTesseract(t, `[ "$1 $2 $3 $4 $5" = "stdin stdout -l deu tsv" ] || { echo "bad args: $*" >&2; exit 1; }
+cat > /dev/null
+printf 'level\tpage_num\tblock_num\tpar_num\tline_num\tword_num\tleft\ttop\twidth\theight\tconf\ttext\n'
+printf '5\t1\t1\t1\t1\t1\t0\t0\t10\t10\t95\tHallo\n'
+printf '5\t1\t1\t1\t1\t2\t0\t0\t10\t10\t85\tWelt\n'
+`)
+
+	r, err := NewTesseract(bin, "deu").Recognize(context.Background(), []byte("image"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Text != "Hallo Welt" || r.Confidence != 90 {
+		t.Fatalf("unexpected recognition: %+v", r)
+	}
+}
+
+func TestTesseractError(t *testing.T) {
+	bin := fakeTesseract(t, "echo 'Error in pixReadMem' >&2\nexit 1\n")
+
+	_, err := NewTesseract(bin, "").Recognize(context.Background(), []byte("image"))
+	if err == nil || !strings.Contains(err.Error(), "Error in pixReadMem") {
+		t.Fatalf("expected tesseract error with stderr, got %v", err)
+	}
+}
+
+func TestNewWithoutTesseract(t *testing.T) {
+	config, err := hocon.ParseString(`ocr { tesseract = "/nonexistent/tesseract" }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = New(config).Recognize(context.Background(), []byte("image"))
+	if !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("got %v, want ErrUnavailable", err)
+	}
+}
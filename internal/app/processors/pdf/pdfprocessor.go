@@ -1,41 +1,63 @@
 package pdfprocessor
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
 	"github.com/rudolfoborges/pdf2go"
 	"io"
 	"mime/multipart"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"sse-demo-core/internal/app/processors/ocr"
+	"strconv"
+	"strings"
 )
 
-func ProcessPdfFile(file *multipart.FileHeader) (string, error) {
+// OCROptions распознавание страниц pdf без текстового слоя (сканов)
+type OCROptions struct {
+	Recognizer ocr.Recognizer
+	// Pdftoppm путь к pdftoppm из poppler, которым страница рендерится в изображение
+	Pdftoppm string
+	DPI      int
+}
+
+// ProcessPdfFile извлекает текст pdf постранично, страницы без текста распознаются через OCR.
+// Возвращает текст и среднюю уверенность распознавания, 0 если OCR не применялся
+func ProcessPdfFile(ctx context.Context, file *multipart.FileHeader, opts OCROptions) (string, float64, error) {
 	logger := logdoc.GetLogger()
 	logger.Debug("Processing pdf file ", file.Filename)
 
 	// Открываем файл
 	src, err := file.Open()
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
 	defer src.Close()
 
 	// Создать временный файл
-	tempFile, err := os.Create(os.TempDir() + file.Filename) //nolint:gosec
+	tempFile, err := os.CreateTemp("", "upload-*.pdf")
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
 	defer os.Remove(tempFile.Name())
 
 	// Записать содержимое загруженного файла во временный файл
 	_, err = io.Copy(tempFile, src)
 	if err != nil {
-		return "", err
+		tempFile.Close()
+		return "", 0, err
+	}
+	if err = tempFile.Close(); err != nil {
+		return "", 0, err
 	}
 
 	pdfPath, err := filepath.Abs(tempFile.Name())
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
 
 	pdf, err := pdf2go.New(pdfPath, pdf2go.Config{
@@ -43,26 +65,79 @@ func ProcessPdfFile(file *multipart.FileHeader) (string, error) {
 	})
 
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
 
 	text, err := pdf.Text()
 	if err != nil {
-		return "", err
+		return "", 0, err
+	}
+
+	// pdftotext разделяет страницы символом form feed
+	pages := strings.Split(text, "\f")
+	if len(pages) > pdf.PagesNumber() {
+		pages = pages[:pdf.PagesNumber()]
+	}
+
+	var confSum float64
+	var recognized int
+
+	for i, page := range pages {
+		if strings.TrimSpace(page) != "" || opts.Recognizer == nil {
+			continue
+		}
+
+		// на странице нет текстового слоя, рендерим в изображение и распознаем
+		r, err := recognizePage(ctx, pdfPath, i+1, opts)
+		if errors.Is(err, ocr.ErrUnavailable) {
+			logger.Warn("pdf page ", i+1, " of ", file.Filename, " has no text and ocr is not available")
+			break
+		}
+		if err != nil {
+			logger.Error("error recognizing pdf page ", i+1, " of ", file.Filename, ", ", err)
+			continue
+		}
+
+		pages[i] = r.Text
+		confSum += r.Confidence
+		recognized++
+	}
+
+	var confidence float64
+	if recognized > 0 {
+		confidence = confSum / float64(recognized)
+	}
+
+	// страницы оставляем разделенными form feed, по нему содержимое делится на страницы при нарезке на фрагменты
+	return strings.Join(pages, "\f"), confidence, nil
+}
+
+func recognizePage(ctx context.Context, pdfPath string, page int, opts OCROptions) (*ocr.Recognition, error) {
+	bin := opts.Pdftoppm
+	if bin == "" {
+		bin = "pdftoppm"
+	}
+	dpi := opts.DPI
+	if dpi <= 0 {
+		dpi = 300
+	}
+
+	var stdout, stderr bytes.Buffer
+
+	// без имени выходного файла pdftoppm пишет изображение страницы в stdout
+	cmd := exec.CommandContext(ctx, bin, //nolint:gosec
+		"-f", strconv.Itoa(page),
+		"-l", strconv.Itoa(page),
+		"-r", strconv.Itoa(dpi),
+		"-png",
+		"-singlefile",
+		pdfPath)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("pdftoppm error: %w, %s", err, strings.TrimSpace(stderr.String()))
 	}
 
-	return text, nil
-
-	// Если у нас бесплатный план, то выгружаем ограниченное количество страниц
-	// pages, err := pdf.Pages()
-	//
-	// if err != nil {
-	//	panic(err)
-	//}
-	//
-	// for _, page := range pages {
-	//	fmt.Println(page.Text())
-	//}
-	//
-	// return "", nil
+	return opts.Recognizer.Recognize(ctx, stdout.Bytes())
 }
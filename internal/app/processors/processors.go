@@ -0,0 +1,290 @@
+package processors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"github.com/gurkankaymak/hocon"
+	"github.com/labstack/gommon/bytes"
+	"mime/multipart"
+	"path/filepath"
+	"runtime/debug"
+	fileutils "sse-demo-core/internal/app/endpoint/files/utils"
+	docxxlsxprocessor "sse-demo-core/internal/app/processors/docs"
+	imageprocessor "sse-demo-core/internal/app/processors/image"
+	markupprocessor "sse-demo-core/internal/app/processors/markup"
+	"sse-demo-core/internal/app/processors/ocr"
+	pdfprocessor "sse-demo-core/internal/app/processors/pdf"
+	csvprocessor "sse-demo-core/internal/app/processors/text"
+	"strings"
+	"time"
+)
+
+// Коды причин ошибок обработки файла, уходят на фронтенд в событии file_processing_error
+const (
+	ReasonUnsupportedContent = "unsupported_content"
+	ReasonDetectionFailed    = "detection_failed"
+	ReasonProcessingFailed   = "processing_failed"
+	ReasonEmptyContent       = "empty_content"
+	ReasonTimeout            = "timeout"
+	ReasonPanic              = "panic"
+	ReasonCancelled          = "cancelled"
+	ReasonOCRUnavailable     = "ocr_unavailable"
+	ReasonTooLarge           = "content_too_large"
+	ReasonTokenBudget        = "token_budget_exceeded"
+)
+
+// ProcessingError ошибка обработки файла с машиночитаемым кодом причины
+type ProcessingError struct {
+	Reason string
+	Err    error
+}
+
+func (e *ProcessingError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Reason, e.Err)
+}
+
+func (e *ProcessingError) Unwrap() error {
+	return e.Err
+}
+
+// Reason возвращает код причины ошибки обработки
+func Reason(err error) string {
+	var pe *ProcessingError
+	if errors.As(err, &pe) {
+		return pe.Reason
+	}
+	return ReasonProcessingFailed
+}
+
+// Result извлеченное содержимое файла
+type Result struct {
+	Content string `json:"content"`
+	// Confidence средняя уверенность распознавания, если текст получен через OCR
+	Confidence float64 `json:"confidence,omitempty"`
+	OCR        bool    `json:"ocr,omitempty"`
+}
+
+// Processor извлекает содержимое загруженных файлов
+type Processor struct {
+	timeout    time.Duration
+	recognizer ocr.Recognizer
+	pdfOCR     pdfprocessor.OCROptions
+	markup     markupprocessor.Options
+	// options настройки процессоров, влияющие на извлеченное содержимое, входят в ключ кеша содержимого
+	options string
+	// extract извлечение содержимого в горутине Process, в тестах подменяется зависающим или падающим
+	extract func(ctx context.Context, file *multipart.FileHeader) (*Result, error)
+}
+
+func New(config *hocon.Config) (*Processor, error) {
+	return NewWithRecognizer(config, ocr.New(config))
+}
+
+// NewWithRecognizer создает Processor с заданным распознаванием текста изображений и сканов pdf
+func NewWithRecognizer(config *hocon.Config, recognizer ocr.Recognizer) (*Processor, error) {
+	maxSize, err := textMaxSize(config)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Processor{
+		timeout:    time.Duration(config.GetInt("upload.file_timeout")) * time.Second,
+		recognizer: recognizer,
+		pdfOCR: pdfprocessor.OCROptions{
+			Recognizer: recognizer,
+			Pdftoppm:   config.GetString("ocr.pdftoppm"),
+			DPI:        config.GetInt("ocr.dpi"),
+		},
+		markup: markupprocessor.Options{
+			MaxSize: maxSize,
+			MaxKeys: config.GetInt("upload.text.max_keys"),
+		},
+	}
+	p.options = fmt.Sprintf("%d-%d-%d-%s", p.markup.MaxSize, p.markup.MaxKeys, p.pdfOCR.DPI, config.GetString("ocr.languages"))
+	p.extract = p.process
+	return p, nil
+}
+
+func textMaxSize(config *hocon.Config) (int64, error) {
+	if config.GetString("upload.text.max_size") == "" {
+		return 0, nil
+	}
+	size, err := bytes.Parse(config.GetString("upload.text.max_size"))
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse upload.text.max_size = %q: %w", config.GetString("upload.text.max_size"), err)
+	}
+	return size, nil
+}
+
+type result struct {
+	res *Result
+	err error
+}
+
+// Process извлекает содержимое файла подходящим процессором.
+// Обработка выполняется в отдельной горутине со своим таймаутом и recover,
+// поэтому зависший или упавший процессор не затрагивает остальные файлы загрузки.
+// По таймауту Process сразу возвращает ошибку и освобождает обработчик пула: процессоры docx/xlsx, разметки и
+// текстового слоя pdf не прерываются по контексту, их результат после таймаута отбрасывается. Процессоры OCR,
+// pdftoppm и csv прерываются по контексту.
+func (p *Processor) Process(ctx context.Context, file *multipart.FileHeader) (*Result, error) {
+	logger := logdoc.GetLogger()
+
+	c, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	resCh := make(chan result, 1)
+
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				logger.Error(">> File Processing panic, file ", file.Filename, ", ", err, "\n", string(debug.Stack()))
+				resCh <- result{err: &ProcessingError{Reason: ReasonPanic, Err: fmt.Errorf("%v", err)}}
+			}
+		}()
+
+		res, err := p.extract(c, file)
+		resCh <- result{res: res, err: err}
+	}()
+
+	select {
+	case r := <-resCh:
+		return r.res, r.err
+	case <-c.Done():
+	}
+
+	err := &ProcessingError{Reason: ReasonTimeout, Err: fmt.Errorf("file processing exceeded %s", p.timeout)}
+	if ctx.Err() != nil {
+		err = &ProcessingError{Reason: ReasonCancelled, Err: ctx.Err()}
+	}
+
+	// resCh с буфером: горутина зависшего процессора запишет результат и завершится без получателя
+	logger.Warn(">> file ", file.Filename, " processing stopped, ", err, ", processor result will be discarded")
+	return nil, err
+}
+
+// Виды обработки файла, от вида зависит извлеченное содержимое: например, csv с типом text/csv
+// разбирается целиком, а определенный по содержимому - только заголовок
+const (
+	kindDocument  = "document"
+	kindPDF       = "pdf"
+	kindImage     = "image"
+	kindCSV       = "csv"
+	kindCSVHeader = "csv_header"
+	kindHTML      = "html"
+	kindJSON      = "json"
+	kindXML       = "xml"
+	kindText      = "text"
+)
+
+// csvHeaderRows строк csv, определенного по содержимому: заголовок и первые строки данных
+const csvHeaderRows = 10
+
+// kind вид обработки файла и тип содержимого, пустой вид - тип не поддерживается
+func (p *Processor) kind(file *multipart.FileHeader) (string, string, error) {
+	fileType, err := fileutils.DetectFileType(file)
+	if err != nil {
+		return "", "", err
+	}
+
+	switch {
+	case fileType == "application/zip" && (file.Header.Get("Content-Type") == "application/vnd.openxmlformats-officedocument.wordprocessingml.document" ||
+		file.Header.Get("Content-Type") == "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"):
+		return kindDocument, fileType, nil
+	case fileType == "application/pdf":
+		return kindPDF, fileType, nil
+	case fileType == "image/png" || fileType == "image/jpeg" || fileType == "image/tiff":
+		return kindImage, fileType, nil
+	case file.Header.Get("Content-Type") == "text/csv":
+		return kindCSV, fileType, nil
+	case fileType == "text/csv":
+		return kindCSVHeader, fileType, nil
+	case strings.HasPrefix(fileType, "text/"):
+		return markupKind(file, fileType), fileType, nil
+	}
+	return "", fileType, nil
+}
+
+// CacheKey ключ кеша извлеченного содержимого файла с SHA-256 hash: кроме содержимого файла
+// результат зависит от вида обработки и настроек процессоров
+func (p *Processor) CacheKey(file *multipart.FileHeader, hash string) (string, error) {
+	kind, _, err := p.kind(file)
+	if err != nil {
+		return "", err
+	}
+	return "content:" + hash + ":" + kind + ":" + p.options, nil
+}
+
+func (p *Processor) process(ctx context.Context, file *multipart.FileHeader) (*Result, error) {
+	// Определяем тип файла
+	kind, fileType, err := p.kind(file)
+	if err != nil {
+		return nil, &ProcessingError{Reason: ReasonDetectionFailed, Err: err}
+	}
+
+	var content string
+	var confidence float64
+	var recognized bool
+
+	// pre-processing file
+	switch kind {
+	case kindDocument:
+		content, err = docxxlsxprocessor.ProcessDocument(file)
+	case kindPDF:
+		// страницы без текстового слоя распознаются через OCR
+		content, confidence, err = pdfprocessor.ProcessPdfFile(ctx, file, p.pdfOCR)
+		recognized = confidence > 0
+	case kindImage:
+		content, confidence, err = imageprocessor.ProcessImage(ctx, file, p.recognizer)
+		recognized = true
+		if errors.Is(err, ocr.ErrUnavailable) {
+			return nil, &ProcessingError{Reason: ReasonOCRUnavailable, Err: err}
+		}
+	case kindCSV:
+		content, err = csvprocessor.ProcessCSVFile(ctx, file)
+	case kindCSVHeader:
+		content, err = csvprocessor.ProcessCSVHeader(ctx, file, csvHeaderRows)
+	case kindHTML:
+		content, err = markupprocessor.ProcessHTML(file, p.markup)
+	case kindJSON:
+		content, err = markupprocessor.ProcessJSON(file, p.markup)
+	case kindXML:
+		content, err = markupprocessor.ProcessXML(file, p.markup)
+	case kindText:
+		content, err = markupprocessor.ProcessText(file, p.markup)
+	default:
+		return nil, &ProcessingError{Reason: ReasonUnsupportedContent, Err: fmt.Errorf("unsupported content type %s", fileType)}
+	}
+
+	if errors.Is(err, markupprocessor.ErrTooLarge) {
+		return nil, &ProcessingError{Reason: ReasonTooLarge, Err: err}
+	}
+	if err != nil {
+		return nil, &ProcessingError{Reason: ReasonProcessingFailed, Err: err}
+	}
+
+	if strings.TrimSpace(content) == "" {
+		return nil, &ProcessingError{Reason: ReasonEmptyContent, Err: errors.New("empty content")}
+	}
+
+	return &Result{Content: content, Confidence: confidence, OCR: recognized}, nil
+}
+
+// markupKind текстовые файлы различаем по расширению, http.DetectContentType
+// для txt, md и json возвращает text/plain
+func markupKind(file *multipart.FileHeader, fileType string) string {
+	ext := strings.ToLower(filepath.Ext(file.Filename))
+
+	switch {
+	case ext == ".html" || ext == ".htm" || strings.HasPrefix(fileType, "text/html"):
+		return kindHTML
+	case ext == ".json" || file.Header.Get("Content-Type") == "application/json":
+		return kindJSON
+	case ext == ".xml" || strings.HasPrefix(fileType, "text/xml"):
+		return kindXML
+	default:
+		return kindText
+	}
+}
@@ -0,0 +1,245 @@
+package processors
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"github.com/gurkankaymak/hocon"
+	"mime/multipart"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"sse-demo-core/internal/app/processors/ocr"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	_, _ = logdoc.Init("udp", "127.0.0.1:9", "processors-test")
+	os.Exit(m.Run())
+}
+
+// pngSignature достаточно для определения image/png по содержимому
+const pngSignature = "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"
+
+// fakeRecognizer распознавание с заранее заданным результатом
+type fakeRecognizer struct {
+	text       string
+	confidence float64
+	err        error
+	// block распознавание ждет отмены контекста
+	block bool
+
+	mu     sync.Mutex
+	images [][]byte
+}
+
+func (f *fakeRecognizer) Recognize(ctx context.Context, image []byte) (*ocr.Recognition, error) {
+	f.mu.Lock()
+	f.images = append(f.images, image)
+	f.mu.Unlock()
+
+	if f.block {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &ocr.Recognition{Text: f.text, Confidence: f.confidence}, nil
+}
+
+func newProcessor(t *testing.T, conf string, recognizer ocr.Recognizer) *Processor {
+	config, err := hocon.ParseString(`upload { file_timeout = 5, text { max_size = "1MB", max_keys = 100 } }` + "\n" + conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewWithRecognizer(config, recognizer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// formFile файл формы, разобранный так же, как в обработчике загрузки
+func formFile(t *testing.T, name string, contentType string, content string) *multipart.FileHeader {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", `form-data; name="file"; filename="`+name+`"`)
+	header.Set("Content-Type", contentType)
+	part, err := w.CreatePart(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = part.Write([]byte(content))
+	_ = w.Close()
+
+	r := httptest.NewRequest("POST", "/upload", &body)
+	r.Header.Set("Content-Type", w.FormDataContentType())
+	if err = r.ParseMultipartForm(1 << 20); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = r.MultipartForm.RemoveAll() })
+	return r.MultipartForm.File["file"][0]
+}
+
+func TestProcessImageOCR(t *testing.T) {
+	recognizer := &fakeRecognizer{text: "Счет на оплату №15", confidence: 87.5}
+	p := newProcessor(t, "", recognizer)
+	image := pngSignature + "image data"
+
+	res, err := p.Process(context.Background(), formFile(t, "scan.png", "image/png", image))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Content != "Счет на оплату №15" || res.Confidence != 87.5 || !res.OCR {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if len(recognizer.images) != 1 || string(recognizer.images[0]) != image {
+		t.Fatalf("recognizer got %d images, want the uploaded image", len(recognizer.images))
+	}
+}
+
+func TestProcessImageOCRUnavailable(t *testing.T) {
+	p := newProcessor(t, "", ocr.Unavailable{})
+
+	_, err := p.Process(context.Background(), formFile(t, "scan.png", "image/png", pngSignature))
+	if Reason(err) != ReasonOCRUnavailable || !errors.Is(err, ocr.ErrUnavailable) {
+		t.Fatalf("got %v, want %s", err, ReasonOCRUnavailable)
+	}
+}
+
+func TestProcessImageOCRErrors(t *testing.T) {
+	cases := []struct {
+		name       string
+		recognizer *fakeRecognizer
+		reason     string
+	}{
+		{"recognizer error", &fakeRecognizer{err: errors.New("tesseract error: exit status 1")}, ReasonProcessingFailed},
+		{"nothing recognized", &fakeRecognizer{text: " \n "}, ReasonEmptyContent},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p := newProcessor(t, "", c.recognizer)
+
+			_, err := p.Process(context.Background(), formFile(t, "scan.png", "image/png", pngSignature))
+			if Reason(err) != c.reason {
+				t.Fatalf("got %v, want %s", err, c.reason)
+			}
+		})
+	}
+}
+
+func TestProcessImageOCRTimeout(t *testing.T) {
+	recognizer := &fakeRecognizer{block: true}
+	p := newProcessor(t, "", recognizer)
+	p.timeout = 50 * time.Millisecond
+
+	_, err := p.Process(context.Background(), formFile(t, "scan.png", "image/png", pngSignature))
+	if Reason(err) != ReasonTimeout {
+		t.Fatalf("got %v, want %s", err, ReasonTimeout)
+	}
+}
+
+func TestProcessImageOCRCancelled(t *testing.T) {
+	p := newProcessor(t, "", &fakeRecognizer{block: true})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := p.Process(ctx, formFile(t, "scan.png", "image/png", pngSignature))
+	if Reason(err) != ReasonCancelled {
+		t.Fatalf("got %v, want %s", err, ReasonCancelled)
+	}
+}
+
+func TestProcessHungProcessorReleasesWorker(t *testing.T) {
+	p := newProcessor(t, "", &fakeRecognizer{})
+	p.timeout = 50 * time.Millisecond
+
+	// процессор не смотрит на контекст, как docx или разметка, и висит до конца теста
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	p.extract = func(context.Context, *multipart.FileHeader) (*Result, error) {
+		<-release
+		return &Result{Content: "late"}, nil
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := p.Process(context.Background(), formFile(t, "report.docx", "text/plain", "text"))
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if Reason(err) != ReasonTimeout {
+			t.Fatalf("got %v, want %s", err, ReasonTimeout)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Process waits for a processor that ignores its context")
+	}
+}
+
+func TestProcessPanic(t *testing.T) {
+	p := newProcessor(t, "", &fakeRecognizer{})
+	p.extract = func(context.Context, *multipart.FileHeader) (*Result, error) {
+		panic("broken file")
+	}
+
+	_, err := p.Process(context.Background(), formFile(t, "report.txt", "text/plain", "text"))
+	if Reason(err) != ReasonPanic || !strings.Contains(err.Error(), "broken file") {
+		t.Fatalf("got %v, want %s", err, ReasonPanic)
+	}
+
+	// после паники процессор обрабатывает следующие файлы
+	p.extract = p.process
+	res, err := p.Process(context.Background(), formFile(t, "report.txt", "text/plain", "plain text"))
+	if err != nil || res.Content == "" {
+		t.Fatalf("got %v, %v after panic, want content", res, err)
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	p := newProcessor(t, "", &fakeRecognizer{})
+	csv := "name,amount\nalice,10\n"
+
+	full, err := p.CacheKey(formFile(t, "report.csv", "text/csv", csv), "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, err := p.CacheKey(formFile(t, "report.csv", "application/octet-stream", csv), "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if full == header {
+		t.Fatalf("csv declared as text/csv and detected by content share cache key %s", full)
+	}
+	if !strings.HasPrefix(full, "content:hash:") {
+		t.Fatalf("unexpected cache key %s", full)
+	}
+
+	// другие настройки процессоров дают другой ключ для того же файла
+	other := newProcessor(t, `upload.text.max_size = "2MB"`, &fakeRecognizer{})
+	otherKey, err := other.CacheKey(formFile(t, "report.csv", "text/csv", csv), "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if otherKey == full {
+		t.Fatalf("processors with different options share cache key %s", full)
+	}
+}
+
+func TestNewInvalidTextMaxSize(t *testing.T) {
+	config, err := hocon.ParseString(`upload.text.max_size = "lots"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = NewWithRecognizer(config, ocr.Unavailable{}); err == nil {
+		t.Fatal("expected error for invalid upload.text.max_size")
+	}
+}
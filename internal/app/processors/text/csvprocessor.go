@@ -1,6 +1,7 @@
 package csvprocessor
 
 import (
+	"context"
 	"errors"
 	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
 	"github.com/jfyne/csvd"
@@ -9,7 +10,7 @@ import (
 	"strings"
 )
 
-func ProcessCSVFile(file *multipart.FileHeader) (string, error) {
+func ProcessCSVFile(ctx context.Context, file *multipart.FileHeader) (string, error) {
 	logger := logdoc.GetLogger()
 	logger.Debug("Processing text file ", file.Filename)
 
@@ -21,7 +22,7 @@ func ProcessCSVFile(file *multipart.FileHeader) (string, error) {
 	defer src.Close()
 
 	sniffer := csvd.NewSniffer(15, ',', '\t', ';', ':', '|')
-	reader := csvd.NewReader(src, sniffer)
+	reader := csvd.NewReader(&contextReader{ctx: ctx, r: src}, sniffer)
 	reader.LazyQuotes = true
 
 	// Устанавливаем разделитель полей
@@ -42,7 +43,7 @@ func ProcessCSVFile(file *multipart.FileHeader) (string, error) {
 	return text, nil
 }
 
-func ProcessCSVHeader(file *multipart.FileHeader, size int) (string, error) {
+func ProcessCSVHeader(ctx context.Context, file *multipart.FileHeader, size int) (string, error) {
 	logger := logdoc.GetLogger()
 	logger.Debug("Processing csv header of the file ", file.Filename)
 
@@ -54,7 +55,7 @@ func ProcessCSVHeader(file *multipart.FileHeader, size int) (string, error) {
 	defer src.Close()
 
 	sniffer := csvd.NewSniffer(15, ',', '\t', ';', ':', '|')
-	reader := csvd.NewReader(src, sniffer)
+	reader := csvd.NewReader(&contextReader{ctx: ctx, r: src}, sniffer)
 	reader.LazyQuotes = true
 
 	// Читаем первые n строк из файла, head + data
@@ -78,3 +79,16 @@ func ProcessCSVHeader(file *multipart.FileHeader, size int) (string, error) {
 
 	return text, nil
 }
+
+// contextReader прерывает чтение файла после отмены контекста обработки
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
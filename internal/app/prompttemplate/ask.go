@@ -0,0 +1,85 @@
+package prompttemplate
+
+import (
+	"fmt"
+	"regexp"
+	"sse-demo-core/internal/app/structs"
+	"strconv"
+	"strings"
+)
+
+// AskPrompt промпт ответа на вопрос по найденным фрагментам загрузок, если этап ask.stage не задан
+// или промпта этого этапа нет в таблице prompts
+const AskPrompt = `Answer the question using only the numbered sources below.
+Cite every source you use by its number in square brackets, for example [1] or [2][3].
+If the sources do not contain the answer, say so instead of guessing. Answer in the language of the question.
+
+Sources:
+
+{{.Content}}`
+
+var citation = regexp.MustCompile(`\[(\d+)]`)
+
+// FromChunks переменные шаблона для фрагментов-источников: {{.Content}} - фрагменты с номерами источников
+func FromChunks(chunks []structs.LayerChunk, language string) Data {
+	data := Data{Language: language, Content: sources(chunks)}
+	seen := make(map[string]bool)
+	for _, c := range chunks {
+		if !seen[c.FileName] {
+			seen[c.FileName] = true
+			data.Files = append(data.Files, c.FileName)
+		}
+	}
+	return data
+}
+
+// AskMessages сообщения ответа на вопрос: промпт с источниками уходит системным сообщением, вопрос - сообщением пользователя.
+// Если шаблон не подставляет источники, они отправляются в сообщении пользователя перед вопросом
+func AskMessages(text string, data Data, question string) ([]structs.Content, error) {
+	prompt, err := Render(text, data)
+	if err != nil {
+		return nil, fmt.Errorf("error rendering prompt template, %w", err)
+	}
+
+	if data.Content != "" && !strings.Contains(prompt, data.Content) {
+		question = data.Content + "\n\n" + question
+	}
+	return []structs.Content{
+		{Role: "system", Content: prompt},
+		{Role: "user", Content: question},
+	}, nil
+}
+
+// Citations номера источников из 1..sources, на которые ссылается ответ, в порядке первого упоминания
+func Citations(answer string, sources int) []int {
+	var numbers []int
+	seen := make(map[int]bool)
+	for _, m := range citation.FindAllStringSubmatch(answer, -1) {
+		n, err := strconv.Atoi(m[1])
+		if err != nil || n < 1 || n > sources || seen[n] {
+			continue
+		}
+		seen[n] = true
+		numbers = append(numbers, n)
+	}
+	return numbers
+}
+
+// sources фрагменты с номерами, файлом, страницей и разделом источника
+func sources(chunks []structs.LayerChunk) string {
+	var sb strings.Builder
+	for i, c := range chunks {
+		if i > 0 {
+			sb.WriteString("\n\n")
+		}
+		sb.WriteString("[" + strconv.Itoa(i+1) + "] " + c.FileName)
+		if c.Page > 0 {
+			sb.WriteString(", page " + strconv.Itoa(c.Page))
+		}
+		if c.Section != "" {
+			sb.WriteString(", section \"" + c.Section + "\"")
+		}
+		sb.WriteString("\n" + c.Content)
+	}
+	return sb.String()
+}
@@ -0,0 +1,72 @@
+package prompttemplate
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sse-demo-core/internal/app/structs"
+	"sse-demo-core/internal/app/utils"
+	"strings"
+)
+
+// ExtractPrompt системный промпт извлечения данных слоя, схема добавляется в конец промпта
+const ExtractPrompt = `Extract data from the document sent by the user.
+Reply with a single JSON value that matches the JSON Schema below, without explanations and without markdown.
+Use null for optional values that are missing in the document, do not invent data.
+Keep placeholders like [EMAIL_1] exactly as they appear in the document.
+
+JSON Schema:
+
+`
+
+// RetryPrompt повторный запрос после ответа, не прошедшего проверку схемой, ошибки проверки добавляются в конец
+const RetryPrompt = `Your reply does not match the JSON Schema. Fix these errors and reply with the corrected JSON only:
+
+`
+
+// ExtractMessages сообщения извлечения данных слоя по схеме: промпт со схемой и указаниями уходит системным сообщением,
+// содержимое слоя - сообщением пользователя
+func ExtractMessages(schema json.RawMessage, instructions string, layer structs.UserLayer) []structs.Content {
+	prompt := ExtractPrompt + string(schema)
+	if instructions = strings.TrimSpace(instructions); instructions != "" {
+		prompt += "\n\nInstructions:\n\n" + instructions
+	}
+	return []structs.Content{
+		{Role: "system", Content: prompt},
+		{Role: "user", Content: utils.Ternary(layer.OptimizedData == "", layer.SourceData, layer.OptimizedData).(string)},
+	}
+}
+
+// RetryMessages сообщения повторного запроса: к исходным добавляются ответ модели и ошибки его проверки схемой
+func RetryMessages(messages []structs.Content, reply string, errs []string) []structs.Content {
+	retry := make([]structs.Content, 0, len(messages)+2)
+	retry = append(retry, messages...)
+	return append(retry,
+		structs.Content{Role: "assistant", Content: reply},
+		structs.Content{Role: "user", Content: RetryPrompt + "- " + strings.Join(errs, "\n- ")})
+}
+
+// ExtractedJSON JSON значение из ответа модели: без обрамления ```json и текста вокруг значения
+func ExtractedJSON(reply string) (json.RawMessage, error) {
+	reply = strings.TrimSpace(reply)
+	if strings.HasPrefix(reply, "```") {
+		reply = strings.TrimPrefix(reply[3:], "json")
+		reply = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(reply), "```"))
+	}
+
+	candidates := []string{reply}
+	// модель могла добавить пояснения до или после значения
+	if start := strings.IndexAny(reply, "{["); start >= 0 {
+		if end := strings.LastIndexAny(reply, "}]"); end > start {
+			candidates = append(candidates, reply[start:end+1])
+		}
+	}
+
+	for _, candidate := range candidates {
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, []byte(candidate)); err == nil {
+			return compact.Bytes(), nil
+		}
+	}
+	return nil, errors.New("reply is not valid json")
+}
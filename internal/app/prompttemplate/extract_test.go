@@ -0,0 +1,65 @@
+package prompttemplate
+
+import (
+	"sse-demo-core/internal/app/structs"
+	"strings"
+	"testing"
+)
+
+func TestExtractedJSON(t *testing.T) {
+	cases := []struct {
+		name     string
+		reply    string
+		expected string
+	}{
+		{"plain", ` {"a": 1} `, `{"a":1}`},
+		{"fenced", "```json\n{\"a\": [1, 2]}\n```", `{"a":[1,2]}`},
+		{"fenced without language", "```\n[1, 2]\n```", `[1,2]`},
+		{"surrounding text", "Here is the result:\n{\"a\": \"b\"}\nHope it helps.", `{"a":"b"}`},
+		{"scalar", `"text"`, `"text"`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			value, err := ExtractedJSON(c.reply)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(value) != c.expected {
+				t.Errorf("value %s, expected %s", value, c.expected)
+			}
+		})
+	}
+
+	for _, reply := range []string{"", "no json here", `{"a": 1`, "```json\n{a: 1}\n```"} {
+		if value, err := ExtractedJSON(reply); err == nil {
+			t.Errorf("reply %q accepted as %s", reply, value)
+		}
+	}
+}
+
+func TestExtractMessages(t *testing.T) {
+	layer := structs.UserLayer{SourceData: "source", OptimizedData: "optimized"}
+
+	messages := ExtractMessages([]byte(`{"type":"object"}`), " only totals ", layer)
+	if len(messages) != 2 || messages[0].Role != "system" || messages[1].Role != "user" {
+		t.Fatalf("messages %+v", messages)
+	}
+	if !strings.HasSuffix(messages[0].Content, `{"type":"object"}`+"\n\nInstructions:\n\nonly totals") {
+		t.Errorf("system prompt %q does not end with schema and instructions", messages[0].Content)
+	}
+	if messages[1].Content != "optimized" {
+		t.Errorf("content %q, expected optimized layer data", messages[1].Content)
+	}
+
+	retry := RetryMessages(messages, `{"a": 1}`, []string{"$.a: expected string, got integer", "$: missing required property \"b\""})
+	if len(retry) != 4 || len(messages) != 2 {
+		t.Fatalf("%d retry messages, %d original, expected 4 and 2", len(retry), len(messages))
+	}
+	if retry[2].Role != "assistant" || retry[2].Content != `{"a": 1}` {
+		t.Errorf("retry reply %+v", retry[2])
+	}
+	if retry[3].Role != "user" || !strings.HasSuffix(retry[3].Content, "- $.a: expected string, got integer\n- $: missing required property \"b\"") {
+		t.Errorf("retry errors %q", retry[3].Content)
+	}
+}
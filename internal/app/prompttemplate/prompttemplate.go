@@ -0,0 +1,94 @@
+package prompttemplate
+
+import (
+	"fmt"
+	"sse-demo-core/internal/app/structs"
+	"sse-demo-core/internal/app/utils"
+	"strings"
+	"text/template"
+)
+
+// Data переменные шаблона промпта: {{.Files}} - имена файлов загрузки, {{.Language}} - язык загрузки,
+// {{.Content}} - содержимое слоев загрузки, очищенное от персональных данных
+type Data struct {
+	Files    []string
+	Language string
+	Content  string
+}
+
+// sample данные для проверки шаблона при сохранении
+var sample = Data{Files: []string{"document.pdf", "table.xlsx"}, Language: "ru", Content: "### document.pdf\n\ncontent"}
+
+// Validate проверяет синтаксис шаблона и то, что он использует только переменные Data
+func Validate(text string) error {
+	_, err := Render(text, sample)
+	return err
+}
+
+func Render(text string, data Data) (string, error) {
+	tmpl, err := template.New("prompt").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var prompt strings.Builder
+	if err = tmpl.Execute(&prompt, data); err != nil {
+		return "", err
+	}
+	return prompt.String(), nil
+}
+
+// FromLayers переменные шаблона для слоев загрузки
+func FromLayers(layers []structs.UserLayer) Data {
+	data := Data{Language: uploadLanguage(layers), Content: uploadDocuments(layers)}
+	for _, l := range layers {
+		data.Files = append(data.Files, l.LayerName)
+	}
+	return data
+}
+
+// Messages сообщения анализа загрузки промптом text: промпт уходит системным сообщением, содержимое загрузки -
+// сообщением пользователя. Если шаблон сам подставляет содержимое, промпт отправляется одним сообщением пользователя
+func Messages(text string, data Data) ([]structs.Content, error) {
+	prompt, err := Render(text, data)
+	if err != nil {
+		return nil, fmt.Errorf("error rendering prompt template, %w", err)
+	}
+
+	if data.Content != "" && strings.Contains(prompt, data.Content) {
+		return []structs.Content{{Role: "user", Content: prompt}}, nil
+	}
+	return []structs.Content{
+		{Role: "system", Content: prompt},
+		{Role: "user", Content: data.Content},
+	}, nil
+}
+
+// uploadLanguage язык загрузки - язык слоев с наибольшим количеством токенов
+func uploadLanguage(layers []structs.UserLayer) string {
+	tokens := make(map[string]int)
+	var lang string
+	for _, l := range layers {
+		if l.Language == "" {
+			continue
+		}
+		tokens[l.Language] += l.Tokens
+		if lang == "" || tokens[l.Language] > tokens[lang] {
+			lang = l.Language
+		}
+	}
+	return lang
+}
+
+// uploadDocuments содержимое слоев загрузки для модели, очищенное от персональных данных, если очистка включена
+func uploadDocuments(layers []structs.UserLayer) string {
+	var documents strings.Builder
+	for i, l := range layers {
+		if i > 0 {
+			documents.WriteString("\n\n")
+		}
+		documents.WriteString("### " + l.LayerName + "\n\n")
+		documents.WriteString(utils.Ternary(l.OptimizedData == "", l.SourceData, l.OptimizedData).(string))
+	}
+	return documents.String()
+}
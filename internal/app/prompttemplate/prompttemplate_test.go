@@ -0,0 +1,75 @@
+package prompttemplate
+
+import (
+	"sse-demo-core/internal/app/structs"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	valid := []string{
+		"Summarize the document",
+		"Files: {{range .Files}}{{.}}, {{end}}language {{.Language}}",
+		"{{if eq .Language \"ru\"}}Отвечай по-русски{{end}}\n{{.Content}}",
+	}
+	for _, text := range valid {
+		if err := Validate(text); err != nil {
+			t.Errorf("template %q rejected, %v", text, err)
+		}
+	}
+
+	invalid := []string{
+		"{{.Document}}",
+		"{{.Content",
+		"{{range .Language}}{{end}}",
+		"{{template \"missing\"}}",
+		"{{.Files.Name}}",
+	}
+	for _, text := range invalid {
+		if err := Validate(text); err == nil {
+			t.Errorf("template %q accepted", text)
+		}
+	}
+}
+
+func TestFromLayers(t *testing.T) {
+	data := FromLayers([]structs.UserLayer{
+		{LayerName: "a.pdf", SourceData: "source", Language: "en", Tokens: 10},
+		{LayerName: "b.pdf", SourceData: "source", OptimizedData: "optimized", Language: "ru", Tokens: 30},
+		{LayerName: "c.png", Language: "", Tokens: 100},
+	})
+
+	if len(data.Files) != 3 || data.Files[2] != "c.png" {
+		t.Errorf("files %v", data.Files)
+	}
+	// язык загрузки - язык слоев с наибольшим количеством токенов, слои без языка не учитываются
+	if data.Language != "ru" {
+		t.Errorf("language %q, expected ru", data.Language)
+	}
+	if expected := "### a.pdf\n\nsource\n\n### b.pdf\n\noptimized\n\n### c.png\n\n"; data.Content != expected {
+		t.Errorf("content %q, expected %q", data.Content, expected)
+	}
+}
+
+func TestMessages(t *testing.T) {
+	data := Data{Language: "en", Content: "document text"}
+
+	messages, err := Messages("Summarize in {{.Language}}", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 || messages[0].Content != "Summarize in en" || messages[1].Content != "document text" {
+		t.Errorf("messages %+v, expected system prompt and user content", messages)
+	}
+
+	messages, err = Messages("Summarize:\n{{.Content}}", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 || messages[0].Role != "user" || messages[0].Content != "Summarize:\ndocument text" {
+		t.Errorf("messages %+v, expected one user message with content", messages)
+	}
+
+	if _, err = Messages("{{.Unknown}}", data); err == nil {
+		t.Error("invalid template rendered")
+	}
+}
@@ -0,0 +1,181 @@
+package redaction
+
+import (
+	"fmt"
+	"github.com/gurkankaymak/hocon"
+	"regexp"
+	"sse-demo-core/internal/app/structs"
+	"strings"
+	"sync"
+)
+
+// Result результат очистки текста
+type Result struct {
+	Text string
+	// Counts количество замен по видам данных
+	Counts map[string]int
+	// Tokens заменители, использованные в тексте
+	Tokens []structs.RedactionToken
+}
+
+// Total общее количество замен
+func (r *Result) Total() int {
+	total := 0
+	for _, n := range r.Counts {
+		total += n
+	}
+	return total
+}
+
+// Redactor набор включенных правил очистки персональных данных
+type Redactor struct {
+	rules []rule
+}
+
+// New правила включаются в redaction.rules, при redaction.enabled = false очистка не выполняется
+func New(config *hocon.Config) *Redactor {
+	r := &Redactor{}
+	if !config.GetBoolean("redaction.enabled") {
+		return r
+	}
+
+	for _, rl := range rules {
+		if config.Get("redaction.rules."+rl.kind) == nil || config.GetBoolean("redaction.rules."+rl.kind) {
+			r.rules = append(r.rules, rl)
+		}
+	}
+	return r
+}
+
+// Enabled true, если включено хотя бы одно правило
+func (r *Redactor) Enabled() bool {
+	return len(r.rules) > 0
+}
+
+// Session заменители одной загрузки: одинаковые значения во всех файлах загрузки
+// получают одинаковый заменитель, безопасна для одновременного использования
+type Session struct {
+	redactor *Redactor
+
+	mu       sync.Mutex
+	byValue  map[string]structs.RedactionToken
+	counters map[string]int
+}
+
+func (r *Redactor) NewSession() *Session {
+	return &Session{redactor: r, byValue: make(map[string]structs.RedactionToken), counters: make(map[string]int)}
+}
+
+// Redact заменяет персональные данные в тексте заменителями вида [EMAIL_1]
+func (s *Session) Redact(text string) *Result {
+	result := &Result{Text: text, Counts: make(map[string]int)}
+	used := make(map[string]bool)
+
+	for _, rl := range s.redactor.rules {
+		result.Text = rl.pattern.ReplaceAllStringFunc(result.Text, func(value string) string {
+			if rl.valid != nil && !rl.valid(value) {
+				return value
+			}
+
+			token := s.token(rl.kind, value)
+			result.Counts[rl.kind]++
+			if !used[token.Placeholder] {
+				used[token.Placeholder] = true
+				result.Tokens = append(result.Tokens, token)
+			}
+			return token.Placeholder
+		})
+	}
+
+	return result
+}
+
+func (s *Session) token(kind string, value string) structs.RedactionToken {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := kind + ":" + normalize(kind, value)
+	if token, ok := s.byValue[key]; ok {
+		return token
+	}
+
+	s.counters[kind]++
+	token := structs.RedactionToken{Placeholder: fmt.Sprintf("[%s_%d]", strings.ToUpper(kind), s.counters[kind]), Kind: kind, Value: value}
+	s.byValue[key] = token
+	return token
+}
+
+// normalize один и тот же номер может быть записан с разными разделителями
+func normalize(kind string, value string) string {
+	if kind == KindEmail {
+		return strings.ToLower(value)
+	}
+	var sb strings.Builder
+	for _, r := range value {
+		if r != ' ' && r != '-' && r != '(' && r != ')' {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+var placeholder = regexp.MustCompile(`\[(?:EMAIL|PHONE|CARD|IBAN|INN|SNILS)_\d+]`)
+
+// Restore возвращает исходные значения вместо заменителей, например в ответе модели.
+// Заменитель с разными значениями в tokens, например из разных загрузок с одним guid, не восстанавливается
+func Restore(text string, tokens []structs.RedactionToken) string {
+	values := make(map[string]string, len(tokens))
+	ambiguous := make(map[string]bool)
+	for _, t := range tokens {
+		if value, ok := values[t.Placeholder]; ok && value != t.Value {
+			ambiguous[t.Placeholder] = true
+		}
+		values[t.Placeholder] = t.Value
+	}
+	for p := range ambiguous {
+		delete(values, p)
+	}
+
+	return placeholder.ReplaceAllStringFunc(text, func(p string) string {
+		if value, ok := values[p]; ok {
+			return value
+		}
+		return p
+	})
+}
+
+// Replace заменяет каждый заменитель в тексте результатом replace
+func Replace(text string, replace func(placeholder string) string) string {
+	return placeholder.ReplaceAllStringFunc(text, replace)
+}
+
+// partial незакрытый заменитель в конце фрагмента ответа
+var partial = regexp.MustCompile(`\[[A-Z]*_?\d*$`)
+
+// Stream восстанавливает заменители в ответе модели, приходящем фрагментами. Заменитель может оказаться разрезан
+// между фрагментами, поэтому незакрытое начало заменителя в конце фрагмента придерживается до следующего
+type Stream struct {
+	restore func(text string) string
+	pending string
+}
+
+func NewStream(restore func(text string) string) *Stream {
+	return &Stream{restore: restore}
+}
+
+// Write восстановленный текст, который можно отправить после фрагмента delta, может быть пустым
+func (s *Stream) Write(delta string) string {
+	text := s.pending + delta
+	s.pending = ""
+	if loc := partial.FindStringIndex(text); loc != nil {
+		text, s.pending = text[:loc[0]], text[loc[0]:]
+	}
+	return s.restore(text)
+}
+
+// Flush придержанный остаток ответа
+func (s *Stream) Flush() string {
+	text := s.pending
+	s.pending = ""
+	return s.restore(text)
+}
@@ -0,0 +1,135 @@
+package redaction
+
+import (
+	"github.com/gurkankaymak/hocon"
+	"sse-demo-core/internal/app/structs"
+	"strings"
+	"testing"
+)
+
+func TestValidators(t *testing.T) {
+	cases := []struct {
+		name  string
+		valid func(string) bool
+		value string
+		want  bool
+	}{
+		{"card visa", validCard, "4111 1111 1111 1111", true},
+		{"card mastercard with dashes", validCard, "5500-0000-0000-0004", true},
+		{"card wrong luhn", validCard, "4111111111111112", false},
+		{"card too short", validCard, "411111111111", false},
+		{"iban gb", validIBAN, "GB82 WEST 1234 5698 7654 32", true},
+		{"iban de", validIBAN, "DE89370400440532013000", true},
+		{"iban wrong checksum", validIBAN, "GB82WEST12345698765433", false},
+		{"iban too short", validIBAN, "GB82WEST1234", false},
+		{"inn legal entity", validINN, "7707083893", true},
+		{"inn legal entity wrong control", validINN, "7707083894", false},
+		{"inn individual", validINN, "500100732259", true},
+		{"inn individual wrong control", validINN, "500100732250", false},
+		{"inn wrong length", validINN, "77070838931", false},
+		{"snils", validSNILS, "112-233-445 95", true},
+		{"snils wrong control", validSNILS, "112-233-445 96", false},
+		{"phone", validPhone, "+7 (495) 123-45-67", true},
+		{"phone too short", validPhone, "8 123 45", false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.valid(c.value); got != c.want {
+				t.Fatalf("valid(%q) = %v, want %v", c.value, got, c.want)
+			}
+		})
+	}
+}
+
+func newRedactor(t *testing.T, conf string) *Redactor {
+	config, err := hocon.ParseString("redaction.enabled = true\n" + conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(config)
+}
+
+func TestRedact(t *testing.T) {
+	cases := []struct {
+		name  string
+		text  string
+		want  string
+		kinds map[string]int
+	}{
+		{"email", "Пишите на Ivan.Petrov@example.com.", "Пишите на [EMAIL_1].", map[string]int{KindEmail: 1}},
+		{"phone", "Тел. +7 (495) 123-45-67 или 8 800 555-35-35", "Тел. [PHONE_1] или [PHONE_2]", map[string]int{KindPhone: 2}},
+		{"card", "Карта 4111 1111 1111 1111", "Карта [CARD_1]", map[string]int{KindCard: 1}},
+		{"card wrong luhn", "Номер 4111 1111 1111 1112", "Номер 4111 1111 1111 1112", map[string]int{}},
+		{"iban", "IBAN GB82 WEST 1234 5698 7654 32", "IBAN [IBAN_1]", map[string]int{KindIBAN: 1}},
+		{"iban wrong checksum", "IBAN GB82WEST12345698765433", "IBAN GB82WEST12345698765433", map[string]int{}},
+		{"inn", "ИНН 7707083893, ИНН 500100732259", "ИНН [INN_1], ИНН [INN_2]", map[string]int{KindINN: 2}},
+		{"inn wrong control", "ИНН 7707083894", "ИНН 7707083894", map[string]int{}},
+		{"snils", "СНИЛС 112-233-445 95", "СНИЛС [SNILS_1]", map[string]int{KindSNILS: 1}},
+		{"snils wrong control", "СНИЛС 112-233-445 96", "СНИЛС 112-233-445 96", map[string]int{}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			result := newRedactor(t, "").NewSession().Redact(c.text)
+			if result.Text != c.want {
+				t.Fatalf("got %q, want %q", result.Text, c.want)
+			}
+			for kind, n := range c.kinds {
+				if result.Counts[kind] != n {
+					t.Fatalf("%s count %d, want %d", kind, result.Counts[kind], n)
+				}
+			}
+			if len(c.kinds) == 0 && result.Total() != 0 {
+				t.Fatalf("unexpected redactions %v", result.Counts)
+			}
+			if restored := Restore(result.Text, result.Tokens); restored != c.text {
+				t.Fatalf("restored %q, want %q", restored, c.text)
+			}
+		})
+	}
+}
+
+func TestRedactSameValue(t *testing.T) {
+	// одно значение в разной записи получает один заменитель во всех файлах загрузки
+	session := newRedactor(t, "").NewSession()
+	first := session.Redact("a@b.ru, +7 495 123-45-67")
+	second := session.Redact("A@B.ru, +7 (495) 123 45 67")
+	if first.Text != "[EMAIL_1], [PHONE_1]" || second.Text != first.Text {
+		t.Fatalf("got %q and %q, want the same placeholders", first.Text, second.Text)
+	}
+	if len(second.Tokens) != 2 || second.Tokens[0].Value != "a@b.ru" {
+		t.Fatalf("unexpected tokens %+v", second.Tokens)
+	}
+}
+
+func TestRedactDisabledRule(t *testing.T) {
+	r := newRedactor(t, "redaction.rules.email = false")
+	if got := r.NewSession().Redact("a@b.ru, ИНН 7707083893").Text; got != "a@b.ru, ИНН [INN_1]" {
+		t.Fatalf("unexpected text %q", got)
+	}
+}
+
+func TestStreamRestoresSplitPlaceholders(t *testing.T) {
+	tokens := []structs.RedactionToken{
+		{Placeholder: "[EMAIL_1]", Value: "ivan@example.com"},
+		{Placeholder: "[PHONE_12]", Value: "+7 900 000-00-00"},
+	}
+	s := NewStream(func(text string) string { return Restore(text, tokens) })
+
+	var out strings.Builder
+	for _, delta := range []string{"Пишите на [EM", "AIL_", "1] или звоните [PHONE_1", "2], см. [1", "]", " [CARD_"} {
+		out.WriteString(s.Write(delta))
+	}
+	out.WriteString(s.Flush())
+
+	want := "Пишите на ivan@example.com или звоните +7 900 000-00-00, см. [1] [CARD_"
+	if out.String() != want {
+		t.Fatalf("got %q, want %q", out.String(), want)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	got := Replace("[EMAIL_1] и [PHONE_1], [1]", func(p string) string { return strings.ToLower(p) })
+	if got != "[email_1] и [phone_1], [1]" {
+		t.Fatalf("unexpected text %q", got)
+	}
+}
@@ -0,0 +1,136 @@
+package redaction
+
+import (
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+// Виды персональных данных, они же имена правил в конфиге redaction.rules
+const (
+	KindEmail = "email"
+	KindPhone = "phone"
+	KindCard  = "card"
+	KindIBAN  = "iban"
+	KindINN   = "inn"
+	KindSNILS = "snils"
+)
+
+type rule struct {
+	kind    string
+	pattern *regexp.Regexp
+	// valid дополнительная проверка найденного значения, например контрольной суммы
+	valid func(value string) bool
+}
+
+// rules порядок важен: длинные номера с контрольными суммами заменяются раньше телефонов,
+// чтобы номер карты или ИНН не был частично принят за телефон
+var rules = []rule{
+	{kind: KindEmail, pattern: regexp.MustCompile(`(?i)[a-z0-9._%+\-]+@[a-z0-9\-]+(?:\.[a-z0-9\-]+)*\.[a-z]{2,}`)},
+	{kind: KindIBAN, pattern: regexp.MustCompile(`\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]){11,30}\b`), valid: validIBAN},
+	{kind: KindCard, pattern: regexp.MustCompile(`\b\d(?:[ \-]?\d){12,18}\b`), valid: validCard},
+	{kind: KindSNILS, pattern: regexp.MustCompile(`\b\d{3}-?\d{3}-?\d{3}[ \-]?\d{2}\b`), valid: validSNILS},
+	{kind: KindINN, pattern: regexp.MustCompile(`\b(?:\d{12}|\d{10})\b`), valid: validINN},
+	{kind: KindPhone, pattern: regexp.MustCompile(`(?:\+\d{1,3}|\b8)[ \-]?\(?\d{3,4}\)?[ \-]?\d{2,3}[ \-]?\d{2}[ \-]?\d{2,3}\b`), valid: validPhone},
+}
+
+func digits(value string) []int {
+	var result []int
+	for _, r := range value {
+		if r >= '0' && r <= '9' {
+			result = append(result, int(r-'0'))
+		}
+	}
+	return result
+}
+
+// validCard проверка номера карты по алгоритму Луна
+func validCard(value string) bool {
+	d := digits(value)
+	if len(d) < 13 || len(d) > 19 {
+		return false
+	}
+
+	sum := 0
+	for i := len(d) - 1; i >= 0; i-- {
+		n := d[i]
+		if (len(d)-1-i)%2 == 1 {
+			n *= 2
+			if n > 9 {
+				n -= 9
+			}
+		}
+		sum += n
+	}
+	return sum%10 == 0
+}
+
+// validIBAN проверка контрольной суммы IBAN по модулю 97
+func validIBAN(value string) bool {
+	iban := strings.ReplaceAll(value, " ", "")
+	if len(iban) < 15 || len(iban) > 34 {
+		return false
+	}
+
+	var numeric strings.Builder
+	for _, r := range iban[4:] + iban[:4] {
+		switch {
+		case r >= '0' && r <= '9':
+			numeric.WriteRune(r)
+		case r >= 'A' && r <= 'Z':
+			numeric.WriteString(big.NewInt(int64(r - 'A' + 10)).String())
+		default:
+			return false
+		}
+	}
+
+	n, ok := new(big.Int).SetString(numeric.String(), 10)
+	return ok && new(big.Int).Mod(n, big.NewInt(97)).Int64() == 1
+}
+
+// validINN проверка контрольных чисел ИНН юридического (10 цифр) и физического (12 цифр) лица
+func validINN(value string) bool {
+	d := digits(value)
+
+	check := func(weights []int) int {
+		sum := 0
+		for i, w := range weights {
+			sum += d[i] * w
+		}
+		return sum % 11 % 10
+	}
+
+	switch len(d) {
+	case 10:
+		return check([]int{2, 4, 10, 3, 5, 9, 4, 6, 8}) == d[9]
+	case 12:
+		return check([]int{7, 2, 4, 10, 3, 5, 9, 4, 6, 8}) == d[10] &&
+			check([]int{3, 7, 2, 4, 10, 3, 5, 9, 4, 6, 8}) == d[11]
+	default:
+		return false
+	}
+}
+
+// validSNILS проверка контрольного числа СНИЛС
+func validSNILS(value string) bool {
+	d := digits(value)
+	if len(d) != 11 {
+		return false
+	}
+
+	sum := 0
+	for i := 0; i < 9; i++ {
+		sum += d[i] * (9 - i)
+	}
+
+	control := sum % 101
+	if control == 100 {
+		control = 0
+	}
+	return control == d[9]*10+d[10]
+}
+
+func validPhone(value string) bool {
+	n := len(digits(value))
+	return n >= 10 && n <= 15
+}
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"fmt"
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"github.com/jmoiron/sqlx"
+	"sse-demo-core/internal/app/structs"
+	"sse-demo-core/internal/errs"
+)
+
+type AnalysisRepository struct {
+	DB *sqlx.DB
+}
+
+func New(db *sqlx.DB) *AnalysisRepository {
+	return &AnalysisRepository{db}
+}
+
+func (r *AnalysisRepository) CreateResult(result *structs.AnalysisResult) (id int, err error) {
+	defer func() {
+		err = errs.WrapWithStackIfErr(">> CreateResult > Ошибка сохранения результата анализа", err)
+	}()
+
+	err = r.DB.Get(&id, `INSERT INTO analysis_results (guid, uuid, user_id, prompt_id, prompt_stage, prompt_version, language, provider, model, status,
+														pipeline_id, pipeline_step, layer_uuid, file_name)
+								  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+							   RETURNING id`,
+		result.GUID,
+		result.UUID,
+		result.UserID,
+		result.PromptID,
+		result.PromptStage,
+		result.PromptVersion,
+		result.Language,
+		result.Provider,
+		result.Model,
+		result.Status,
+		result.PipelineID,
+		result.PipelineStep,
+		result.LayerUUID,
+		result.FileName)
+
+	return
+}
+
+// UpdateResult сохраняет ответ модели, ошибку и статус завершенного анализа
+func (r *AnalysisRepository) UpdateResult(result *structs.AnalysisResult) (err error) {
+	defer func() {
+		err = errs.WrapWithStackIfErr(">> UpdateResult > Ошибка обновления результата анализа", err)
+	}()
+
+	_, err = r.DB.Exec(`UPDATE analysis_results
+							   SET model = $2,
+								   result = $3,
+								   error = $4,
+								   prompt_tokens = $5,
+								   completion_tokens = $6,
+								   status = $7,
+								   completed = now()
+							 WHERE id = $1`,
+		result.ID,
+		result.Model,
+		result.Result,
+		result.Error,
+		result.PromptTokens,
+		result.CompletionTokens,
+		result.Status)
+
+	return
+}
+
+func (r *AnalysisRepository) FindResultsByGUID(guid string, userID int) (results []structs.AnalysisResult, err error) {
+	defer func() {
+		err = errs.WrapWithStackIfErr(">> FindResultsByGUID > Ошибка поиска результатов анализа загрузки", err)
+	}()
+
+	logger := logdoc.GetLogger()
+
+	err = r.DB.Select(&results, `SELECT id,
+											 guid,
+											 uuid,
+											 user_id,
+											 prompt_id,
+											 prompt_stage,
+											 prompt_version,
+											 language,
+											 provider,
+											 model,
+											 result,
+											 error,
+											 prompt_tokens,
+											 completion_tokens,
+											 status,
+											 created,
+											 completed
+										FROM analysis_results a
+									   WHERE a.guid = $1
+										 AND a.user_id = $2
+										 AND a.pipeline_id = 0
+									ORDER BY a.id`, guid, userID)
+	if err != nil {
+		logger.Warn(fmt.Sprintf(">> FindResultsByGUID > Ошибка поиска результатов анализа загрузки guid: %s, userId: %d", guid, userID))
+	}
+
+	return
+}
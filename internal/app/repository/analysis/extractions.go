@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"sse-demo-core/internal/app/structs"
+	"sse-demo-core/internal/errs"
+)
+
+func (r *AnalysisRepository) CreateExtraction(extraction *structs.Extraction) (id int, err error) {
+	defer func() {
+		err = errs.WrapWithStackIfErr(">> CreateExtraction > Ошибка сохранения извлечения данных", err)
+	}()
+
+	err = r.DB.Get(&id, `INSERT INTO layer_extractions (guid, uuid, user_id, layer_id, layer_uuid, file_name, schema, instructions, provider, status)
+									   VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+									RETURNING id`,
+		extraction.GUID,
+		extraction.UUID,
+		extraction.UserID,
+		extraction.LayerID,
+		extraction.LayerUUID,
+		extraction.FileName,
+		string(extraction.Schema),
+		extraction.Instructions,
+		extraction.Provider,
+		extraction.Status)
+
+	return
+}
+
+// UpdateExtraction сохраняет результат, расход токенов, ошибку и статус извлечения
+func (r *AnalysisRepository) UpdateExtraction(extraction *structs.Extraction) (err error) {
+	defer func() {
+		err = errs.WrapWithStackIfErr(">> UpdateExtraction > Ошибка обновления извлечения данных", err)
+	}()
+
+	result := extraction.Result
+	if len(result) == 0 {
+		result = json.RawMessage("null")
+	}
+
+	_, err = r.DB.Exec(`UPDATE layer_extractions
+							   SET result = $2,
+								   attempts = $3,
+								   model = $4,
+								   prompt_tokens = $5,
+								   completion_tokens = $6,
+								   error = $7,
+								   status = $8,
+								   completed = CASE WHEN $8 = 'running' THEN NULL ELSE now() END
+							 WHERE id = $1`,
+		extraction.ID,
+		string(result),
+		extraction.Attempts,
+		extraction.Model,
+		extraction.PromptTokens,
+		extraction.CompletionTokens,
+		extraction.Error,
+		extraction.Status)
+
+	return
+}
+
+func (r *AnalysisRepository) FindExtractionsByGUID(guid string, userID int) (extractions []structs.Extraction, err error) {
+	defer func() {
+		err = errs.WrapWithStackIfErr(">> FindExtractionsByGUID > Ошибка поиска извлечений данных загрузки", err)
+	}()
+
+	logger := logdoc.GetLogger()
+
+	err = r.DB.Select(&extractions, `SELECT id,
+												 guid,
+												 uuid,
+												 user_id,
+												 layer_id,
+												 layer_uuid,
+												 file_name,
+												 schema,
+												 instructions,
+												 result,
+												 attempts,
+												 provider,
+												 model,
+												 prompt_tokens,
+												 completion_tokens,
+												 error,
+												 status,
+												 created,
+												 completed
+											FROM layer_extractions e
+										   WHERE e.guid = $1
+											 AND e.user_id = $2
+										ORDER BY e.id`, guid, userID)
+	if err != nil {
+		logger.Warn(fmt.Sprintf(">> FindExtractionsByGUID > Ошибка поиска извлечений данных загрузки guid: %s, userId: %d", guid, userID))
+	}
+
+	return
+}
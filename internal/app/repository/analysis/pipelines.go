@@ -0,0 +1,201 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"sse-demo-core/internal/app/structs"
+	"sse-demo-core/internal/errs"
+)
+
+func (r *AnalysisRepository) CreatePipeline(pipeline *structs.Pipeline) (id int, err error) {
+	defer func() {
+		err = errs.WrapWithStackIfErr(">> CreatePipeline > Ошибка сохранения конвейера", err)
+	}()
+
+	err = r.DB.Get(&id, `INSERT INTO pipelines (guid, uuid, user_id, name, stages, language, status)
+								  VALUES ($1, $2, $3, $4, $5, $6, $7)
+							   RETURNING id`,
+		pipeline.GUID,
+		pipeline.UUID,
+		pipeline.UserID,
+		pipeline.Name,
+		string(pipeline.Stages),
+		pipeline.Language,
+		pipeline.Status)
+
+	return
+}
+
+// UpdatePipeline сохраняет этап, с которого конвейер продолжится, результат, ошибку и статус конвейера
+func (r *AnalysisRepository) UpdatePipeline(pipeline *structs.Pipeline) (err error) {
+	defer func() {
+		err = errs.WrapWithStackIfErr(">> UpdatePipeline > Ошибка обновления конвейера", err)
+	}()
+
+	_, err = r.DB.Exec(`UPDATE pipelines
+							   SET step = $2,
+								   result = $3,
+								   error = $4,
+								   status = $5,
+								   updated = now(),
+								   completed = CASE WHEN $5 = 'running' THEN NULL ELSE now() END
+							 WHERE id = $1`,
+		pipeline.ID,
+		pipeline.Step,
+		pipeline.Result,
+		pipeline.Error,
+		pipeline.Status)
+
+	return
+}
+
+// ResumePipeline переводит конвейер в running, если он завершился ошибкой
+// или не обновлялся дольше stale секунд - процесс, выполнявший его, упал. false - конвейер продолжать нельзя
+func (r *AnalysisRepository) ResumePipeline(pipeline *structs.Pipeline, stale int) (resumed bool, err error) {
+	defer func() {
+		err = errs.WrapWithStackIfErr(">> ResumePipeline > Ошибка перезапуска конвейера", err)
+	}()
+
+	err = r.DB.Get(pipeline, `UPDATE pipelines
+									 SET status = 'running',
+										 error = '',
+										 updated = now(),
+										 completed = NULL
+								   WHERE id = $1
+									 AND (status = 'failed' OR (status = 'running' AND updated < now() - $2 * interval '1 second'))
+							   RETURNING id, guid, uuid, user_id, name, stages, language, step, result, error, status, created, updated, completed`,
+		pipeline.ID, stale)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// FindInterruptedPipelines конвейеры running, не обновлявшиеся дольше stale секунд, старые первыми
+func (r *AnalysisRepository) FindInterruptedPipelines(stale int) (pipelines []structs.Pipeline, err error) {
+	defer func() {
+		err = errs.WrapWithStackIfErr(">> FindInterruptedPipelines > Ошибка поиска прерванных конвейеров", err)
+	}()
+
+	err = r.DB.Select(&pipelines, `SELECT id,
+											   guid,
+											   uuid,
+											   user_id,
+											   name,
+											   stages,
+											   language,
+											   step,
+											   result,
+											   error,
+											   status,
+											   created,
+											   updated,
+											   completed
+										  FROM pipelines p
+										 WHERE p.status = 'running'
+										   AND p.updated < now() - $1 * interval '1 second'
+									  ORDER BY p.updated`, stale)
+
+	return
+}
+
+func (r *AnalysisRepository) FindPipeline(id int, guid string, userID int) (pipeline *structs.Pipeline, err error) {
+	defer func() {
+		err = errs.WrapWithStackIfErr(">> FindPipeline > Ошибка поиска конвейера", err)
+	}()
+
+	var p structs.Pipeline
+	err = r.DB.Get(&p, `SELECT id,
+									guid,
+									uuid,
+									user_id,
+									name,
+									stages,
+									language,
+									step,
+									result,
+									error,
+									status,
+									created,
+									updated,
+									completed
+							   FROM pipelines p
+							  WHERE p.id = $1
+								AND p.guid = $2
+								AND p.user_id = $3`, id, guid, userID)
+	if err != nil {
+		return
+	}
+
+	return &p, nil
+}
+
+func (r *AnalysisRepository) FindPipelinesByGUID(guid string, userID int) (pipelines []structs.Pipeline, err error) {
+	defer func() {
+		err = errs.WrapWithStackIfErr(">> FindPipelinesByGUID > Ошибка поиска конвейеров загрузки", err)
+	}()
+
+	logger := logdoc.GetLogger()
+
+	err = r.DB.Select(&pipelines, `SELECT id,
+											   guid,
+											   uuid,
+											   user_id,
+											   name,
+											   stages,
+											   language,
+											   step,
+											   result,
+											   error,
+											   status,
+											   created,
+											   updated,
+											   completed
+										  FROM pipelines p
+										 WHERE p.guid = $1
+										   AND p.user_id = $2
+									  ORDER BY p.id`, guid, userID)
+	if err != nil {
+		logger.Warn(fmt.Sprintf(">> FindPipelinesByGUID > Ошибка поиска конвейеров загрузки guid: %s, userId: %d", guid, userID))
+	}
+
+	return
+}
+
+// FindPipelineResults результаты этапов конвейера в порядке этапов, completed - только завершенные
+func (r *AnalysisRepository) FindPipelineResults(pipelineID int, completed bool) (results []structs.AnalysisResult, err error) {
+	defer func() {
+		err = errs.WrapWithStackIfErr(">> FindPipelineResults > Ошибка поиска результатов этапов конвейера", err)
+	}()
+
+	err = r.DB.Select(&results, `SELECT id,
+											 guid,
+											 uuid,
+											 user_id,
+											 prompt_id,
+											 prompt_stage,
+											 prompt_version,
+											 language,
+											 provider,
+											 model,
+											 result,
+											 error,
+											 prompt_tokens,
+											 completion_tokens,
+											 status,
+											 created,
+											 completed,
+											 pipeline_id,
+											 pipeline_step,
+											 layer_uuid,
+											 file_name
+										FROM analysis_results a
+									   WHERE a.pipeline_id = $1
+										 AND (NOT $2 OR a.status = 'completed')
+									ORDER BY a.pipeline_step, a.id`, pipelineID, completed)
+
+	return
+}
@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"fmt"
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"sse-demo-core/internal/app/structs"
+	"sse-demo-core/internal/errs"
+)
+
+type ChunkRepository struct {
+	DB *sqlx.DB
+}
+
+func New(db *sqlx.DB) *ChunkRepository {
+	return &ChunkRepository{db}
+}
+
+// chunkColumns поля фрагмента вместе с uuid и именем файла слоя
+const chunkColumns = `c.id,
+					  c.layer_id,
+					  c.guid,
+					  l.uuid,
+					  c.user_id,
+					  l.layer_name AS file_name,
+					  c.chunk_index,
+					  c.section,
+					  c.page,
+					  c.content,
+					  c.tokens,
+					  c.model`
+
+// CreateChunks сохраняет фрагменты слоя одной транзакцией
+func (r *ChunkRepository) CreateChunks(chunks []structs.LayerChunk) (err error) {
+	defer func() {
+		err = errs.WrapWithStackIfErr(">> CreateChunks > Ошибка сохранения фрагментов слоя", err)
+	}()
+
+	tx, err := r.DB.Beginx()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	for _, chunk := range chunks {
+		_, err = tx.NamedExec(`INSERT INTO layer_chunks (layer_id, guid, user_id, chunk_index, section, page, content, tokens,
+										   model, embedding)
+									VALUES (:layer_id, :guid, :user_id, :chunk_index, :section, :page, :content, :tokens,
+											:model, :embedding)`, chunk)
+		if err != nil {
+			return
+		}
+	}
+
+	return tx.Commit()
+}
+
+// VectorAvailable есть ли столбец embedding_vector, который миграция создает при установленном расширении pgvector
+func (r *ChunkRepository) VectorAvailable() (available bool, err error) {
+	defer func() {
+		err = errs.WrapWithStackIfErr(">> VectorAvailable > Ошибка проверки расширения pgvector", err)
+	}()
+
+	err = r.DB.Get(&available, `SELECT exists(SELECT 1
+											 FROM information_schema.columns
+											WHERE table_schema = current_schema()
+											  AND table_name = 'layer_chunks'
+											  AND column_name = 'embedding_vector')`)
+
+	return
+}
+
+// SearchChunks topK фрагментов пользователя, ближайших к embedding по косинусному расстоянию pgvector.
+// Сравниваются только эмбеддинги модели model, guids - только фрагменты этих загрузок, пустой - всех.
+// Выражение и условие совпадают с индексами hnsw миграции, для размерностей без индекса идет перебор в базе
+func (r *ChunkRepository) SearchChunks(userID int, model string, embedding []float32, guids []string, topK int) (chunks []structs.LayerChunk, err error) {
+	defer func() {
+		err = errs.WrapWithStackIfErr(">> SearchChunks > Ошибка поиска фрагментов загрузок", err)
+	}()
+
+	logger := logdoc.GetLogger()
+
+	err = r.DB.Select(&chunks, fmt.Sprintf(`SELECT `+chunkColumns+`,
+										   1 - (c.embedding_vector::vector(%[1]d) <=> $3::real[]::vector(%[1]d)) AS score
+									  FROM layer_chunks c
+									  JOIN user_layers l ON l.id = c.layer_id
+									 WHERE vector_dims(c.embedding_vector) = %[1]d
+									   AND c.user_id = $1
+									   AND c.model = $2
+									   AND (coalesce(cardinality($4::text[]), 0) = 0 OR c.guid = ANY ($4::text[]))
+								  ORDER BY c.embedding_vector::vector(%[1]d) <=> $3::real[]::vector(%[1]d)
+									 LIMIT $5`, len(embedding)), userID, model, pq.Float32Array(embedding), pq.StringArray(guids), topK)
+	if err != nil {
+		logger.Warn(fmt.Sprintf(">> SearchChunks > Ошибка поиска фрагментов загрузок userId: %d, model: %s", userID, model))
+	}
+
+	return
+}
+
+// FindChunkEmbeddings страница эмбеддингов модели model фрагментов пользователя для поиска перебором, без содержимого
+// фрагментов: не больше limit фрагментов с id больше afterID в порядке id
+func (r *ChunkRepository) FindChunkEmbeddings(userID int, model string, guids []string, afterID int, limit int) (chunks []structs.LayerChunk, err error) {
+	defer func() {
+		err = errs.WrapWithStackIfErr(">> FindChunkEmbeddings > Ошибка поиска эмбеддингов фрагментов", err)
+	}()
+
+	err = r.DB.Select(&chunks, `SELECT c.id,
+										   c.embedding
+									  FROM layer_chunks c
+									 WHERE c.user_id = $1
+									   AND c.model = $2
+									   AND (coalesce(cardinality($3::text[]), 0) = 0 OR c.guid = ANY ($3::text[]))
+									   AND c.id > $4
+								  ORDER BY c.id
+									 LIMIT $5`,
+		userID, model, pq.StringArray(guids), afterID, limit)
+
+	return
+}
+
+func (r *ChunkRepository) FindChunksByIDs(ids []int64) (chunks []structs.LayerChunk, err error) {
+	defer func() {
+		err = errs.WrapWithStackIfErr(">> FindChunksByIDs > Ошибка поиска фрагментов", err)
+	}()
+
+	err = r.DB.Select(&chunks, `SELECT `+chunkColumns+`
+									  FROM layer_chunks c
+									  JOIN user_layers l ON l.id = c.layer_id
+									 WHERE c.id = ANY ($1::bigint[])`, pq.Int64Array(ids))
+
+	return
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"fmt"
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"github.com/jmoiron/sqlx"
+	"sse-demo-core/internal/app/structs"
+	"sse-demo-core/internal/app/utils"
+	"sse-demo-core/internal/errs"
+)
+
+type LayerRepository struct {
+	DB *sqlx.DB
+}
+
+func New(db *sqlx.DB) *LayerRepository {
+	return &LayerRepository{db}
+}
+
+func (r *LayerRepository) CreateLayer(layer *structs.UserLayer) (id int, err error) {
+	defer func() {
+		err = errs.WrapWithStackIfErr(">> CreateLayer > Ошибка сохранения слоя данных пользователя", err)
+	}()
+
+	err = r.DB.Get(&id, `INSERT INTO user_layers (guid, uuid, user_id, layer_name, source_size, source_name, source_type,
+									 source_hash, ocr_confidence, tokens, redactions, language, source_file_link, source_data,
+									 optimized_data, status)
+							  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+						   RETURNING id`,
+		layer.GUID,
+		layer.UUID,
+		layer.UserID,
+		layer.LayerName,
+		layer.SourceSize,
+		layer.SourceName,
+		layer.SourceType,
+		layer.SourceHash,
+		layer.OCRConfidence,
+		layer.Tokens,
+		utils.Ternary(layer.Redactions == "", "{}", layer.Redactions),
+		layer.Language,
+		layer.SourceFileLink,
+		layer.SourceData,
+		layer.OptimizedData,
+		layer.Status)
+
+	return
+}
+
+func (r *LayerRepository) FindLayersByGUID(guid string, userID int) (layers []structs.UserLayer, err error) {
+	defer func() {
+		err = errs.WrapWithStackIfErr(">> FindLayersByGUID > Ошибка поиска слоев данных загрузки", err)
+	}()
+
+	logger := logdoc.GetLogger()
+
+	err = r.DB.Select(&layers, `SELECT id,
+											guid,
+											uuid,
+											user_id,
+											layer_name,
+											source_size,
+											source_name,
+											source_type,
+											source_hash,
+											ocr_confidence,
+											tokens,
+											redactions,
+											language,
+											source_file_link,
+											source_data,
+											optimized_data,
+											openai_file_id,
+											assistant_id,
+											thread_id,
+											loaded,
+											status
+									   FROM user_layers l
+									  WHERE l.guid = $1
+									    AND l.user_id = $2
+								   ORDER BY l.id`, guid, userID)
+	if err != nil {
+		logger.Warn(fmt.Sprintf(">> FindLayersByGUID > Ошибка поиска слоев данных загрузки guid: %s, userId: %d", guid, userID))
+	}
+
+	return
+}
+
+// UpdateLayerFile сохраняет файл ассистентов, в который загружено содержимое слоя, и ассистента, к которому он подключен
+func (r *LayerRepository) UpdateLayerFile(id int, fileID string, assistantID string) (err error) {
+	defer func() {
+		err = errs.WrapWithStackIfErr(">> UpdateLayerFile > Ошибка обновления файла ассистентов слоя", err)
+	}()
+
+	_, err = r.DB.Exec(`UPDATE user_layers
+							   SET openai_file_id = $2,
+								   assistant_id = $3
+							 WHERE id = $1`, id, fileID, assistantID)
+
+	return
+}
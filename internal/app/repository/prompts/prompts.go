@@ -0,0 +1,250 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"github.com/jmoiron/sqlx"
+	"sse-demo-core/internal/app/structs"
+	"sse-demo-core/internal/errs"
+)
+
+type PromptRepository struct {
+	DB *sqlx.DB
+}
+
+func New(db *sqlx.DB) *PromptRepository {
+	return &PromptRepository{db}
+}
+
+// FindPromptByStage ищет промпт этапа для языка документа, при отсутствии - промпт этапа без языка
+func (r *PromptRepository) FindPromptByStage(stage int, language string) (prompt *structs.Prompt, err error) {
+	defer func() {
+		err = errs.WrapWithStackIfErr(">> FindPromptByStage > Ошибка поиска промпта этапа", err)
+	}()
+
+	logger := logdoc.GetLogger()
+
+	var p structs.Prompt
+	err = r.DB.Get(&p, `SELECT id,
+										name,
+										prompt_text,
+										prompt_stage,
+										language,
+										version,
+										updated_at
+								   FROM prompts p
+								  WHERE p.prompt_stage = $1
+								    AND p.language IN ($2, '')
+								    AND p.deleted_at IS NULL
+							   ORDER BY p.language = $2 DESC, p.id
+								  LIMIT 1`, stage, language)
+	if err != nil {
+		logger.Warn(fmt.Sprintf(">> FindPromptByStage > Ошибка поиска промпта этапа: %d, язык: %s", stage, language))
+		return
+	}
+
+	prompt = &p
+	return
+}
+
+// FindPrompts промпты этапа stage, 0 - всех этапов
+func (r *PromptRepository) FindPrompts(stage int) (prompts []structs.Prompt, err error) {
+	defer func() {
+		err = errs.WrapWithStackIfErr(">> FindPrompts > Ошибка поиска промптов", err)
+	}()
+
+	err = r.DB.Select(&prompts, `SELECT id,
+											 name,
+											 prompt_text,
+											 prompt_stage,
+											 language,
+											 version,
+											 updated_at
+										FROM prompts p
+									   WHERE ($1 = 0 OR p.prompt_stage = $1)
+										 AND p.deleted_at IS NULL
+									ORDER BY p.prompt_stage, p.language, p.id`, stage)
+
+	return
+}
+
+func (r *PromptRepository) FindPromptByID(id int) (prompt *structs.Prompt, err error) {
+	defer func() {
+		err = errs.WrapWithStackIfErr(">> FindPromptByID > Ошибка поиска промпта по id", err)
+	}()
+
+	var p structs.Prompt
+	err = r.DB.Get(&p, `SELECT id,
+										name,
+										prompt_text,
+										prompt_stage,
+										language,
+										version,
+										updated_at
+								   FROM prompts p
+								  WHERE p.id = $1
+								    AND p.deleted_at IS NULL`, id)
+	if err != nil {
+		return
+	}
+
+	return &p, nil
+}
+
+// CreatePrompt сохраняет промпт и его первую версию
+func (r *PromptRepository) CreatePrompt(prompt *structs.Prompt, userID int) (err error) {
+	defer func() {
+		err = errs.WrapWithStackIfErr(">> CreatePrompt > Ошибка сохранения промпта", err)
+	}()
+
+	tx, err := r.DB.Beginx()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	err = tx.Get(prompt, `INSERT INTO prompts (name, prompt_text, prompt_stage, language)
+							   VALUES ($1, $2, $3, $4)
+							RETURNING id, name, prompt_text, prompt_stage, language, version, updated_at`,
+		prompt.Name,
+		prompt.PromptText,
+		prompt.PromptStage,
+		prompt.Language)
+	if err != nil {
+		return
+	}
+
+	if err = createVersion(tx, prompt, userID); err != nil {
+		return
+	}
+
+	err = tx.Commit()
+	return
+}
+
+// UpdatePrompt сохраняет изменения промпта новой версией, false - промпт не найден
+func (r *PromptRepository) UpdatePrompt(prompt *structs.Prompt, userID int) (found bool, err error) {
+	defer func() {
+		err = errs.WrapWithStackIfErr(">> UpdatePrompt > Ошибка обновления промпта", err)
+	}()
+
+	tx, err := r.DB.Beginx()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	err = tx.Get(prompt, `UPDATE prompts
+							   SET name = $2,
+								   prompt_text = $3,
+								   prompt_stage = $4,
+								   language = $5,
+								   version = version + 1,
+								   updated_at = now()
+							 WHERE id = $1
+							   AND deleted_at IS NULL
+						 RETURNING id, name, prompt_text, prompt_stage, language, version, updated_at`,
+		prompt.ID,
+		prompt.Name,
+		prompt.PromptText,
+		prompt.PromptStage,
+		prompt.Language)
+	if errors.Is(err, sql.ErrNoRows) {
+		_ = tx.Rollback()
+		return false, nil
+	}
+	if err != nil {
+		return
+	}
+
+	if err = createVersion(tx, prompt, userID); err != nil {
+		return
+	}
+
+	err = tx.Commit()
+	return err == nil, err
+}
+
+// DeletePrompt помечает промпт удаленным, версии промпта сохраняются, false - промпт не найден
+func (r *PromptRepository) DeletePrompt(id int) (found bool, err error) {
+	defer func() {
+		err = errs.WrapWithStackIfErr(">> DeletePrompt > Ошибка удаления промпта", err)
+	}()
+
+	res, err := r.DB.Exec(`UPDATE prompts SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL`, id)
+	if err != nil {
+		return
+	}
+	rows, err := res.RowsAffected()
+	return rows > 0, err
+}
+
+// FindPromptVersions версии промпта, в том числе удаленного, новые первыми
+func (r *PromptRepository) FindPromptVersions(promptID int) (versions []structs.PromptVersion, err error) {
+	defer func() {
+		err = errs.WrapWithStackIfErr(">> FindPromptVersions > Ошибка поиска версий промпта", err)
+	}()
+
+	err = r.DB.Select(&versions, `SELECT id,
+											  prompt_id,
+											  version,
+											  name,
+											  prompt_text,
+											  prompt_stage,
+											  language,
+											  user_id,
+											  created_at
+										 FROM prompt_versions v
+										WHERE v.prompt_id = $1
+									 ORDER BY v.version DESC`, promptID)
+
+	return
+}
+
+func (r *PromptRepository) FindPromptVersion(promptID int, version int) (promptVersion *structs.PromptVersion, err error) {
+	defer func() {
+		err = errs.WrapWithStackIfErr(">> FindPromptVersion > Ошибка поиска версии промпта", err)
+	}()
+
+	var v structs.PromptVersion
+	err = r.DB.Get(&v, `SELECT id,
+										prompt_id,
+										version,
+										name,
+										prompt_text,
+										prompt_stage,
+										language,
+										user_id,
+										created_at
+								   FROM prompt_versions v
+								  WHERE v.prompt_id = $1
+								    AND v.version = $2`, promptID, version)
+	if err != nil {
+		return
+	}
+
+	return &v, nil
+}
+
+func createVersion(tx *sqlx.Tx, prompt *structs.Prompt, userID int) error {
+	_, err := tx.Exec(`INSERT INTO prompt_versions (prompt_id, version, name, prompt_text, prompt_stage, language, user_id)
+							VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		prompt.ID,
+		prompt.Version,
+		prompt.Name,
+		prompt.PromptText,
+		prompt.PromptStage,
+		prompt.Language,
+		userID)
+	return err
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"fmt"
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"github.com/jmoiron/sqlx"
+	"sse-demo-core/internal/app/structs"
+	"sse-demo-core/internal/errs"
+)
+
+type RedactionRepository struct {
+	DB *sqlx.DB
+}
+
+func New(db *sqlx.DB) *RedactionRepository {
+	return &RedactionRepository{db}
+}
+
+// CreateTokens сохраняет заменители слоя загрузки
+func (r *RedactionRepository) CreateTokens(tokens []structs.RedactionToken) (err error) {
+	defer func() {
+		err = errs.WrapWithStackIfErr(">> CreateTokens > Ошибка сохранения заменителей персональных данных", err)
+	}()
+
+	tx, err := r.DB.Beginx()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	for _, t := range tokens {
+		_, err = tx.Exec(`INSERT INTO redaction_tokens (guid, uuid, user_id, placeholder, kind, value, encrypted)
+							   VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			t.GUID,
+			t.UUID,
+			t.UserID,
+			t.Placeholder,
+			t.Kind,
+			t.Value,
+			t.Encrypted)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	return
+}
+
+func (r *RedactionRepository) FindTokensByGUID(guid string, userID int) (tokens []structs.RedactionToken, err error) {
+	defer func() {
+		err = errs.WrapWithStackIfErr(">> FindTokensByGUID > Ошибка поиска заменителей персональных данных", err)
+	}()
+
+	logger := logdoc.GetLogger()
+
+	err = r.DB.Select(&tokens, `SELECT id,
+											guid,
+											uuid,
+											user_id,
+											placeholder,
+											kind,
+											value,
+											encrypted,
+											created
+									   FROM redaction_tokens t
+									  WHERE t.guid = $1
+									    AND t.user_id = $2
+								   ORDER BY t.id`, guid, userID)
+	if err != nil {
+		logger.Warn(fmt.Sprintf(">> FindTokensByGUID > Ошибка поиска заменителей guid: %s, userId: %d", guid, userID))
+	}
+
+	return
+}
+
+// FindTokensByUUID заменители одного слоя загрузки
+func (r *RedactionRepository) FindTokensByUUID(guid string, uid string, userID int) (tokens []structs.RedactionToken, err error) {
+	defer func() {
+		err = errs.WrapWithStackIfErr(">> FindTokensByUUID > Ошибка поиска заменителей персональных данных слоя", err)
+	}()
+
+	logger := logdoc.GetLogger()
+
+	err = r.DB.Select(&tokens, `SELECT id,
+											guid,
+											uuid,
+											user_id,
+											placeholder,
+											kind,
+											value,
+											encrypted,
+											created
+									   FROM redaction_tokens t
+									  WHERE t.guid = $1
+									    AND t.uuid = $2
+									    AND t.user_id = $3
+								   ORDER BY t.id`, guid, uid, userID)
+	if err != nil {
+		logger.Warn(fmt.Sprintf(">> FindTokensByUUID > Ошибка поиска заменителей guid: %s, uuid: %s, userId: %d", guid, uid, userID))
+	}
+
+	return
+}
@@ -0,0 +1,229 @@
+package repository
+
+import (
+	"fmt"
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"github.com/jmoiron/sqlx"
+	"sse-demo-core/internal/app/structs"
+	"sse-demo-core/internal/app/utils"
+	"sse-demo-core/internal/errs"
+)
+
+type ThreadRepository struct {
+	DB *sqlx.DB
+}
+
+func New(db *sqlx.DB) *ThreadRepository {
+	return &ThreadRepository{db}
+}
+
+// CreateThread сохраняет тред вместе с подключенными к нему файлами
+func (r *ThreadRepository) CreateThread(thread *structs.Thread, files []structs.ThreadFile) (id int, err error) {
+	defer func() {
+		err = errs.WrapWithStackIfErr(">> CreateThread > Ошибка сохранения треда", err)
+	}()
+
+	tx, err := r.DB.Beginx()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	err = tx.Get(&id, `INSERT INTO threads (user_id, thread_name, thread_id, object, guid, metadata)
+							VALUES ($1, $2, $3, $4, $5, $6)
+						 RETURNING id`,
+		thread.UserID,
+		thread.ThreadName,
+		thread.ThreadID,
+		thread.Object,
+		thread.GUID,
+		utils.Ternary(len(thread.Metadata) == 0, "{}", string(thread.Metadata)))
+	if err != nil {
+		return
+	}
+
+	for _, file := range files {
+		_, err = tx.Exec(`INSERT INTO thread_files (user_id, thread_id, file_id, file_name, purpose, bytes)
+							   VALUES ($1, $2, $3, $4, $5, $6)`,
+			file.UserID,
+			file.ThreadID,
+			file.FileID,
+			file.FileName,
+			file.Purpose,
+			file.Bytes)
+		if err != nil {
+			return
+		}
+	}
+
+	err = tx.Commit()
+	return
+}
+
+func (r *ThreadRepository) FindThread(threadID string, userID int) (thread *structs.Thread, err error) {
+	defer func() {
+		err = errs.WrapWithStackIfErr(">> FindThread > Ошибка поиска треда", err)
+	}()
+
+	var t structs.Thread
+	err = r.DB.Get(&t, `SELECT t.id,
+								   t.user_id,
+								   t.thread_name,
+								   t.thread_id,
+								   t.object,
+								   t.guid,
+								   t.created_at,
+								   t.metadata,
+								   (SELECT count(*) FROM messages m WHERE m.thread_id = t.thread_id) AS messages_cnt
+							  FROM threads t
+							 WHERE t.thread_id = $1
+							   AND t.user_id = $2`, threadID, userID)
+	if err != nil {
+		return
+	}
+
+	return &t, nil
+}
+
+func (r *ThreadRepository) FindThreadsByUser(userID int) (threads []structs.Thread, err error) {
+	defer func() {
+		err = errs.WrapWithStackIfErr(">> FindThreadsByUser > Ошибка поиска тредов пользователя", err)
+	}()
+
+	logger := logdoc.GetLogger()
+
+	err = r.DB.Select(&threads, `SELECT t.id,
+											 t.user_id,
+											 t.thread_name,
+											 t.thread_id,
+											 t.object,
+											 t.guid,
+											 t.created_at,
+											 t.metadata,
+											 count(m.id) AS messages_cnt
+										FROM threads t
+								   LEFT JOIN messages m ON m.thread_id = t.thread_id
+									   WHERE t.user_id = $1
+									GROUP BY t.id
+									ORDER BY t.created_at DESC`, userID)
+	if err != nil {
+		logger.Warn(fmt.Sprintf(">> FindThreadsByUser > Ошибка поиска тредов пользователя userId: %d", userID))
+	}
+
+	return
+}
+
+func (r *ThreadRepository) FindThreadFiles(threadID string, userID int) (files []structs.ThreadFile, err error) {
+	defer func() {
+		err = errs.WrapWithStackIfErr(">> FindThreadFiles > Ошибка поиска файлов треда", err)
+	}()
+
+	err = r.DB.Select(&files, `SELECT id,
+										   user_id,
+										   thread_id,
+										   file_id,
+										   file_name,
+										   created_at,
+										   purpose,
+										   bytes
+									  FROM thread_files f
+									 WHERE f.thread_id = $1
+									   AND f.user_id = $2
+								  ORDER BY f.id`, threadID, userID)
+
+	return
+}
+
+// CreateMessage сохраняет сообщение треда, повторно полученное от провайдера сообщение не дублируется
+func (r *ThreadRepository) CreateMessage(message *structs.ThreadMessage) (err error) {
+	defer func() {
+		err = errs.WrapWithStackIfErr(">> CreateMessage > Ошибка сохранения сообщения треда", err)
+	}()
+
+	_, err = r.DB.Exec(`INSERT INTO messages (id, user_id, object, created_at, thread_id, role, prompt, assistant_id, run_id,
+											  content, file_ids, tokens, hidden, system, metadata)
+							 VALUES ($1, $2, $3, to_timestamp($4), $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+						ON CONFLICT (id) DO NOTHING`,
+		message.ID,
+		message.UserID,
+		message.Object,
+		message.CreatedAt,
+		message.ThreadID,
+		message.Role,
+		message.Prompt,
+		message.AssistantID,
+		message.RunID,
+		utils.Ternary(len(message.Content) == 0, "[]", string(message.Content)),
+		utils.Ternary(len(message.FileIDs) == 0, "[]", string(message.FileIDs)),
+		message.Tokens,
+		message.Hidden,
+		message.System,
+		utils.Ternary(len(message.Metadata) == 0, "{}", string(message.Metadata)))
+
+	return
+}
+
+func (r *ThreadRepository) FindMessagesByThread(threadID string, userID int) (messages []structs.ThreadMessage, err error) {
+	defer func() {
+		err = errs.WrapWithStackIfErr(">> FindMessagesByThread > Ошибка поиска сообщений треда", err)
+	}()
+
+	logger := logdoc.GetLogger()
+
+	err = r.DB.Select(&messages, `SELECT m.id,
+											  m.user_id,
+											  m.object,
+											  EXTRACT(epoch FROM m.created_at)::bigint AS created_at,
+											  m.thread_id,
+											  m.role,
+											  m.prompt,
+											  m.assistant_id,
+											  m.run_id,
+											  m.content,
+											  m.file_ids,
+											  coalesce(m.tokens, 0) AS tokens,
+											  m.hidden,
+											  m.system,
+											  m.metadata
+										 FROM messages m
+										WHERE m.thread_id = $1
+										  AND m.user_id = $2
+									 ORDER BY m.created_at, m.id`, threadID, userID)
+	if err != nil {
+		logger.Warn(fmt.Sprintf(">> FindMessagesByThread > Ошибка поиска сообщений треда threadId: %s, userId: %d", threadID, userID))
+	}
+
+	return
+}
+
+// FindMessagesWithoutTokens сообщения, токены которых не посчитаны при записи, в порядке создания
+func (r *ThreadRepository) FindMessagesWithoutTokens(limit int) (messages []structs.ThreadMessage, err error) {
+	defer func() {
+		err = errs.WrapWithStackIfErr(">> FindMessagesWithoutTokens > Ошибка поиска сообщений без токенов", err)
+	}()
+
+	err = r.DB.Select(&messages, `SELECT m.id,
+											  m.thread_id,
+											  m.role,
+											  m.prompt
+										 FROM messages m
+										WHERE m.tokens IS NULL
+									 ORDER BY m.created_at, m.id
+										LIMIT $1`, limit)
+
+	return
+}
+
+func (r *ThreadRepository) UpdateMessageTokens(id string, tokens int) (err error) {
+	defer func() {
+		err = errs.WrapWithStackIfErr(">> UpdateMessageTokens > Ошибка обновления токенов сообщения", err)
+	}()
+
+	_, err = r.DB.Exec(`UPDATE messages SET tokens = $2 WHERE id = $1`, id, tokens)
+
+	return
+}
@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"fmt"
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"github.com/jmoiron/sqlx"
+	"sse-demo-core/internal/app/structs"
+	"sse-demo-core/internal/errs"
+	"strings"
+)
+
+// периоды статистики, имя периода - префикс колонок, которые sqlx раскладывает по полям UserUsage
+var periods = []struct {
+	name   string
+	filter string
+}{
+	{"current_month", "e.created >= date_trunc('month', now())"},
+	{"last_month", "e.created >= date_trunc('month', now()) - interval '1 month' AND e.created < date_trunc('month', now())"},
+	{"year", "e.created >= date_trunc('year', now())"},
+	{"total", "true"},
+}
+
+// usageColumns агрегаты расхода usage_events e по всем периодам
+func usageColumns() string {
+	var columns []string
+	for _, p := range periods {
+		columns = append(columns,
+			fmt.Sprintf(`count(*) FILTER (WHERE e.kind = 'upload' AND %s) AS "%s.uploads"`, p.filter, p.name),
+			fmt.Sprintf(`coalesce(sum(e.files) FILTER (WHERE %s), 0) AS "%s.files"`, p.filter, p.name),
+			fmt.Sprintf(`coalesce(sum(e.bytes) FILTER (WHERE %s), 0) AS "%s.bytes"`, p.filter, p.name),
+			fmt.Sprintf(`coalesce(sum(e.requests) FILTER (WHERE %s), 0) AS "%s.requests"`, p.filter, p.name),
+			fmt.Sprintf(`coalesce(sum(e.prompt_tokens + e.completion_tokens) FILTER (WHERE %s), 0) AS "%s.tokens"`, p.filter, p.name))
+	}
+	return strings.Join(columns, ",\n")
+}
+
+// messagesColumns количество сообщений m по периодам, в колонках UserMessagesStatistics
+func messagesColumns() string {
+	return `count(*) FILTER (WHERE m.created_at >= date_trunc('month', now())) AS current_month_count,
+			count(*) FILTER (WHERE m.created_at >= date_trunc('month', now()) - interval '1 month'
+							   AND m.created_at < date_trunc('month', now())) AS last_month_count,
+			count(*) FILTER (WHERE m.created_at >= date_trunc('year', now())) AS year_count,
+			count(*) AS total_count`
+}
+
+type UsageRepository struct {
+	DB *sqlx.DB
+}
+
+func New(db *sqlx.DB) *UsageRepository {
+	return &UsageRepository{db}
+}
+
+func (r *UsageRepository) CreateEvent(event *structs.UsageEvent) (err error) {
+	defer func() {
+		err = errs.WrapWithStackIfErr(">> CreateEvent > Ошибка сохранения расхода пользователя", err)
+	}()
+
+	_, err = r.DB.NamedExec(`INSERT INTO usage_events (user_id, kind, guid, files, bytes, requests, prompt_tokens, completion_tokens)
+								  VALUES (:user_id, :kind, :guid, :files, :bytes, :requests, :prompt_tokens, :completion_tokens)`, event)
+
+	return
+}
+
+func (r *UsageRepository) FindUserUsage(userID int) (usage *structs.UserUsage, err error) {
+	defer func() {
+		err = errs.WrapWithStackIfErr(">> FindUserUsage > Ошибка поиска расхода пользователя", err)
+	}()
+
+	logger := logdoc.GetLogger()
+
+	var u structs.UserUsage
+	err = r.DB.Get(&u, `SELECT $1::bigint AS user_id,
+`+usageColumns()+`
+						  FROM usage_events e
+						 WHERE e.user_id = $1`, userID)
+	if err != nil {
+		logger.Warn(fmt.Sprintf(">> FindUserUsage > Ошибка поиска расхода пользователя userId: %d", userID))
+		return
+	}
+
+	return &u, nil
+}
+
+// FindUsersUsage расход всех пользователей, у которых он был
+func (r *UsageRepository) FindUsersUsage() (usage []structs.UserUsage, err error) {
+	defer func() {
+		err = errs.WrapWithStackIfErr(">> FindUsersUsage > Ошибка поиска расхода пользователей", err)
+	}()
+
+	err = r.DB.Select(&usage, `SELECT e.user_id,
+									   coalesce(u.email, '') AS email,
+`+usageColumns()+`
+								  FROM usage_events e
+							 LEFT JOIN users u ON u.id = e.user_id
+							  GROUP BY e.user_id, u.email
+							  ORDER BY e.user_id`)
+
+	return
+}
+
+// FindUserMessagesStatistics количество сообщений пользователя в тредах ассистентов, без скрытых и системных
+func (r *UsageRepository) FindUserMessagesStatistics(userID int) (statistics *structs.UserMessagesStatistics, err error) {
+	defer func() {
+		err = errs.WrapWithStackIfErr(">> FindUserMessagesStatistics > Ошибка поиска статистики сообщений пользователя", err)
+	}()
+
+	var s structs.UserMessagesStatistics
+	err = r.DB.Get(&s, `SELECT $1::bigint AS user_id,
+									'' AS email,
+`+messagesColumns()+`
+							  FROM messages m
+							 WHERE m.user_id = $1
+							   AND m.role = 'user'
+							   AND NOT m.hidden
+							   AND NOT m.system`, userID)
+	if err != nil {
+		return
+	}
+
+	return &s, nil
+}
+
+func (r *UsageRepository) FindMessagesStatistics() (statistics []structs.UserMessagesStatistics, err error) {
+	defer func() {
+		err = errs.WrapWithStackIfErr(">> FindMessagesStatistics > Ошибка поиска статистики сообщений", err)
+	}()
+
+	err = r.DB.Select(&statistics, `SELECT m.user_id,
+											coalesce(u.email, '') AS email,
+`+messagesColumns()+`
+									   FROM messages m
+								  LEFT JOIN users u ON u.id = m.user_id
+									  WHERE m.role = 'user'
+									    AND NOT m.hidden
+									    AND NOT m.system
+								   GROUP BY m.user_id, u.email
+								   ORDER BY m.user_id`)
+
+	return
+}
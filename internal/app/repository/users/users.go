@@ -24,7 +24,8 @@ func (r *UserRepository) FindUserByID(id int) (user *structs.User, err error) {
 	logger := logdoc.GetLogger()
 
 	var u structs.User
-	err = r.DB.Get(&u, `SELECT id
+	err = r.DB.Get(&u, `SELECT id,
+										coalesce(role, '') as role
 									FROM users u
 								   WHERE u.id = $1`, id)
 	if err != nil || u.ID == 0 {
@@ -48,7 +49,8 @@ func (r *UserRepository) FindUserBySub(sub string) (user *structs.User, err erro
 	logger := logdoc.GetLogger()
 
 	var u structs.User
-	err = r.DB.Get(&u, `SELECT id
+	err = r.DB.Get(&u, `SELECT id,
+										coalesce(role, '') as role
 									FROM users u
 								   WHERE u.sub = $1`, sub)
 	if err != nil || u.ID == 0 {
@@ -0,0 +1,137 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"github.com/go-resty/resty/v2"
+	"github.com/gurkankaymak/hocon"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Policy повтор запросов к внешним сервисам с экспоненциальной задержкой и полным джиттером
+type Policy struct {
+	// MaxAttempts количество попыток, включая первую
+	MaxAttempts int
+	// InitialInterval верхняя граница задержки перед первым повтором, удваивается с каждой попыткой до MaxInterval
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	// MaxElapsed общее время всех попыток, повтор, который не успевает уложиться, не выполняется. 0 - без ограничения
+	MaxElapsed time.Duration
+}
+
+// FromConfig политика из блока retry конфига
+func FromConfig(config *hocon.Config) Policy {
+	return Policy{
+		MaxAttempts:     config.GetInt("retry.max_attempts"),
+		InitialInterval: time.Duration(config.GetInt("retry.initial_interval")) * time.Millisecond,
+		MaxInterval:     time.Duration(config.GetInt("retry.max_interval")) * time.Millisecond,
+		MaxElapsed:      time.Duration(config.GetInt("retry.max_elapsed")) * time.Second,
+	}
+}
+
+// Retryable повторяются ответы 429 и 5xx, кроме 501 - метод не поддерживается и повтор не поможет
+func Retryable(status int) bool {
+	return status == http.StatusTooManyRequests ||
+		(status >= http.StatusInternalServerError && status != http.StatusNotImplemented)
+}
+
+// Do вызывает fn, пока она возвращает сетевую ошибку или ответ с повторяемым статусом.
+// Ответ с ошибкой возвращается вызывающему как есть, чтобы он сформировал ошибку из тела ответа.
+// Ожидание между попытками прерывается отменой ctx
+func Do(ctx context.Context, p Policy, name string, fn func() (*resty.Response, error)) (*resty.Response, error) {
+	logger := logdoc.GetLogger()
+
+	attempts := p.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	started := time.Now()
+
+	for attempt := 1; ; attempt++ {
+		response, err := fn()
+
+		retryable := false
+		switch {
+		case err != nil:
+			// отмена и таймаут вызывающего не повторяем
+			retryable = ctx.Err() == nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+		case response != nil:
+			retryable = Retryable(response.StatusCode())
+		}
+		if !retryable || attempt >= attempts {
+			return response, err
+		}
+
+		wait := p.backoff(attempt)
+		if after, ok := retryAfter(response); ok {
+			wait = after
+		}
+		if p.MaxElapsed > 0 && time.Since(started)+wait > p.MaxElapsed {
+			logger.Warn(name, " request is not retried, retry in ", wait, " exceeds max elapsed time ", p.MaxElapsed)
+			return response, err
+		}
+
+		logger.Warn(name, " request attempt ", attempt, " of ", attempts, " failed, ", describe(response, err), ", retrying in ", wait)
+
+		// тело потокового ответа resty не читает и не закрывает
+		if response != nil && response.RawBody() != nil {
+			_ = response.RawBody().Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff полный джиттер: случайная задержка от 0 до min(MaxInterval, InitialInterval * 2^(attempt-1))
+func (p Policy) backoff(attempt int) time.Duration {
+	ceiling := p.InitialInterval
+	for i := 1; i < attempt && (p.MaxInterval <= 0 || ceiling < p.MaxInterval); i++ {
+		ceiling *= 2
+	}
+	if p.MaxInterval > 0 && ceiling > p.MaxInterval {
+		ceiling = p.MaxInterval
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1)) //nolint:gosec
+}
+
+// retryAfter задержка из заголовка Retry-After: секунды или HTTP дата
+func retryAfter(response *resty.Response) (time.Duration, bool) {
+	if response == nil {
+		return 0, false
+	}
+	value := response.Header().Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(at); wait > 0 {
+			return wait, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func describe(response *resty.Response, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return fmt.Sprintf("status %d", response.StatusCode())
+}
@@ -0,0 +1,118 @@
+package analysisservice
+
+import (
+	"github.com/jmoiron/sqlx"
+	arepository "sse-demo-core/internal/app/repository/analysis"
+	"sse-demo-core/internal/app/structs"
+)
+
+type AnalysisServiceImpl struct {
+	results arepository.AnalysisRepository
+}
+
+func New(db *sqlx.DB) *AnalysisServiceImpl {
+	arepo := arepository.New(db)
+	return &AnalysisServiceImpl{*arepo}
+}
+
+// StartAnalysis сохраняет запущенный анализ со статусом running
+func (s *AnalysisServiceImpl) StartAnalysis(result *structs.AnalysisResult) (int, error) {
+	result.Status = "running"
+	id, err := s.results.CreateResult(result)
+	if err != nil {
+		return 0, err
+	}
+	result.ID = id
+	return id, nil
+}
+
+func (s *AnalysisServiceImpl) CompleteAnalysis(result *structs.AnalysisResult) error {
+	result.Status = "completed"
+	return s.results.UpdateResult(result)
+}
+
+func (s *AnalysisServiceImpl) FailAnalysis(result *structs.AnalysisResult, cause error) error {
+	result.Status = "failed"
+	result.Error = cause.Error()
+	return s.results.UpdateResult(result)
+}
+
+func (s *AnalysisServiceImpl) FindUploadResults(guid string, userID int) ([]structs.AnalysisResult, error) {
+	return s.results.FindResultsByGUID(guid, userID)
+}
+
+// StartPipeline сохраняет запущенный конвейер со статусом running
+func (s *AnalysisServiceImpl) StartPipeline(pipeline *structs.Pipeline) (int, error) {
+	pipeline.Status = "running"
+	id, err := s.results.CreatePipeline(pipeline)
+	if err != nil {
+		return 0, err
+	}
+	pipeline.ID = id
+	return id, nil
+}
+
+// ResumePipeline продолжает упавший или завершившийся ошибкой конвейер с его сохраненного этапа
+func (s *AnalysisServiceImpl) ResumePipeline(pipeline *structs.Pipeline, stale int) (bool, error) {
+	return s.results.ResumePipeline(pipeline, stale)
+}
+
+// CompletePipelineStep отмечает этап step завершенным, при перезапуске конвейер продолжится со следующего
+func (s *AnalysisServiceImpl) CompletePipelineStep(pipeline *structs.Pipeline, step int) error {
+	pipeline.Step = step + 1
+	return s.results.UpdatePipeline(pipeline)
+}
+
+func (s *AnalysisServiceImpl) CompletePipeline(pipeline *structs.Pipeline) error {
+	pipeline.Status = "completed"
+	return s.results.UpdatePipeline(pipeline)
+}
+
+func (s *AnalysisServiceImpl) FailPipeline(pipeline *structs.Pipeline, cause error) error {
+	pipeline.Status = "failed"
+	pipeline.Error = cause.Error()
+	return s.results.UpdatePipeline(pipeline)
+}
+
+// FindInterruptedPipelines конвейеры, выполнявший которые процесс остановился
+func (s *AnalysisServiceImpl) FindInterruptedPipelines(stale int) ([]structs.Pipeline, error) {
+	return s.results.FindInterruptedPipelines(stale)
+}
+
+func (s *AnalysisServiceImpl) FindPipeline(id int, guid string, userID int) (*structs.Pipeline, error) {
+	return s.results.FindPipeline(id, guid, userID)
+}
+
+func (s *AnalysisServiceImpl) FindUploadPipelines(guid string, userID int) ([]structs.Pipeline, error) {
+	return s.results.FindPipelinesByGUID(guid, userID)
+}
+
+func (s *AnalysisServiceImpl) FindPipelineResults(pipelineID int, completed bool) ([]structs.AnalysisResult, error) {
+	return s.results.FindPipelineResults(pipelineID, completed)
+}
+
+// StartExtraction сохраняет запущенное извлечение данных слоя со статусом running
+func (s *AnalysisServiceImpl) StartExtraction(extraction *structs.Extraction) (int, error) {
+	extraction.Status = "running"
+	id, err := s.results.CreateExtraction(extraction)
+	if err != nil {
+		return 0, err
+	}
+	extraction.ID = id
+	return id, nil
+}
+
+func (s *AnalysisServiceImpl) CompleteExtraction(extraction *structs.Extraction) error {
+	extraction.Status = "completed"
+	return s.results.UpdateExtraction(extraction)
+}
+
+func (s *AnalysisServiceImpl) FailExtraction(extraction *structs.Extraction, cause error) error {
+	extraction.Status = "failed"
+	extraction.Error = cause.Error()
+	return s.results.UpdateExtraction(extraction)
+}
+
+func (s *AnalysisServiceImpl) FindUploadExtractions(guid string, userID int) ([]structs.Extraction, error) {
+	return s.results.FindExtractionsByGUID(guid, userID)
+}
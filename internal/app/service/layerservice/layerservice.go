@@ -0,0 +1,38 @@
+package layerservice
+
+import (
+	"github.com/jmoiron/sqlx"
+	lrepository "sse-demo-core/internal/app/repository/layers"
+	"sse-demo-core/internal/app/structs"
+)
+
+type LayerServiceImpl struct {
+	layers lrepository.LayerRepository
+}
+
+func New(db *sqlx.DB) *LayerServiceImpl {
+	lrepo := lrepository.New(db)
+	return &LayerServiceImpl{*lrepo}
+}
+
+func (s *LayerServiceImpl) SaveLayer(layer *structs.UserLayer) (int, error) {
+	id, err := s.layers.CreateLayer(layer)
+	if err != nil {
+		return 0, err
+	}
+	layer.ID = id
+	return id, nil
+}
+
+func (s *LayerServiceImpl) FindUploadLayers(guid string, userID int) ([]structs.UserLayer, error) {
+	return s.layers.FindLayersByGUID(guid, userID)
+}
+
+func (s *LayerServiceImpl) SetLayerFile(layer *structs.UserLayer, fileID string, assistantID string) error {
+	if err := s.layers.UpdateLayerFile(layer.ID, fileID, assistantID); err != nil {
+		return err
+	}
+	layer.OpenaiFileID = fileID
+	layer.AssistantID = assistantID
+	return nil
+}
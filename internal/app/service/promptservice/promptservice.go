@@ -0,0 +1,52 @@
+package promptservice
+
+import (
+	"github.com/jmoiron/sqlx"
+	prepository "sse-demo-core/internal/app/repository/prompts"
+	"sse-demo-core/internal/app/structs"
+)
+
+type PromptServiceImpl struct {
+	prompts prepository.PromptRepository
+}
+
+func New(db *sqlx.DB) *PromptServiceImpl {
+	prepo := prepository.New(db)
+	return &PromptServiceImpl{*prepo}
+}
+
+// FindStagePrompt промпт этапа анализа на языке документа, language определяется при загрузке файла
+func (s *PromptServiceImpl) FindStagePrompt(stage int, language string) (*structs.Prompt, error) {
+	return s.prompts.FindPromptByStage(stage, language)
+}
+
+// FindPrompts промпты этапа stage, 0 - всех этапов
+func (s *PromptServiceImpl) FindPrompts(stage int) ([]structs.Prompt, error) {
+	return s.prompts.FindPrompts(stage)
+}
+
+func (s *PromptServiceImpl) FindPrompt(id int) (*structs.Prompt, error) {
+	return s.prompts.FindPromptByID(id)
+}
+
+// CreatePrompt сохраняет промпт первой версией, userID - автор версии
+func (s *PromptServiceImpl) CreatePrompt(prompt *structs.Prompt, userID int) error {
+	return s.prompts.CreatePrompt(prompt, userID)
+}
+
+// UpdatePrompt сохраняет промпт следующей версией, false - промпт не найден
+func (s *PromptServiceImpl) UpdatePrompt(prompt *structs.Prompt, userID int) (bool, error) {
+	return s.prompts.UpdatePrompt(prompt, userID)
+}
+
+func (s *PromptServiceImpl) DeletePrompt(id int) (bool, error) {
+	return s.prompts.DeletePrompt(id)
+}
+
+func (s *PromptServiceImpl) FindPromptVersions(id int) ([]structs.PromptVersion, error) {
+	return s.prompts.FindPromptVersions(id)
+}
+
+func (s *PromptServiceImpl) FindPromptVersion(id int, version int) (*structs.PromptVersion, error) {
+	return s.prompts.FindPromptVersion(id, version)
+}
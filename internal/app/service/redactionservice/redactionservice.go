@@ -0,0 +1,120 @@
+package redactionservice
+
+import (
+	"errors"
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"github.com/gurkankaymak/hocon"
+	"github.com/jmoiron/sqlx"
+	"sse-demo-core/internal/app/redaction"
+	rrepository "sse-demo-core/internal/app/repository/redactions"
+	"sse-demo-core/internal/app/structs"
+)
+
+type RedactionServiceImpl struct {
+	tokens rrepository.RedactionRepository
+	// cipher шифрует исходные значения заменителей, nil - значения хранятся открытым текстом
+	cipher *redaction.Cipher
+}
+
+// New без redaction.key исходные персональные данные хранятся в redaction_tokens открытым текстом
+func New(config *hocon.Config, db *sqlx.DB) (*RedactionServiceImpl, error) {
+	logger := logdoc.GetLogger()
+
+	c, err := redaction.NewCipher(config.GetString("redaction.key"))
+	if err != nil {
+		return nil, err
+	}
+	if c == nil && config.GetBoolean("redaction.enabled") {
+		logger.Warn(">> redaction.key is not set, redacted personal data is stored in plaintext")
+	}
+
+	rrepo := rrepository.New(db)
+	return &RedactionServiceImpl{tokens: *rrepo, cipher: c}, nil
+}
+
+// SaveTokens сохраняет заменители слоя uid загрузки guid
+func (s *RedactionServiceImpl) SaveTokens(guid string, uid string, userID int, tokens []structs.RedactionToken) error {
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	stored := make([]structs.RedactionToken, len(tokens))
+	for i, t := range tokens {
+		t.GUID = guid
+		t.UUID = uid
+		t.UserID = userID
+		if s.cipher != nil {
+			value, err := s.cipher.Encrypt(t.Value)
+			if err != nil {
+				return err
+			}
+			t.Value, t.Encrypted = value, true
+		}
+		stored[i] = t
+	}
+	return s.tokens.CreateTokens(stored)
+}
+
+// Encrypt шифрует текст с персональными данными ключом redaction.key, без ключа возвращает текст как есть
+func (s *RedactionServiceImpl) Encrypt(text string) (string, error) {
+	if s.cipher == nil {
+		return text, nil
+	}
+	return s.cipher.Encrypt(text)
+}
+
+// Decrypt расшифровывает текст, зашифрованный Encrypt
+func (s *RedactionServiceImpl) Decrypt(text string) (string, error) {
+	if s.cipher == nil {
+		return text, nil
+	}
+	return s.cipher.Decrypt(text)
+}
+
+// Restore возвращает исходные персональные данные загрузки вместо заменителей в тексте по всем слоям загрузки
+func (s *RedactionServiceImpl) Restore(guid string, userID int, text string) (string, error) {
+	tokens, err := s.FindTokens(guid, "", userID)
+	if err != nil {
+		return "", err
+	}
+	return redaction.Restore(text, tokens), nil
+}
+
+// RestoreLayer возвращает исходные персональные данные вместо заменителей в тексте одного слоя uid
+func (s *RedactionServiceImpl) RestoreLayer(guid string, uid string, userID int, text string) (string, error) {
+	tokens, err := s.FindTokens(guid, uid, userID)
+	if err != nil {
+		return "", err
+	}
+	return redaction.Restore(text, tokens), nil
+}
+
+// FindTokens заменители слоя uid загрузки guid с расшифрованными значениями, пустой uid - всех слоев загрузки.
+// Нужны, чтобы восстанавливать ответ, приходящий фрагментами, не читая заменители на каждый фрагмент
+func (s *RedactionServiceImpl) FindTokens(guid string, uid string, userID int) ([]structs.RedactionToken, error) {
+	var tokens []structs.RedactionToken
+	var err error
+	if uid == "" {
+		tokens, err = s.tokens.FindTokensByGUID(guid, userID)
+	} else {
+		tokens, err = s.tokens.FindTokensByUUID(guid, uid, userID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range tokens {
+		if !tokens[i].Encrypted {
+			continue
+		}
+		if s.cipher == nil {
+			return nil, errors.New("redaction tokens are encrypted, but redaction.key is not set")
+		}
+		value, err := s.cipher.Decrypt(tokens[i].Value)
+		if err != nil {
+			return nil, err
+		}
+		tokens[i].Value = value
+	}
+	return tokens, nil
+}
@@ -0,0 +1,120 @@
+package searchservice
+
+import (
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"github.com/gurkankaymak/hocon"
+	"github.com/jmoiron/sqlx"
+	"math"
+	"sort"
+	crepository "sse-demo-core/internal/app/repository/chunks"
+	"sse-demo-core/internal/app/structs"
+)
+
+type SearchServiceImpl struct {
+	chunks crepository.ChunkRepository
+	// vector поиск через pgvector, иначе перебором эмбеддингов пользователя
+	vector bool
+	// page сколько эмбеддингов читать из базы за раз при поиске перебором
+	page int
+}
+
+// New проверяет, установлено ли расширение pgvector, search.pgvector = false - всегда искать перебором
+func New(config *hocon.Config, db *sqlx.DB) *SearchServiceImpl {
+	logger := logdoc.GetLogger()
+
+	crepo := crepository.New(db)
+	s := &SearchServiceImpl{chunks: *crepo, page: config.GetInt("search.page")}
+	if s.page <= 0 {
+		s.page = 1000
+	}
+
+	if config.GetBoolean("search.pgvector") {
+		available, err := crepo.VectorAvailable()
+		if err != nil {
+			logger.Warn(">> error checking pgvector extension, falling back to brute force search, ", err)
+		}
+		s.vector = available
+	}
+	logger.Info(">> vector search uses pgvector: ", s.vector)
+
+	return s
+}
+
+func (s *SearchServiceImpl) SaveChunks(chunks []structs.LayerChunk) error {
+	return s.chunks.CreateChunks(chunks)
+}
+
+// Search topK фрагментов пользователя, ближайших к embedding модели model, в загрузках guids, пустой - во всех
+func (s *SearchServiceImpl) Search(userID int, model string, embedding []float32, guids []string, topK int) ([]structs.LayerChunk, error) {
+	if s.vector {
+		return s.chunks.SearchChunks(userID, model, embedding, guids, topK)
+	}
+
+	// эмбеддинги читаются страницами, в памяти остаются страница и topK лучших
+	scores := make(map[int64]float64, topK)
+	var ids []int64
+	for afterID := 0; ; {
+		candidates, err := s.chunks.FindChunkEmbeddings(userID, model, guids, afterID, s.page)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range candidates {
+			if len(c.Embedding) == len(embedding) {
+				scores[int64(c.ID)] = cosine(c.Embedding, embedding)
+				ids = append(ids, int64(c.ID))
+			}
+		}
+		sort.Slice(ids, func(i, j int) bool {
+			if scores[ids[i]] != scores[ids[j]] {
+				return scores[ids[i]] > scores[ids[j]]
+			}
+			return ids[i] < ids[j]
+		})
+		if len(ids) > topK {
+			for _, id := range ids[topK:] {
+				delete(scores, id)
+			}
+			ids = ids[:topK]
+		}
+
+		if len(candidates) < s.page {
+			break
+		}
+		afterID = candidates[len(candidates)-1].ID
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	chunks, err := s.chunks.FindChunksByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	// фрагменты из базы приходят без порядка близости
+	for i := range chunks {
+		chunks[i].Score = scores[int64(chunks[i].ID)]
+	}
+	sort.Slice(chunks, func(i, j int) bool {
+		if chunks[i].Score != chunks[j].Score {
+			return chunks[i].Score > chunks[j].Score
+		}
+		return chunks[i].ID < chunks[j].ID
+	})
+	return chunks, nil
+}
+
+// cosine косинусная близость векторов одной размерности, 0 для нулевого вектора
+func cosine(a, b []float32) float64 {
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
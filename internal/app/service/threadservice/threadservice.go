@@ -0,0 +1,58 @@
+package threadservice
+
+import (
+	"github.com/jmoiron/sqlx"
+	"sse-demo-core/internal/app/chunker"
+	trepository "sse-demo-core/internal/app/repository/threads"
+	"sse-demo-core/internal/app/structs"
+	"strings"
+)
+
+// codeFences разметка блоков кода в сообщениях ассистента
+var codeFences = strings.NewReplacer("```python\n", "", "```", "")
+
+type ThreadServiceImpl struct {
+	threads trepository.ThreadRepository
+	chunker *chunker.Chunker
+}
+
+func New(db *sqlx.DB, chunks *chunker.Chunker) *ThreadServiceImpl {
+	trepo := trepository.New(db)
+	return &ThreadServiceImpl{*trepo, chunks}
+}
+
+func (s *ThreadServiceImpl) CreateThread(thread *structs.Thread, files []structs.ThreadFile) (int, error) {
+	id, err := s.threads.CreateThread(thread, files)
+	if err != nil {
+		return 0, err
+	}
+	thread.ID = id
+	return id, nil
+}
+
+func (s *ThreadServiceImpl) FindThread(threadID string, userID int) (*structs.Thread, error) {
+	return s.threads.FindThread(threadID, userID)
+}
+
+func (s *ThreadServiceImpl) FindUserThreads(userID int) ([]structs.Thread, error) {
+	return s.threads.FindThreadsByUser(userID)
+}
+
+func (s *ThreadServiceImpl) FindThreadFiles(threadID string, userID int) ([]structs.ThreadFile, error) {
+	return s.threads.FindThreadFiles(threadID, userID)
+}
+
+// SaveMessage сохраняет сообщение треда, токены сообщения считаются здесь же
+func (s *ThreadServiceImpl) SaveMessage(message *structs.ThreadMessage) error {
+	message.Tokens = CountTokens(s.chunker, message.Prompt)
+	return s.threads.CreateMessage(message)
+}
+
+// CountTokens количество токенов сообщения треда, разметка блоков кода не считается
+func CountTokens(chunks *chunker.Chunker, prompt string) int {
+	return chunks.Count(codeFences.Replace(prompt))
+}
+
+func (s *ThreadServiceImpl) FindThreadMessages(threadID string, userID int) ([]structs.ThreadMessage, error) {
+	return s.threads.FindMessagesByThread(threadID, userID)
+}
@@ -0,0 +1,70 @@
+package usageservice
+
+import (
+	"github.com/jmoiron/sqlx"
+	"sse-demo-core/internal/app/policy"
+	urepository "sse-demo-core/internal/app/repository/usage"
+	"sse-demo-core/internal/app/structs"
+)
+
+type UsageServiceImpl struct {
+	quotas *policy.Quotas
+	usage  urepository.UsageRepository
+}
+
+func New(quotas *policy.Quotas, db *sqlx.DB) *UsageServiceImpl {
+	urepo := urepository.New(db)
+	return &UsageServiceImpl{quotas, *urepo}
+}
+
+// RecordUpload учитывает обработанные файлы загрузки guid
+func (s *UsageServiceImpl) RecordUpload(userID int, guid string, files int, bytes int64) error {
+	return s.usage.CreateEvent(&structs.UsageEvent{UserID: userID, Kind: policy.JobUpload, GUID: guid, Files: files, Bytes: bytes})
+}
+
+// RecordLLM учитывает запрос к модели, usage может отсутствовать у провайдера
+func (s *UsageServiceImpl) RecordLLM(userID int, guid string, usage *structs.Usage) error {
+	event := structs.UsageEvent{UserID: userID, Kind: policy.JobLLM, GUID: guid, Requests: 1}
+	if usage != nil {
+		event.PromptTokens = usage.PromptToken
+		event.CompletionTokens = usage.CompletionTokens
+	}
+	return s.usage.CreateEvent(&event)
+}
+
+// CheckQuota nil, если работа job укладывается в квоту роли пользователя на текущий месяц
+func (s *UsageServiceImpl) CheckQuota(user *structs.User, job string, size int64) (*structs.QuotaExceededDetails, error) {
+	usage, err := s.usage.FindUserUsage(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return policy.CheckQuota(s.quotas.ForRole(user.Role), usage.CurrentMonth, job, size), nil
+}
+
+// FindUserUsage расход пользователя по периодам вместе с квотой его роли и статистикой сообщений
+func (s *UsageServiceImpl) FindUserUsage(user *structs.User) (*structs.UserUsage, error) {
+	usage, err := s.usage.FindUserUsage(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	usage.Email = user.Email
+
+	quota := s.quotas.ForRole(user.Role)
+	usage.Quota = &quota
+
+	usage.Messages, err = s.usage.FindUserMessagesStatistics(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	usage.Messages.Email = user.Email
+
+	return usage, nil
+}
+
+func (s *UsageServiceImpl) FindUsersUsage() ([]structs.UserUsage, error) {
+	return s.usage.FindUsersUsage()
+}
+
+func (s *UsageServiceImpl) FindMessagesStatistics() ([]structs.UserMessagesStatistics, error) {
+	return s.usage.FindMessagesStatistics()
+}
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// MemoryStorage хранилище в памяти процесса, для локального запуска и тестов
+type MemoryStorage struct {
+	mu      sync.RWMutex
+	objects map[string][]byte
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{objects: make(map[string][]byte)}
+}
+
+func (s *MemoryStorage) Put(_ context.Context, key string, r io.Reader, _ int64, _ string) error {
+	cleaned, err := cleanKey(key)
+	if err != nil {
+		return err
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.objects[cleaned] = data
+	s.mu.Unlock()
+
+	return nil
+}
+
+func (s *MemoryStorage) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	cleaned, err := cleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	data, ok := s.objects[cleaned]
+	s.mu.RUnlock()
+
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (s *MemoryStorage) Delete(_ context.Context, key string) error {
+	cleaned, err := cleanKey(key)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	delete(s.objects, cleaned)
+	s.mu.Unlock()
+
+	return nil
+}
+
+func (s *MemoryStorage) Presign(_ context.Context, key string, expires time.Duration) (string, error) {
+	cleaned, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	q := url.Values{}
+	q.Set("expires", time.Now().Add(expires).Format(time.RFC3339))
+
+	return "memory://" + cleaned + "?" + q.Encode(), nil
+}
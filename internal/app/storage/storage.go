@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gurkankaymak/hocon"
+	"io"
+	"path"
+	"sse-demo-core/internal/app/utils"
+	"strings"
+	"time"
+)
+
+// ErrNotFound возвращается, если объект отсутствует в хранилище
+var ErrNotFound = errors.New("storage: object not found")
+
+// Storage интерфейс хранилища оригиналов загруженных файлов.
+type Storage interface {
+	// Put сохраняет объект по ключу, size -1 если размер неизвестен.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+
+	// Get возвращает содержимое объекта, закрыть reader обязан вызывающий.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+
+	// Delete удаляет объект по ключу, отсутствие объекта ошибкой не считается.
+	Delete(ctx context.Context, key string) error
+
+	// Presign возвращает временную ссылку на скачивание объекта.
+	Presign(ctx context.Context, key string, expires time.Duration) (string, error)
+}
+
+// New создает хранилище по integration.storage.type: local, s3 или memory
+func New(config *hocon.Config) (Storage, error) {
+	switch t := config.GetString("integration.storage.type"); t {
+	case "", "local":
+		return NewLocalStorage(
+			config.GetString("integration.storage.local.path"),
+			utils.ConfigString(config, "integration.storage.local.url"),
+			config.GetString("integration.storage.local.secret"),
+		)
+	case "s3":
+		return NewS3Storage(S3Config{
+			Endpoint:  utils.ConfigString(config, "integration.storage.s3.endpoint"),
+			Region:    config.GetString("integration.storage.s3.region"),
+			Bucket:    config.GetString("integration.storage.s3.bucket"),
+			AccessKey: config.GetString("integration.storage.s3.access_key"),
+			SecretKey: config.GetString("integration.storage.s3.secret_key"),
+			PathStyle: config.GetBoolean("integration.storage.s3.path_style"),
+		})
+	case "memory":
+		return NewMemoryStorage(), nil
+	default:
+		return nil, fmt.Errorf("unknown storage type: %s", t)
+	}
+}
+
+// UploadKey формирует ключ оригинала загруженного файла
+func UploadKey(userID int, guid string, uid string, fileName string) string {
+	return fmt.Sprintf("uploads/%d/%s/%s/%s", userID, guid, uid, path.Base(strings.ReplaceAll(fileName, "\\", "/")))
+}
+
+// cleanKey нормализует ключ и не дает выйти за пределы хранилища через ../
+func cleanKey(key string) (string, error) {
+	cleaned := strings.TrimPrefix(path.Clean("/"+key), "/")
+	if cleaned == "" || cleaned == "." {
+		return "", fmt.Errorf("invalid storage key: %q", key)
+	}
+	return cleaned, nil
+}
@@ -0,0 +1,286 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/gurkankaymak/hocon"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 заменитель MinIO: хранит объекты bucket в памяти, адресация через путь /bucket/key
+type fakeS3 struct {
+	mu      sync.Mutex
+	bucket  string
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/"+f.bucket+"/")
+	if key == r.URL.Path {
+		writeS3Error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeS3Error(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		f.objects[key] = data
+		w.Header().Set("ETag", `"etag"`)
+	case http.MethodGet:
+		data, ok := f.objects[key]
+		if !ok {
+			writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		_, _ = w.Write(data)
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeS3Error(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+func writeS3Error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?><Error><Code>` + code + `</Code><Message>` + code + `</Message></Error>`))
+}
+
+func newFakeS3(t *testing.T) (*S3Storage, *fakeS3) {
+	fake := &fakeS3{bucket: "uploads", objects: make(map[string][]byte)}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	s, err := NewS3Storage(S3Config{
+		Endpoint:  server.URL,
+		Region:    "us-east-1",
+		Bucket:    fake.bucket,
+		AccessKey: "access",
+		SecretKey: "secret",
+		PathStyle: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, fake
+}
+
+// testStorage общие для всех хранилищ проверки записи, чтения и удаления объектов
+func testStorage(t *testing.T, s Storage) {
+	ctx := context.Background()
+	key := UploadKey(1, "guid", "uuid", "report.txt")
+	content := []byte("quarterly report")
+
+	if err := s.Put(ctx, key, bytes.NewReader(content), int64(len(content)), "text/plain"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	r, err := s.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	data, err := io.ReadAll(r)
+	_ = r.Close()
+	if err != nil {
+		t.Fatalf("read object: %v", err)
+	}
+	if !bytes.Equal(data, content) {
+		t.Fatalf("Get returned %q, want %q", data, content)
+	}
+
+	// повторная запись заменяет объект
+	if err = s.Put(ctx, key, strings.NewReader("v2"), -1, ""); err != nil {
+		t.Fatalf("Put again: %v", err)
+	}
+	r, err = s.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get after overwrite: %v", err)
+	}
+	data, _ = io.ReadAll(r)
+	_ = r.Close()
+	if string(data) != "v2" {
+		t.Fatalf("Get after overwrite returned %q, want %q", data, "v2")
+	}
+
+	if _, err = s.Get(ctx, "uploads/1/guid/uuid/missing.txt"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get missing: got %v, want ErrNotFound", err)
+	}
+
+	if err = s.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err = s.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get deleted: got %v, want ErrNotFound", err)
+	}
+	if err = s.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete missing: %v", err)
+	}
+
+	for _, invalid := range []string{"", "/", ".."} {
+		if err = s.Put(ctx, invalid, strings.NewReader("x"), 1, ""); err == nil {
+			t.Fatalf("Put with key %q: expected error", invalid)
+		}
+	}
+}
+
+func TestLocalStorage(t *testing.T) {
+	root := t.TempDir()
+	s, err := NewLocalStorage(root, "http://localhost/storage", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testStorage(t, s)
+}
+
+func TestLocalStorageStaysInRoot(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "root")
+	s, err := NewLocalStorage(root, "", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = s.Put(context.Background(), "../../escape.txt", strings.NewReader("x"), 1, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(filepath.Join(root, "escape.txt")); err != nil {
+		t.Fatalf("object written outside of storage root: %v", err)
+	}
+}
+
+func TestLocalStoragePresign(t *testing.T) {
+	s, err := NewLocalStorage(t.TempDir(), "http://localhost/storage", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := UploadKey(1, "guid", "uuid", "отчет 1.pdf")
+	link, err := s.Presign(context.Background(), key, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signedKey := strings.TrimPrefix(u.Path, "/storage/")
+	if signedKey != key {
+		t.Fatalf("link key %q, want %q", signedKey, key)
+	}
+
+	expires, signature := u.Query().Get("expires"), u.Query().Get("signature")
+	if !s.Verify(signedKey, expires, signature) {
+		t.Fatal("valid link rejected")
+	}
+	if s.Verify(UploadKey(1, "guid", "uuid", "other.pdf"), expires, signature) {
+		t.Fatal("link accepted for another key")
+	}
+	if s.Verify(signedKey, expires, strings.Repeat("0", len(signature))) {
+		t.Fatal("link accepted with wrong signature")
+	}
+
+	// ссылка другого экземпляра с другим секретом не принимается
+	other, err := NewLocalStorage(t.TempDir(), "http://localhost/storage", "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.Verify(signedKey, expires, signature) {
+		t.Fatal("link accepted with another secret")
+	}
+
+	expired, err := s.Presign(context.Background(), key, -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ = url.Parse(expired)
+	if s.Verify(key, u.Query().Get("expires"), u.Query().Get("signature")) {
+		t.Fatal("expired link accepted")
+	}
+}
+
+func TestMemoryStorage(t *testing.T) {
+	testStorage(t, NewMemoryStorage())
+}
+
+func TestS3Storage(t *testing.T) {
+	s, fake := newFakeS3(t)
+
+	testStorage(t, s)
+
+	key := UploadKey(1, "guid", "uuid", "report.txt")
+	if err := s.Put(context.Background(), key, strings.NewReader("data"), 4, "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	fake.mu.Lock()
+	_, ok := fake.objects[key]
+	fake.mu.Unlock()
+	if !ok {
+		t.Fatalf("object %q not stored in bucket", key)
+	}
+
+	link, err := s.Presign(context.Background(), key, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Path != "/uploads/"+key || u.Query().Get("X-Amz-Signature") == "" {
+		t.Fatalf("unexpected presigned link %s", link)
+	}
+}
+
+func TestUploadKey(t *testing.T) {
+	cases := map[string]string{
+		"report.pdf":           "uploads/7/guid/uuid/report.pdf",
+		"../../etc/passwd":     "uploads/7/guid/uuid/passwd",
+		`C:\Users\me\scan.png`: "uploads/7/guid/uuid/scan.png",
+	}
+	for name, want := range cases {
+		if got := UploadKey(7, "guid", "uuid", name); got != want {
+			t.Errorf("UploadKey(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	config, err := hocon.ParseString(`integration.storage { type = "memory" }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.(*MemoryStorage); !ok {
+		t.Fatalf("New returned %T, want *MemoryStorage", s)
+	}
+
+	config, err = hocon.ParseString(`integration.storage { type = "ftp" }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = New(config); err == nil {
+		t.Fatal("expected error for unknown storage type")
+	}
+}
@@ -3,6 +3,7 @@ package structs
 import (
 	"database/sql"
 	"encoding/json"
+	"github.com/lib/pq"
 	"time"
 )
 
@@ -27,15 +28,88 @@ type OpenAIError struct {
 }
 
 type OpenAIRequest struct {
-	Model    string    `json:"model"`
-	Stream   bool      `json:"stream"`
-	Messages []Content `json:"messages"`
+	Model         string               `json:"model"`
+	Stream        bool                 `json:"stream"`
+	Messages      []Content            `json:"messages"`
+	Temperature   *float64             `json:"temperature,omitempty"`
+	MaxTokens     int                  `json:"max_tokens,omitempty"`
+	StreamOptions *OpenAIStreamOptions `json:"stream_options,omitempty"`
+	// ResponseFormat json_object или json_schema - ответ модели ограничен JSON
+	ResponseFormat *OpenAIResponseFormat `json:"response_format,omitempty"`
+}
+
+type OpenAIResponseFormat struct {
+	Type       string            `json:"type"`
+	JSONSchema *OpenAIJSONSchema `json:"json_schema,omitempty"`
+}
+
+type OpenAIJSONSchema struct {
+	Name   string          `json:"name"`
+	Schema json.RawMessage `json:"schema"`
+	Strict bool            `json:"strict"`
+}
+
+// OpenAIStreamOptions include_usage - последним событием потока приходит расход токенов
+type OpenAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
 }
 
 type GigaChatRequest struct {
-	Model    string    `json:"model"`
-	Stream   bool      `json:"stream"`
-	Messages []Content `json:"messages"`
+	Model       string    `json:"model"`
+	Stream      bool      `json:"stream"`
+	Messages    []Content `json:"messages"`
+	Temperature *float64  `json:"temperature,omitempty"`
+	MaxTokens   int       `json:"max_tokens,omitempty"`
+	// Functions и FunctionCall вызов функции, аргументы которой модель заполняет по схеме параметров
+	Functions    []GigaChatFunction `json:"functions,omitempty"`
+	FunctionCall *FunctionCallName  `json:"function_call,omitempty"`
+}
+
+type GigaChatFunction struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters"`
+}
+
+type FunctionCallName struct {
+	Name string `json:"name"`
+}
+
+// LLMRequest запрос к языковой модели, не зависящий от провайдера
+type LLMRequest struct {
+	// Model пустая - модель провайдера из конфига
+	Model       string
+	Messages    []Content
+	Temperature *float64
+	MaxTokens   int
+	// Schema JSON Schema ответа, провайдер ограничивает ответ JSON режимом или вызовом функции, если умеет
+	Schema json.RawMessage `json:",omitempty"`
+}
+
+// LLMResponse ответ языковой модели, не зависящий от провайдера
+type LLMResponse struct {
+	Provider     string `json:"provider"`
+	Model        string `json:"model"`
+	Content      string `json:"content"`
+	FinishReason string `json:"finish_reason,omitempty"`
+	Usage        Usage  `json:"usage"`
+	// Cached ответ взят из кеша ответов, Usage - расход исходного запроса
+	Cached bool `json:"cached,omitempty"`
+}
+
+// EmbeddingRequest запрос эмбеддингов текстов Input, не зависящий от провайдера
+type EmbeddingRequest struct {
+	// Model пустая - модель эмбеддингов провайдера из конфига
+	Model string
+	Input []string
+}
+
+// EmbeddingResponse эмбеддинги в порядке текстов запроса
+type EmbeddingResponse struct {
+	Provider   string      `json:"provider"`
+	Model      string      `json:"model"`
+	Embeddings [][]float32 `json:"embeddings"`
+	Usage      Usage       `json:"usage"`
 }
 
 type GigaChatToken struct {
@@ -118,14 +192,48 @@ type AssistantsThread struct {
 }
 
 type Thread struct {
-	ID         int         `db:"id" json:"id"`
-	UserID     int         `db:"user_id" json:"user_id"`
-	ThreadName string      `db:"thread_name" json:"thread_name"`
-	ThreadID   string      `db:"thread_id" json:"thread_id"`
-	Object     string      `db:"object" json:"object"`
-	CreatedAt  time.Time   `db:"created_at" json:"created_at"`
-	Metadata   interface{} `db:"metadata" json:"metadata"`
-	Messages   int         `db:"messages_cnt" json:"messages"`
+	ID         int             `db:"id" json:"id"`
+	UserID     int             `db:"user_id" json:"user_id"`
+	ThreadName string          `db:"thread_name" json:"thread_name"`
+	ThreadID   string          `db:"thread_id" json:"thread_id"`
+	Object     string          `db:"object" json:"object"`
+	GUID       string          `db:"guid" json:"guid,omitempty"` // загрузка, файлы которой подключены к треду
+	CreatedAt  time.Time       `db:"created_at" json:"created_at"`
+	Metadata   json.RawMessage `db:"metadata" json:"metadata"`
+	Messages   int             `db:"messages_cnt" json:"messages"`
+}
+
+// AssistantsFilesRequest загрузка обработанных слоев загрузки guid в файлы ассистентов,
+// с AssistantID файлы подключаются к ассистенту
+type AssistantsFilesRequest struct {
+	GUID        string `json:"guid"`
+	AssistantID string `json:"assistant_id"`
+}
+
+// CreateThreadRequest файлы загрузки GUID прикладываются к первому сообщению треда
+type CreateThreadRequest struct {
+	Name string `json:"name"`
+	GUID string `json:"guid"`
+}
+
+type RunStartedResponse struct {
+	GUID   string `json:"guid"`
+	UUID   string `json:"uuid"`
+	RunID  string `json:"run_id"`
+	Status string `json:"status"`
+}
+
+type RunCompletedDetails struct {
+	RunID    string          `json:"run_id"`
+	Messages []ThreadMessage `json:"messages"`
+	Usage    *Usage          `json:"usage,omitempty"`
+}
+
+type RunFailedDetails struct {
+	RunID   string `json:"run_id"`
+	Status  string `json:"status"`
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message"`
 }
 
 type ThreadFile struct {
@@ -179,6 +287,8 @@ type AIThreadRun struct {
 	FileIds      []string           `json:"file_ids"`
 	Metadata     struct {
 	} `json:"metadata"`
+	// Usage расход токенов завершенного запуска
+	Usage *Usage `json:"usage"`
 }
 
 type AIThreadRunTools struct {
@@ -242,7 +352,7 @@ type ThreadMessage struct {
 	Tokens      int             `db:"tokens" json:"tokens"`
 	Hidden      bool            `db:"hidden" json:"hidden"`
 	System      bool            `db:"system" json:"system"`
-	Metadata    any             `db:"metadata" json:"metadata"`
+	Metadata    json.RawMessage `db:"metadata" json:"metadata"`
 }
 
 type UserMessagesStatistics struct {
@@ -254,6 +364,46 @@ type UserMessagesStatistics struct {
 	TotalCount        int    `json:"total_count" db:"total_count"`
 }
 
+// UsageEvent учтенная работа пользователя: kind upload - обработанные файлы загрузки, llm - запрос к модели
+type UsageEvent struct {
+	UserID           int    `db:"user_id"`
+	Kind             string `db:"kind"`
+	GUID             string `db:"guid"`
+	Files            int    `db:"files"`
+	Bytes            int64  `db:"bytes"`
+	Requests         int    `db:"requests"`
+	PromptTokens     int    `db:"prompt_tokens"`
+	CompletionTokens int    `db:"completion_tokens"`
+}
+
+// UsageCounters расход пользователя за период
+type UsageCounters struct {
+	Uploads  int   `json:"uploads" db:"uploads"`
+	Files    int   `json:"files" db:"files"`
+	Bytes    int64 `json:"bytes" db:"bytes"`
+	Requests int   `json:"requests" db:"requests"`
+	Tokens   int   `json:"tokens" db:"tokens"`
+}
+
+// UsageQuota месячная квота роли, 0 - без ограничения
+type UsageQuota struct {
+	Uploads  int   `json:"uploads"`
+	Bytes    int64 `json:"bytes"`
+	Requests int   `json:"requests"`
+	Tokens   int   `json:"tokens"`
+}
+
+type UserUsage struct {
+	UserID       int                     `json:"user_id" db:"user_id"`
+	Email        string                  `json:"email,omitempty" db:"email"`
+	CurrentMonth UsageCounters           `json:"current_month" db:"current_month"`
+	LastMonth    UsageCounters           `json:"last_month" db:"last_month"`
+	Year         UsageCounters           `json:"year" db:"year"`
+	Total        UsageCounters           `json:"total" db:"total"`
+	Messages     *UserMessagesStatistics `json:"messages,omitempty" db:"-"`
+	Quota        *UsageQuota             `json:"quota,omitempty" db:"-"`
+}
+
 type UsersCounter interface {
 	GetRole() string
 	IsHidden() bool
@@ -368,22 +518,64 @@ type OpenAIStreamingResponse struct {
 		} `json:"delta"`
 		FinishReason string `json:"finish_reason"`
 	} `json:"choices"`
+	Usage *Usage `json:"usage,omitempty"`
 }
 
 type HuggingFaceRequest struct {
-	Inputs  string             `json:"inputs"`
-	Stream  bool               `json:"stream"`
-	Options HuggingFaceOptions `json:"options"`
+	Inputs     string                 `json:"inputs"`
+	Stream     bool                   `json:"stream"`
+	Parameters *HuggingFaceParameters `json:"parameters,omitempty"`
+	Options    HuggingFaceOptions     `json:"options"`
+}
+
+type HuggingFaceParameters struct {
+	MaxNewTokens   int      `json:"max_new_tokens,omitempty"`
+	Temperature    *float64 `json:"temperature,omitempty"`
+	ReturnFullText bool     `json:"return_full_text"`
+}
+
+type HuggingFaceStreamingResponse struct {
+	Token struct {
+		Text    string `json:"text"`
+		Special bool   `json:"special"`
+	} `json:"token"`
+	GeneratedText *string `json:"generated_text"`
+	Details       *struct {
+		FinishReason    string `json:"finish_reason"`
+		GeneratedTokens int    `json:"generated_tokens"`
+	} `json:"details"`
 }
 
 type HuggingFaceOptions struct {
-	UseCache bool `json:"use_cache"`
+	UseCache     bool `json:"use_cache"`
+	WaitForModel bool `json:"wait_for_model,omitempty"`
 }
 
 type HuggingFaceResponse []struct {
 	GeneratedText string `json:"generated_text"`
 }
 
+type HuggingFaceEmbeddingRequest struct {
+	Inputs  []string           `json:"inputs"`
+	Options HuggingFaceOptions `json:"options"`
+}
+
+// OpenAIEmbeddingRequest запрос эмбеддингов OpenAI, в том же формате их принимает GigaChat
+type OpenAIEmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type OpenAIEmbeddingResponse struct {
+	Object string `json:"object"`
+	Model  string `json:"model"`
+	Data   []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+	Usage Usage `json:"usage"`
+}
+
 type CreateUser struct {
 	TenantID int    `json:"tenant_id" validate:"required"`
 	Last     string `json:"lastName" validate:"required"`
@@ -421,7 +613,12 @@ type UserLayer struct {
 	SourceSize     int       `db:"source_size"`
 	SourceName     string    `db:"source_name"`
 	SourceType     string    `db:"source_type"`
-	SourceFileLink string    `db:"source_file_link"`
+	SourceHash     string    `db:"source_hash"`
+	OCRConfidence  float64   `db:"ocr_confidence"`
+	Tokens         int       `db:"tokens"`
+	Language       string    `db:"language"`
+	Redactions     string    `db:"redactions"`       // количество замен персональных данных по видам, json
+	SourceFileLink string    `db:"source_file_link"` // ключ оригинала в хранилище, ссылку на скачивание выдает GET /uploads/:guid/layers/:uuid/link
 	SourceData     string    `db:"source_data"`
 	OptimizedData  string    `db:"optimized_data"`
 	OpenaiFileID   string    `db:"openai_file_id"`
@@ -431,10 +628,193 @@ type UserLayer struct {
 	Status         string    `db:"status"`
 }
 
+// Prompt текущая версия промпта, PromptText - шаблон text/template, переменные шаблона - prompttemplate.Data
 type Prompt struct {
-	ID          int    `db:"id"`
+	ID          int    `json:"id" db:"id"`
+	Name        string `json:"name" db:"name"`
 	PromptText  string `json:"prompt_text" db:"prompt_text" validate:"required"`
 	PromptStage int    `json:"prompt_stage" db:"prompt_stage" validate:"required"`
+	// Language язык документов, для которых предназначен промпт, пустой - для любого языка
+	Language  string    `json:"language" db:"language"`
+	Version   int       `json:"version" db:"version"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// PromptVersion сохраненная версия промпта, каждое изменение промпта добавляет новую версию
+type PromptVersion struct {
+	ID          int       `json:"-" db:"id"`
+	PromptID    int       `json:"prompt_id" db:"prompt_id"`
+	Version     int       `json:"version" db:"version"`
+	Name        string    `json:"name" db:"name"`
+	PromptText  string    `json:"prompt_text" db:"prompt_text"`
+	PromptStage int       `json:"prompt_stage" db:"prompt_stage"`
+	Language    string    `json:"language" db:"language"`
+	UserID      int       `json:"user_id" db:"user_id"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+type PromptRequest struct {
+	Name        string `json:"name"`
+	PromptText  string `json:"prompt_text"`
+	PromptStage int    `json:"prompt_stage"`
+	Language    string `json:"language"`
+}
+
+// PromptPreviewRequest загрузка guid, на которой показывается промпт, version - версия промпта, 0 - текущая
+type PromptPreviewRequest struct {
+	GUID    string `json:"guid"`
+	Version int    `json:"version"`
+}
+
+// PromptPreviewResponse сообщения, которые уйдут в модель при анализе загрузки промптом
+type PromptPreviewResponse struct {
+	PromptID int       `json:"prompt_id"`
+	Version  int       `json:"version"`
+	GUID     string    `json:"guid"`
+	Language string    `json:"language"`
+	Messages []Content `json:"messages"`
+	Tokens   int       `json:"tokens"`
+}
+
+// AnalysisResult результат анализа слоев загрузки моделью, status: running, completed, failed
+type AnalysisResult struct {
+	ID               int        `json:"id" db:"id"`
+	GUID             string     `json:"guid" db:"guid"`
+	UUID             string     `json:"uuid" db:"uuid"`
+	UserID           int        `json:"-" db:"user_id"`
+	PromptID         int        `json:"prompt_id" db:"prompt_id"`
+	PromptStage      int        `json:"prompt_stage" db:"prompt_stage"`
+	PromptVersion    int        `json:"prompt_version" db:"prompt_version"`
+	Language         string     `json:"language" db:"language"`
+	Provider         string     `json:"provider" db:"provider"`
+	Model            string     `json:"model" db:"model"`
+	Result           string     `json:"result" db:"result"`
+	Error            string     `json:"error,omitempty" db:"error"`
+	PromptTokens     int        `json:"prompt_tokens" db:"prompt_tokens"`
+	CompletionTokens int        `json:"completion_tokens" db:"completion_tokens"`
+	Status           string     `json:"status" db:"status"`
+	Created          time.Time  `json:"created" db:"created"`
+	Completed        *time.Time `json:"completed,omitempty" db:"completed"`
+	// PipelineID, PipelineStep - конвейер и номер его этапа с 0, LayerUUID и FileName - файл этапа per_file
+	PipelineID   int    `json:"pipeline_id,omitempty" db:"pipeline_id"`
+	PipelineStep int    `json:"pipeline_step,omitempty" db:"pipeline_step"`
+	LayerUUID    string `json:"layer_uuid,omitempty" db:"layer_uuid"`
+	FileName     string `json:"file_name,omitempty" db:"file_name"`
+}
+
+// PipelineStage этап конвейера: промпт этапа stage из таблицы prompts, per_file - промпт применяется к каждому файлу отдельно
+type PipelineStage struct {
+	Stage   int  `json:"stage"`
+	PerFile bool `json:"per_file"`
+}
+
+// Pipeline конвейер анализа загрузки guid: результат этапа уходит на вход следующему,
+// step - номер этапа, с которого конвейер продолжится. status: running, completed, failed
+type Pipeline struct {
+	ID        int             `json:"id" db:"id"`
+	GUID      string          `json:"guid" db:"guid"`
+	UUID      string          `json:"uuid" db:"uuid"`
+	UserID    int             `json:"-" db:"user_id"`
+	Name      string          `json:"name" db:"name"`
+	Stages    json.RawMessage `json:"stages" db:"stages"`
+	Language  string          `json:"language" db:"language"`
+	Step      int             `json:"step" db:"step"`
+	Result    string          `json:"result" db:"result"`
+	Error     string          `json:"error,omitempty" db:"error"`
+	Status    string          `json:"status" db:"status"`
+	Created   time.Time       `json:"created" db:"created"`
+	Updated   time.Time       `json:"updated" db:"updated"`
+	Completed *time.Time      `json:"completed,omitempty" db:"completed"`
+	// Results результаты этапов с восстановленными персональными данными
+	Results []AnalysisResult `json:"results,omitempty" db:"-"`
+}
+
+// PipelineRequest запуск конвейера name из pipeline.pipelines, пустой - pipeline.default
+type PipelineRequest struct {
+	Name string `json:"name"`
+}
+
+type PipelineStartedResponse struct {
+	GUID       string `json:"guid"`
+	UUID       string `json:"uuid"`
+	PipelineID int    `json:"pipeline_id"`
+	Step       int    `json:"step"`
+	Position   int    `json:"position,omitempty"`
+}
+
+// PipelineDetails события конвейера pipeline_started, pipeline_stage_started, pipeline_stage_result,
+// pipeline_stage_completed, pipeline_completed и pipeline_failed
+type PipelineDetails struct {
+	PipelineID int    `json:"pipeline_id"`
+	Step       int    `json:"step"`
+	Stage      int    `json:"stage,omitempty"`
+	PerFile    bool   `json:"per_file,omitempty"`
+	FileName   string `json:"file_name,omitempty"`
+	ResultID   int    `json:"result_id,omitempty"`
+	Result     string `json:"result,omitempty"`
+	// Resumed результат этапа сохранен до перезапуска конвейера, модель повторно не вызывалась
+	Resumed bool   `json:"resumed,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// Extraction структурированные данные слоя загрузки, извлеченные моделью по JSON Schema. UUID - запуск извлечения,
+// общий для слоев одного запроса. Result - значение, прошедшее проверку схемой, с восстановленными персональными данными.
+// Attempts - количество запросов к модели с повторами после ошибок проверки. status: running, completed, failed
+type Extraction struct {
+	ID               int             `json:"id" db:"id"`
+	GUID             string          `json:"guid" db:"guid"`
+	UUID             string          `json:"uuid" db:"uuid"`
+	UserID           int             `json:"-" db:"user_id"`
+	LayerID          int             `json:"-" db:"layer_id"`
+	LayerUUID        string          `json:"layer_uuid" db:"layer_uuid"`
+	FileName         string          `json:"file_name" db:"file_name"`
+	Schema           json.RawMessage `json:"schema" db:"schema"`
+	Instructions     string          `json:"instructions,omitempty" db:"instructions"`
+	Result           json.RawMessage `json:"result" db:"result"`
+	Attempts         int             `json:"attempts" db:"attempts"`
+	Provider         string          `json:"provider" db:"provider"`
+	Model            string          `json:"model" db:"model"`
+	PromptTokens     int             `json:"prompt_tokens" db:"prompt_tokens"`
+	CompletionTokens int             `json:"completion_tokens" db:"completion_tokens"`
+	Error            string          `json:"error,omitempty" db:"error"`
+	Status           string          `json:"status" db:"status"`
+	Created          time.Time       `json:"created" db:"created"`
+	Completed        *time.Time      `json:"completed,omitempty" db:"completed"`
+}
+
+// ExtractionRequest извлечение данных по JSON Schema из слоев загрузки, Layers - uuid слоев, пустой - из всех слоев.
+// Instructions - дополнительные указания модели
+type ExtractionRequest struct {
+	Schema       json.RawMessage `json:"schema"`
+	Instructions string          `json:"instructions"`
+	Layers       []string        `json:"layers"`
+}
+
+type ExtractionStartedResponse struct {
+	GUID          string `json:"guid"`
+	UUID          string `json:"uuid"`
+	ExtractionIDs []int  `json:"extraction_ids"`
+	Position      int    `json:"position,omitempty"`
+}
+
+// ExtractionDetails события извлечения слоя extraction_started, extraction_retry с ошибками проверки ответа схемой,
+// extraction_result с результатом или ошибкой, а также extraction_failed, если извлечение не выполнялось
+type ExtractionDetails struct {
+	ExtractionID int             `json:"extraction_id,omitempty"`
+	LayerUUID    string          `json:"layer_uuid,omitempty"`
+	FileName     string          `json:"file_name,omitempty"`
+	Attempt      int             `json:"attempt,omitempty"`
+	Errors       []string        `json:"errors,omitempty"`
+	Status       string          `json:"status,omitempty"`
+	Result       json.RawMessage `json:"result,omitempty"`
+	Message      string          `json:"message,omitempty"`
+}
+
+// ExtractionCompletedDetails событие extraction_completed: сколько слоев извлечено и сколько не прошли проверку схемой
+type ExtractionCompletedDetails struct {
+	Completed int   `json:"completed"`
+	Failed    int   `json:"failed"`
+	Usage     Usage `json:"usage"`
 }
 
 type ResponseUser struct {
@@ -445,6 +825,39 @@ type ResponseUser struct {
 	Role  string `json:"role" validate:"required"`
 }
 
+// LayerChunk фрагмент содержимого слоя загрузки с эмбеддингом для поиска. Content очищен от персональных данных,
+// в ответе /search они восстановлены. UUID и FileName - слой и файл источника, Score - косинусная близость к запросу
+type LayerChunk struct {
+	ID        int             `db:"id" json:"-"`
+	LayerID   int             `db:"layer_id" json:"layer_id"`
+	GUID      string          `db:"guid" json:"guid"`
+	UUID      string          `db:"uuid" json:"uuid"`
+	UserID    int             `db:"user_id" json:"-"`
+	FileName  string          `db:"file_name" json:"file_name"`
+	Index     int             `db:"chunk_index" json:"index"`
+	Section   string          `db:"section" json:"section,omitempty"`
+	Page      int             `db:"page" json:"page,omitempty"`
+	Content   string          `db:"content" json:"content"`
+	Tokens    int             `db:"tokens" json:"tokens"`
+	Model     string          `db:"model" json:"-"`
+	Embedding pq.Float32Array `db:"embedding" json:"-"`
+	Score     float64         `db:"score" json:"score"`
+}
+
+// SearchRequest поиск фрагментов загрузок пользователя, близких к Query. GUIDs - только в этих загрузках,
+// TopK - количество фрагментов, 0 - search.top_k
+type SearchRequest struct {
+	Query string   `json:"query"`
+	GUIDs []string `json:"guids"`
+	TopK  int      `json:"top_k"`
+}
+
+type SearchResponse struct {
+	Query   string       `json:"query"`
+	Model   string       `json:"model"`
+	Results []LayerChunk `json:"results"`
+}
+
 type Token struct {
 	Token string `json:"token" xml:"token"`
 }
@@ -454,8 +867,9 @@ type AuthRes struct {
 }
 
 type ErrorResponse struct {
-	Code  int    `json:"code"`
-	Error string `json:"error"`
+	Code   int    `json:"code"`
+	Error  string `json:"error"`
+	Reason string `json:"reason,omitempty"`
 }
 
 type Usage struct {
@@ -473,6 +887,14 @@ type Choice struct {
 type Message struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
+	// FunctionCall вызов функции вместо текстового ответа
+	FunctionCall *FunctionCall `json:"function_call,omitempty"`
+}
+
+// FunctionCall аргументы приходят объектом (GigaChat) или строкой с JSON (OpenAI)
+type FunctionCall struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
 }
 
 type OpenAIModelsResponse struct {
@@ -524,6 +946,145 @@ type Notification struct {
 	UUID     string
 	State    string
 	FileName string
+	Details  any
+}
+
+type QueuedDetails struct {
+	Position int `json:"position"`
+}
+
+type StoredDetails struct {
+	Link string `json:"link"`
+}
+
+// LayerLinkResponse временная ссылка на скачивание оригинала файла слоя
+type LayerLinkResponse struct {
+	UUID      string    `json:"uuid"`
+	FileName  string    `json:"file_name"`
+	Link      string    `json:"link"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type ProcessedDetails struct {
+	OCR        bool    `json:"ocr,omitempty"`
+	Confidence float64 `json:"confidence,omitempty"`
+	Tokens     int     `json:"tokens"`
+	Chunks     int     `json:"chunks"`
+	// Redactions количество замен персональных данных по видам
+	Redactions map[string]int `json:"redactions,omitempty"`
+	Language   string         `json:"language,omitempty"`
+	// Indexed фрагменты, проиндексированные для поиска по загрузкам
+	Indexed int `json:"indexed,omitempty"`
+}
+
+// RedactionToken заменитель персональных данных в тексте, отправляемом в модель,
+// исходное значение хранится только на сервере, зашифрованным при заданном redaction.key
+type RedactionToken struct {
+	ID          int       `db:"id"`
+	GUID        string    `db:"guid"`
+	UUID        string    `db:"uuid"`
+	UserID      int       `db:"user_id"`
+	Placeholder string    `db:"placeholder"`
+	Kind        string    `db:"kind"`
+	Value       string    `db:"value"`
+	Encrypted   bool      `db:"encrypted"`
+	Created     time.Time `db:"created"`
+}
+
+type TokenBudgetDetails struct {
+	Tokens int `json:"tokens"`
+	Limit  int `json:"limit"`
+}
+
+// QuotaExceededDetails событие quota_exceeded: месячная квота роли пользователя исчерпана,
+// reason - исчерпанный показатель: uploads, bytes, requests или tokens
+type QuotaExceededDetails struct {
+	Reason  string `json:"reason"`
+	Limit   int64  `json:"limit"`
+	Used    int64  `json:"used"`
+	Message string `json:"message"`
+}
+
+type CachedDetails struct {
+	SHA256 string `json:"sha256"`
+}
+
+// AnalysisRequest запрос потокового анализа, фрагменты ответа уходят подписчикам /sse загрузки guid
+type AnalysisRequest struct {
+	GUID        string    `json:"guid"`
+	Prompt      string    `json:"prompt"`
+	Messages    []Content `json:"messages"`
+	Model       string    `json:"model"`
+	Temperature *float64  `json:"temperature"`
+	MaxTokens   int       `json:"max_tokens"`
+}
+
+type AnalysisStartedResponse struct {
+	GUID string `json:"guid"`
+	UUID string `json:"uuid"`
+	// ResultID и Position - сохраняемый результат анализа загрузки и позиция в очереди анализа
+	ResultID int `json:"result_id,omitempty"`
+	Position int `json:"position,omitempty"`
+}
+
+// UploadAnalysisRequest анализ слоев загрузки промптом этапа stage, 0 - этап из analysis.stage
+type UploadAnalysisRequest struct {
+	Stage int `json:"stage"`
+}
+
+type AnalysisDeltaDetails struct {
+	Delta string `json:"delta"`
+}
+
+type AnalysisCompletedDetails struct {
+	Provider     string `json:"provider"`
+	Model        string `json:"model"`
+	FinishReason string `json:"finish_reason,omitempty"`
+	Usage        Usage  `json:"usage"`
+	Cached       bool   `json:"cached,omitempty"`
+	// ResultID и Result - сохраненный результат анализа загрузки с восстановленными персональными данными
+	ResultID int    `json:"result_id,omitempty"`
+	Result   string `json:"result,omitempty"`
+	// Sources источники, на которые ссылается ответ на вопрос /ask
+	Sources []AskSource `json:"sources,omitempty"`
+}
+
+// AskRequest вопрос по загрузкам пользователя, GUIDs - только по этим загрузкам, пустой - по всем.
+// TopK - количество фрагментов-источников, 0 - ask.top_k
+type AskRequest struct {
+	Question string   `json:"question"`
+	GUIDs    []string `json:"guids"`
+	TopK     int      `json:"top_k"`
+}
+
+// AskSource фрагмент-источник ответа, Number - номер, по которому на него ссылается ответ модели
+type AskSource struct {
+	Number   int     `json:"number"`
+	GUID     string  `json:"guid"`
+	UUID     string  `json:"uuid"`
+	FileName string  `json:"file_name"`
+	Page     int     `json:"page,omitempty"`
+	Section  string  `json:"section,omitempty"`
+	Index    int     `json:"index"`
+	Score    float64 `json:"score"`
+	Content  string  `json:"content"`
+}
+
+type AskSourcesDetails struct {
+	Sources []AskSource `json:"sources"`
+}
+
+type AnalysisErrorDetails struct {
+	// Reason provider_unavailable - circuit breaker провайдера открыт, provider_busy - заняты все слоты bulkhead
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message"`
+	// RetryAfter через сколько секунд провайдер будет снова опрошен
+	RetryAfter int `json:"retry_after,omitempty"`
+}
+
+type FileErrorDetails struct {
+	Reason  string `json:"reason"`
+	Message string `json:"message,omitempty"`
 }
 
 type AnalyseData struct {
@@ -0,0 +1,15 @@
+package utils
+
+import (
+	"github.com/gurkankaymak/hocon"
+	"strings"
+)
+
+// ConfigString строковое значение конфига как оно задано: hocon.String.String() возвращает строки с ':'
+// (адреса со схемой или портом) в кавычках
+func ConfigString(config *hocon.Config, path string) string {
+	if value, ok := config.Get(path).(hocon.String); ok {
+		return strings.Trim(string(value), `"`)
+	}
+	return config.GetString(path)
+}
@@ -24,4 +24,7 @@ func GraceShutdown(app *app.App) {
 	if err := app.Echo.Shutdown(ctx); err != nil {
 		logger.Error("gracefully shutdown error")
 	}
+
+	// сервер уже не принимает запросы, дожидаемся фоновых задач
+	app.Close()
 }
@@ -91,6 +91,25 @@ func GetUserIDFromClaims(claims jwt.MapClaims, u services.UserService) (int, err
 	return userID, nil
 }
 
+// GetUserFromClaims ищет пользователя по sub из токена, если не нашли - по id
+func GetUserFromClaims(claims jwt.MapClaims, u services.UserService) (*structs.User, error) {
+	logger := logdoc.GetLogger()
+
+	if sub, ok := claims["sub"].(string); ok && sub != "" {
+		user, err := u.FindUserBySub(sub)
+		if err == nil {
+			return user, nil
+		}
+		logger.Warn("error getting user by sub, trying by id")
+	}
+
+	id, ok := claims["id"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("user id not found in token claims")
+	}
+	return u.FindUserById(int(id))
+}
+
 func GetRoleFromClaims(claims jwt.MapClaims) string {
 	return claims["rol"].(string)
 }
@@ -0,0 +1,240 @@
+package workerpool
+
+import (
+	"context"
+	"errors"
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
+
+var ErrClosed = errors.New("worker pool closed")
+
+var (
+	poolSize = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "sse_demo_core",
+		Subsystem: "worker_pool",
+		Name:      "size",
+		Help:      "Количество обработчиков пула",
+	}, []string{"pool"})
+	busyWorkers = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "sse_demo_core",
+		Subsystem: "worker_pool",
+		Name:      "busy_workers",
+		Help:      "Количество занятых обработчиков пула",
+	}, []string{"pool"})
+	queueDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "sse_demo_core",
+		Subsystem: "worker_pool",
+		Name:      "queue_depth",
+		Help:      "Количество задач, ожидающих свободного обработчика",
+	}, []string{"pool"})
+	queuedUsers = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "sse_demo_core",
+		Subsystem: "worker_pool",
+		Name:      "queued_users",
+		Help:      "Количество пользователей с задачами в очереди",
+	}, []string{"pool"})
+)
+
+// Task задача пула, ctx - контекст, с которым задача была поставлена в очередь.
+// Если контекст уже отменен, задача должна завершиться сразу.
+type Task func(ctx context.Context)
+
+type job struct {
+	ctx  context.Context
+	task Task
+}
+
+// Pool общий для процесса пул обработчиков с ограниченным количеством воркеров.
+// Задачи раскладываются по очередям пользователей и выбираются по кругу,
+// поэтому большая загрузка одного пользователя не блокирует остальных.
+type Pool struct {
+	name   string
+	mu     sync.Mutex
+	cond   *sync.Cond
+	wg     sync.WaitGroup
+	queues map[int][]job
+	users  []int // порядок обхода очередей пользователей
+	next   int   // индекс пользователя, чья задача будет выбрана следующей
+	depth  int
+	size   int
+	busy   int
+	closed bool
+}
+
+// New создает пул из size обработчиков, name - метка pool метрик пула
+func New(name string, size int) *Pool {
+	if size <= 0 {
+		size = runtime.NumCPU()
+	}
+
+	p := &Pool{name: name, queues: make(map[int][]job), size: size}
+	p.cond = sync.NewCond(&p.mu)
+
+	for i := 0; i < size; i++ {
+		p.wg.Add(1)
+		go p.worker()
+	}
+	poolSize.WithLabelValues(p.name).Set(float64(size))
+
+	return p
+}
+
+// Submit ставит задачу пользователя в очередь и возвращает позицию ожидания.
+// Позиция 0 означает, что задача будет сразу взята в работу свободным обработчиком.
+func (p *Pool) Submit(ctx context.Context, userID int, task Task) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return 0, ErrClosed
+	}
+
+	position := p.position(userID)
+
+	if _, ok := p.queues[userID]; !ok {
+		// круг обхода закончен, новый пользователь встает в конец следующего, а не выбирается первым
+		if p.next >= len(p.users) {
+			p.next = 0
+		}
+		p.users = append(p.users, userID)
+		queuedUsers.WithLabelValues(p.name).Set(float64(len(p.users)))
+	}
+	p.queues[userID] = append(p.queues[userID], job{ctx: ctx, task: task})
+	p.depth++
+	queueDepth.WithLabelValues(p.name).Set(float64(p.depth))
+
+	p.cond.Signal()
+
+	return position, nil
+}
+
+// Close перестает принимать задачи и дожидается выполнения уже поставленных
+func (p *Pool) Close() {
+	p.mu.Lock()
+	p.closed = true
+	p.cond.Broadcast()
+	p.mu.Unlock()
+
+	p.wg.Wait()
+}
+
+// position считает, сколько задач будет выбрано до новой задачи пользователя
+// при круговом обходе очередей, за вычетом свободных обработчиков
+func (p *Pool) position(userID int) int {
+	own := len(p.queues[userID])
+	ahead := own
+
+	// новый пользователь встает в конец списка, то есть перед теми, кто уже обойден в текущем круге
+	ownIndex := len(p.users)
+	for i, u := range p.users {
+		if u == userID {
+			ownIndex = i
+			break
+		}
+	}
+
+	for i, u := range p.users {
+		if u == userID {
+			continue
+		}
+		// очереди, стоящие в круге обхода раньше нашей, успеют отдать на одну задачу больше
+		rounds := own
+		if p.before(i, ownIndex) {
+			rounds++
+		}
+		ahead += minInt(len(p.queues[u]), rounds)
+	}
+
+	free := p.size - p.busy
+	if ahead < free {
+		return 0
+	}
+	return ahead - free + 1
+}
+
+// before проверяет, будет ли очередь с индексом i обойдена раньше очереди с индексом j
+func (p *Pool) before(i, j int) bool {
+	next := p.next
+	if next >= len(p.users) {
+		next = 0
+	}
+	shift := func(k int) int {
+		return (k - next + len(p.users) + 1) % (len(p.users) + 1)
+	}
+	return shift(i) < shift(j)
+}
+
+func (p *Pool) worker() {
+	defer p.wg.Done()
+
+	for {
+		p.mu.Lock()
+		for p.depth == 0 && !p.closed {
+			p.cond.Wait()
+		}
+		if p.depth == 0 {
+			p.mu.Unlock()
+			return
+		}
+
+		j := p.dequeue()
+		p.busy++
+		busyWorkers.WithLabelValues(p.name).Set(float64(p.busy))
+		p.mu.Unlock()
+
+		p.run(j)
+
+		p.mu.Lock()
+		p.busy--
+		busyWorkers.WithLabelValues(p.name).Set(float64(p.busy))
+		p.mu.Unlock()
+	}
+}
+
+// dequeue забирает задачу из очереди следующего по кругу пользователя, вызывается под блокировкой
+func (p *Pool) dequeue() job {
+	if p.next >= len(p.users) {
+		p.next = 0
+	}
+
+	userID := p.users[p.next]
+	queue := p.queues[userID]
+	j := queue[0]
+
+	if len(queue) == 1 {
+		delete(p.queues, userID)
+		p.users = append(p.users[:p.next], p.users[p.next+1:]...)
+		queuedUsers.WithLabelValues(p.name).Set(float64(len(p.users)))
+	} else {
+		p.queues[userID] = queue[1:]
+		p.next++
+	}
+
+	p.depth--
+	queueDepth.WithLabelValues(p.name).Set(float64(p.depth))
+
+	return j
+}
+
+func (p *Pool) run(j job) {
+	logger := logdoc.GetLogger()
+	defer func() {
+		if err := recover(); err != nil {
+			logger.Error("worker pool task panic, ", err, "\n", string(debug.Stack()))
+		}
+	}()
+
+	j.task(j.ctx)
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
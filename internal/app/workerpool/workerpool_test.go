@@ -0,0 +1,234 @@
+package workerpool
+
+import (
+	"context"
+	"errors"
+	logdoc "github.com/LogDoc-org/logdoc-go-appender/logrus"
+	"math/rand"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	_, _ = logdoc.Init("udp", "127.0.0.1:9", "workerpool-test")
+	os.Exit(m.Run())
+}
+
+// block занимает единственный обработчик пула, пока не будет вызвана возвращенная функция
+func block(t *testing.T, p *Pool) func() {
+	t.Helper()
+
+	started, release := make(chan struct{}), make(chan struct{})
+	if _, err := p.Submit(context.Background(), 0, func(ctx context.Context) {
+		close(started)
+		<-release
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("blocking task is not started")
+	}
+	return func() { close(release) }
+}
+
+// TestRoundRobin задачи пользователя с большой очередью чередуются с задачами остальных
+func TestRoundRobin(t *testing.T) {
+	p := New(t.Name(), 1)
+	release := block(t, p)
+
+	var mu sync.Mutex
+	var order []int
+	record := func(userID int) Task {
+		return func(ctx context.Context) {
+			mu.Lock()
+			order = append(order, userID)
+			mu.Unlock()
+		}
+	}
+
+	for i := 0; i < 6; i++ {
+		if _, err := p.Submit(context.Background(), 1, record(1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := p.Submit(context.Background(), 2, record(2)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	release()
+	p.Close()
+
+	expected := []int{1, 2, 1, 2, 1, 1, 1, 1}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("order %v, expected %v", order, expected)
+	}
+}
+
+// TestSubmitPosition позиции, которые Submit возвращает при занятом обработчике, совпадают с порядком выполнения
+func TestSubmitPosition(t *testing.T) {
+	p := New(t.Name(), 1)
+	release := block(t, p)
+
+	var mu sync.Mutex
+	var order []string
+	submit := func(userID int, name string, expected int) {
+		t.Helper()
+
+		position, err := p.Submit(context.Background(), userID, func(ctx context.Context) {
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if position != expected {
+			t.Errorf("position of %s is %d, expected %d", name, position, expected)
+		}
+	}
+
+	submit(1, "a1", 1)
+	submit(1, "a2", 2)
+	submit(1, "a3", 3)
+	// новый пользователь обгоняет вторую и третью задачи первого
+	submit(2, "b1", 2)
+	submit(2, "b2", 4)
+
+	release()
+	p.Close()
+
+	expected := []string{"a1", "b1", "a2", "b2", "a3"}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("order %v, expected %v", order, expected)
+	}
+}
+
+func TestSubmitFreeWorker(t *testing.T) {
+	p := New(t.Name(), 2)
+	defer p.Close()
+
+	release := block(t, p)
+	defer release()
+
+	position, err := p.Submit(context.Background(), 1, func(ctx context.Context) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if position != 0 {
+		t.Errorf("position %d with a free worker, expected 0", position)
+	}
+}
+
+// TestPosition position совпадает с порядком dequeue при произвольном состоянии очередей, в том числе посреди круга обхода
+func TestPosition(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for trial := 0; trial < 500; trial++ {
+		// пул без обработчиков, задачи забираются dequeue вручную
+		p := &Pool{name: t.Name(), queues: make(map[int][]job), size: 1, busy: 1}
+		p.cond = sync.NewCond(&p.mu)
+
+		var probed bool
+		var taken int
+		noop := func(ctx context.Context) {}
+
+		for i := r.Intn(20); i > 0; i-- {
+			if p.depth > 0 && r.Intn(3) == 0 {
+				p.dequeue()
+				continue
+			}
+			if _, err := p.Submit(context.Background(), r.Intn(4), noop); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		userID := r.Intn(5)
+		position, err := p.Submit(context.Background(), userID, func(ctx context.Context) { probed = true })
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for !probed {
+			taken++
+			p.dequeue().task(context.Background())
+		}
+		if taken != position {
+			t.Fatalf("trial %d: task of userId %d reported position %d, dequeued %d", trial, userID, position, taken)
+		}
+	}
+}
+
+func TestBefore(t *testing.T) {
+	p := &Pool{users: []int{10, 20, 30}, next: 1}
+
+	// обход начинается с индекса 1, новый пользователь с индексом 3 стоит перед уже обойденным индексом 0
+	cases := []struct {
+		i, j     int
+		expected bool
+	}{
+		{1, 2, true},
+		{2, 3, true},
+		{3, 0, true},
+		{0, 1, false},
+		{0, 3, false},
+		{2, 1, false},
+	}
+	for _, c := range cases {
+		if got := p.before(c.i, c.j); got != c.expected {
+			t.Errorf("before(%d, %d) = %v, expected %v", c.i, c.j, got, c.expected)
+		}
+	}
+
+	// индекс next за концом списка означает начало нового круга
+	p.next = 3
+	if !p.before(0, 2) {
+		t.Error("before(0, 2) = false after wrap, expected true")
+	}
+}
+
+func TestClose(t *testing.T) {
+	p := New(t.Name(), 1)
+	release := block(t, p)
+
+	done := false
+	if _, err := p.Submit(context.Background(), 1, func(ctx context.Context) { done = true }); err != nil {
+		t.Fatal(err)
+	}
+
+	go release()
+	p.Close()
+
+	if !done {
+		t.Error("queued task is not finished on close")
+	}
+	if _, err := p.Submit(context.Background(), 1, func(ctx context.Context) {}); !errors.Is(err, ErrClosed) {
+		t.Errorf("submit after close returned %v, expected ErrClosed", err)
+	}
+}
+
+func TestPanicReleasesWorker(t *testing.T) {
+	p := New(t.Name(), 1)
+
+	if _, err := p.Submit(context.Background(), 1, func(ctx context.Context) { panic("task failed") }); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	if _, err := p.Submit(context.Background(), 1, func(ctx context.Context) { close(done) }); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("worker is not released after panic")
+	}
+	p.Close()
+}
@@ -10,17 +10,42 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/sirupsen/logrus"
+	"sse-demo-core/internal/app/caching"
+	"sse-demo-core/internal/app/chunker"
+	"sse-demo-core/internal/app/embedding"
+	"sse-demo-core/internal/app/endpoint/analysis"
+	"sse-demo-core/internal/app/endpoint/assistants"
+	"sse-demo-core/internal/app/endpoint/files/download"
 	"sse-demo-core/internal/app/endpoint/files/streaming"
 	"sse-demo-core/internal/app/endpoint/files/uploadsse"
+	fileutils "sse-demo-core/internal/app/endpoint/files/utils"
+	"sse-demo-core/internal/app/endpoint/prompts"
 	"sse-demo-core/internal/app/endpoint/root"
-	llama2 "sse-demo-core/internal/app/integration/huggingface"
+	"sse-demo-core/internal/app/endpoint/search"
+	"sse-demo-core/internal/app/endpoint/usage"
+	"sse-demo-core/internal/app/integration/llm"
+	"sse-demo-core/internal/app/interfaces/services"
 	customcors "sse-demo-core/internal/app/mv/cors"
+	"sse-demo-core/internal/app/mv/headerchecker"
 	"sse-demo-core/internal/app/mv/multipartchecker"
+	"sse-demo-core/internal/app/mv/rolechecker"
+	"sse-demo-core/internal/app/policy"
+	"sse-demo-core/internal/app/processors"
+	"sse-demo-core/internal/app/redaction"
+	"sse-demo-core/internal/app/service/analysisservice"
 	"sse-demo-core/internal/app/service/jwtservice"
+	"sse-demo-core/internal/app/service/layerservice"
+	"sse-demo-core/internal/app/service/promptservice"
+	"sse-demo-core/internal/app/service/redactionservice"
+	"sse-demo-core/internal/app/service/searchservice"
+	"sse-demo-core/internal/app/service/threadservice"
+	"sse-demo-core/internal/app/service/usageservice"
 	"sse-demo-core/internal/app/service/userservice"
-	"sse-demo-core/internal/app/structs"
+	"sse-demo-core/internal/app/storage"
 	"sse-demo-core/internal/app/utils"
+	"sse-demo-core/internal/app/workerpool"
 	echopprof "sse-demo-core/internal/pprof"
+	"strconv"
 	"strings"
 )
 
@@ -33,10 +58,28 @@ type App struct {
 	root      *root.Endpoint
 	streaming *streaming.Endpoint
 	files     *files.Endpoint
+	download  *download.Endpoint
+	analysis  *analysis.Endpoint
+	assistant *assistants.Endpoint
+	usage     *usage.Endpoint
+	prompt    *prompts.Endpoint
+	search    *search.Endpoint
 
-	u   *userservice.UserServiceImpl
-	jwt *jwtservice.JwtServiceImpl
-	l2  *llama2.ServiceImpl
+	u          *userservice.UserServiceImpl
+	jwt        *jwtservice.JwtServiceImpl
+	layers     *layerservice.LayerServiceImpl
+	redactions *redactionservice.RedactionServiceImpl
+	prompts    *promptservice.PromptServiceImpl
+	results    *analysisservice.AnalysisServiceImpl
+	threads    *threadservice.ThreadServiceImpl
+	accounting *usageservice.UsageServiceImpl
+	index      *searchservice.SearchServiceImpl
+	llm        services.LLMProvider
+
+	pool    *workerpool.Pool
+	queue   *workerpool.Pool
+	cache   caching.Cache
+	storage storage.Storage
 }
 
 var logger *logrus.Logger
@@ -49,16 +92,95 @@ func New(config *hocon.Config, port string, db *sqlx.DB) (*App, error) {
 	// services
 	a.u = userservice.New(db)
 	a.jwt = jwtservice.New(config, db)
-	a.l2 = llama2.New(config)
+	a.layers = layerservice.New(db)
+	a.prompts = promptservice.New(db)
+	a.results = analysisservice.New(db)
+
+	// исходные значения заменителей персональных данных шифруются ключом redaction.key
+	redactions, err := redactionservice.New(config, db)
+	if err != nil {
+		return nil, err
+	}
+	a.redactions = redactions
+
+	// политики загрузки и квоты ролей разбираются один раз, ошибка конфигурации останавливает запуск
+	quotas, err := policy.NewQuotas(config)
+	if err != nil {
+		return nil, err
+	}
+	uploads, err := policy.NewUploadPolicies(config)
+	if err != nil {
+		return nil, err
+	}
+	a.accounting = usageservice.New(quotas, db)
+	a.index = searchservice.New(config, db)
+
+	// used to cache user data, openai thread data, extracted files content, llm responses
+	// cache.type = "memory" - кеш в памяти процесса для локального запуска без redis
+	if config.GetString("cache.type") == "memory" {
+		a.cache = caching.NewMemoryCache(config.GetInt("cache.memory.capacity"))
+	} else {
+		a.cache = caching.NewRedisCache(fmt.Sprintf("%s:%d", config.GetString("redis.host"), config.GetInt("redis.port")))
+	}
+
+	// клиент языковой модели провайдера из integration.active
+	// breaker и bulkhead провайдеров общие для клиента модели и Assistants API
+	guards := llm.NewGuards(config)
+	provider, err := llm.New(config, a.cache, guards)
+	if err != nil {
+		return nil, err
+	}
+	a.llm = provider
+
+	// общий пул обработки загруженных файлов
+	a.pool = workerpool.New("upload", config.GetInt("upload.workers"))
+	// очередь анализов загрузок
+	a.queue = workerpool.New("analysis", config.GetInt("analysis.workers"))
+
+	// хранилище оригиналов загруженных файлов
+	store, err := storage.New(config)
+	if err != nil {
+		return nil, err
+	}
+	a.storage = store
 
-	// used to cache user data, openai thread data
-	//cache := caching.NewRedisCache(config.GetString("redis.addr"))
+	// нарезка извлеченного содержимого на фрагменты и подсчет токенов
+	chunks, err := chunker.New(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// сообщения тредов ассистентов, токены сообщений считаются при записи
+	a.threads = threadservice.New(db, chunks)
+
+	// эмбеддинги фрагментов загрузок для поиска
+	embedder := embedding.New(config, a.llm)
+
+	// извлечение содержимого загруженных файлов
+	processor, err := processors.New(config)
+	if err != nil {
+		return nil, err
+	}
 
 	// controllers
 	a.root = root.New()
 
-	a.files = files.New(config, a.jwt)
-	a.streaming = streaming.New(config)
+	a.files = files.New(config, a.jwt, a.u, a.layers, a.pool, a.cache, a.storage, processor, chunks,
+		redaction.New(config), a.redactions, a.accounting, embedder, a.index)
+	if local, ok := a.storage.(*storage.LocalStorage); ok {
+		a.download = download.New(local)
+	}
+	a.streaming = streaming.New(config, a.u)
+
+	// Создаем глобальный пул соединений для передачи данных между handlers
+	// ключ - guid - уникальный идентификатор загрузки или анализа
+	connections := fileutils.NewConnections()
+	a.analysis = analysis.New(config, a.u, a.layers, a.prompts, a.redactions, a.results, a.llm, a.accounting, chunks,
+		embedder, a.index, a.queue, connections)
+	a.assistant = assistants.New(config, a.u, a.layers, a.threads, llm.NewAssistants(config, guards), a.accounting, connections)
+	a.usage = usage.New(a.u, a.accounting)
+	a.prompt = prompts.New(a.prompts, a.layers, chunks)
+	a.search = search.New(config, a.u, a.index, a.redactions, embedder)
 
 	// Echo instance
 	a.Echo = echo.New()
@@ -88,7 +210,11 @@ func New(config *hocon.Config, port string, db *sqlx.DB) (*App, error) {
 		echopprof.Wrap(a.Echo)
 	}
 
-	a.Echo.Use(middleware.BodyLimit("10M"))
+	// Ограничение размера тела - максимальный размер загрузки среди всех ролей,
+	// ограничения конкретной роли проверяет multipartchecker
+	if limit := uploads.MaxBodySize(); limit > 0 {
+		a.Echo.Use(middleware.BodyLimit(strconv.FormatInt(limit, 10)))
+	}
 
 	a.Echo.Use(middleware.RecoverWithConfig(middleware.RecoverConfig{
 		LogErrorFunc: func(c echo.Context, err error, stack []byte) error {
@@ -109,6 +235,7 @@ func New(config *hocon.Config, port string, db *sqlx.DB) (*App, error) {
 			return strings.Compare(c.Request().RequestURI, "/upload") == 0 ||
 				strings.Contains(c.Request().RequestURI, "/core/metrics") ||
 				strings.Contains(c.Request().RequestURI, "/debug/") ||
+				strings.HasPrefix(c.Request().RequestURI, "/storage/") ||
 				strings.Contains(c.Request().RequestURI, "/assistants/file") ||
 				strings.Contains(c.Request().RequestURI, "/assistant/file")
 		},
@@ -135,13 +262,43 @@ func New(config *hocon.Config, port string, db *sqlx.DB) (*App, error) {
 	})
 	a.Echo.Use(echo.WrapMiddleware(telerMiddleware.Handler))
 
-	// Создаем глобальный пул соединений для передачи данных между handlers
-	// ключ - guid - уникальный идентификатор загрузки
-	var sseConnections = make(map[string]chan structs.Notification)
-
 	a.Echo.GET("/", a.root.RootHandler)
-	a.Echo.POST("/upload", a.files.FileUploadHandler(sseConnections), multipartchecker.MultipartCountChecker(a.jwt))
-	a.Echo.GET("/sse", a.streaming.ProcessStreamingDataHandler(sseConnections))
+	a.Echo.POST("/upload", a.files.FileUploadHandler(connections), multipartchecker.MultipartCountChecker(uploads, a.jwt, a.u))
+	a.Echo.GET("/sse", a.streaming.ProcessStreamingDataHandler(connections), headerchecker.HeaderCheck(a.jwt))
+	a.Echo.POST("/analysis", a.analysis.AnalysisHandler, headerchecker.HeaderCheck(a.jwt))
+	a.Echo.POST("/uploads/:guid/analyze", a.analysis.UploadAnalysisHandler, headerchecker.HeaderCheck(a.jwt))
+	a.Echo.GET("/uploads/:guid/analysis", a.analysis.UploadAnalysisResultsHandler, headerchecker.HeaderCheck(a.jwt))
+	a.Echo.GET("/uploads/:guid/layers/:uuid/link", a.files.LayerLinkHandler, headerchecker.HeaderCheck(a.jwt))
+	a.Echo.POST("/uploads/:guid/pipelines", a.analysis.StartPipelineHandler, headerchecker.HeaderCheck(a.jwt))
+	a.Echo.GET("/uploads/:guid/pipelines", a.analysis.PipelinesHandler, headerchecker.HeaderCheck(a.jwt))
+	a.Echo.GET("/uploads/:guid/pipelines/:id", a.analysis.PipelineHandler, headerchecker.HeaderCheck(a.jwt))
+	a.Echo.POST("/uploads/:guid/pipelines/:id/resume", a.analysis.ResumePipelineHandler, headerchecker.HeaderCheck(a.jwt))
+	a.Echo.POST("/uploads/:guid/extractions", a.analysis.ExtractionHandler, headerchecker.HeaderCheck(a.jwt))
+	a.Echo.GET("/uploads/:guid/extractions", a.analysis.ExtractionsHandler, headerchecker.HeaderCheck(a.jwt))
+	a.Echo.POST("/assistants/files", a.assistant.FilesHandler, headerchecker.HeaderCheck(a.jwt))
+	a.Echo.POST("/assistants/threads", a.assistant.CreateThreadHandler, headerchecker.HeaderCheck(a.jwt))
+	a.Echo.GET("/assistants/threads", a.assistant.ThreadsHandler, headerchecker.HeaderCheck(a.jwt))
+	a.Echo.POST("/assistants/threads/:thread/messages", a.assistant.CreateMessageHandler, headerchecker.HeaderCheck(a.jwt))
+	a.Echo.GET("/assistants/threads/:thread/messages", a.assistant.MessagesHandler, headerchecker.HeaderCheck(a.jwt))
+	a.Echo.POST("/assistants/threads/:thread/runs", a.assistant.CreateRunHandler, headerchecker.HeaderCheck(a.jwt))
+	a.Echo.GET("/assistants/threads/:thread/runs/:run", a.assistant.RunHandler, headerchecker.HeaderCheck(a.jwt))
+	a.Echo.GET("/me/usage", a.usage.MyUsageHandler, headerchecker.HeaderCheck(a.jwt))
+	a.Echo.POST("/search", a.search.SearchHandler, headerchecker.HeaderCheck(a.jwt))
+	a.Echo.POST("/ask", a.analysis.AskHandler, headerchecker.HeaderCheck(a.jwt))
+
+	admin := a.Echo.Group("/admin", headerchecker.HeaderCheck(a.jwt), rolechecker.RoleCheck(a.u, config.GetStringSlice("admin.roles")))
+	admin.GET("/usage", a.usage.UsersUsageHandler)
+	admin.GET("/usage/messages", a.usage.MessagesStatisticsHandler)
+	admin.GET("/prompts", a.prompt.PromptsHandler)
+	admin.POST("/prompts", a.prompt.CreatePromptHandler)
+	admin.GET("/prompts/:id", a.prompt.PromptHandler)
+	admin.PUT("/prompts/:id", a.prompt.UpdatePromptHandler)
+	admin.DELETE("/prompts/:id", a.prompt.DeletePromptHandler)
+	admin.GET("/prompts/:id/versions", a.prompt.VersionsHandler)
+	admin.POST("/prompts/:id/preview", a.prompt.PreviewHandler)
+	if a.download != nil {
+		a.Echo.GET("/storage/*", a.download.DownloadHandler)
+	}
 	logger.Info("Application created!")
 
 	return &a, nil
@@ -151,6 +308,9 @@ func (a *App) Run() error {
 	closer := utils.Tracing(a.Echo)
 	defer closer.Close()
 
+	// конвейеры, прерванные остановкой процесса
+	go a.analysis.ResumeInterrupted()
+
 	// Start server
 	err := a.Echo.Start(":" + a.port)
 	if err != nil {
@@ -158,3 +318,10 @@ func (a *App) Run() error {
 	}
 	return nil
 }
+
+// Close дожидается задач, уже поставленных в пулы обработки файлов и анализов, новые задачи не принимаются
+func (a *App) Close() {
+	a.pool.Close()
+	a.queue.Close()
+	logger.Info("Worker pools closed")
+}
@@ -0,0 +1,15 @@
+alter table public.analysis_results
+    drop column if exists prompt_version;
+
+drop table if exists public.prompt_versions;
+
+drop index if exists prompts_prompt_stage_language_index;
+
+alter table public.prompts
+    drop column if exists name,
+    drop column if exists version,
+    drop column if exists updated_at,
+    drop column if exists deleted_at;
+
+create unique index if not exists prompts_prompt_text_prompt_stage_language_uindex
+    on public.prompts (prompt_text, prompt_stage, language);
@@ -0,0 +1,37 @@
+-- шаблоны промптов бывают длиннее допустимого для btree индекса текста, уникальность текста не проверяем
+drop index if exists prompts_prompt_text_prompt_stage_language_uindex;
+
+alter table public.prompts
+    add column if not exists name       text      default ''    not null,
+    add column if not exists version    integer   default 1     not null,
+    add column if not exists updated_at timestamp default now() not null,
+    -- удаленный промпт не выбирается для анализа, история его версий сохраняется
+    add column if not exists deleted_at timestamp;
+
+create index if not exists prompts_prompt_stage_language_index
+    on public.prompts (prompt_stage, language);
+
+create table if not exists public.prompt_versions
+(
+    id           bigserial
+        constraint prompt_versions_pk primary key,
+    prompt_id    bigint                  not null
+        constraint prompt_versions_prompts_id_fk references public.prompts,
+    version      integer                 not null,
+    name         text      default ''    not null,
+    prompt_text  text                    not null,
+    prompt_stage bigint                  not null,
+    language     text      default ''    not null,
+    user_id      bigint    default 0     not null,
+    created_at   timestamp default now() not null
+);
+
+create unique index if not exists prompt_versions_prompt_id_version_uindex
+    on public.prompt_versions (prompt_id, version);
+
+insert into public.prompt_versions (prompt_id, version, prompt_text, prompt_stage, language)
+select id, version, coalesce(prompt_text, ''), coalesce(prompt_stage, 0), language
+  from public.prompts;
+
+alter table public.analysis_results
+    add column if not exists prompt_version integer default 0 not null;
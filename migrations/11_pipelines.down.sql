@@ -0,0 +1,15 @@
+delete from public.prompt_versions
+ where prompt_id in (select id from public.prompts where name like 'pipeline summary: %');
+
+delete from public.prompts
+ where name like 'pipeline summary: %';
+
+drop index if exists analysis_results_pipeline_id_index;
+
+alter table public.analysis_results
+    drop column if exists pipeline_id,
+    drop column if exists pipeline_step,
+    drop column if exists layer_uuid,
+    drop column if exists file_name;
+
+drop table if exists public.pipelines;
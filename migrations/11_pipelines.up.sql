@@ -0,0 +1,52 @@
+create table if not exists public.pipelines
+(
+    id        bigserial
+        constraint pipelines_pk primary key,
+    guid      text                    not null,
+    uuid      text                    not null,
+    user_id   bigint                  not null,
+    name      text      default ''    not null,
+    stages    jsonb     default '[]'  not null,
+    language  text      default ''    not null,
+    step      integer   default 0     not null,
+    result    text      default ''    not null,
+    error     text      default ''    not null,
+    status    text      default ''    not null,
+    created   timestamp default now() not null,
+    updated   timestamp default now() not null,
+    completed timestamp
+);
+
+create unique index if not exists pipelines_uuid_uindex
+    on public.pipelines (uuid);
+
+create index if not exists pipelines_guid_user_id_index
+    on public.pipelines (guid, user_id);
+
+alter table public.analysis_results
+    add column if not exists pipeline_id   bigint  default 0  not null,
+    add column if not exists pipeline_step integer default 0  not null,
+    add column if not exists layer_uuid    text    default '' not null,
+    add column if not exists file_name     text    default '' not null;
+
+create index if not exists analysis_results_pipeline_id_index
+    on public.analysis_results (pipeline_id) where pipeline_id <> 0;
+
+-- промпты этапов конвейера по умолчанию (pipeline.pipelines.summary), администратор может заменить их в /admin/prompts.
+-- Содержимое файлов или результат предыдущего этапа уходит в модель сообщением пользователя
+insert into public.prompts (name, prompt_text, prompt_stage, language)
+select s.name, s.prompt_text, s.prompt_stage, ''
+  from (values (10, 'pipeline summary: file',
+                'Summarize the document. Keep the parties, dates, amounts and obligations. Answer in the language of the document ({{.Language}}).'),
+               (11, 'pipeline summary: merge',
+                'Merge the summaries of the files of the upload into one summary without repetitions. Answer in the language of the summaries ({{.Language}}).'),
+               (12, 'pipeline summary: conclusions',
+                'List the main conclusions, risks and open questions that follow from the summary. Answer in the language of the summary ({{.Language}}).')
+       ) as s (prompt_stage, name, prompt_text)
+ where not exists(select 1 from public.prompts p where p.prompt_stage = s.prompt_stage and p.deleted_at is null);
+
+insert into public.prompt_versions (prompt_id, version, name, prompt_text, prompt_stage, language)
+select p.id, p.version, p.name, p.prompt_text, p.prompt_stage, p.language
+  from public.prompts p
+ where p.name like 'pipeline summary: %'
+   and not exists(select 1 from public.prompt_versions v where v.prompt_id = p.id);
@@ -0,0 +1,3 @@
+drop table if exists public.layer_chunks;
+
+drop function if exists public.layer_chunks_embedding_vector();
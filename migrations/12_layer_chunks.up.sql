@@ -0,0 +1,70 @@
+create table if not exists public.layer_chunks
+(
+    id          bigserial
+        constraint layer_chunks_pk primary key,
+    layer_id    bigint                  not null
+        constraint layer_chunks_user_layers_id_fk references public.user_layers (id) on delete cascade,
+    guid        text                    not null,
+    user_id     bigint                  not null,
+    chunk_index integer                 not null,
+    section     text      default ''    not null,
+    page        integer   default 0     not null,
+    content     text                    not null,
+    tokens      integer   default 0     not null,
+    model       text                    not null,
+    embedding   real[]                  not null,
+    created     timestamp default now() not null
+);
+
+create index if not exists layer_chunks_user_id_model_index
+    on public.layer_chunks (user_id, model);
+
+create index if not exists layer_chunks_layer_id_index
+    on public.layer_chunks (layer_id);
+
+-- pgvector необязателен: без расширения поиск идет перебором эмбеддингов в приложении.
+-- С расширением (0.5+ для hnsw) эмбеддинги копируются триггером в столбец embedding_vector, для размерностей
+-- распространенных моделей эмбеддингов строятся индексы hnsw по косинусному расстоянию
+do
+$$
+    declare
+        dimensions integer;
+    begin
+        if not exists(select 1 from pg_available_extensions where name = 'vector') then
+            raise notice 'pgvector is not installed, search falls back to brute force';
+            return;
+        end if;
+
+        create extension if not exists vector;
+
+        alter table public.layer_chunks
+            add column if not exists embedding_vector vector;
+
+        create or replace function public.layer_chunks_embedding_vector() returns trigger as
+        $trigger$
+        begin
+            new.embedding_vector = new.embedding::vector;
+            return new;
+        end
+        $trigger$ language plpgsql;
+
+        drop trigger if exists layer_chunks_embedding_vector_trigger on public.layer_chunks;
+        create trigger layer_chunks_embedding_vector_trigger
+            before insert or update of embedding
+            on public.layer_chunks
+            for each row
+        execute function public.layer_chunks_embedding_vector();
+
+        update public.layer_chunks
+           set embedding_vector = embedding::vector
+         where embedding_vector is null;
+
+        -- MiniLM, e5/bge base, GigaChat Embeddings, OpenAI text-embedding-3-small/ada-002
+        foreach dimensions in array array [384, 768, 1024, 1536]
+            loop
+                execute format('create index if not exists layer_chunks_embedding_vector_%s_index
+                                    on public.layer_chunks using hnsw ((embedding_vector::vector(%s)) vector_cosine_ops)
+                                 where vector_dims(embedding_vector) = %s', dimensions, dimensions, dimensions);
+            end loop;
+    end
+$$;
@@ -0,0 +1,33 @@
+create table if not exists public.layer_extractions
+(
+    id                bigserial
+        constraint layer_extractions_pk primary key,
+    guid              text                    not null,
+    uuid              text                    not null,
+    user_id           bigint                  not null,
+    layer_id          bigint                  not null
+        constraint layer_extractions_user_layers_id_fk references public.user_layers (id) on delete cascade,
+    layer_uuid        text      default ''    not null,
+    file_name         text      default ''    not null,
+    schema            jsonb                   not null,
+    instructions      text      default ''    not null,
+    result            jsonb     default 'null'::jsonb not null,
+    attempts          integer   default 0     not null,
+    provider          text      default ''    not null,
+    model             text      default ''    not null,
+    prompt_tokens     integer   default 0     not null,
+    completion_tokens integer   default 0     not null,
+    error             text      default ''    not null,
+    status            text      default ''    not null,
+    created           timestamp default now() not null,
+    completed         timestamp
+);
+
+create index if not exists layer_extractions_uuid_index
+    on public.layer_extractions (uuid);
+
+create index if not exists layer_extractions_guid_user_id_index
+    on public.layer_extractions (guid, user_id);
+
+create index if not exists layer_extractions_layer_id_index
+    on public.layer_extractions (layer_id);
@@ -0,0 +1 @@
+drop table if exists public.user_layers;
\ No newline at end of file
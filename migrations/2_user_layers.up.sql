@@ -0,0 +1,27 @@
+create table if not exists public.user_layers
+(
+    id               bigserial
+        constraint user_layers_pk primary key,
+    guid             text                    not null,
+    uuid             text                    not null,
+    user_id          bigint                  not null,
+    layer_name       text      default ''    not null,
+    source_size      bigint    default 0     not null,
+    source_name      text      default ''    not null,
+    source_type      text      default ''    not null,
+    source_hash      text      default ''    not null,
+    source_file_link text      default ''    not null,
+    source_data      text      default ''    not null,
+    optimized_data   text      default ''    not null,
+    openai_file_id   text      default ''    not null,
+    assistant_id     text      default ''    not null,
+    thread_id        text      default ''    not null,
+    loaded           timestamp default now() not null,
+    status           text      default ''    not null
+);
+
+create unique index if not exists user_layers_uuid_uindex
+    on public.user_layers (uuid);
+
+create index if not exists user_layers_guid_user_id_index
+    on public.user_layers (guid, user_id);
@@ -0,0 +1,2 @@
+alter table public.user_layers
+    drop column if exists ocr_confidence;
@@ -0,0 +1,2 @@
+alter table public.user_layers
+    add column if not exists tokens integer default 0 not null;
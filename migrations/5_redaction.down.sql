@@ -0,0 +1,4 @@
+alter table public.user_layers
+    drop column if exists redactions;
+
+drop table if exists public.redaction_tokens;
@@ -0,0 +1,22 @@
+create table if not exists public.redaction_tokens
+(
+    id          bigserial
+        constraint redaction_tokens_pk primary key,
+    guid        text                    not null,
+    uuid        text                    not null,
+    user_id     bigint                  not null,
+    placeholder text                    not null,
+    kind        text                    not null,
+    -- исходное значение, зашифрованное ключом redaction.key, если encrypted
+    value       text                    not null,
+    encrypted   boolean   default false not null,
+    created     timestamp default now() not null
+);
+
+-- заменители нумеруются в пределах загрузки, guid задает клиент и может повторяться
+-- у разных пользователей и загрузок, поэтому заменитель уникален только в пределах слоя
+create unique index if not exists redaction_tokens_user_id_guid_uuid_placeholder_uindex
+    on public.redaction_tokens (user_id, guid, uuid, placeholder);
+
+alter table public.user_layers
+    add column if not exists redactions jsonb default '{}' not null;
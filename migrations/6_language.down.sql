@@ -0,0 +1,10 @@
+drop index if exists prompts_prompt_text_prompt_stage_language_uindex;
+
+create unique index if not exists prompts_prompt_text_prompt_stage_uindex
+    on public.prompts (prompt_text, prompt_stage);
+
+alter table public.prompts
+    drop column if exists language;
+
+alter table public.user_layers
+    drop column if exists language;
@@ -0,0 +1,10 @@
+alter table public.user_layers
+    add column if not exists language text default '' not null;
+
+alter table public.prompts
+    add column if not exists language text default '' not null;
+
+drop index if exists prompts_prompt_text_prompt_stage_uindex;
+
+create unique index if not exists prompts_prompt_text_prompt_stage_language_uindex
+    on public.prompts (prompt_text, prompt_stage, language);
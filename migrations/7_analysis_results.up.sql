@@ -0,0 +1,26 @@
+create table if not exists public.analysis_results
+(
+    id                bigserial
+        constraint analysis_results_pk primary key,
+    guid              text                    not null,
+    uuid              text                    not null,
+    user_id           bigint                  not null,
+    prompt_id         bigint    default 0     not null,
+    prompt_stage      bigint    default 0     not null,
+    language          text      default ''    not null,
+    provider          text      default ''    not null,
+    model             text      default ''    not null,
+    result            text      default ''    not null,
+    error             text      default ''    not null,
+    prompt_tokens     integer   default 0     not null,
+    completion_tokens integer   default 0     not null,
+    status            text      default ''    not null,
+    created           timestamp default now() not null,
+    completed         timestamp
+);
+
+create unique index if not exists analysis_results_uuid_uindex
+    on public.analysis_results (uuid);
+
+create index if not exists analysis_results_guid_user_id_index
+    on public.analysis_results (guid, user_id);
@@ -0,0 +1,3 @@
+drop table if exists public.messages;
+drop table if exists public.thread_files;
+drop table if exists public.threads;
\ No newline at end of file
@@ -0,0 +1,61 @@
+create table if not exists public.threads
+(
+    id          bigserial
+        constraint threads_pk primary key,
+    user_id     bigint                  not null,
+    thread_name text      default ''    not null,
+    thread_id   text                    not null,
+    object      text      default ''    not null,
+    guid        text      default ''    not null,
+    created_at  timestamp default now() not null,
+    metadata    jsonb     default '{}'  not null
+);
+
+create unique index if not exists threads_thread_id_uindex
+    on public.threads (thread_id);
+
+create index if not exists threads_user_id_index
+    on public.threads (user_id);
+
+create table if not exists public.thread_files
+(
+    id         bigserial
+        constraint thread_files_pk primary key,
+    user_id    bigint                  not null,
+    thread_id  text                    not null,
+    file_id    text                    not null,
+    file_name  text      default ''    not null,
+    created_at timestamp default now() not null,
+    purpose    text      default ''    not null,
+    bytes      bigint    default 0     not null
+);
+
+create index if not exists thread_files_thread_id_index
+    on public.thread_files (thread_id);
+
+create table if not exists public.messages
+(
+    id           text
+        constraint messages_pk primary key,
+    user_id      bigint                  not null,
+    object       text      default ''    not null,
+    created_at   timestamp default now() not null,
+    thread_id    text                    not null,
+    role         text                    not null,
+    prompt       text      default ''    not null,
+    assistant_id text      default ''    not null,
+    run_id       text      default ''    not null,
+    content      jsonb     default '[]'  not null,
+    file_ids     jsonb     default '[]'  not null,
+    -- токены считаются при записи, null - сообщение еще не посчитано, его досчитывает token_updater
+    tokens       integer,
+    hidden       boolean   default false not null,
+    system       boolean   default false not null,
+    metadata     jsonb     default '{}'  not null
+);
+
+create index if not exists messages_thread_id_created_at_index
+    on public.messages (thread_id, created_at);
+
+create index if not exists messages_tokens_null_index
+    on public.messages (created_at) where tokens is null;
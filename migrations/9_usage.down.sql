@@ -0,0 +1 @@
+drop table if exists public.usage_events;
\ No newline at end of file
@@ -0,0 +1,17 @@
+create table if not exists public.usage_events
+(
+    id                bigserial
+        constraint usage_events_pk primary key,
+    user_id           bigint                  not null,
+    kind              text                    not null,
+    guid              text      default ''    not null,
+    files             integer   default 0     not null,
+    bytes             bigint    default 0     not null,
+    requests          integer   default 0     not null,
+    prompt_tokens     integer   default 0     not null,
+    completion_tokens integer   default 0     not null,
+    created           timestamp default now() not null
+);
+
+create index if not exists usage_events_user_id_created_index
+    on public.usage_events (user_id, created);